@@ -0,0 +1,148 @@
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Schemes for the IDs of chunked documents
+const (
+	ChunkIDsFile    = "file"    // <SHA-256 of the file's bytes>_<chunk index>
+	ChunkIDsContent = "content" // Hash of the chunk's normalized inputs; see ContentChunkID
+	ChunkIDsStable  = "stable"  // Hash of the chunk's own text and file path; see StableChunkID
+)
+
+// chunkIDsKey is the collection metadata key recording the chunk ID scheme of a database
+const chunkIDsKey = "chunk_ids"
+
+// contentChunkIDVersion prefixes the hashed input so the scheme can change without collisions
+const contentChunkIDVersion = "chunk-id-v1"
+
+// stableChunkIDVersion prefixes the hashed input of stable chunk IDs likewise
+const stableChunkIDVersion = "stable-chunk-id-v1"
+
+// IsValidChunkIDScheme reports whether scheme is one of the chunk ID schemes
+func IsValidChunkIDScheme(scheme string) bool {
+	return scheme == ChunkIDsFile || scheme == ChunkIDsContent || scheme == ChunkIDsStable
+}
+
+// chunkIDScheme returns the chunk ID scheme of config, resolving the empty default to file IDs
+func chunkIDScheme(config Config) string {
+	if config.ChunkIDs == "" {
+		return ChunkIDsFile
+	}
+	return config.ChunkIDs
+}
+
+// checkChunkIDScheme returns an error when a database recorded in metadata uses a different chunk
+// ID scheme than config; re-indexing under another scheme would store every chunk twice. Databases
+// from before the scheme was recorded use file IDs.
+func checkChunkIDScheme(metadata map[string]string, config Config) error {
+	scheme := metadata[chunkIDsKey]
+	if scheme == "" {
+		scheme = ChunkIDsFile
+	}
+	if scheme != chunkIDScheme(config) {
+		return fmt.Errorf("database uses %s chunk IDs, but -chunk-ids is %s; index into a new database to switch schemes", scheme, chunkIDScheme(config))
+	}
+	return nil
+}
+
+// ContentChunkID returns the content-derived ID of the chunkIndex-th chunk of a file. It depends
+// only on the text given, never on paths, timestamps, or the platform, so every machine indexing
+// the same file computes the same IDs. The ID is the lowercase hexadecimal SHA-256 of the UTF-8
+// bytes
+//
+//	"chunk-id-v1" NUL hex(SHA-256(normalized file)) NUL normalized chunk NUL heading path NUL index
+//
+// followed by "_" and the index, where NUL is a zero byte, the heading path is joined with " > "
+// as in the heading_path metadata, and the index is in decimal without leading zeros. Text is
+// normalized by dropping a leading byte order mark, converting CRLF and CR line endings to LF,
+// removing trailing spaces and tabs from every line, and removing trailing newlines, so that
+// checkouts with different line-ending settings agree. The file's hash keeps identical chunks of
+// different files apart.
+func ContentChunkID(fileContent, chunkContent, headingPath string, chunkIndex int) string {
+	fileDigest := sha256.Sum256([]byte(normalizeChunkIDInput(fileContent)))
+	return contentChunkID(hex.EncodeToString(fileDigest[:]), chunkContent, headingPath, chunkIndex)
+}
+
+// contentChunkID is ContentChunkID for a file whose normalized content hash is already known
+func contentChunkID(fileDigest, chunkContent, headingPath string, chunkIndex int) string {
+	index := strconv.Itoa(chunkIndex)
+	input := strings.Join([]string{
+		contentChunkIDVersion,
+		fileDigest,
+		normalizeChunkIDInput(chunkContent),
+		headingPath,
+		index,
+	}, "\x00")
+	digest := sha256.Sum256([]byte(input))
+	return hex.EncodeToString(digest[:]) + "_" + index
+}
+
+// normalizeChunkIDInput applies the normalization ContentChunkID documents
+func normalizeChunkIDInput(text string) string {
+	text = strings.TrimPrefix(text, "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// StableChunkID returns the ID of a chunk derived from nothing but its own text and the file_path
+// it is stored under, so that editing other parts of the file, even inserting text that shifts
+// every later chunk_index, leaves the chunk's ID unchanged. The ID is the lowercase hexadecimal
+// SHA-256 of the UTF-8 bytes
+//
+//	"stable-chunk-id-v1" NUL file path NUL normalized chunk
+//
+// with the chunk normalized as ContentChunkID describes. The file path keeps identical chunks of
+// different files apart; it is only the same on every machine for databases indexed with -root.
+// The n-th repeat of an identical chunk within one file, counting from 2, gets the suffix "_n".
+func StableChunkID(filePath, chunkContent string) string {
+	input := strings.Join([]string{stableChunkIDVersion, filePath, normalizeChunkIDInput(chunkContent)}, "\x00")
+	digest := sha256.Sum256([]byte(input))
+	return hex.EncodeToString(digest[:])
+}
+
+// assignChunkIDs replaces the file-hash IDs ChunkDocument and SourceCommentChunks give chunks of
+// fileContent with IDs of the configured scheme
+func assignChunkIDs(chunks []DocumentChunk, fileContent string, config Config) {
+	switch chunkIDScheme(config) {
+	case ChunkIDsContent:
+		digest := sha256.Sum256([]byte(normalizeChunkIDInput(fileContent)))
+		fileDigest := hex.EncodeToString(digest[:])
+		for i := range chunks {
+			chunks[i].ID = contentChunkID(fileDigest, chunks[i].Content, strings.Join(chunks[i].HeadingPath, " > "), chunks[i].ChunkIndex)
+		}
+	case ChunkIDsStable:
+		seen := make(map[string]int)
+		for i := range chunks {
+			id := StableChunkID(storedFilePath(chunks[i].FilePath, config), chunks[i].Content)
+			seen[id]++
+			if seen[id] > 1 {
+				id += "_" + strconv.Itoa(seen[id])
+			}
+			chunks[i].ID = id
+		}
+	}
+}
+
+// dropReplacedDocuments removes the stored documents of the file at absPath before its new
+// documents are added under stable chunk IDs. Unchanged chunks are stored again under the IDs
+// they had, and chunks edited away since the last run would otherwise linger beside them. Other
+// schemes keep the documents of earlier versions of a file, which the file hash tells apart.
+func dropReplacedDocuments(collection *chromem.Collection, absPath string, config Config) error {
+	if chunkIDScheme(config) != ChunkIDsStable {
+		return nil
+	}
+	return deleteFileDocuments(collection, absPath, config)
+}
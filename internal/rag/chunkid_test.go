@@ -0,0 +1,160 @@
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestContentChunkIDIsMachineIndependent(t *testing.T) {
+	// Computed independently from the documented input with sha256sum
+	const want = "1e91436297a5f0d8171ecd09f8b9770127e8e32d88e7bc38257505403dfc5ee7_1"
+	if got := ContentChunkID("# Guide\n\nInstall it.\n", "Install it.", "Guide", 1); got != want {
+		t.Fatalf("ContentChunkID = %s, want %s", got, want)
+	}
+
+	// A Windows checkout with CRLF line endings, a byte order mark, and trailing whitespace
+	if got := ContentChunkID("\ufeff# Guide  \r\n\r\nInstall it.\r\n", "Install it.\t\r\n", "Guide", 1); got != want {
+		t.Fatalf("ContentChunkID of the CRLF checkout = %s, want %s", got, want)
+	}
+
+	for name, id := range map[string]string{
+		"file":         ContentChunkID("# Guide\n\nInstall it now.\n", "Install it.", "Guide", 1),
+		"content":      ContentChunkID("# Guide\n\nInstall it.\n", "Install it!", "Guide", 1),
+		"heading path": ContentChunkID("# Guide\n\nInstall it.\n", "Install it.", "Setup", 1),
+		"index":        ContentChunkID("# Guide\n\nInstall it.\n", "Install it.", "Guide", 2),
+	} {
+		if id == want {
+			t.Errorf("changing the %s did not change the ID", name)
+		}
+	}
+}
+
+func TestContentChunkIDsMatchAcrossIndexRuns(t *testing.T) {
+	var content strings.Builder
+	for _, section := range []string{"Install", "Configure", "Deploy"} {
+		content.WriteString("## " + section + "\n\n")
+		content.WriteString(strings.Repeat(section+" the service by following these steps. ", 20))
+		content.WriteString("\n\n")
+	}
+
+	// Two machines index the same file from different directories into their own databases
+	indexedIDs := func() []string {
+		config := newTestConfig(t, 8)
+		config.ChunkIDs = ChunkIDsContent
+		docsDir := writeTestFiles(t, map[string]string{"guide.md": content.String()})
+		captureStdout(t, func() {
+			if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+				t.Fatalf("unexpected indexing error: %v", err)
+			}
+		})
+
+		var ids []string
+		for _, doc := range readTestDatabase(t, config) {
+			ids = append(ids, doc.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	first, second := indexedIDs(), indexedIDs()
+	if len(first) < 2 {
+		t.Fatalf("expected the file to be chunked, got IDs %v", first)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("chunk IDs differ between index runs:\n%v\n%v", first, second)
+	}
+	fileHash := sha256.Sum256([]byte(content.String()))
+	if strings.HasPrefix(first[0], hex.EncodeToString(fileHash[:])) {
+		t.Fatalf("content chunk ID %s is derived from the file hash alone", first[0])
+	}
+}
+
+func TestIndexingRefusesToSwitchChunkIDSchemes(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.ChunkIDs = ChunkIDsContent
+	docsDir := writeTestFiles(t, map[string]string{"note.md": "# Note\n\nShort note.\n"})
+	captureStdout(t, func() {
+		if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+			t.Fatalf("unexpected indexing error: %v", err)
+		}
+	})
+
+	config.ChunkIDs = ChunkIDsFile
+	var err error
+	captureStdout(t, func() {
+		err = IndexDocuments(docsDir, config, 200, 15, 0.25)
+	})
+	if err == nil || !strings.Contains(err.Error(), "database uses content chunk IDs, but -chunk-ids is file") {
+		t.Fatalf("expected the scheme switch to be refused, got %v", err)
+	}
+}
+
+func TestStableChunkIDsSurviveInsertedText(t *testing.T) {
+	section := func(name string) string {
+		return "## " + name + "\n\n" + strings.Repeat(name+" the service by following these steps. ", 20) + "\n\n"
+	}
+	original := section("Install") + section("Configure") + section("Deploy")
+
+	config := newTestConfig(t, 8)
+	config.ChunkIDs = ChunkIDsStable
+	docsDir := writeTestFiles(t, map[string]string{"guide.md": original})
+	config.Root = docsDir
+	index := func() []chromem.Result {
+		captureStdout(t, func() {
+			if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+				t.Fatalf("unexpected indexing error: %v", err)
+			}
+		})
+		return readTestDatabase(t, config)
+	}
+
+	before := index()
+	if len(before) < 2 {
+		t.Fatalf("expected the file to be chunked, got %d documents", len(before))
+	}
+	chunkIndex := func(doc chromem.Result) int {
+		index, _ := strconv.Atoi(doc.Metadata["chunk_index"])
+		return index
+	}
+	last := before[0]
+	for _, doc := range before {
+		if chunkIndex(doc) > chunkIndex(last) {
+			last = doc
+		}
+	}
+	if want := StableChunkID("guide.md", last.Content); last.ID != want {
+		t.Fatalf("expected the last chunk to have ID %s, got %s", want, last.ID)
+	}
+
+	// A section inserted at the top shifts every later chunk_index
+	edited := section("Prepare") + original
+	if err := os.WriteFile(filepath.Join(docsDir, "guide.md"), []byte(edited), 0o644); err != nil {
+		t.Fatalf("failed to edit fixture: %v", err)
+	}
+	after := index()
+	fileHash := sha256.Sum256([]byte(edited))
+	kept := false
+	for _, doc := range after {
+		if doc.Metadata["file_hash"] != hex.EncodeToString(fileHash[:]) {
+			t.Fatalf("expected the chunks of the old version to be replaced, found %s with chunk_index %s", doc.ID, doc.Metadata["chunk_index"])
+		}
+		if doc.ID == last.ID {
+			kept = true
+			if chunkIndex(doc) == chunkIndex(last) {
+				t.Fatalf("expected chunk %s to move past chunk_index %s", doc.ID, last.Metadata["chunk_index"])
+			}
+		}
+	}
+	if !kept {
+		t.Fatalf("expected the unchanged last chunk to keep ID %s", last.ID)
+	}
+}
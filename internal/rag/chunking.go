@@ -2,7 +2,10 @@ package rag
 
 import (
 	"fmt"
+	"os"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 )
@@ -41,16 +44,31 @@ func Min(a, b int) int {
 	return b
 }
 
-// ExtractHeadings finds all markdown headings in the text
-func ExtractHeadings(content string) []HeadingInfo {
+// headingRegex matches an ATX markdown heading line
+var headingRegex = regexp.MustCompile(`^(#{1,6})\s+(.+)$`)
+
+// utf8BOM is the byte order mark some editors, mostly on Windows, write at the start of a file
+const utf8BOM = "\uFEFF"
+
+// ExtractHeadings finds the markdown headings in the text, stopping after maxHeadings of them
+// (0 for no limit)
+func ExtractHeadings(content string, maxHeadings int) []HeadingInfo {
 	var headings []HeadingInfo
-	lines := strings.Split(content, "\n")
 	position := 0
 
-	headingRegex := regexp.MustCompile(`^(#{1,6})\s+(.+)$`)
+	// A byte order mark is not part of the first line; positions still count its bytes
+	if strings.HasPrefix(content, utf8BOM) {
+		content = content[len(utf8BOM):]
+		position = len(utf8BOM)
+	}
 
-	for _, line := range lines {
+	for len(content) > 0 {
+		// A CRLF line keeps its \r, which TrimSpace drops, so the +1 below covers only the \n
+		line, rest, _ := strings.Cut(content, "\n")
 		if matches := headingRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+			if maxHeadings > 0 && len(headings) == maxHeadings {
+				break
+			}
 			level := len(matches[1])
 			text := strings.TrimSpace(matches[2])
 			headings = append(headings, HeadingInfo{
@@ -60,54 +78,122 @@ func ExtractHeadings(content string) []HeadingInfo {
 			})
 		}
 		position += len(line) + 1 // +1 for newline
+		content = rest
 	}
 
 	return headings
 }
 
-// GetHeadingContext returns the hierarchical heading context for a given position
-func GetHeadingContext(headings []HeadingInfo, position int) []string {
-	var context []string
-	var stack []HeadingInfo
+// HeadingIndex answers heading context lookups in logarithmic time, so that chunking a file
+// with thousands of headings does not rescan them for every chunk
+type HeadingIndex struct {
+	headings []HeadingInfo // Headings at or above the maximum level, in position order
+	parents  []int         // Index of each heading's enclosing heading, or -1
+}
 
+// NewHeadingIndex indexes headings, which must be in position order. Only headings at or above
+// maxLevel are included (0 includes all levels).
+func NewHeadingIndex(headings []HeadingInfo, maxLevel int) *HeadingIndex {
+	index := &HeadingIndex{}
+	var stack []int
 	for _, heading := range headings {
-		if heading.Position >= position {
-			break
+		if maxLevel > 0 && heading.Level > maxLevel {
+			continue
 		}
-		for len(stack) > 0 && stack[len(stack)-1].Level >= heading.Level {
+		for len(stack) > 0 && index.headings[stack[len(stack)-1]].Level >= heading.Level {
 			stack = stack[:len(stack)-1]
 		}
-		stack = append(stack, heading)
+		parent := -1
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+		index.headings = append(index.headings, heading)
+		index.parents = append(index.parents, parent)
+		stack = append(stack, len(index.headings)-1)
 	}
-	for _, heading := range stack {
-		context = append(context, heading.Text)
+	return index
+}
+
+// Context returns the hierarchical heading context for a given position: the last heading
+// before it and the headings enclosing that one
+func (index *HeadingIndex) Context(position int) []string {
+	i := sort.Search(len(index.headings), func(i int) bool {
+		return index.headings[i].Position >= position
+	}) - 1
+
+	var context []string
+	for ; i >= 0; i = index.parents[i] {
+		context = append(context, index.headings[i].Text)
 	}
+	slices.Reverse(context)
 	return context
 }
 
-// FindBestSplitPoint finds the best place to split text, preferring sentence boundaries
-func FindBestSplitPoint(text string, maxPos int) int {
+// GetHeadingContext returns the hierarchical heading context for a given position.
+// Only headings at or above maxLevel are included (0 includes all levels).
+func GetHeadingContext(headings []HeadingInfo, position, maxLevel int) []string {
+	return NewHeadingIndex(headings, maxLevel).Context(position)
+}
+
+// FindBestSplitPoint finds the best place to split text at or before maxPos, preferring sentence
+// boundaries. A split that would land inside a markdown table or list moves to where the structure
+// ends, or to where it starts if that is after minPos; a structure too long for either is split
+// between rows or items, never between a table's header and its body.
+func FindBestSplitPoint(text string, minPos, maxPos int) int {
+	split := findTextSplitPoint(text, minPos, maxPos)
+	block, ok := structureBlockAt(text, split)
+	if !ok {
+		return split
+	}
+
+	switch {
+	case block.End <= maxPos:
+		return block.End
+	case block.Start >= minPos && block.Start > 0:
+		return block.Start
+	}
+	if rowStart := lineStart(text, maxPos); rowStart > block.BodyStart && rowStart > minPos {
+		return rowStart
+	}
+	// Keep at least the first row or item of the body with what precedes it
+	return nextLineStart(text, block.BodyStart)
+}
+
+// lookback returns how far before maxPos findTextSplitPoint searches for one kind of boundary:
+// 1/divisor of the room between minPos and maxPos, so that larger chunks search proportionally
+// further back for a clean boundary, but at least floor bytes
+func lookback(minPos, maxPos, divisor, floor int) int {
+	return max((maxPos-minPos)/divisor, floor)
+}
+
+// findTextSplitPoint finds the best place to split prose, trying sentence ends, paragraph breaks,
+// line breaks, and spaces in turn, each within its lookback window before maxPos
+func findTextSplitPoint(text string, minPos, maxPos int) int {
 	if maxPos >= len(text) {
 		return len(text)
 	}
-	for i := maxPos; i > maxPos-200 && i > 0; i-- {
+	for i := maxPos; i > maxPos-lookback(minPos, maxPos, 10, 200) && i > 0; i-- {
 		if text[i] == '.' || text[i] == '!' || text[i] == '?' {
-			if i+1 >= len(text) || (text[i+1] == ' ' || text[i+1] == '\n') {
+			if i+1 >= len(text) || (text[i+1] == ' ' || text[i+1] == '\n' || text[i+1] == '\r') {
 				return i + 1
 			}
 		}
 	}
-	for i := maxPos; i > maxPos-300 && i > 0; i-- {
+	for i := maxPos; i > maxPos-lookback(minPos, maxPos, 8, 300) && i > 0; i-- {
 		if i > 0 && text[i] == '\n' && text[i-1] == '\n' {
 			return i
 		}
+		// A CRLF blank line splits before its \r, keeping each line ending whole
+		if i > 1 && text[i] == '\n' && text[i-1] == '\r' && text[i-2] == '\n' {
+			return i - 1
+		}
 	}
-	for i := maxPos; i > maxPos-100 && i > 0; i-- {
+	for i := maxPos; i > maxPos-lookback(minPos, maxPos, 20, 100) && i > 0; i-- {
 		if text[i] == '\n' {
 			return i + 1
 		}
 	}
-	for i := maxPos; i > maxPos-50 && i > 0; i-- {
+	for i := maxPos; i > maxPos-lookback(minPos, maxPos, 40, 50) && i > 0; i-- {
 		if text[i] == ' ' {
 			return i + 1
 		}
@@ -115,12 +201,224 @@ func FindBestSplitPoint(text string, maxPos int) int {
 	return maxPos
 }
 
-// ChunkDocument splits a document into semantically coherent chunks
-func ChunkDocument(filePath, content, fileHash string, maxTokensPerChunk, chunkOverlapPercent int, approxTokensPerChar float64) []DocumentChunk {
+// Kinds of markdown structure a split should not cut through
+const (
+	structureTable  = "table"
+	structureList   = "list"
+	structureIndent = "indent" // An indented line, which continues a list item
+)
+
+var listItemRegex = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s`)
+
+// structureBlock is a run of table rows or list items spanning [Start, End) of a document
+type structureBlock struct {
+	Kind      string
+	Start     int // Offset of the first line
+	BodyStart int // Offset of the first line a split may precede: past a table's header and delimiter rows
+	End       int // Offset just past the last line
+}
+
+// structureKind classifies a line as a table row, a list item, an indented line, or none of them
+func structureKind(line string) string {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "":
+		return ""
+	case strings.HasPrefix(trimmed, "|"):
+		return structureTable
+	case listItemRegex.MatchString(line):
+		return structureList
+	case strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t"):
+		return structureIndent
+	}
+	return ""
+}
+
+// sameStructure reports whether a line of the given kind belongs to a block of blockKind
+func sameStructure(kind, blockKind string) bool {
+	if blockKind == structureTable {
+		return kind == structureTable
+	}
+	return kind == structureList || kind == structureIndent
+}
+
+// lineStart returns the offset of the start of the line containing pos
+func lineStart(text string, pos int) int {
+	return strings.LastIndexByte(text[:pos], '\n') + 1
+}
+
+// nextLineStart returns the offset of the start of the line after the one containing pos
+func nextLineStart(text string, pos int) int {
+	if i := strings.IndexByte(text[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(text)
+}
+
+// lineAt returns the line starting at offset start, without its \n or \r\n line ending
+func lineAt(text string, start int) string {
+	return strings.TrimSuffix(strings.TrimSuffix(text[start:nextLineStart(text, start)], "\n"), "\r")
+}
+
+// structureBlockAt returns the table or list that a split at pos would cut through: one whose
+// line contains pos, or, when pos is at the start of a line, one spanning that line and the previous
+func structureBlockAt(text string, pos int) (structureBlock, bool) {
+	if pos <= 0 || pos >= len(text) {
+		return structureBlock{}, false
+	}
+
+	anchor := lineStart(text, pos)
+	kind := structureKind(lineAt(text, anchor))
+	if kind == "" {
+		return structureBlock{}, false
+	}
+	if kind == structureIndent {
+		kind = structureList
+	}
+	if anchor == pos {
+		if anchor == 0 || !sameStructure(structureKind(lineAt(text, lineStart(text, anchor-1))), kind) {
+			return structureBlock{}, false
+		}
+	}
+
+	block := structureBlock{Kind: kind, Start: anchor, End: nextLineStart(text, anchor)}
+	for block.Start > 0 {
+		prev := lineStart(text, block.Start-1)
+		if !sameStructure(structureKind(lineAt(text, prev)), kind) {
+			break
+		}
+		block.Start = prev
+	}
+	for block.End < len(text) && sameStructure(structureKind(lineAt(text, block.End)), kind) {
+		block.End = nextLineStart(text, block.End)
+	}
+
+	if kind == structureList {
+		// Indented lines before the first item are not part of the list
+		for block.Start < anchor && structureKind(lineAt(text, block.Start)) == structureIndent {
+			block.Start = nextLineStart(text, block.Start)
+		}
+		if structureKind(lineAt(text, block.Start)) != structureList {
+			return structureBlock{}, false
+		}
+		block.BodyStart = block.Start
+		return block, true
+	}
+
+	// A table's body starts after the header row and the delimiter row beneath it
+	block.BodyStart = nextLineStart(text, block.Start)
+	if block.BodyStart < block.End && isTableDelimiter(lineAt(text, block.BodyStart)) {
+		block.BodyStart = nextLineStart(text, block.BodyStart)
+	}
+	return block, true
+}
+
+// isTableDelimiter reports whether line is a table's header delimiter row, such as |---|:--:|
+func isTableDelimiter(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.Contains(trimmed, "-") && strings.Trim(trimmed, "|-: ") == ""
+}
+
+// FenceRegion is a fenced code block spanning [Start, End) of a document, including its fence lines
+type FenceRegion struct {
+	Start  int    // Offset of the opening fence line
+	End    int    // Offset just past the closing fence line (or the end of the document if unclosed)
+	Opener string // The opening fence line, e.g. "```go"
+	Fence  string // The fence characters that close the block, e.g. "```"
+}
+
+// FindFenceRegions finds the ``` and ~~~ fenced code blocks in a markdown document
+func FindFenceRegions(content string) []FenceRegion {
+	var regions []FenceRegion
+	var open *FenceRegion
+
+	for pos := 0; pos < len(content); {
+		lineEnd := strings.IndexByte(content[pos:], '\n')
+		next := len(content)
+		if lineEnd >= 0 {
+			lineEnd += pos
+			next = lineEnd + 1
+		} else {
+			lineEnd = len(content)
+		}
+		line := strings.TrimRight(content[pos:lineEnd], "\r")
+		fence := fencePrefix(line)
+
+		if open == nil && fence != "" {
+			open = &FenceRegion{Start: pos, Opener: strings.TrimSpace(line), Fence: fence}
+		} else if open != nil && fence != "" && fence[0] == open.Fence[0] && len(fence) >= len(open.Fence) &&
+			strings.TrimSpace(line) == fence {
+			// A closing fence uses the same character, is at least as long, and has no info string
+			open.End = next
+			regions = append(regions, *open)
+			open = nil
+		}
+		pos = next
+	}
+
+	if open != nil {
+		open.End = len(content)
+		regions = append(regions, *open)
+	}
+	return regions
+}
+
+// fencePrefix returns the run of three or more backticks or tildes starting a line (after up to
+// three spaces of indentation), or "" if the line is not a fence
+func fencePrefix(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == trimmed[0] {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return trimmed[:n]
+}
+
+// fenceContaining returns the fenced code block that pos falls strictly inside
+func fenceContaining(regions []FenceRegion, pos int) (FenceRegion, bool) {
+	for _, region := range regions {
+		if region.Start < pos && pos < region.End {
+			return region, true
+		}
+	}
+	return FenceRegion{}, false
+}
+
+// fencedChunkContent returns content[start:end], re-opening a code block the chunk starts inside
+// and closing one it ends inside so every chunk has balanced fences
+func fencedChunkContent(content string, start, end int, regions []FenceRegion) string {
+	chunkContent := content[start:end]
+	if region, ok := fenceContaining(regions, start); ok {
+		chunkContent = region.Opener + "\n" + chunkContent
+	}
+	if region, ok := fenceContaining(regions, end); ok {
+		if !strings.HasSuffix(chunkContent, "\n") {
+			chunkContent += "\n"
+		}
+		chunkContent += region.Fence + "\n"
+	}
+	return chunkContent
+}
+
+// ChunkDocument splits a document into semantically coherent chunks, reporting its progress on
+// standard output
+func ChunkDocument(filePath, content, fileHash string, maxTokensPerChunk, chunkOverlapPercent, maxHeadingLevel, maxHeadings int, counter TokenCounter) []DocumentChunk {
+	return chunkDocument(filePath, content, fileHash, maxTokensPerChunk, chunkOverlapPercent, maxHeadingLevel, maxHeadings, counter, TextProgress(os.Stdout, LogNormal))
+}
+
+// chunkDocument is ChunkDocument reporting its progress to report
+func chunkDocument(filePath, content, fileHash string, maxTokensPerChunk, chunkOverlapPercent, maxHeadingLevel, maxHeadings int, counter TokenCounter, report ProgressFunc) []DocumentChunk {
 	var chunks []DocumentChunk
 
 	// If document is small enough, return as single chunk
-	if EstimateTokenCount(content, approxTokensPerChar) <= maxTokensPerChunk {
+	totalTokens := counter.CountTokens(content)
+	if totalTokens <= maxTokensPerChunk {
 		chunk := DocumentChunk{
 			ID:          fmt.Sprintf("%s_0", fileHash),
 			FilePath:    filePath,
@@ -129,20 +427,26 @@ func ChunkDocument(filePath, content, fileHash string, maxTokensPerChunk, chunkO
 			Content:     content,
 			StartOffset: 0,
 			EndOffset:   len(content),
-			TokenCount:  EstimateTokenCount(content, approxTokensPerChar),
+			TokenCount:  totalTokens,
 			HeadingPath: []string{},
 			CreatedAt:   time.Now(),
 		}
 		return []DocumentChunk{chunk}
 	}
 
-	headings := ExtractHeadings(content)
-	fmt.Printf("  Found %d headings in document\n", len(headings))
+	headings := ExtractHeadings(content, maxHeadings)
+	fences := FindFenceRegions(content)
+	report.infof("  Found %d headings and %d code blocks in document", len(headings), len(fences))
+	if maxHeadings > 0 && len(headings) == maxHeadings {
+		report.warnf(filePath, nil, "  Warning: Heading limit reached, later headings are not used for splitting or context")
+	}
+	headingIndex := NewHeadingIndex(headings, maxHeadingLevel)
 
-	maxChunkChars := int(float64(maxTokensPerChunk) / approxTokensPerChar)
+	// Size chunks by the document's own character-to-token density
+	maxChunkChars := int(float64(maxTokensPerChunk) * float64(len(content)) / float64(totalTokens))
 	overlapChars := int(float64(maxChunkChars) * float64(chunkOverlapPercent) / 100.0)
 
-	fmt.Printf("  Max chunk chars: %d, overlap: %d\n", maxChunkChars, overlapChars)
+	report.infof("  Max chunk chars: %d, overlap: %d", maxChunkChars, overlapChars)
 
 	chunkIndex := 0
 	start := 0
@@ -150,39 +454,68 @@ func ChunkDocument(filePath, content, fileHash string, maxTokensPerChunk, chunkO
 
 	for start < contentLen {
 		if chunkIndex > 1000 {
-			fmt.Printf("  Warning: Too many chunks created, stopping at chunk %d\n", chunkIndex)
+			report.warnf(filePath, nil, "  Warning: Too many chunks created, stopping at chunk %d", chunkIndex)
 			break
 		}
 		idealEnd := start + maxChunkChars
+		// Dense passages such as code or CJK text can exceed the limit at the average density
+		for idealEnd < contentLen && idealEnd-start > maxChunkChars/10 {
+			tokens := counter.CountTokens(content[start:idealEnd])
+			if tokens <= maxTokensPerChunk {
+				break
+			}
+			idealEnd = start + int(float64(idealEnd-start)*float64(maxTokensPerChunk)/float64(tokens)*0.95)
+		}
 		bestEnd := idealEnd
 		bestHeadingLevel := 7
+		splitAt := "size limit"
 
 		// Only consider heading splits if we're at least 50% through the ideal chunk
 		minHeadingSplitPos := start + (maxChunkChars / 2)
 
-		for _, heading := range headings {
-			if heading.Position > minHeadingSplitPos && heading.Position <= idealEnd {
-				if heading.Level < bestHeadingLevel {
-					bestEnd = heading.Position
-					bestHeadingLevel = heading.Level
-				}
+		first := sort.Search(len(headings), func(i int) bool {
+			return headings[i].Position > minHeadingSplitPos
+		})
+		for _, heading := range headings[first:] {
+			if heading.Position > idealEnd {
+				break
+			}
+			if heading.Level < bestHeadingLevel {
+				bestEnd = heading.Position
+				bestHeadingLevel = heading.Level
+				splitAt = fmt.Sprintf("level %d heading", heading.Level)
 			}
 		}
 		if bestEnd == idealEnd {
-			bestEnd = FindBestSplitPoint(content, idealEnd)
+			bestEnd = FindBestSplitPoint(content, start+maxChunkChars/10, idealEnd)
+			splitAt = "text boundary"
 		}
-		if bestEnd > contentLen {
+		if bestEnd >= contentLen {
 			bestEnd = contentLen
+			splitAt = "end of document"
 		}
 		if bestEnd <= start {
 			bestEnd = start + Min(maxChunkChars, contentLen-start)
+			splitAt = "size limit"
 		}
-		chunkContent := content[start:bestEnd]
-		if len(strings.TrimSpace(chunkContent)) == 0 {
+		// Never split in the middle of a multi-byte UTF-8 character
+		if snapped := SnapToRuneStart(content, bestEnd); snapped > start {
+			bestEnd = snapped
+		} else {
+			bestEnd = SnapToRuneEnd(content, bestEnd)
+		}
+		// Never split inside a fenced code block; end before it instead, unless the block starts
+		// so close to the chunk start that it cannot fit in a chunk of its own
+		if fence, ok := fenceContaining(fences, bestEnd); ok && fence.Start > start+maxChunkChars/10 {
+			bestEnd = fence.Start
+			splitAt = "code block start"
+		}
+		if len(strings.TrimSpace(content[start:bestEnd])) == 0 {
 			start = bestEnd
 			continue
 		}
-		headingContext := GetHeadingContext(headings, start)
+		chunkContent := fencedChunkContent(content, start, bestEnd, fences)
+		headingContext := headingIndex.Context(start)
 		chunk := DocumentChunk{
 			ID:          fmt.Sprintf("%s_%d", fileHash, chunkIndex),
 			FilePath:    filePath,
@@ -191,29 +524,35 @@ func ChunkDocument(filePath, content, fileHash string, maxTokensPerChunk, chunkO
 			Content:     chunkContent,
 			StartOffset: start,
 			EndOffset:   bestEnd,
-			TokenCount:  EstimateTokenCount(chunkContent, approxTokensPerChar),
+			TokenCount:  counter.CountTokens(chunkContent),
 			HeadingPath: headingContext,
 			CreatedAt:   time.Now(),
 		}
 		chunks = append(chunks, chunk)
+		report.debugf("  Chunk %d: offsets %d-%d (ideal end %d), %d tokens, split at %s", chunkIndex, start, bestEnd, idealEnd, chunk.TokenCount, splitAt)
 		if bestEnd >= contentLen {
 			break
 		}
 		nextStart := bestEnd - overlapChars
+		if splitAt == "code block start" {
+			// Overlapping back into prose would only end at the same fence again
+			nextStart = bestEnd
+		}
 		// Ensure we make meaningful progress - at least 10% of max chunk size
 		minProgress := maxChunkChars / 10
 		if nextStart <= start+minProgress {
 			nextStart = start + minProgress
 		}
+		nextStart = SnapToRuneEnd(content, nextStart)
 		if nextStart >= contentLen {
 			break
 		}
 		start = nextStart
 		chunkIndex++
 		if chunkIndex%10 == 0 {
-			fmt.Printf("  Created %d chunks so far...\n", chunkIndex)
+			report.infof("  Created %d chunks so far...", chunkIndex)
 		}
 	}
-	fmt.Printf("  Chunking complete: %d chunks created\n", len(chunks))
+	report.infof("  Chunking complete: %d chunks created", len(chunks))
 	return chunks
 }
@@ -0,0 +1,318 @@
+package rag
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestGetHeadingContextIncludesAllLevelsByDefault(t *testing.T) {
+	content := "# Guide\n\n## Install\n\n### Linux\n\nRun the installer.\n"
+	headings := ExtractHeadings(content, 0)
+
+	got := GetHeadingContext(headings, len(content), 0)
+	want := []string{"Guide", "Install", "Linux"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected heading context: got %q, want %q", got, want)
+	}
+}
+
+func TestGetHeadingContextExcludesHeadingsBelowMaxLevel(t *testing.T) {
+	content := "# Guide\n\n## Install\n\n### Linux\n\n#### Debian\n\nRun the installer.\n"
+	headings := ExtractHeadings(content, 0)
+
+	got := GetHeadingContext(headings, len(content), 2)
+	want := []string{"Guide", "Install"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected heading context: got %q, want %q", got, want)
+	}
+}
+
+// headingDenseDocument returns a document of count headings cycling through levels 1-4, each
+// followed by a line of prose, like a generated table of contents
+func headingDenseDocument(count int) string {
+	var content strings.Builder
+	for i := 0; i < count; i++ {
+		level := i%4 + 1
+		fmt.Fprintf(&content, "%s Heading %d\n\nEntry %d of the generated contents.\n\n", strings.Repeat("#", level), i, i)
+	}
+	return content.String()
+}
+
+// scanHeadingContext is the straightforward linear scan the heading index must agree with
+func scanHeadingContext(headings []HeadingInfo, position, maxLevel int) []string {
+	var stack []HeadingInfo
+	for _, heading := range headings {
+		if heading.Position >= position {
+			break
+		}
+		if maxLevel > 0 && heading.Level > maxLevel {
+			continue
+		}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= heading.Level {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, heading)
+	}
+	var context []string
+	for _, heading := range stack {
+		context = append(context, heading.Text)
+	}
+	return context
+}
+
+func TestHeadingIndexMatchesLinearScanOnHeadingDenseFile(t *testing.T) {
+	content := headingDenseDocument(5000)
+	headings := ExtractHeadings(content, 0)
+	if len(headings) != 5000 {
+		t.Fatalf("expected 5000 headings, got %d", len(headings))
+	}
+
+	for _, maxLevel := range []int{0, 2} {
+		index := NewHeadingIndex(headings, maxLevel)
+		for position := 0; position <= len(content); position += 97 {
+			got := index.Context(position)
+			want := scanHeadingContext(headings, position, maxLevel)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("max level %d, position %d: got %q, want %q", maxLevel, position, got, want)
+			}
+		}
+	}
+
+	chunks := ChunkDocument("toc.md", content, "hash", 500, 15, 0, 0, HeuristicTokenCounter{TokensPerChar: 0.25})
+	if len(chunks) < 10 {
+		t.Fatalf("expected the document to be chunked, got %d chunks", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if want := scanHeadingContext(headings, chunk.StartOffset, 0); !reflect.DeepEqual(chunk.HeadingPath, want) {
+			t.Fatalf("chunk %d: got heading path %q, want %q", chunk.ChunkIndex, chunk.HeadingPath, want)
+		}
+	}
+}
+
+func TestExtractHeadingsStopsAtLimit(t *testing.T) {
+	content := headingDenseDocument(50)
+	headings := ExtractHeadings(content, 10)
+	if len(headings) != 10 || headings[9].Text != "Heading 9" {
+		t.Fatalf("expected the first 10 headings, got %d", len(headings))
+	}
+}
+
+func TestExtractHeadingsHandlesBOMAndCRLF(t *testing.T) {
+	content := "\uFEFF# Guide\r\n\r\nIntro\r\n\r\n## Setup\r\n\r\nSteps\r\n"
+	headings := ExtractHeadings(content, 0)
+	if len(headings) != 2 {
+		t.Fatalf("expected both headings, got %+v", headings)
+	}
+	for i, want := range []string{"# Guide", "## Setup"} {
+		h := headings[i]
+		if !strings.HasPrefix(content[h.Position:], want+"\r\n") || h.Text != want[strings.Index(want, " ")+1:] {
+			t.Fatalf("heading %d: got %q at %d, pointing at %q", i, h.Text, h.Position, content[h.Position:])
+		}
+	}
+}
+
+func TestFindBestSplitPointSplitsAtCRLFParagraphBreak(t *testing.T) {
+	text := strings.Repeat("word ", 20) + "\r\n\r\n" + strings.Repeat("more ", 20)
+	split := FindBestSplitPoint(text, 0, len(text)-10)
+	if want := strings.Index(text, "\r\n\r\n") + 2; split != want {
+		t.Fatalf("expected a split before the blank line at %d, got %d (%q | %q)", want, split, text[:split], text[split:])
+	}
+}
+
+func BenchmarkChunkDocumentHeadingDense(b *testing.B) {
+	content := headingDenseDocument(20000)
+	counter := HeuristicTokenCounter{TokensPerChar: 0.25}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ChunkDocument("toc.md", content, "hash", 500, 15, 0, 0, counter)
+	}
+}
+
+// fenceLineCount counts the lines of text that are code fences
+func fenceLineCount(text string) int {
+	count := 0
+	for _, line := range strings.Split(text, "\n") {
+		if fencePrefix(line) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestChunkDocumentDoesNotSplitInsideCodeBlock(t *testing.T) {
+	// Without sentence ends or blank lines, the natural split point is a line break inside the code
+	prose := strings.Repeat("some prose words ", 18)
+	code := "```go\n" + strings.Repeat("fmt.Println(\"hello\")\n", 10) + "```\n"
+	content := prose + "\n" + code + prose
+	fenceStart := strings.Index(content, "```go")
+
+	chunks := ChunkDocument("code.md", content, "hash", 100, 15, 0, 0, HeuristicTokenCounter{TokensPerChar: 0.25})
+	if len(chunks) < 2 {
+		t.Fatalf("expected the document to be chunked, got %d chunks", len(chunks))
+	}
+	if chunks[0].EndOffset != fenceStart {
+		t.Fatalf("expected the first chunk to end at the code fence (%d), got %d", fenceStart, chunks[0].EndOffset)
+	}
+	if !strings.Contains(chunks[1].Content, code) {
+		t.Fatalf("expected the second chunk to hold the whole code block, got:\n%s", chunks[1].Content)
+	}
+	for _, chunk := range chunks {
+		if fenceLineCount(chunk.Content)%2 != 0 {
+			t.Fatalf("chunk %d has unbalanced fences:\n%s", chunk.ChunkIndex, chunk.Content)
+		}
+	}
+}
+
+func TestChunkDocumentReopensOversizedCodeBlock(t *testing.T) {
+	content := "~~~python\n" + strings.Repeat("print('a fairly long line of code')\n", 60) + "~~~\n"
+
+	chunks := ChunkDocument("code.md", content, "hash", 100, 15, 0, 0, HeuristicTokenCounter{TokensPerChar: 0.25})
+	if len(chunks) < 2 {
+		t.Fatalf("expected the oversized code block to be split, got %d chunks", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if fenceLineCount(chunk.Content)%2 != 0 {
+			t.Fatalf("chunk %d has unbalanced fences:\n%s", chunk.ChunkIndex, chunk.Content)
+		}
+		if chunk.ChunkIndex > 0 && !strings.HasPrefix(chunk.Content, "~~~python\n") {
+			t.Fatalf("expected chunk %d to re-open the code block, got:\n%s", chunk.ChunkIndex, chunk.Content)
+		}
+	}
+}
+
+func TestChunkDocumentDoesNotRepeatProseBeforeOversizedCodeBlock(t *testing.T) {
+	var content strings.Builder
+	for i := 0; i < 40; i++ {
+		content.WriteString(fmt.Sprintf("Paragraph %d has a few sentences of prose. It is followed by more prose.\n\n", i))
+	}
+	content.WriteString("```go\n" + strings.Repeat("fmt.Println(\"a line of code in a long block\")\n", 200) + "```\n")
+
+	chunks := ChunkDocument("code.md", content.String(), "hash", 500, 15, 0, 0, HeuristicTokenCounter{TokensPerChar: 0.25})
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].EndOffset <= chunks[i-1].EndOffset {
+			t.Fatalf("chunk %d (%d-%d) lies within chunk %d (%d-%d)", i, chunks[i].StartOffset, chunks[i].EndOffset, i-1, chunks[i-1].StartOffset, chunks[i-1].EndOffset)
+		}
+	}
+}
+
+// longTable builds a markdown table with a header, a delimiter row, and the given number of body rows
+func longTable(rows int) string {
+	var table strings.Builder
+	table.WriteString("| Name | Description |\n|------|-------------|\n")
+	for i := 0; i < rows; i++ {
+		table.WriteString(fmt.Sprintf("| row%03d | The value. Of row %d. |\n", i, i))
+	}
+	return table.String()
+}
+
+func TestFindBestSplitPointSplitsBeforeTable(t *testing.T) {
+	intro := strings.Repeat("Intro prose sentence. ", 20) + "\n\n"
+	text := intro + longTable(40) + "\nClosing paragraph.\n"
+
+	// The ideal end falls mid-table, right after a sentence-like "." inside a row
+	maxPos := len(intro) + 500
+	if got := FindBestSplitPoint(text, 0, maxPos); got != len(intro) {
+		t.Fatalf("expected a split where the table starts (%d), got %d", len(intro), got)
+	}
+}
+
+func TestFindBestSplitPointKeepsTableHeaderWithBody(t *testing.T) {
+	text := longTable(40)
+	header := strings.Index(text, "| row000")
+	firstBodyRowEnd := strings.Index(text, "| row001")
+
+	// The table starts too close to the chunk start to split before it, so the split must fall
+	// between body rows, never between the header and the first body row
+	for _, maxPos := range []int{10, header - 5, header + 3} {
+		if got := FindBestSplitPoint(text, 1, maxPos); got != firstBodyRowEnd {
+			t.Fatalf("maxPos %d: expected a split after the first body row (%d), got %d", maxPos, firstBodyRowEnd, got)
+		}
+	}
+
+	maxPos := firstBodyRowEnd + 200
+	got := FindBestSplitPoint(text, 1, maxPos)
+	if got <= firstBodyRowEnd || got > maxPos || text[got-1] != '\n' {
+		t.Fatalf("expected a split at a row boundary within the limit, got %d", got)
+	}
+}
+
+func TestFindBestSplitPointSplitsAfterListEnds(t *testing.T) {
+	list := "Steps:\n\n1. Install the tool.\n   Continue setup.\n2. Run it. Then check.\n3. Done.\n"
+	text := list + "\n" + strings.Repeat("Trailing prose. ", 30)
+
+	// A sentence end inside the list is nearest to maxPos, but the list ends before it
+	maxPos := strings.Index(text, "Trailing") - 1
+	if got := FindBestSplitPoint(text, 0, maxPos); got != len(list) {
+		t.Fatalf("expected a split after the list (%d), got %d", len(list), got)
+	}
+	// Splitting between items pulls back to the list start
+	between := strings.Index(text, "2. Run")
+	if got := FindBestSplitPoint(text, 0, between+5); got != strings.Index(text, "1. Install") {
+		t.Fatalf("expected a split before the list, got %d", got)
+	}
+}
+
+func TestChunkDocumentKeepsTableHeaderWithBodyRows(t *testing.T) {
+	content := "# Reference\n\n" + longTable(200)
+	chunks := ChunkDocument("/docs/table.md", content, "hash", 500, 0, 0, 0, HeuristicTokenCounter{TokensPerChar: 0.25})
+	if len(chunks) < 2 {
+		t.Fatalf("expected the table to need several chunks, got %d", len(chunks))
+	}
+
+	for _, chunk := range chunks {
+		if strings.Contains(chunk.Content, "| Name |") && !strings.Contains(chunk.Content, "| row000 |") {
+			t.Fatalf("chunk %d has the table header without its first body row:\n%s", chunk.ChunkIndex, chunk.Content)
+		}
+		if chunk.EndOffset < len(content) && content[chunk.EndOffset-1] != '\n' {
+			t.Fatalf("chunk %d ends mid-row at %d", chunk.ChunkIndex, chunk.EndOffset)
+		}
+	}
+}
+
+// longWordParagraphs returns paragraphs of 70-character words without sentence ends, like
+// generated reference text or lists of URLs, so that the nearest space can be more than the
+// minimum lookback before a split
+func longWordParagraphs(paragraphs, words int) string {
+	var text strings.Builder
+	for p := range paragraphs {
+		for w := range words {
+			if w > 0 {
+				text.WriteString(" ")
+			}
+			url := fmt.Sprintf("https://example.com/p%02d/w%03d/", p, w)
+			text.WriteString(url + strings.Repeat("x", 70-len(url)))
+		}
+		text.WriteString("\n\n")
+	}
+	return text.String()
+}
+
+func TestFindBestSplitPointLooksBackProportionally(t *testing.T) {
+	text := longWordParagraphs(1, 200)
+	for _, maxPos := range []int{4000, 4030, 4060, 9000} {
+		split := FindBestSplitPoint(text, maxPos/10, maxPos)
+		if split <= maxPos/10 || split > maxPos || text[split-1] != ' ' {
+			t.Errorf("FindBestSplitPoint(%d) = %d, want a split just after a space", maxPos, split)
+		}
+	}
+}
+
+func TestChunkDocumentSplitsLongParagraphsAtWhitespace(t *testing.T) {
+	content := longWordParagraphs(3, 300)
+	chunks := ChunkDocument("long.md", content, "hash", 1000, 15, 0, 0, HeuristicTokenCounter{TokensPerChar: 0.25})
+	if len(chunks) < 3 {
+		t.Fatalf("expected the document to be chunked, got %d chunks", len(chunks))
+	}
+	for _, chunk := range chunks[:len(chunks)-1] {
+		if end := chunk.EndOffset; end < len(content) && !unicode.IsSpace(rune(content[end-1])) {
+			t.Errorf("chunk %d ends mid-word at %d: %q", chunk.ChunkIndex, end, content[end-20:end+20])
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+const (
+	clusterMaxIterations   = 100 // k-means stops after this many rounds even if chunks still move
+	clusterRepresentatives = 5   // Chunks printed per cluster
+)
+
+// Cluster is a group of chunks whose embeddings are close to one another
+type Cluster struct {
+	Size  int
+	Files int // Distinct files among the chunks
+	// Members ordered by similarity to the cluster's centroid, most representative first
+	Members []ClusterMember
+}
+
+// ClusterMember is a chunk in a cluster
+type ClusterMember struct {
+	ID          string
+	FilePath    string
+	HeadingPath string
+	Similarity  float32 // Cosine similarity to the cluster's centroid
+}
+
+// ComputeClusters groups every stored chunk into k clusters with k-means over their stored
+// embeddings, using cosine similarity. The initial centroids are chosen by farthest-point
+// selection from the chunks in ID order, so the same database always clusters the same way.
+// Clusters are returned largest first.
+func ComputeClusters(config Config, k int) ([]Cluster, error) {
+	collection, err := openCollection(config)
+	if err != nil {
+		return nil, err
+	}
+	results, err := allDocuments(context.Background(), collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+	resolveResultPaths(results, config)
+	if k < 1 || k > len(results) {
+		return nil, fmt.Errorf("cannot make %d clusters of %d chunks", k, len(results))
+	}
+
+	// Sort by ID so that the initial centroids do not depend on the query ranking
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	vectors := make([][]float32, len(results))
+	for i, result := range results {
+		if len(result.Embedding) != len(results[0].Embedding) {
+			return nil, fmt.Errorf("chunk %s has a %d-dimensional embedding, but chunk %s has %d; reindex the database with one model", result.ID, len(result.Embedding), results[0].ID, len(results[0].Embedding))
+		}
+		vectors[i] = result.Embedding
+	}
+
+	assignments, centroids := kMeans(vectors, k)
+
+	clusters := make([]Cluster, k)
+	files := make([]map[string]bool, k)
+	for i, result := range results {
+		c := assignments[i]
+		similarity, _ := CosineSimilarity(vectors[i], centroids[c])
+		clusters[c].Members = append(clusters[c].Members, ClusterMember{
+			ID:          result.ID,
+			FilePath:    result.Metadata["file_path"],
+			HeadingPath: result.Metadata["heading_path"],
+			Similarity:  similarity,
+		})
+		if files[c] == nil {
+			files[c] = make(map[string]bool)
+		}
+		files[c][result.Metadata["file_path"]] = true
+	}
+	for c := range clusters {
+		clusters[c].Size = len(clusters[c].Members)
+		clusters[c].Files = len(files[c])
+		members := clusters[c].Members
+		sort.SliceStable(members, func(i, j int) bool { return members[i].Similarity > members[j].Similarity })
+	}
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].Size > clusters[j].Size })
+	return clusters, nil
+}
+
+// kMeans assigns each of vectors to one of k clusters, returning the cluster of each vector and
+// the centroids. k must be between 1 and len(vectors), and the vectors must have the same length.
+func kMeans(vectors [][]float32, k int) ([]int, [][]float32) {
+	centroids := farthestPointCentroids(vectors, k)
+	assignments := make([]int, len(vectors))
+	for i := range assignments {
+		assignments[i] = -1
+	}
+
+	for iteration := 0; iteration < clusterMaxIterations; iteration++ {
+		moved := false
+		for i, vector := range vectors {
+			best := nearestCentroid(vector, centroids)
+			if best != assignments[i] {
+				assignments[i] = best
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+
+		// Move each centroid to the mean of its vectors; a cluster left empty keeps its centroid
+		sums := make([][]float64, k)
+		counts := make([]int, k)
+		for i, vector := range vectors {
+			c := assignments[i]
+			if sums[c] == nil {
+				sums[c] = make([]float64, len(vector))
+			}
+			for d, value := range vector {
+				sums[c][d] += float64(value)
+			}
+			counts[c]++
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue
+			}
+			centroid := make([]float32, len(sums[c]))
+			for d, sum := range sums[c] {
+				centroid[d] = float32(sum / float64(counts[c]))
+			}
+			centroids[c] = centroid
+		}
+	}
+	return assignments, centroids
+}
+
+// farthestPointCentroids picks k of vectors as initial centroids: the first vector, then
+// repeatedly the vector least similar to its nearest centroid so far
+func farthestPointCentroids(vectors [][]float32, k int) [][]float32 {
+	centroids := [][]float32{vectors[0]}
+	nearest := make([]float32, len(vectors)) // Similarity of each vector to its nearest centroid
+	for i, vector := range vectors {
+		nearest[i], _ = CosineSimilarity(vector, vectors[0])
+	}
+	for len(centroids) < k {
+		farthest := 0
+		for i := range vectors {
+			if nearest[i] < nearest[farthest] {
+				farthest = i
+			}
+		}
+		centroids = append(centroids, vectors[farthest])
+		for i, vector := range vectors {
+			if similarity, _ := CosineSimilarity(vector, vectors[farthest]); similarity > nearest[i] {
+				nearest[i] = similarity
+			}
+		}
+	}
+	return centroids
+}
+
+// nearestCentroid returns the index of the centroid most similar to vector
+func nearestCentroid(vector []float32, centroids [][]float32) int {
+	best, bestSimilarity := 0, float32(-2)
+	for c, centroid := range centroids {
+		if similarity, _ := CosineSimilarity(vector, centroid); similarity > bestSimilarity {
+			best, bestSimilarity = c, similarity
+		}
+	}
+	return best
+}
+
+// ShowClusters prints k clusters of the stored chunks with their sizes and most representative
+// files and headings
+func ShowClusters(k int, config Config) error {
+	fmt.Println("Document Clusters")
+	fmt.Println("=================")
+	fmt.Printf("Database: %s\n\n", config.DBPath)
+
+	clusters, err := ComputeClusters(config, k)
+	if err != nil {
+		return err
+	}
+
+	for i, cluster := range clusters {
+		fmt.Printf("Cluster %d: %d chunks from %d files\n", i+1, cluster.Size, cluster.Files)
+		for j, member := range cluster.Members {
+			if j == clusterRepresentatives {
+				fmt.Printf("  ... and %d more\n", cluster.Size-j)
+				break
+			}
+			if member.HeadingPath != "" {
+				fmt.Printf("  %.4f  %s (%s)\n", member.Similarity, member.FilePath, member.HeadingPath)
+			} else {
+				fmt.Printf("  %.4f  %s\n", member.Similarity, member.FilePath)
+			}
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestComputeClustersGroupsChunksByTopic(t *testing.T) {
+	config := newTestConfig(t, 64)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/oauth.md", "auth1", "oauth token refresh login", 64),
+		testDocument("/docs/sessions.md", "auth2", "login session token expiry", 64),
+		testDocument("/docs/sso.md", "auth3", "oauth login single sign on token", 64),
+		testDocument("/docs/garden.md", "garden1", "tomato garden soil watering", 64),
+		testDocument("/docs/compost.md", "garden2", "compost soil garden worms", 64),
+	})
+
+	clusters, err := ComputeClusters(config, 2)
+	if err != nil {
+		t.Fatalf("unexpected cluster error: %v", err)
+	}
+	if len(clusters) != 2 || clusters[0].Size != 3 || clusters[1].Size != 2 {
+		t.Fatalf("expected clusters of 3 and 2 chunks, got %+v", clusters)
+	}
+	for i, prefix := range []string{"auth", "garden"} {
+		for _, member := range clusters[i].Members {
+			if !strings.HasPrefix(member.ID, prefix) {
+				t.Fatalf("expected cluster %d to hold only %s chunks, got %+v", i+1, prefix, clusters[i].Members)
+			}
+		}
+	}
+
+	if _, err := ComputeClusters(config, 6); err == nil || !strings.Contains(err.Error(), "cannot make 6 clusters of 5 chunks") {
+		t.Fatalf("expected an error for more clusters than chunks, got %v", err)
+	}
+}
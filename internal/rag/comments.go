@@ -0,0 +1,232 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CommentBlock is a run of source comments whose text is indexed as markdown
+type CommentBlock struct {
+	Text        string // Comment text with the comment markers removed
+	StartOffset int    // Byte offset where the comment starts in the source file
+	EndOffset   int    // Byte offset where the comment ends in the source file
+	StartLine   int    // First line of the comment (1-based)
+	EndLine     int    // Last line of the comment (1-based)
+	TextLine    int    // Line holding the first line of Text (1-based)
+}
+
+// commentSyntax describes the comment markers of a family of source languages
+type commentSyntax struct {
+	line       string // Line comment marker
+	block      bool   // Supports /* */ block comments
+	docstrings bool   // Supports """ and ''' docstrings
+}
+
+var (
+	cStyleComments  = commentSyntax{line: "//", block: true}
+	hashComments    = commentSyntax{line: "#"}
+	pythonComments  = commentSyntax{line: "#", docstrings: true}
+	sourceLanguages = map[string]commentSyntax{
+		".go": cStyleComments, ".c": cStyleComments, ".h": cStyleComments, ".cc": cStyleComments,
+		".cpp": cStyleComments, ".hpp": cStyleComments, ".java": cStyleComments, ".js": cStyleComments,
+		".jsx": cStyleComments, ".ts": cStyleComments, ".tsx": cStyleComments, ".rs": cStyleComments,
+		".swift": cStyleComments, ".kt": cStyleComments, ".cs": cStyleComments, ".scala": cStyleComments,
+		".php": cStyleComments,
+		".py":  pythonComments,
+		".sh":  hashComments, ".bash": hashComments, ".rb": hashComments, ".pl": hashComments,
+		".r": hashComments, ".yaml": hashComments, ".yml": hashComments, ".toml": hashComments,
+	}
+)
+
+// isSourceFile reports whether path has one of the configured source extensions, whose comments
+// are indexed instead of the whole file
+func isSourceFile(path string, config Config) bool {
+	return len(config.SourceExtensions) > 0 && hasIndexedExtension(path, config.SourceExtensions)
+}
+
+// ExtractCommentBlocks returns the full-line comment blocks in source content, using the comment
+// syntax implied by ext (C-style for unknown extensions). Consecutive line comments form one
+// block; comments trailing code on the same line are ignored, as are compiler directives such as
+// //go:build and shebang lines.
+func ExtractCommentBlocks(content, ext string) []CommentBlock {
+	syntax, ok := sourceLanguages[strings.ToLower(ext)]
+	if !ok {
+		syntax = cStyleComments
+	}
+
+	var blocks []CommentBlock
+	var lines []string
+	var blockStart, blockEnd, startLine int
+	flush := func(endLine int) {
+		joined := strings.Join(lines, "\n")
+		text := strings.TrimSpace(joined)
+		if text != "" {
+			leading := joined[:strings.Index(joined, text)]
+			blocks = append(blocks, CommentBlock{
+				Text:        text,
+				StartOffset: blockStart,
+				EndOffset:   blockEnd,
+				StartLine:   startLine,
+				EndLine:     endLine,
+				TextLine:    startLine + strings.Count(leading, "\n"),
+			})
+		}
+		lines = nil
+	}
+
+	// closer is the marker ending the open block comment or docstring, if any
+	closer := ""
+	offset := 0
+	for lineNum, line := range strings.SplitAfter(content, "\n") {
+		lineEnd := offset + len(strings.TrimRight(line, "\r\n"))
+		trimmed := strings.TrimSpace(line)
+		lineStart := offset + strings.Index(line, trimmed)
+		offset += len(line)
+
+		if closer != "" {
+			text, closed := strings.CutSuffix(trimmed, closer)
+			if !closed {
+				if i := strings.Index(trimmed, closer); i >= 0 {
+					text, closed = trimmed[:i], true
+				}
+			}
+			if closer == "*/" {
+				text = stripLeadingMarker(text, "*")
+			}
+			lines = append(lines, text)
+			blockEnd = lineEnd
+			if closed {
+				flush(lineNum + 1)
+				closer = ""
+			}
+			continue
+		}
+
+		opener, blockCloser := blockOpener(trimmed, syntax)
+		switch {
+		case opener != "":
+			flush(lineNum)
+			closer = blockCloser
+			blockStart, blockEnd, startLine = lineStart, lineEnd, lineNum+1
+			rest := strings.TrimPrefix(trimmed, opener)
+			if i := strings.Index(rest, closer); i >= 0 {
+				lines = append(lines, rest[:i])
+				flush(lineNum + 1)
+				closer = ""
+			} else {
+				lines = append(lines, rest)
+			}
+		case strings.HasPrefix(trimmed, syntax.line) && !isCommentDirective(trimmed, lineNum):
+			if lines == nil {
+				blockStart, startLine = lineStart, lineNum+1
+			}
+			lines = append(lines, stripLeadingMarker(trimmed, syntax.line))
+			blockEnd = lineEnd
+		default:
+			flush(lineNum)
+		}
+	}
+	flush(strings.Count(content, "\n") + 1)
+
+	return blocks
+}
+
+// blockOpener returns the marker opening a block comment or docstring at the start of trimmed,
+// and the marker that closes it
+func blockOpener(trimmed string, syntax commentSyntax) (string, string) {
+	if syntax.block && strings.HasPrefix(trimmed, "/*") {
+		if strings.HasPrefix(trimmed, "/**") && !strings.HasPrefix(trimmed, "/**/") {
+			return "/**", "*/"
+		}
+		return "/*", "*/"
+	}
+	if syntax.docstrings {
+		for _, quote := range []string{`"""`, `'''`} {
+			for _, prefix := range []string{"", "r", "u"} {
+				if strings.HasPrefix(trimmed, prefix+quote) {
+					return prefix + quote, quote
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// isCommentDirective reports whether a line comment is a tool directive rather than prose
+func isCommentDirective(trimmed string, lineNum int) bool {
+	return (lineNum == 0 && strings.HasPrefix(trimmed, "#!")) ||
+		strings.HasPrefix(trimmed, "//go:") ||
+		strings.HasPrefix(trimmed, "//nolint") ||
+		strings.HasPrefix(trimmed, "# -*-")
+}
+
+// stripLeadingMarker removes marker and the single space that conventionally follows it
+func stripLeadingMarker(line, marker string) string {
+	line = strings.TrimPrefix(strings.TrimSpace(line), marker)
+	return strings.TrimPrefix(line, " ")
+}
+
+// SourceCommentChunks extracts the comment blocks of a source file as chunks. Each chunk's
+// offsets cover the original comment in the source file; blocks too large to embed at once are
+// split, with each piece covering the source lines its text came from.
+func SourceCommentChunks(filePath, content, fileHash string, maxTokensPerChunk, chunkOverlapPercent, maxHeadingLevel, maxHeadings int, counter TokenCounter) []DocumentChunk {
+	return sourceCommentChunks(filePath, content, fileHash, maxTokensPerChunk, chunkOverlapPercent, maxHeadingLevel, maxHeadings, counter, TextProgress(os.Stdout, LogNormal))
+}
+
+// sourceCommentChunks is SourceCommentChunks reporting the progress of splitting blocks to report
+func sourceCommentChunks(filePath, content, fileHash string, maxTokensPerChunk, chunkOverlapPercent, maxHeadingLevel, maxHeadings int, counter TokenCounter, report ProgressFunc) []DocumentChunk {
+	var chunks []DocumentChunk
+	for _, block := range ExtractCommentBlocks(content, filepath.Ext(filePath)) {
+		pieces := []DocumentChunk{{Content: block.Text, TokenCount: counter.CountTokens(block.Text)}}
+		if pieces[0].TokenCount > maxTokensPerChunk {
+			pieces = chunkDocument(filePath, block.Text, fileHash, maxTokensPerChunk, chunkOverlapPercent, maxHeadingLevel, maxHeadings, counter, report)
+		}
+
+		for _, piece := range pieces {
+			start, end := block.StartOffset, block.EndOffset
+			if len(pieces) > 1 {
+				start, end = commentPieceRange(content, block, piece)
+			}
+			chunkIndex := len(chunks)
+			chunks = append(chunks, DocumentChunk{
+				ID:          fmt.Sprintf("%s_%d", fileHash, chunkIndex),
+				FilePath:    filePath,
+				FileHash:    fileHash,
+				ChunkIndex:  chunkIndex,
+				Content:     piece.Content,
+				StartOffset: start,
+				EndOffset:   end,
+				TokenCount:  piece.TokenCount,
+				HeadingPath: piece.HeadingPath,
+				CreatedAt:   time.Now(),
+			})
+		}
+	}
+	return chunks
+}
+
+// commentPieceRange returns the range of the source lines of block that piece, a chunk of
+// block.Text, was stripped from, kept within the block
+func commentPieceRange(content string, block CommentBlock, piece DocumentChunk) (int, int) {
+	first, last := LineRange(block.Text, piece.StartOffset, piece.EndOffset)
+	first += block.TextLine - 1
+	last += block.TextLine - 1
+
+	start := 0
+	for line := 1; line < first; line++ {
+		start += strings.IndexByte(content[start:], '\n') + 1
+	}
+	end := start
+	for line := first; line <= last; line++ {
+		next := strings.IndexByte(content[end:], '\n')
+		if next < 0 {
+			end = len(content)
+			break
+		}
+		end += next + 1
+	}
+	return max(start, block.StartOffset), min(end, block.EndOffset)
+}
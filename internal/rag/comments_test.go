@@ -0,0 +1,102 @@
+package rag
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractCommentBlocksFromGoDocComment(t *testing.T) {
+	source := `//go:build linux
+
+// Package auth handles tokens.
+//
+// # Refreshing
+//
+// Call **Refresh** before the token expires.
+package auth
+
+/*
+ * Legacy notes
+ * - keep for v1
+ */
+func Refresh() {
+	x := 1 // trailing comments are not docs
+}
+`
+
+	blocks := ExtractCommentBlocks(source, ".go")
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 comment blocks, got %d: %+v", len(blocks), blocks)
+	}
+
+	want := CommentBlock{
+		Text:        "Package auth handles tokens.\n\n# Refreshing\n\nCall **Refresh** before the token expires.",
+		StartOffset: 18,
+		EndOffset:   117,
+		StartLine:   3,
+		EndLine:     7,
+		TextLine:    3,
+	}
+	if !reflect.DeepEqual(blocks[0], want) {
+		t.Fatalf("got %+v, want %+v", blocks[0], want)
+	}
+	if source[blocks[0].StartOffset:blocks[0].StartOffset+2] != "//" {
+		t.Fatalf("expected the block to start at the comment marker")
+	}
+
+	if blocks[1].Text != "Legacy notes\n- keep for v1" || blocks[1].StartLine != 10 || blocks[1].EndLine != 13 || blocks[1].TextLine != 11 {
+		t.Fatalf("unexpected block comment: %+v", blocks[1])
+	}
+}
+
+func TestExtractCommentBlocksFromPythonDocstring(t *testing.T) {
+	source := "#!/usr/bin/env python\ndef f():\n    \"\"\"Compute **f**.\n\n    Returns nothing.\n    \"\"\"\n"
+
+	blocks := ExtractCommentBlocks(source, ".py")
+	if len(blocks) != 1 || blocks[0].Text != "Compute **f**.\n\nReturns nothing." {
+		t.Fatalf("unexpected blocks: %+v", blocks)
+	}
+	if blocks[0].StartLine != 3 || blocks[0].EndLine != 6 {
+		t.Fatalf("unexpected lines %d-%d", blocks[0].StartLine, blocks[0].EndLine)
+	}
+}
+
+func TestSearchKeepsEachPieceOfSplitDocComment(t *testing.T) {
+	config := newTestConfig(t, 32)
+	config.SourceExtensions = []string{".go"}
+	config.LogLevel = LogQuiet
+
+	var source strings.Builder
+	source.WriteString("package auth\n\n")
+	for i := 0; i < 30; i++ {
+		source.WriteString(fmt.Sprintf("// Step %d of the token refresh flow checks the token expiry.\n//\n", i))
+	}
+	source.WriteString("func Refresh() {}\n")
+	root := writeTestFiles(t, map[string]string{"auth.go": source.String()})
+	if err := IndexDocuments(root, config, 100, 15, 0.25); err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+	docs := readTestDatabase(t, config)
+	if len(docs) < 3 {
+		t.Fatalf("expected the doc comment to be split, got %d chunks", len(docs))
+	}
+
+	results, err := MCPSearchDocumentsWithResults("token refresh flow", config, len(docs))
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if len(results) != len(docs) {
+		t.Fatalf("expected all %d pieces of the doc comment, got %d", len(docs), len(results))
+	}
+	for _, result := range results {
+		if result.FilePath != filepath.Join(root, "auth.go") {
+			t.Fatalf("unexpected result file %s", result.FilePath)
+		}
+		if !strings.Contains(source.String()[result.StartOffset:result.EndOffset], strings.SplitN(strings.TrimSpace(result.Content), "\n", 2)[0]) {
+			t.Fatalf("chunk %s range %d-%d does not cover its text %q", result.ID, result.StartOffset, result.EndOffset, result.Content)
+		}
+	}
+}
@@ -3,51 +3,151 @@ package rag
 import (
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 )
 
 // Config holds all configuration values
 type Config struct {
-	OllamaURL      string
-	EmbeddingModel string
-	DBPath         string
+	OllamaURL         string
+	EmbeddingModel    string
+	EmbeddingMode     string // Embedding API shape: "ollama" or "openai"
+	OpenAIAPIKey      string // Bearer token for OpenAI-compatible APIs
+	DBPath            string
+	Root              string        // Directory indexed file paths are stored relative to (empty for absolute paths)
+	Prune             bool          // Remove documents whose source files no longer exist
+	PruneAfter        time.Duration // How long a file must stay missing before it is pruned
+	HeadingMaxLevel   int           // Deepest heading level kept in heading context (0 for all levels)
+	NoCache           bool          // Disable the persistent embedding cache
+	CacheMaxEntries   int           // Maximum number of cached embeddings (0 for no limit)
+	CacheMaxAge       time.Duration // Cached embeddings unused for longer are evicted (0 for no limit)
+	AccurateTokens    bool          // Count tokens with the pre-tokenizer estimator instead of the character heuristic
+	WarnChunksPerFile int           // Warn when a file produces more chunks than this (0 to disable)
+	MaxHeadings       int           // Headings per file used for splitting and heading context (0 for no limit)
+	ChunkIDs          string        // Chunk ID scheme: "file", "content", or "stable" (empty for file)
+	Concurrency       int           // Number of concurrent embedding requests
+	EmbedPathWeight   int           // Times the file name is folded into embedded text (0 to disable)
+	EmbedHeadings     bool          // Prepend each chunk's heading path to its embedded text
+	NormalizeText     string        // Normalization of embedded document and query text: "off", "whitespace", or "markdown" (empty for off)
+	Hybrid            bool          // Blend BM25 lexical scores with vector similarity when searching
+	HybridAlpha       float64       // Weight of vector similarity in hybrid search (1 is pure vector)
+	HeadingFilter     string        // Only return chunks whose heading path contains this text
+	TagFilter         string        // Only return chunks whose frontmatter tags include this tag
+	OpenTasksFilter   bool          // Only return chunks containing open task-list items
+	ExtractTasks      bool          // Record the task-list items of each chunk in metadata when indexing
+	DedupFiles        bool          // Index files with identical content once, listing the copies in duplicate_paths
+	JSON              bool          // Emit search, list, and stats output as JSON
+	LinkScheme        string        // URI scheme for editor links in search output (empty for plain paths)
+	Fields            []string      // Metadata keys shown in search and list output (empty for the default layout and DefaultFields)
+	ChunkOrder        string        // Order of chunks within a file in rag_search results: "position" or "similarity"
+	MixedEntries      string        // Entries kept for a file matching as both a whole file and chunks: "chunks" or "file" (empty for chunks)
+	ReRanker          ReRanker      // Post-processes search results before grouping (nil for none)
+	MinSimilarity     float64       // Drop search results scoring below this (0 to keep all)
+	SearchOffset      int           // Skip this many of the best search results, to page through them
+	NoDedup           bool          // Keep chunks in rag_search results that mostly repeat a higher-ranked chunk
+	MaxChunksPerFile  int           // Keep at most this many rag_search results of each file (0 for no limit)
+	PreferRegion      string        // Boost chunks in this region of their document: "beginning", "middle", or "end" (empty for none)
+	RecencyBoost      float64       // Weight from 0 to 1 of the decay applied to older documents' scores (0 for none)
+	RecencyHalfLife   float64       // Age in days at which the recency decay halves a score (0 for DefaultRecencyHalfLifeDays)
+	MCPMinSimilarity  float64       // Default MinSimilarity for rag_search calls that omit min_similarity
+	QueryCacheSize    int           // Query embeddings kept in memory for repeated searches (0 to disable)
+	MinDocsForSearch  int           // Documents the database must hold before rag_search runs queries (0 or 1 for any)
+	MCPTransport      string        // MCP server transport: "stdio" or "http"
+	MCPAddr           string        // Listen address for the HTTP MCP transport
+	Include           []string      // Globs of relative paths to index (empty for all files)
+	Exclude           []string      // Globs of relative paths to skip, taking precedence over Include
+	Extensions        []string      // File extensions to index, lowercase with a leading dot (empty for .md)
+	SourceExtensions  []string      // Source file extensions whose comments are indexed as markdown (empty for none)
+	WalkConcurrency   int           // Directories read concurrently while finding files (0 or 1 for a sequential walk)
+	IncludeHidden     bool          // Index files and directories whose names begin with "."
+	RespectGitignore  bool          // Skip paths ignored by .gitignore files found while indexing
+	IndexTimeout      time.Duration // Abort indexing after this long, saving what was indexed (0 for no limit)
+	FlushInterval     int           // Save the database every this many files while indexing (0 to save only at the end)
+	Since             time.Time     // Only index files modified at or after this time (zero for all files)
+	DryRun            bool          // Report what indexing would do without embedding or writing the database
+	Progress          ProgressFunc  // Receives indexing progress events (nil for text on standard output)
+	LogLevel          string        // Detail of text progress output: "quiet", "normal", or "verbose" (empty for normal)
+	WatchDebounce     time.Duration // Quiet period after file changes before watch mode re-indexes and saves
+	SlowLog           time.Duration // Log embeddings, queries, and database loads slower than this to stderr (0 to disable)
 }
 
-// GetConfig returns configuration based on command line args, environment variables, and defaults
-func GetConfig(ollamaURL, embeddingModel, dbPath *string, defaultOllamaURL, defaultEmbeddingModel, defaultDBPath string) Config {
+// GetConfig returns configuration based on command line args, environment variables, an optional
+// config file, and defaults
+func GetConfig(configPath, ollamaURL, embeddingModel, embeddingMode, dbPath *string, concurrency *int, defaultOllamaURL, defaultEmbeddingModel, defaultEmbeddingMode, defaultDBPath string, defaultConcurrency int) (Config, error) {
 	config := Config{}
 
-	// Ollama URL priority: CLI arg -> env var -> default
+	var fileConfig FileConfig
+	if path := findConfigFile(*configPath); path != "" {
+		var err error
+		fileConfig, err = LoadConfigFile(path)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	// Ollama URL priority: CLI arg -> env var -> config file -> default
 	if *ollamaURL != "" {
 		config.OllamaURL = *ollamaURL
 	} else if envURL := os.Getenv("RAG_OLLAMA_URL"); envURL != "" {
 		config.OllamaURL = envURL
+	} else if fileConfig.OllamaURL != "" {
+		config.OllamaURL = fileConfig.OllamaURL
 	} else {
 		config.OllamaURL = defaultOllamaURL
 	}
 
-	// Embedding Model priority: CLI arg -> env var -> default
+	// Embedding Model priority: CLI arg -> env var -> config file -> default
 	if *embeddingModel != "" {
 		config.EmbeddingModel = *embeddingModel
 	} else if envModel := os.Getenv("RAG_EMBEDDING_MODEL"); envModel != "" {
 		config.EmbeddingModel = envModel
+	} else if fileConfig.EmbeddingModel != "" {
+		config.EmbeddingModel = fileConfig.EmbeddingModel
 	} else {
 		config.EmbeddingModel = defaultEmbeddingModel
 	}
 
-	// Database Path priority: CLI arg -> env var -> default
+	// Embedding Mode priority: CLI arg -> env var -> config file -> default
+	if *embeddingMode != "" {
+		config.EmbeddingMode = *embeddingMode
+	} else if envMode := os.Getenv("RAG_EMBEDDING_MODE"); envMode != "" {
+		config.EmbeddingMode = envMode
+	} else if fileConfig.EmbeddingMode != "" {
+		config.EmbeddingMode = fileConfig.EmbeddingMode
+	} else {
+		config.EmbeddingMode = defaultEmbeddingMode
+	}
+
+	// API key is only read from the environment to keep it out of shell history
+	config.OpenAIAPIKey = os.Getenv("RAG_OPENAI_API_KEY")
+
+	// Database Path priority: CLI arg -> env var -> config file -> default
 	if *dbPath != "" {
 		config.DBPath = *dbPath
 	} else if envDBPath := os.Getenv("RAG_DB_PATH"); envDBPath != "" {
 		config.DBPath = envDBPath
+	} else if fileConfig.DBPath != "" {
+		config.DBPath = fileConfig.DBPath
 	} else {
 		config.DBPath = defaultDBPath
 	}
 
+	// Concurrency priority: CLI arg -> env var -> config file -> default
+	if *concurrency > 0 {
+		config.Concurrency = *concurrency
+	} else if envConcurrency, err := strconv.Atoi(os.Getenv("RAG_CONCURRENCY")); err == nil && envConcurrency > 0 {
+		config.Concurrency = envConcurrency
+	} else if fileConfig.Concurrency > 0 {
+		config.Concurrency = fileConfig.Concurrency
+	} else {
+		config.Concurrency = defaultConcurrency
+	}
+
 	// Convert DB path to absolute path
 	absDBPath, err := filepath.Abs(config.DBPath)
 	if err == nil {
 		config.DBPath = absDBPath
 	}
 
-	return config
+	return config, nil
 }
@@ -0,0 +1,102 @@
+package rag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// getTestConfig calls GetConfig with no CLI values other than the config file path
+func getTestConfig(t *testing.T, configPath string) Config {
+	t.Helper()
+
+	empty := ""
+	zero := 0
+	config, err := GetConfig(&configPath, &empty, &empty, &empty, &empty, &zero,
+		"http://default/api/embeddings", "default-model", EmbeddingModeOllama, "default.db", 4)
+	if err != nil {
+		t.Fatalf("unexpected config error: %v", err)
+	}
+	return config
+}
+
+// clearConfigEnv unsets the environment variables read by GetConfig for the duration of the test
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+
+	for _, name := range []string{"RAG_OLLAMA_URL", "RAG_EMBEDDING_MODEL", "RAG_EMBEDDING_MODE", "RAG_DB_PATH", "RAG_CONCURRENCY", "RAG_CONFIG"} {
+		t.Setenv(name, "")
+	}
+	t.Chdir(t.TempDir())
+}
+
+func TestGetConfigFileSitsBetweenEnvAndDefaults(t *testing.T) {
+	clearConfigEnv(t)
+	root := writeTestFiles(t, map[string]string{
+		"rag.yaml": "# team settings\nembedding-model: file-model\nembedding-mode: \"openai\"\ndb: shared.db\nconcurrency: 8\n",
+	})
+	t.Setenv("RAG_EMBEDDING_MODEL", "env-model")
+
+	config := getTestConfig(t, filepath.Join(root, "rag.yaml"))
+
+	if config.EmbeddingModel != "env-model" {
+		t.Fatalf("expected the env var to beat the config file, got %s", config.EmbeddingModel)
+	}
+	if config.EmbeddingMode != EmbeddingModeOpenAI {
+		t.Fatalf("expected the config file to beat the default, got %s", config.EmbeddingMode)
+	}
+	if config.DBPath != filepath.Join(root, "shared.db") {
+		t.Fatalf("expected the database path relative to the config file, got %s", config.DBPath)
+	}
+	if config.Concurrency != 8 {
+		t.Fatalf("expected concurrency from the config file, got %d", config.Concurrency)
+	}
+	if config.OllamaURL != "http://default/api/embeddings" {
+		t.Fatalf("expected the default for keys missing from the config file, got %s", config.OllamaURL)
+	}
+}
+
+func TestGetConfigCLIBeatsConfigFile(t *testing.T) {
+	clearConfigEnv(t)
+	root := writeTestFiles(t, map[string]string{
+		"rag.json": `{"ollama-url": "http://file/api/embeddings", "concurrency": 2}`,
+	})
+
+	configPath := filepath.Join(root, "rag.json")
+	cliURL := "http://cli/api/embeddings"
+	empty := ""
+	zero := 0
+	config, err := GetConfig(&configPath, &cliURL, &empty, &empty, &empty, &zero,
+		"http://default/api/embeddings", "default-model", EmbeddingModeOllama, "default.db", 4)
+	if err != nil {
+		t.Fatalf("unexpected config error: %v", err)
+	}
+
+	if config.OllamaURL != cliURL {
+		t.Fatalf("expected the CLI value to beat the config file, got %s", config.OllamaURL)
+	}
+	if config.Concurrency != 2 {
+		t.Fatalf("expected concurrency from the JSON config file, got %d", config.Concurrency)
+	}
+}
+
+func TestGetConfigDiscoversDefaultConfigFile(t *testing.T) {
+	clearConfigEnv(t)
+	if err := os.WriteFile(".mcp-rag.yaml", []byte("embedding-model: discovered-model\n"), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	config := getTestConfig(t, "")
+
+	if config.EmbeddingModel != "discovered-model" {
+		t.Fatalf("expected the config file in the working directory to be used, got %s", config.EmbeddingModel)
+	}
+}
+
+func TestLoadConfigFileRejectsUnknownKeys(t *testing.T) {
+	root := writeTestFiles(t, map[string]string{"rag.yaml": "embedding_model: typo\n"})
+
+	if _, err := LoadConfigFile(filepath.Join(root, "rag.yaml")); err == nil {
+		t.Fatalf("expected an error for an unknown key")
+	}
+}
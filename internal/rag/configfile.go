@@ -0,0 +1,140 @@
+package rag
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultConfigFileNames are looked up in the working directory when no config file is given
+var DefaultConfigFileNames = []string{".mcp-rag.yaml", ".mcp-rag.yml", ".mcp-rag.json"}
+
+// FileConfig holds the settings read from a config file; zero values fall through to defaults
+type FileConfig struct {
+	OllamaURL      string
+	EmbeddingModel string
+	EmbeddingMode  string
+	DBPath         string
+	Concurrency    int
+}
+
+// LoadConfigFile reads a config file using the same keys as the command line flags
+// (ollama-url, embedding-model, embedding-mode, db, concurrency). Files ending in .json
+// are parsed as JSON; anything else is parsed as flat YAML "key: value" lines.
+func LoadConfigFile(path string) (FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FileConfig{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]string
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		values, err = parseJSONConfig(data)
+	} else {
+		values, err = parseYAMLConfig(data)
+	}
+	if err != nil {
+		return FileConfig{}, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var fileConfig FileConfig
+	for key, value := range values {
+		switch key {
+		case "ollama-url":
+			fileConfig.OllamaURL = value
+		case "embedding-model":
+			fileConfig.EmbeddingModel = value
+		case "embedding-mode":
+			fileConfig.EmbeddingMode = value
+		case "db":
+			// Relative database paths are resolved against the config file's directory
+			if value != "" && !filepath.IsAbs(value) {
+				value = filepath.Join(filepath.Dir(path), value)
+			}
+			fileConfig.DBPath = value
+		case "concurrency":
+			concurrency, err := strconv.Atoi(value)
+			if err != nil || concurrency < 1 {
+				return FileConfig{}, fmt.Errorf("invalid concurrency in config file %s: %q", path, value)
+			}
+			fileConfig.Concurrency = concurrency
+		default:
+			return FileConfig{}, fmt.Errorf("unknown key in config file %s: %s", path, key)
+		}
+	}
+
+	return fileConfig, nil
+}
+
+// findConfigFile returns the explicitly requested config file, falling back to RAG_CONFIG and then
+// the default file names in the working directory. An empty result means no config file is used.
+func findConfigFile(configPath string) string {
+	if configPath != "" {
+		return configPath
+	}
+	if envPath := os.Getenv("RAG_CONFIG"); envPath != "" {
+		return envPath
+	}
+	for _, name := range DefaultConfigFileNames {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// parseJSONConfig parses a flat JSON object, converting scalar values to strings
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string, float64, bool:
+			values[key] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("value for %s must be a string or number", key)
+		}
+	}
+	return values, nil
+}
+
+// parseYAMLConfig parses the flat subset of YAML used by config files: "key: value" lines with
+// optional quoting, blank lines, and # comments
+func parseYAMLConfig(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", lineNumber)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if unquoted := unquoteYAML(value); unquoted != value {
+			value = unquoted
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,44 @@
+package rag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// ErrDatabaseNotFound is returned when config.DBPath does not exist yet
+var ErrDatabaseNotFound = errors.New("database not found. Please run indexing first with -index")
+
+// openDatabase loads the database at config.DBPath. The database file is returned still open so
+// that its collection metadata can be read, such as the embedding fingerprint; the caller closes it.
+func openDatabase(config Config) (*chromem.DB, *os.File, error) {
+	if _, err := os.Stat(config.DBPath); os.IsNotExist(err) {
+		return nil, nil, ErrDatabaseNotFound
+	}
+
+	db := chromem.NewDB()
+	file, err := os.Open(config.DBPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := importDatabase(db, file, config.DBPath, config); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to load database: %w", err)
+	}
+	return db, file, nil
+}
+
+// loadCollection loads the documents collection of the database at config.DBPath, which is nil
+// when the database has none, and a function that releases the database once the caller is
+// done with the collection. Queries embed with CreateEmbeddingFunc whatever model indexed the
+// database; loadSearchCollection refuses a database indexed with another model.
+func loadCollection(config Config) (*chromem.Collection, func(), error) {
+	db, file, err := openDatabase(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	release := func() { file.Close() }
+	return db.GetCollection("documents", CreateEmbeddingFunc(config)), release, nil
+}
@@ -0,0 +1,39 @@
+package rag
+
+// dedupOverlapThreshold is the fraction of the shorter of two chunks from the same file that
+// must be covered by the other for the lower-scoring one to be dropped as a near duplicate
+const dedupOverlapThreshold = 0.5
+
+// dedupOverlappingChunks drops chunks that mostly repeat a higher-ranked chunk of the same file,
+// as adjacent chunks sharing their overlap region can. Results must be in rank order; whole-file
+// results cover their file and so absorb any chunk of it ranked below them.
+func dedupOverlappingChunks(results []SearchResult) []SearchResult {
+	kept := make([]SearchResult, 0, len(results))
+	for _, result := range results {
+		duplicate := false
+		for _, other := range kept {
+			if other.FilePath == result.FilePath && rangesOverlap(other, result) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+// rangesOverlap reports whether two results of the same file overlap by more than
+// dedupOverlapThreshold of the shorter one
+func rangesOverlap(a, b SearchResult) bool {
+	if !a.IsChunk || !b.IsChunk {
+		return true
+	}
+	overlap := min(a.EndOffset, b.EndOffset) - max(a.StartOffset, b.StartOffset)
+	shorter := min(a.EndOffset-a.StartOffset, b.EndOffset-b.StartOffset)
+	if overlap <= 0 || shorter <= 0 {
+		return false
+	}
+	return float64(overlap) > dedupOverlapThreshold*float64(shorter)
+}
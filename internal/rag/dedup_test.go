@@ -0,0 +1,76 @@
+package rag
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// testChunk returns a chunk document of filePath covering [start, end)
+func testChunk(filePath, id, content string, start, end, dim int) chromem.Document {
+	doc := testDocument(filePath, id, content, dim)
+	doc.Metadata["is_chunk"] = "true"
+	doc.Metadata["start_offset"] = strconv.Itoa(start)
+	doc.Metadata["end_offset"] = strconv.Itoa(end)
+	return doc
+}
+
+func TestMCPSearchDocumentsDropsOverlappingChunks(t *testing.T) {
+	config := newTestConfig(t, 32)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testChunk("/docs/auth.md", "auth_0", "oauth provider setup steps", 0, 1000, 32),
+		testChunk("/docs/auth.md", "auth_1", "oauth provider setup notes", 150, 1100, 32),
+		testChunk("/docs/auth.md", "auth_2", "oauth provider token refresh", 2000, 3000, 32),
+		testDocument("/docs/other.md", "other", "gardening in spring", 32),
+	})
+
+	ids := func(results []SearchResult) []string {
+		var ids []string
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return ids
+	}
+
+	results, err := MCPSearchDocumentsWithResults("oauth provider setup", config, 2)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if got := ids(results); len(got) != 2 || got[0] != "auth_0" || got[1] != "auth_2" {
+		t.Fatalf("expected the overlapping chunk to be dropped and its slot refilled, got %v", got)
+	}
+
+	config.NoDedup = true
+	results, err = MCPSearchDocumentsWithResults("oauth provider setup", config, 2)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if got := ids(results); len(got) != 2 || got[1] != "auth_1" {
+		t.Fatalf("expected -no-dedup to keep the overlapping chunk, got %v", got)
+	}
+}
+
+func TestDedupOverlappingChunks(t *testing.T) {
+	chunk := func(id, file string, start, end int) SearchResult {
+		return SearchResult{ID: id, FilePath: file, IsChunk: true, StartOffset: start, EndOffset: end}
+	}
+	results := []SearchResult{
+		chunk("a", "/docs/a.md", 0, 1000),
+		chunk("b", "/docs/a.md", 850, 1850), // 15% overlap, kept
+		chunk("c", "/docs/b.md", 100, 900),  // Another file, kept
+		chunk("d", "/docs/a.md", 900, 1600), // Mostly inside b, dropped
+		{ID: "e", FilePath: "/docs/b.md"},   // Whole file below a chunk of it, dropped
+		{ID: "f", FilePath: "/docs/c.md"},   // Whole file, kept
+		chunk("g", "/docs/c.md", 0, 50),     // Inside a whole file above it, dropped
+	}
+
+	var got []string
+	for _, result := range dedupOverlappingChunks(results) {
+		got = append(got, result.ID)
+	}
+	if want := "a,b,c,f"; strings.Join(got, ",") != want {
+		t.Fatalf("unexpected results after dedup: got %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package rag
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// DeleteFile removes every chunk stored for filePath from the database, and the path from the
+// duplicate_paths of any file it is a copy of, and saves it, returning the number of chunks
+// removed; a copy indexed only through duplicate_paths has none. The file itself is left alone,
+// so it comes back the next time its folder is indexed unless it is also deleted or excluded.
+func DeleteFile(filePath string, config Config) (int, error) {
+	// A relative path is taken from the root when one is set and otherwise the working directory
+	absFilePath, err := filepath.Abs(resolveFilePath(filePath, config))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get absolute path for %s: %w", filePath, err)
+	}
+
+	// Deleting while a reindex saves the same database would lose one of the two changes
+	reindexMu.Lock()
+	defer reindexMu.Unlock()
+
+	db, file, err := openDatabase(config)
+	if err != nil {
+		return 0, err
+	}
+	file.Close()
+
+	// Create embedding function for Ollama (needed for GetCollection)
+	collection := db.GetCollection("documents", CreateEmbeddingFunc(config))
+	if collection == nil {
+		return 0, fmt.Errorf("file not found in the index: %s", absFilePath)
+	}
+
+	before := collection.Count()
+	if err := deleteFileDocuments(collection, absFilePath, config); err != nil {
+		return 0, fmt.Errorf("failed to delete chunks of %s: %w", absFilePath, err)
+	}
+	removed := before - collection.Count()
+	stored := storedFilePath(absFilePath, config)
+	unlisted, err := unlistDuplicatePaths(collection, func(path string) bool {
+		return path == stored || path == absFilePath
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove %s from duplicate_paths: %w", absFilePath, err)
+	}
+	if removed == 0 && len(unlisted) == 0 {
+		return 0, fmt.Errorf("file not found in the index: %s", absFilePath)
+	}
+
+	if err := saveDatabaseAtomic(db, config.DBPath); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// DeleteDocuments removes a file from the index as -delete does, reporting how many chunks
+// were removed
+func DeleteDocuments(filePath string, config Config) error {
+	report := progressReporter(config)
+	report.infof("Deleting from index: %s", filePath)
+	report.infof("Using database: %s", config.DBPath)
+
+	removed, err := DeleteFile(filePath, config)
+	if err != nil {
+		return err
+	}
+	report.infof("✓ Deleted %d chunks of %s", removed, filePath)
+	return nil
+}
@@ -0,0 +1,55 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestDeleteFileRemovesAllChunksOfFile(t *testing.T) {
+	config := newTestConfig(t, 8)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		statsTestChunk("/docs/secret.md", "aaaa1111", 300, 0),
+		statsTestChunk("/docs/secret.md", "aaaa1111", 300, 1),
+		statsTestChunk("/docs/secret.md", "aaaa1111", 300, 2),
+		statsTestChunk("/docs/public.md", "bbbb2222", 100, 0),
+	})
+
+	removed, err := DeleteFile("/docs/secret.md", config)
+	if err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if removed != 3 {
+		t.Fatalf("expected 3 chunks to be removed, got %d", removed)
+	}
+
+	docs := readTestDatabase(t, config)
+	if len(docs) != 1 || docs[0].Metadata["file_path"] != "/docs/public.md" {
+		t.Fatalf("expected only the other file to remain saved, got %v", docs)
+	}
+
+	if _, err := DeleteFile("/docs/secret.md", config); err == nil || !strings.Contains(err.Error(), "file not found in the index: /docs/secret.md") {
+		t.Fatalf("expected deleting an unindexed file to fail, got %v", err)
+	}
+}
+
+func TestDeleteFileUnlistsDuplicateCopy(t *testing.T) {
+	config := newTestConfig(t, 8)
+	primary := statsTestChunk("/docs/public.md", "aaaa1111", 100, 0)
+	primary.Metadata[duplicatePathsKey] = "/docs/secret.md\n/docs/other.md"
+	writeTestDatabase(t, config.DBPath, []chromem.Document{primary})
+
+	removed, err := DeleteFile("/docs/secret.md", config)
+	if err != nil {
+		t.Fatalf("unexpected error deleting a copy: %v", err)
+	}
+	if removed != 0 {
+		t.Fatalf("expected no chunks removed for a copy, got %d", removed)
+	}
+
+	docs := readTestDatabase(t, config)
+	if len(docs) != 1 || docs[0].Metadata[duplicatePathsKey] != "/docs/other.md" {
+		t.Fatalf("expected the copy to be unlisted from duplicate_paths, got %v", docs)
+	}
+}
@@ -0,0 +1,101 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// writeTiedTestDatabase writes a database in which every document has the same embedding, so
+// every query scores them all equally and only tie-breaking decides their order
+func writeTiedTestDatabase(t *testing.T, config Config, files int) {
+	t.Helper()
+
+	var docs []chromem.Document
+	for i := 0; i < files; i++ {
+		doc := testDocument(fmt.Sprintf("/docs/file%02d.md", files-1-i), fmt.Sprintf("hash%02d", i), "identical content", 8)
+		doc.Metadata["file_size"] = "17"
+		doc.Metadata["token_count"] = "4"
+		docs = append(docs, doc)
+	}
+	for i := 0; i < 3; i++ {
+		doc := testDocument("/docs/chunked.md", fmt.Sprintf("chunkhash_%d", i), "identical content", 8)
+		doc.Metadata["is_chunk"] = "true"
+		doc.Metadata["chunk_index"] = fmt.Sprint(i)
+		doc.Metadata["start_offset"] = fmt.Sprint(i * 100)
+		doc.Metadata["end_offset"] = fmt.Sprint(i*100 + 100)
+		docs = append(docs, doc)
+	}
+	writeTestDatabase(t, config.DBPath, docs)
+}
+
+// runPipeline runs every output path against the database from scratch and returns the
+// combined output
+func runPipeline(t *testing.T, config Config) string {
+	t.Helper()
+
+	var output strings.Builder
+	output.WriteString(captureStdout(t, func() {
+		for _, hybrid := range []bool{false, true} {
+			searchConfig := config
+			searchConfig.Hybrid = hybrid
+			searchConfig.HybridAlpha = 0.5
+			if err := SearchDocuments("identical content", searchConfig); err != nil {
+				t.Fatalf("search failed: %v", err)
+			}
+		}
+
+		jsonConfig := config
+		jsonConfig.JSON = true
+		if err := ListDocuments(jsonConfig); err != nil {
+			t.Fatalf("list failed: %v", err)
+		}
+		if err := ShowStats(jsonConfig); err != nil {
+			t.Fatalf("stats failed: %v", err)
+		}
+	}))
+
+	for _, order := range []string{ChunkOrderPosition, ChunkOrderSimilarity} {
+		results, err := MCPSearchDocumentsWithResults("identical content", config, 10)
+		if err != nil {
+			t.Fatalf("MCP search failed: %v", err)
+		}
+		output.WriteString(formatSearchResponse("identical content", groupResultsByFile(results, order, MixedEntriesChunks), 0, false, false))
+	}
+	return output.String()
+}
+
+func TestPipelineOutputIsReproducibleAcrossRuns(t *testing.T) {
+	config := newTestConfig(t, 8)
+	writeTiedTestDatabase(t, config, 12)
+
+	// Each run reloads the database, so map iteration and chromem's handling of equal scores
+	// differ between runs; the output must not
+	first := runPipeline(t, config)
+	for run := 1; run < 10; run++ {
+		if got := runPipeline(t, config); got != first {
+			t.Fatalf("run %d output differs from the first run:\n--- first\n%s\n--- run %d\n%s", run, first, run, got)
+		}
+	}
+}
+
+func TestQueryCollectionBreaksTiesAcrossTheCutoff(t *testing.T) {
+	config := newTestConfig(t, 8)
+	writeTiedTestDatabase(t, config, 12)
+
+	results, err := MCPSearchDocumentsWithResults("identical content", config, 5)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	var got []string
+	for _, result := range results {
+		got = append(got, fmt.Sprintf("%s#%d", result.FilePath, result.ChunkIndex))
+	}
+	want := []string{"/docs/chunked.md#0", "/docs/chunked.md#1", "/docs/chunked.md#2", "/docs/file00.md#0", "/docs/file01.md#0"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, want the first documents in path order %v", got, want)
+	}
+}
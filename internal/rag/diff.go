@@ -0,0 +1,128 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells bounds the line comparison table of UnifiedDiff; larger inputs are diffed as a
+// wholesale replacement
+const maxDiffCells = 4_000_000
+
+// diffOp is one line of an edit script: kept (' '), removed ('-'), or added ('+')
+type diffOp struct {
+	kind    byte
+	text    string
+	oldLine int // 1-based line in the old text where the op applies
+	newLine int // 1-based line in the new text where the op applies
+}
+
+// UnifiedDiff returns a line-based unified diff turning oldText into newText, with contextLines
+// unchanged lines around each change, or "" when the texts are equal
+func UnifiedDiff(oldName, newName, oldText, newText string, contextLines int) string {
+	if oldText == newText {
+		return ""
+	}
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	// Group changes into hunks, merging those whose context would touch
+	var hunks [][2]int
+	for k, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		lo, hi := max(k-contextLines, 0), min(k+contextLines+1, len(ops))
+		if len(hunks) > 0 && lo <= hunks[len(hunks)-1][1] {
+			hunks[len(hunks)-1][1] = hi
+		} else {
+			hunks = append(hunks, [2]int{lo, hi})
+		}
+	}
+
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range hunks {
+		lines := ops[hunk[0]:hunk[1]]
+		oldCount, newCount := 0, 0
+		for _, op := range lines {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&diff, "@@ -%s +%s @@\n", hunkRange(lines[0].oldLine, oldCount), hunkRange(lines[0].newLine, newCount))
+		for _, op := range lines {
+			diff.WriteByte(op.kind)
+			diff.WriteString(op.text)
+			diff.WriteByte('\n')
+		}
+	}
+	return diff.String()
+}
+
+// hunkRange formats the start and length of one side of a hunk; an empty side is given as the
+// line before it, as diff tools do
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits text into lines without their newlines
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes a minimal edit script between two sequences of lines from their longest
+// common subsequence, listing removals before additions within a change
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	var ops []diffOp
+	if (n+1)*(m+1) > maxDiffCells {
+		for i, line := range a {
+			ops = append(ops, diffOp{kind: '-', text: line, oldLine: i + 1, newLine: 1})
+		}
+		for j, line := range b {
+			ops = append(ops, diffOp{kind: '+', text: line, oldLine: n + 1, newLine: j + 1})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], oldLine: i + 1, newLine: j + 1})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', text: a[i], oldLine: i + 1, newLine: j + 1})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: b[j], oldLine: i + 1, newLine: j + 1})
+			j++
+		}
+	}
+	return ops
+}
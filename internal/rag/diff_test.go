@@ -0,0 +1,20 @@
+package rag
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	oldText := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	newText := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+
+	got := UnifiedDiff("indexed", "current", oldText, newText, 1)
+	want := "--- indexed\n+++ current\n" +
+		"@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n" +
+		"@@ -10,1 +10,2 @@\n ten\n+eleven\n"
+	if got != want {
+		t.Fatalf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+
+	if diff := UnifiedDiff("a", "b", oldText, oldText, 3); diff != "" {
+		t.Fatalf("expected no diff for equal texts, got:\n%s", diff)
+	}
+}
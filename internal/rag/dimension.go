@@ -0,0 +1,69 @@
+package rag
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// embeddingDimension keeps every vector stored in a collection the same length. chromem stores
+// vectors of any length, but similarities between vectors of different models are meaningless.
+type embeddingDimension struct {
+	size int   // Length every vector must have, 0 until the first embedding is checked
+	err  error // First mismatch found, which stops IndexDocuments
+}
+
+// check records the length of the first embedding it sees and rejects any whose length differs
+func (d *embeddingDimension) check(embedding []float32) error {
+	if d == nil {
+		return nil
+	}
+	if d.size == 0 {
+		d.size = len(embedding)
+		return nil
+	}
+	if len(embedding) != d.size {
+		err := fmt.Errorf("embedding has %d dimensions, but the collection stores %d-dimensional vectors; was the database indexed with another embedding model?", len(embedding), d.size)
+		if d.err == nil {
+			d.err = err
+		}
+		return err
+	}
+	return nil
+}
+
+// checkAll checks the embeddings of every chunk of a file, so that none are stored unless all fit
+func (d *embeddingDimension) checkAll(embeddings map[string][]float32) error {
+	for _, embedding := range embeddings {
+		if err := d.check(embedding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storedEmbeddingDimension returns the length of the vectors in the database in reader, or 0 when
+// it holds none. The fingerprint recorded in metadata is used when present; databases from before
+// it was recorded are decoded to measure one of their vectors.
+func storedEmbeddingDimension(reader io.ReadSeeker, metadata map[string]string) (int, error) {
+	if dimension, err := strconv.Atoi(metadata[fingerprintDimensionKey]); err == nil && dimension > 0 {
+		return dimension, nil
+	}
+
+	var persisted struct {
+		Collections map[string]*struct {
+			Documents map[string]*struct {
+				Embedding []float32
+			}
+		}
+	}
+	if err := decodeDatabase(reader, &persisted); err != nil {
+		return 0, fmt.Errorf("failed to read stored embeddings: %w", err)
+	}
+	if collection := persisted.Collections["documents"]; collection != nil {
+		for _, doc := range collection.Documents {
+			return len(doc.Embedding), nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,71 @@
+package rag
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestIndexDocumentsRejectsMismatchedDimensions(t *testing.T) {
+	// The server switches from 8- to 16-dimensional vectors after the preflight check and the first
+	// file
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		dim := 8
+		if requests.Add(1) > 2 {
+			dim = 16
+		}
+		json.NewEncoder(w).Encode(OllamaEmbeddingResponse{Embedding: testEmbedding(req.Prompt, dim)})
+	}))
+	defer server.Close()
+
+	config := newTestConfig(t, 8)
+	config.OllamaURL = server.URL
+	config.NoCache = true
+	docsDir := writeTestFiles(t, map[string]string{
+		"a.md": "# A\n\nFirst file.\n",
+		"b.md": "# B\n\nSecond file.\n",
+	})
+
+	var err error
+	output := captureStdout(t, func() {
+		err = IndexDocuments(docsDir, config, 200, 15, 0.25)
+	})
+	if err == nil || !strings.Contains(err.Error(), "embedding has 16 dimensions, but the collection stores 8-dimensional vectors") {
+		t.Fatalf("expected a dimension mismatch error, got %v", err)
+	}
+	if !strings.Contains(output, "Not storing") {
+		t.Fatalf("expected the mismatched file to be reported, got:\n%s", output)
+	}
+}
+
+func TestIndexDocumentsRejectsReindexingLegacyDatabaseWithOtherDimension(t *testing.T) {
+	config := newTestConfig(t, 16)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/old.md", "oldhash", "Indexed with an older model", 8),
+	})
+	docsDir := writeTestFiles(t, map[string]string{"new.md": "# New\n\nA new file.\n"})
+
+	var err error
+	captureStdout(t, func() {
+		err = IndexDocuments(docsDir, config, 200, 15, 0.25)
+	})
+	if err == nil || !strings.Contains(err.Error(), "but the collection stores 8-dimensional vectors") {
+		t.Fatalf("expected a dimension mismatch error, got %v", err)
+	}
+	readConfig := newTestConfig(t, 8)
+	readConfig.DBPath = config.DBPath
+	if docs := readTestDatabase(t, readConfig); len(docs) != 1 {
+		t.Fatalf("expected the database to be left as it was, found %d documents", len(docs))
+	}
+}
@@ -0,0 +1,88 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change in drift diffs
+const diffContextLines = 3
+
+// IndexedDrift describes how a file has changed since one of its documents was indexed
+type IndexedDrift struct {
+	Changed bool   // The file's content hash differs from the one recorded at indexing time
+	Diff    string // Unified diff from the indexed text to the file's current text over the same range
+}
+
+// MCPIndexedDrift compares an indexed document with its file on disk. When the file's hash no
+// longer matches, the text stored for the document is diffed against the file's current content
+// over the document's offsets.
+func MCPIndexedDrift(doc *MCPSearchResult) (*IndexedDrift, error) {
+	content, err := os.ReadFile(doc.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", doc.FilePath, err)
+	}
+
+	hash := sha256.Sum256(content)
+	if hex.EncodeToString(hash[:]) == doc.Metadata["file_hash"] {
+		return &IndexedDrift{}, nil
+	}
+
+	contentStr := string(content)
+	start, _ := strconv.Atoi(doc.Metadata["start_offset"])
+	end, err := strconv.Atoi(doc.Metadata["end_offset"])
+	if err != nil {
+		end = len(contentStr)
+	}
+	end = SnapToRuneEnd(contentStr, min(max(end, 0), len(contentStr)))
+	start = SnapToRuneStart(contentStr, min(max(start, 0), end))
+
+	return &IndexedDrift{
+		Changed: true,
+		Diff:    UnifiedDiff("indexed", "current", doc.Content, contentStr[start:end], diffContextLines),
+	}, nil
+}
+
+// MCPFindIndexedRange finds the indexed document of filePath covering exactly the given range,
+// as copied from a rag_search result, or the whole-file document when no range is given
+func MCPFindIndexedRange(config Config, filePath string, startOffset, endOffset *int) (*MCPSearchResult, error) {
+	collection, err := openCollection(config)
+	if err != nil {
+		return nil, err
+	}
+	if collection.Count() == 0 {
+		return nil, fmt.Errorf("no documents found in the database")
+	}
+
+	results, err := allDocuments(context.Background(), collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	for _, result := range results {
+		if resolveFilePath(result.Metadata["file_path"], config) != filePath {
+			continue
+		}
+		isChunk := result.Metadata["is_chunk"] == "true"
+		var matches bool
+		if startOffset == nil && endOffset == nil {
+			matches = !isChunk
+		} else {
+			matches = (startOffset == nil || result.Metadata["start_offset"] == strconv.Itoa(*startOffset)) &&
+				(endOffset == nil || result.Metadata["end_offset"] == strconv.Itoa(*endOffset))
+		}
+		if matches {
+			return &MCPSearchResult{
+				Content:  result.Content,
+				FilePath: filePath,
+				IsChunk:  isChunk,
+				Metadata: result.Metadata,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("no indexed chunk of %s matches this range; pass a chunk_id or the exact offsets from rag_search", filePath)
+}
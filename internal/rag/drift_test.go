@@ -0,0 +1,58 @@
+package rag
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMCPIndexedDriftDiffsFileEditedAfterIndexing(t *testing.T) {
+	config := newTestConfig(t, 8)
+	docsDir := writeTestFiles(t, map[string]string{
+		"setup.md": "# Setup\n\nInstall the CLI.\nRun the setup wizard.\nRestart the shell.\n",
+	})
+	filePath := filepath.Join(docsDir, "setup.md")
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+
+	results, err := MCPSearchDocumentsWithResults("setup wizard", config, 1)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	doc, err := MCPRetrieveChunk(config, results[0].ID)
+	if err != nil {
+		t.Fatalf("unexpected retrieval error: %v", err)
+	}
+
+	drift, err := MCPIndexedDrift(doc)
+	if err != nil {
+		t.Fatalf("unexpected drift error: %v", err)
+	}
+	if drift.Changed || drift.Diff != "" {
+		t.Fatalf("expected an unedited file to match the index, got %+v", drift)
+	}
+
+	if err := os.WriteFile(filePath, []byte("# Setup\n\nInstall the CLI.\nRun the configure command.\nRestart the shell.\n"), 0o644); err != nil {
+		t.Fatalf("failed to edit fixture: %v", err)
+	}
+	drift, err = MCPIndexedDrift(doc)
+	if err != nil {
+		t.Fatalf("unexpected drift error: %v", err)
+	}
+	if !drift.Changed {
+		t.Fatalf("expected the edit to be detected")
+	}
+	for _, want := range []string{"--- indexed\n+++ current\n", "-Run the setup wizard.\n", "+Run the configure command.\n", " Install the CLI.\n"} {
+		if !strings.Contains(drift.Diff, want) {
+			t.Fatalf("expected %q in diff:\n%s", want, drift.Diff)
+		}
+	}
+
+	// A small file is indexed whole, so retrieving it without a range finds the same document
+	found, err := MCPFindIndexedRange(config, filePath, nil, nil)
+	if err != nil || found.Content != doc.Content {
+		t.Fatalf("expected the whole-file document to be found, got %v", err)
+	}
+}
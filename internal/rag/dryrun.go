@@ -0,0 +1,127 @@
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// DryRunFile is what indexing would do with one file
+type DryRunFile struct {
+	Path      string
+	Chunks    int
+	Tokens    int
+	Unchanged bool // The file's current content is already in the database
+}
+
+// DryRunReport is what indexing a folder would do, without embedding or writing anything
+type DryRunReport struct {
+	Files         []DryRunFile
+	Chunks        int
+	Tokens        int
+	Unchanged     int // Files whose content is already indexed
+	ChunksToEmbed int // Chunks of new or changed files
+	TokensToEmbed int // Tokens of new or changed files
+}
+
+// DryRunIndex walks absRootPath with the indexer's filters, hashes and chunks every file, and
+// checks each hash against the existing database. Indexed documents record their file's content
+// hash, so unchanged files are found without calling the embedding API.
+func DryRunIndex(absRootPath string, config Config, maxTokensPerChunk, chunkOverlapPercent int, approxTokensPerChar float64) (*DryRunReport, error) {
+	mdFiles, err := findMarkdownFiles(absRootPath, config)
+	if err != nil {
+		return nil, err
+	}
+	mdFiles = filesModifiedSince(mdFiles, config.Since)
+
+	indexedHashes, err := readIndexedFileHashes(config.DBPath)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := NewTokenCounter(config, approxTokensPerChar)
+	progress := progressReporter(config)
+	report := &DryRunReport{}
+	for _, filePath := range mdFiles {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Printf("Warning: Could not read file %s: %v\n", filePath, err)
+			continue
+		}
+
+		hash := sha256.Sum256(content)
+		file := DryRunFile{Path: filePath, Unchanged: indexedHashes[hex.EncodeToString(hash[:])]}
+		file.Chunks, file.Tokens = planChunks(filePath, string(content), config, maxTokensPerChunk, chunkOverlapPercent, counter, progress)
+
+		report.Files = append(report.Files, file)
+		report.Chunks += file.Chunks
+		report.Tokens += file.Tokens
+		if file.Unchanged {
+			report.Unchanged++
+		} else {
+			report.ChunksToEmbed += file.Chunks
+			report.TokensToEmbed += file.Tokens
+		}
+	}
+	return report, nil
+}
+
+// readIndexedFileHashes returns the file hashes of the documents in the database at dbPath, or
+// none when it does not exist yet. Only metadata is decoded, so no embeddings are loaded and the
+// embedding API is not needed, whatever the chunk ID scheme.
+func readIndexedFileHashes(dbPath string) (map[string]bool, error) {
+	hashes := make(map[string]bool)
+	file, err := os.Open(dbPath)
+	if os.IsNotExist(err) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer file.Close()
+
+	var persisted struct {
+		Collections map[string]*struct {
+			Documents map[string]*struct {
+				Metadata map[string]string
+			}
+		}
+	}
+	if err := decodeDatabase(file, &persisted); err != nil {
+		return nil, fmt.Errorf("failed to load database: %w", err)
+	}
+	if collection := persisted.Collections["documents"]; collection != nil {
+		for _, doc := range collection.Documents {
+			hashes[doc.Metadata["file_hash"]] = true
+		}
+	}
+	return hashes, nil
+}
+
+// showDryRun prints what indexing absRootPath would do
+func showDryRun(absRootPath string, config Config, maxTokensPerChunk, chunkOverlapPercent int, approxTokensPerChar float64) error {
+	report, err := DryRunIndex(absRootPath, config, maxTokensPerChunk, chunkOverlapPercent, approxTokensPerChar)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Dry Run")
+	fmt.Println("=======")
+	fmt.Printf("Path: %s\n", absRootPath)
+	fmt.Printf("Database: %s\n\n", config.DBPath)
+	for _, file := range report.Files {
+		status := "index"
+		if file.Unchanged {
+			status = "unchanged"
+		}
+		fmt.Printf("  %-9s  %s (%s chunks, %s tokens)\n", status, file.Path, FormatNumber(file.Chunks), FormatNumber(file.Tokens))
+	}
+
+	fmt.Printf("\nFiles: %s (%s new or changed, %s unchanged)\n",
+		FormatNumber(len(report.Files)), FormatNumber(len(report.Files)-report.Unchanged), FormatNumber(report.Unchanged))
+	fmt.Printf("Chunks: %s (%s to embed)\n", FormatNumber(report.Chunks), FormatNumber(report.ChunksToEmbed))
+	fmt.Printf("Estimated tokens: %s (%s to embed)\n", FormatNumber(report.Tokens), FormatNumber(report.TokensToEmbed))
+	fmt.Println("Dry run: no embeddings were requested and the database was not written.")
+	return nil
+}
@@ -0,0 +1,75 @@
+package rag
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRunIndexReportsWithoutEmbeddingOrWriting(t *testing.T) {
+	config := newTestConfig(t, 8)
+	docsDir := writeTestFiles(t, map[string]string{
+		"same.md":    "# Same\n\nUnchanged note.\n",
+		"changed.md": "# Changed\n\nFirst version.\n",
+	})
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+	before, err := os.ReadFile(config.DBPath)
+	if err != nil {
+		t.Fatalf("failed to read database: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(docsDir, "changed.md"), []byte("# Changed\n\nSecond version.\n"), 0o644); err != nil {
+		t.Fatalf("failed to edit fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(docsDir, "large.md"), []byte(strings.Repeat("A sentence of filler text. ", 200)), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run called the embedding API")
+		http.Error(w, "unexpected", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	config.OllamaURL = server.URL
+	config.DryRun = true
+
+	output := captureStdout(t, func() {
+		if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+			t.Fatalf("unexpected dry run error: %v", err)
+		}
+	})
+
+	after, err := os.ReadFile(config.DBPath)
+	if err != nil {
+		t.Fatalf("failed to read database: %v", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatalf("expected the dry run to leave the database untouched")
+	}
+
+	report, err := DryRunIndex(docsDir, config, 200, 15, 0.25)
+	if err != nil {
+		t.Fatalf("unexpected dry run error: %v", err)
+	}
+	unchanged := map[string]bool{}
+	for _, file := range report.Files {
+		unchanged[filepath.Base(file.Path)] = file.Unchanged
+	}
+	if len(report.Files) != 3 || !unchanged["same.md"] || unchanged["changed.md"] || unchanged["large.md"] {
+		t.Fatalf("unexpected files in report: %+v", report.Files)
+	}
+	if report.Chunks < 4 || report.ChunksToEmbed != report.Chunks-1 || report.Unchanged != 1 {
+		t.Fatalf("unexpected totals: %+v", report)
+	}
+	for _, want := range []string{"unchanged  " + filepath.Join(docsDir, "same.md"), "Files: 3 (2 new or changed, 1 unchanged)"} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("expected %q in output:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// duplicatePathsKey is the metadata key listing, one per line, the other files with the same
+// content as the indexed file when -dedup-files is set
+const duplicatePathsKey = "duplicate_paths"
+
+// findDuplicateFiles groups files with identical content. Each file whose content an earlier file
+// already has maps to that earlier file in original, and each earlier file maps to its later
+// copies in duplicates. Files that cannot be read are left for the indexer to report.
+func findDuplicateFiles(files []string) (original map[string]string, duplicates map[string][]string) {
+	original = make(map[string]string)
+	duplicates = make(map[string][]string)
+	first := make(map[[sha256.Size]byte]string)
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		hash := sha256.Sum256(content)
+		if firstPath, ok := first[hash]; ok {
+			original[path] = firstPath
+			duplicates[firstPath] = append(duplicates[firstPath], path)
+			continue
+		}
+		first[hash] = path
+	}
+	return original, duplicates
+}
+
+// duplicateLinks returns, for the path on disk of every file the collection lists in duplicate_paths
+// or stores with some listed, the files it shares content with according to the stored documents
+func duplicateLinks(collection *chromem.Collection, config Config) (map[string][]string, error) {
+	links := make(map[string][]string)
+	if collection.Count() == 0 {
+		return links, nil
+	}
+	results, err := allDocuments(context.Background(), collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, result := range results {
+		filePath := resolveFilePath(result.Metadata["file_path"], config)
+		if seen[filePath] {
+			continue
+		}
+		seen[filePath] = true
+		for _, stored := range duplicatePaths(result.Metadata) {
+			copyPath := resolveFilePath(stored, config)
+			links[filePath] = append(links[filePath], copyPath)
+			links[copyPath] = append(links[copyPath], filePath)
+		}
+	}
+	return links, nil
+}
+
+// duplicateGroupFiles returns the files of all whose documents must be rewritten when only the
+// files in changed have changed: those files, the files they were stored as sharing content with
+// according to links, and every file of their duplicate groups now, in the order of all. A changed
+// copy moves the original's duplicate_paths, and a changed original hands its copies to another.
+func duplicateGroupFiles(changed, all []string, original map[string]string, duplicates map[string][]string, links map[string][]string) []string {
+	include := make(map[string]bool)
+	addGroup := func(path string) {
+		include[path] = true
+		first := path
+		if firstPath, ok := original[path]; ok {
+			first = firstPath
+		}
+		include[first] = true
+		for _, copyPath := range duplicates[first] {
+			include[copyPath] = true
+		}
+	}
+	for _, path := range changed {
+		addGroup(path)
+		for _, linked := range links[path] {
+			addGroup(linked)
+		}
+	}
+
+	var files []string
+	for _, path := range all {
+		if include[path] {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// addDuplicateMetadata records the paths of the files sharing a document's content in its
+// metadata, stored like file_path
+func addDuplicateMetadata(metadata map[string]string, duplicates []string, config Config) {
+	if len(duplicates) == 0 {
+		return
+	}
+	stored := make([]string, len(duplicates))
+	for i, path := range duplicates {
+		stored[i] = storedFilePath(path, config)
+	}
+	metadata[duplicatePathsKey] = strings.Join(stored, "\n")
+}
+
+// formatPathList renders paths as a comma-separated list of code spans
+func formatPathList(paths []string) string {
+	quoted := make([]string, len(paths))
+	for i, path := range paths {
+		quoted[i] = "`" + path + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// duplicatePaths returns the paths listed in the duplicate_paths metadata of a document
+func duplicatePaths(metadata map[string]string) []string {
+	if metadata[duplicatePathsKey] == "" {
+		return nil
+	}
+	return strings.Split(metadata[duplicatePathsKey], "\n")
+}
+
+// unlistDuplicatePaths removes the stored paths for which gone returns true from the
+// duplicate_paths of every document, returning the paths removed in sorted order
+func unlistDuplicatePaths(collection *chromem.Collection, gone func(path string) bool) ([]string, error) {
+	results, err := allDocuments(context.Background(), collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	removed := make(map[string]bool)
+	for _, result := range results {
+		paths := duplicatePaths(result.Metadata)
+		kept := make([]string, 0, len(paths))
+		for _, path := range paths {
+			if gone(path) {
+				removed[path] = true
+				continue
+			}
+			kept = append(kept, path)
+		}
+		if len(kept) == len(paths) {
+			continue
+		}
+
+		metadata := make(map[string]string, len(result.Metadata))
+		for k, v := range result.Metadata {
+			metadata[k] = v
+		}
+		if len(kept) == 0 {
+			delete(metadata, duplicatePathsKey)
+		} else {
+			metadata[duplicatePathsKey] = strings.Join(kept, "\n")
+		}
+		err := collection.AddDocument(context.Background(), chromem.Document{
+			ID:        result.ID,
+			Metadata:  metadata,
+			Embedding: result.Embedding,
+			Content:   result.Content,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update chunk %s: %w", result.ID, err)
+		}
+	}
+
+	unlisted := make([]string, 0, len(removed))
+	for path := range removed {
+		unlisted = append(unlisted, path)
+	}
+	sort.Strings(unlisted)
+	return unlisted, nil
+}
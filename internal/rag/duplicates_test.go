@@ -0,0 +1,167 @@
+package rag
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestIndexDedupFilesIndexesIdenticalContentOnce(t *testing.T) {
+	license := "# License\n\nPermission is hereby granted, free of charge, to any person.\n"
+	docsDir := writeTestFiles(t, map[string]string{
+		"a/LICENSE.md": license,
+		"b/LICENSE.md": license,
+		"c/LICENSE.md": license,
+		"guide.md":     "# Guide\n\nInstall the widget before configuring it.\n",
+	})
+	config := newTestConfig(t, 8)
+	config.Progress = TextProgress(io.Discard, LogNormal)
+	config.DedupFiles = true
+
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected index error: %v", err)
+	}
+
+	copies := []string{filepath.Join(docsDir, "b", "LICENSE.md"), filepath.Join(docsDir, "c", "LICENSE.md")}
+	var licenseDocs int
+	for _, doc := range readTestDatabase(t, config) {
+		switch doc.Metadata["file_path"] {
+		case filepath.Join(docsDir, "a", "LICENSE.md"):
+			licenseDocs++
+			if got := duplicatePaths(doc.Metadata); !reflect.DeepEqual(got, copies) {
+				t.Fatalf("expected the copies %v in duplicate_paths, got %v", copies, got)
+			}
+		case filepath.Join(docsDir, "guide.md"):
+			if doc.Metadata[duplicatePathsKey] != "" {
+				t.Fatalf("expected no duplicate_paths on a unique file, got %q", doc.Metadata[duplicatePathsKey])
+			}
+		default:
+			t.Fatalf("expected copies not to be indexed, found %s", doc.Metadata["file_path"])
+		}
+	}
+	if licenseDocs != 1 {
+		t.Fatalf("expected the license to be indexed once, got %d documents", licenseDocs)
+	}
+
+	results, err := Search("permission is hereby granted", config, SearchOptions{MaxResults: 1})
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	response := formatSearchResponse("permission is hereby granted", groupResultsByFile(results, ChunkOrderPosition, MixedEntriesChunks), 0, false, false)
+	if want := "- **Same content at:** `" + copies[0] + "`, `" + copies[1] + "`"; !strings.Contains(response, want) {
+		t.Fatalf("expected the response to list the copies as %q, got:\n%s", want, response)
+	}
+}
+
+// storedDuplicatePaths returns the duplicate_paths of every file in results by file path
+func storedDuplicatePaths(results []chromem.Result) map[string][]string {
+	stored := make(map[string][]string)
+	for _, result := range results {
+		stored[result.Metadata["file_path"]] = duplicatePaths(result.Metadata)
+	}
+	return stored
+}
+
+func TestIndexDedupFilesSinceUpdatesDuplicatePaths(t *testing.T) {
+	license := "# License\n\nPermission is hereby granted, free of charge, to any person.\n"
+	docsDir := writeTestFiles(t, map[string]string{
+		"a.md": license,
+		"b.md": license,
+	})
+	aPath, bPath, cPath := filepath.Join(docsDir, "a.md"), filepath.Join(docsDir, "b.md"), filepath.Join(docsDir, "c.md")
+	config := newTestConfig(t, 8)
+	config.Progress = TextProgress(io.Discard, LogNormal)
+	config.DedupFiles = true
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected index error: %v", err)
+	}
+
+	// Only the files written after the first run are selected by -since
+	old := time.Now().Add(-time.Hour)
+	for _, path := range []string{aPath, bPath} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("failed to age %s: %v", path, err)
+		}
+	}
+	config.Since = old.Add(time.Minute)
+
+	// A new copy of an unchanged original is listed in the original's duplicate_paths
+	if err := os.WriteFile(cPath, []byte(license), 0o644); err != nil {
+		t.Fatalf("failed to write copy: %v", err)
+	}
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected index error: %v", err)
+	}
+	want := map[string][]string{aPath: {bPath, cPath}}
+	if got := storedDuplicatePaths(readTestDatabase(t, config)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v after adding a copy, got %v", want, got)
+	}
+
+	// A copy that is edited is indexed on its own and leaves the original's duplicate_paths
+	if err := os.WriteFile(bPath, []byte("# Changed\n\nNo longer the license.\n"), 0o644); err != nil {
+		t.Fatalf("failed to edit copy: %v", err)
+	}
+	if err := os.Chtimes(cPath, old, old); err != nil {
+		t.Fatalf("failed to age %s: %v", cPath, err)
+	}
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected index error: %v", err)
+	}
+	want = map[string][]string{aPath: {cPath}, bPath: nil}
+	if got := storedDuplicatePaths(readTestDatabase(t, config)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v after editing a copy, got %v", want, got)
+	}
+}
+
+func TestWatcherSyncUpdatesDuplicatePaths(t *testing.T) {
+	license := "# License\n\nPermission is hereby granted, free of charge, to any person.\n"
+	root := writeTestFiles(t, map[string]string{
+		"a.md": license,
+		"b.md": license,
+	})
+	aPath, bPath, cPath := filepath.Join(root, "a.md"), filepath.Join(root, "b.md"), filepath.Join(root, "c.md")
+	config := newTestConfig(t, 8)
+	config.Progress = TextProgress(io.Discard, LogNormal)
+	config.DedupFiles = true
+
+	doc := testDocument(aPath, "aaaa1111", license, 8)
+	doc.Metadata[duplicatePathsKey] = bPath
+	w := newTestWatcher(t, root, config, []chromem.Document{doc})
+
+	// A copy created while watching is listed rather than indexed
+	if err := os.WriteFile(cPath, []byte(license), 0o644); err != nil {
+		t.Fatalf("failed to write copy: %v", err)
+	}
+	w.pending[cPath] = struct{}{}
+	w.sync()
+	results, err := allDocuments(context.Background(), w.collection)
+	if err != nil {
+		t.Fatalf("failed to list documents: %v", err)
+	}
+	want := map[string][]string{aPath: {bPath, cPath}}
+	if got := storedDuplicatePaths(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v after creating a copy, got %v", want, got)
+	}
+
+	// Deleting the original hands its documents to the first remaining copy
+	if err := os.Remove(aPath); err != nil {
+		t.Fatalf("failed to remove original: %v", err)
+	}
+	w.pending[aPath] = struct{}{}
+	w.sync()
+	results, err = allDocuments(context.Background(), w.collection)
+	if err != nil {
+		t.Fatalf("failed to list documents: %v", err)
+	}
+	want = map[string][]string{bPath: {cPath}}
+	if got := storedDuplicatePaths(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v after deleting the original, got %v", want, got)
+	}
+}
@@ -0,0 +1,239 @@
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EmbeddingCacheSuffix is appended to the database path to locate the embedding cache
+const EmbeddingCacheSuffix = ".embcache"
+
+// EmbeddingCache is a persistent cache of embeddings keyed by content, model, and mode.
+// A nil *EmbeddingCache is valid and caches nothing. It is safe for concurrent use.
+type EmbeddingCache struct {
+	mu         sync.Mutex
+	path       string
+	maxEntries int
+	maxAge     time.Duration // Entries unused for longer are evicted (0 for no limit)
+	entries    map[string]*embeddingCacheEntry
+	clock      int64 // Monotonic counter used to track least recently used entries
+	dirty      bool
+}
+
+// embeddingCacheEntry is a single cached embedding
+type embeddingCacheEntry struct {
+	Embedding []float32
+	LastUsed  int64
+	UsedAt    time.Time // When the entry was last stored or read
+}
+
+// embeddingCacheFile is the on-disk representation of the cache
+type embeddingCacheFile struct {
+	Clock   int64
+	Entries map[string]*embeddingCacheEntry
+}
+
+// NewEmbeddingCache creates an empty embedding cache stored at path.
+// maxEntries <= 0 means no size cap.
+func NewEmbeddingCache(path string, maxEntries int) *EmbeddingCache {
+	return &EmbeddingCache{
+		path:       path,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*embeddingCacheEntry),
+	}
+}
+
+// LoadEmbeddingCache loads the embedding cache stored next to the database, starting
+// empty if the cache does not exist yet. maxEntries <= 0 means no size cap; entries unused
+// for longer than config.CacheMaxAge are evicted when the cache is saved.
+func LoadEmbeddingCache(config Config, maxEntries int) (*EmbeddingCache, error) {
+	cache := NewEmbeddingCache(config.DBPath+EmbeddingCacheSuffix, maxEntries)
+	cache.maxAge = config.CacheMaxAge
+
+	file, err := os.Open(cache.path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedding cache: %w", err)
+	}
+	defer file.Close()
+
+	var stored embeddingCacheFile
+	if err := gob.NewDecoder(file).Decode(&stored); err != nil {
+		return nil, fmt.Errorf("failed to load embedding cache: %w", err)
+	}
+	if stored.Entries != nil {
+		cache.entries = stored.Entries
+	}
+	cache.clock = stored.Clock
+
+	// Entries from before their use was timed start aging now
+	now := time.Now()
+	for _, entry := range cache.entries {
+		if entry.UsedAt.IsZero() {
+			entry.UsedAt = now
+		}
+	}
+
+	return cache, nil
+}
+
+// embeddingCacheKey derives the cache key from the content and the embedding model and mode
+// so that switching models never returns stale vectors
+func embeddingCacheKey(text string, config Config) string {
+	hash := sha256.Sum256([]byte(config.EmbeddingMode + "\x00" + config.EmbeddingModel + "\x00" + text))
+	return hex.EncodeToString(hash[:])
+}
+
+// Get returns the cached embedding for text, if present
+func (c *EmbeddingCache) Get(text string, config Config) ([]float32, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[embeddingCacheKey(text, config)]
+	if !ok {
+		return nil, false
+	}
+	c.clock++
+	entry.LastUsed = c.clock
+	entry.UsedAt = time.Now()
+	c.dirty = true
+	return entry.Embedding, true
+}
+
+// Put stores the embedding for text
+func (c *EmbeddingCache) Put(text string, config Config, embedding []float32) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock++
+	c.entries[embeddingCacheKey(text, config)] = &embeddingCacheEntry{
+		Embedding: embedding,
+		LastUsed:  c.clock,
+		UsedAt:    time.Now(),
+	}
+	c.dirty = true
+}
+
+// Len returns the number of cached embeddings
+func (c *EmbeddingCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// evict removes the entries unused for longer than the age limit, then the least recently used
+// entries until the cache fits its size cap
+func (c *EmbeddingCache) evict() {
+	if c.maxAge > 0 {
+		cutoff := time.Now().Add(-c.maxAge)
+		for key, entry := range c.entries {
+			if entry.UsedAt.Before(cutoff) {
+				delete(c.entries, key)
+				c.dirty = true
+			}
+		}
+	}
+
+	if c.maxEntries <= 0 || len(c.entries) <= c.maxEntries {
+		return
+	}
+
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].LastUsed < c.entries[keys[j]].LastUsed
+	})
+
+	for _, key := range keys[:len(keys)-c.maxEntries] {
+		delete(c.entries, key)
+	}
+	c.dirty = true
+}
+
+// Save evicts entries over the age limit or size cap and writes the cache to disk if it changed
+func (c *EmbeddingCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict()
+	if !c.dirty {
+		return nil
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create embedding cache file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	err = gob.NewEncoder(tmpFile).Encode(embeddingCacheFile{Clock: c.clock, Entries: c.entries})
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, c.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save embedding cache: %w", err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// ClearEmbeddingCache deletes the embedding cache stored next to the database
+func ClearEmbeddingCache(config Config) error {
+	report := progressReporter(config)
+	path := config.DBPath + EmbeddingCacheSuffix
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		report.infof("No embedding cache at %s", path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find embedding cache: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove embedding cache: %w", err)
+	}
+	report.infof("✓ Removed embedding cache %s (%s)", path, FormatBytes(info.Size()))
+	return nil
+}
+
+// embeddingCacheSize returns the number of embeddings cached next to the database and the size
+// of the cache file in bytes, both 0 when there is no cache
+func embeddingCacheSize(config Config) (int, int64, error) {
+	info, err := os.Stat(config.DBPath + EmbeddingCacheSuffix)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find embedding cache: %w", err)
+	}
+	cache, err := LoadEmbeddingCache(config, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cache.Len(), info.Size(), nil
+}
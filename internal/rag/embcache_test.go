@@ -0,0 +1,122 @@
+package rag
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestEmbeddingCacheKeyIncludesModel(t *testing.T) {
+	cache := NewEmbeddingCache(filepath.Join(t.TempDir(), "rag.db.embcache"), 0)
+	configA := Config{EmbeddingMode: EmbeddingModeOllama, EmbeddingModel: "model-a"}
+	configB := Config{EmbeddingMode: EmbeddingModeOllama, EmbeddingModel: "model-b"}
+
+	cache.Put("hello", configA, []float32{1, 2})
+
+	if _, ok := cache.Get("hello", configA); !ok {
+		t.Fatalf("expected a cache hit for the same model")
+	}
+	if _, ok := cache.Get("hello", configB); ok {
+		t.Fatalf("expected a cache miss for a different model")
+	}
+}
+
+func TestEmbeddingCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	config := Config{DBPath: filepath.Join(t.TempDir(), "rag.db"), EmbeddingModel: "test-model"}
+	cache, err := LoadEmbeddingCache(config, 2)
+	if err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+
+	cache.Put("first", config, []float32{1})
+	cache.Put("second", config, []float32{2})
+	cache.Get("first", config)
+	cache.Put("third", config, []float32{3})
+
+	if err := cache.Save(); err != nil {
+		t.Fatalf("unexpected save error: %v", err)
+	}
+
+	reloaded, err := LoadEmbeddingCache(config, 2)
+	if err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+	if reloaded.Len() != 2 {
+		t.Fatalf("unexpected cache size: got %d, want 2", reloaded.Len())
+	}
+	if _, ok := reloaded.Get("second", config); ok {
+		t.Fatalf("expected the least recently used entry to be evicted")
+	}
+	for _, text := range []string{"first", "third"} {
+		if _, ok := reloaded.Get(text, config); !ok {
+			t.Fatalf("expected %q to remain cached", text)
+		}
+	}
+}
+
+func TestEmbeddingCacheEvictsEntriesOverMaxAge(t *testing.T) {
+	config := Config{DBPath: filepath.Join(t.TempDir(), "rag.db"), EmbeddingModel: "test-model", CacheMaxAge: 24 * time.Hour}
+	cache, err := LoadEmbeddingCache(config, 0)
+	if err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+
+	cache.Put("stale", config, []float32{1})
+	cache.Put("fresh", config, []float32{2})
+	cache.entries[embeddingCacheKey("stale", config)].UsedAt = time.Now().Add(-48 * time.Hour)
+
+	if err := cache.Save(); err != nil {
+		t.Fatalf("unexpected save error: %v", err)
+	}
+
+	reloaded, err := LoadEmbeddingCache(config, 0)
+	if err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+	if _, ok := reloaded.Get("stale", config); ok {
+		t.Fatalf("expected the entry unused for longer than -cache-max-age to be evicted")
+	}
+	if _, ok := reloaded.Get("fresh", config); !ok {
+		t.Fatalf("expected the recently used entry to remain cached")
+	}
+}
+
+func TestClearEmbeddingCacheRemovesCacheAndStatsReportIt(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.Progress = TextProgress(io.Discard, LogNormal)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/a.md", "aaaa1111", "alpha notes", 8),
+	})
+	cache := NewEmbeddingCache(config.DBPath+EmbeddingCacheSuffix, 0)
+	cache.Put("first", config, []float32{1})
+	cache.Put("second", config, []float32{2})
+	if err := cache.Save(); err != nil {
+		t.Fatalf("unexpected save error: %v", err)
+	}
+
+	stats, err := ComputeStats(config)
+	if err != nil {
+		t.Fatalf("unexpected stats error: %v", err)
+	}
+	if stats.CacheEntries != 2 || stats.CacheSize == 0 {
+		t.Fatalf("expected stats to report 2 cached embeddings and a size, got %d entries, %d bytes", stats.CacheEntries, stats.CacheSize)
+	}
+
+	if err := ClearEmbeddingCache(config); err != nil {
+		t.Fatalf("unexpected clear error: %v", err)
+	}
+	if _, err := os.Stat(config.DBPath + EmbeddingCacheSuffix); !os.IsNotExist(err) {
+		t.Fatalf("expected the cache file to be removed, got %v", err)
+	}
+	stats, err = ComputeStats(config)
+	if err != nil {
+		t.Fatalf("unexpected stats error: %v", err)
+	}
+	if stats.CacheEntries != 0 || stats.CacheSize != 0 {
+		t.Fatalf("expected an empty cache after clearing, got %d entries, %d bytes", stats.CacheEntries, stats.CacheSize)
+	}
+}
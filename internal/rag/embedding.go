@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,10 +30,85 @@ type OllamaEmbeddingRequest struct {
 // OllamaEmbeddingResponse represents the response structure from Ollama API
 type OllamaEmbeddingResponse struct {
 	Embedding []float32 `json:"embedding"`
+	Done      bool      `json:"done,omitempty"` // Set on the final object of a streamed response
 }
 
-// GetEmbedding gets embedding from Ollama API
-func GetEmbedding(text string, config Config) ([]float32, error) {
+// Supported embedding API modes
+const (
+	EmbeddingModeOllama = "ollama" // Ollama /api/embeddings
+	EmbeddingModeOpenAI = "openai" // OpenAI-compatible /v1/embeddings
+)
+
+// OpenAIEmbeddingRequest represents the request structure for OpenAI-compatible APIs
+type OpenAIEmbeddingRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+// OpenAIEmbeddingResponse represents the response structure from OpenAI-compatible APIs
+type OpenAIEmbeddingResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// retryBackoff is the delay before the first retry of a failed embedding, growing with each retry
+var retryBackoff = time.Second
+
+// EmbeddingAPIError is a non-200 response from the embedding API
+type EmbeddingAPIError struct {
+	API        string // "Ollama API" or "embeddings API", for the message
+	StatusCode int
+	Body       string
+}
+
+func (e *EmbeddingAPIError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.API, e.StatusCode, e.Body)
+}
+
+// EmbeddingRequestError is a request to the embedding API that got no response, such as when
+// the server is not running
+type EmbeddingRequestError struct {
+	API string // "Ollama" or "embeddings API", for the message
+	Err error
+}
+
+func (e *EmbeddingRequestError) Error() string {
+	return fmt.Sprintf("failed to make request to %s: %v", e.API, e.Err)
+}
+
+func (e *EmbeddingRequestError) Unwrap() error {
+	return e.Err
+}
+
+// isTransientEmbeddingError reports whether a failed embedding request may succeed if retried:
+// network errors, rate limiting, and server errors. Other API responses, such as an unknown
+// model or a malformed request, fail the same way every time.
+func isTransientEmbeddingError(err error) bool {
+	var apiErr *EmbeddingAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// GetEmbedding gets embedding from the configured embedding API, abandoning the request if ctx
+// is cancelled
+func GetEmbedding(ctx context.Context, text string, config Config) ([]float32, error) {
+	defer logSlow(config, "embedding", fmt.Sprintf("model %s, %d characters", config.EmbeddingModel, len(text)), time.Now())
+	switch config.EmbeddingMode {
+	case "", EmbeddingModeOllama:
+		return getOllamaEmbedding(ctx, text, config)
+	case EmbeddingModeOpenAI:
+		return getOpenAIEmbedding(ctx, text, config)
+	default:
+		return nil, fmt.Errorf("unsupported embedding mode: %s", config.EmbeddingMode)
+	}
+}
+
+// getOllamaEmbedding gets embedding from Ollama API
+func getOllamaEmbedding(ctx context.Context, text string, config Config) ([]float32, error) {
 	reqBody := OllamaEmbeddingRequest{
 		Model:  config.EmbeddingModel,
 		Prompt: text,
@@ -37,30 +117,104 @@ func GetEmbedding(text string, config Config) ([]float32, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
-	resp, err := http.Post(config.OllamaURL, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.OllamaURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, &EmbeddingRequestError{API: "Ollama", Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &EmbeddingAPIError{API: "Ollama API", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	// Some Ollama versions stream the response as several JSON objects, so keep reading until the
+	// stream ends. Each object with an embedding carries the whole vector, so the one marked done,
+	// or else the last one, is the result.
+	var embedding []float32
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var embeddingResp OllamaEmbeddingResponse
+		err := decoder.Decode(&embeddingResp)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		if len(embeddingResp.Embedding) > 0 {
+			embedding = embeddingResp.Embedding
+		}
+		if embeddingResp.Done {
+			break
+		}
+	}
+	if len(embedding) == 0 {
+		return nil, fmt.Errorf("Ollama response contained no embedding")
+	}
+	return embedding, nil
+}
+
+// getOpenAIEmbedding gets embedding from an OpenAI-compatible embeddings API
+func getOpenAIEmbedding(ctx context.Context, text string, config Config) ([]float32, error) {
+	reqBody := OpenAIEmbeddingRequest{
+		Model: config.EmbeddingModel,
+		Input: text,
+	}
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.OllamaURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if config.OpenAIAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.OpenAIAPIKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request to Ollama: %w", err)
+		return nil, &EmbeddingRequestError{API: "embeddings API", Err: err}
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Ollama API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, &EmbeddingAPIError{API: "embeddings API", StatusCode: resp.StatusCode, Body: string(body)}
 	}
-	var embeddingResp OllamaEmbeddingResponse
+	var embeddingResp OpenAIEmbeddingResponse
 	decoder := json.NewDecoder(resp.Body)
 	if err := decoder.Decode(&embeddingResp); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-	return embeddingResp.Embedding, nil
+	if len(embeddingResp.Data) == 0 {
+		return nil, fmt.Errorf("embeddings API returned no data")
+	}
+	return embeddingResp.Data[0].Embedding, nil
 }
 
-// BatchEmbedChunks processes chunks in batches with retry logic
-func BatchEmbedChunks(chunks []DocumentChunk, config Config) (map[string][]float32, error) {
+// BatchEmbedChunks processes chunks in batches with retry logic, reusing cached embeddings when available.
+// Chunks within a batch are embedded concurrently by up to config.Concurrency workers. Cancelling
+// ctx stops the remaining work and returns its error.
+func BatchEmbedChunks(ctx context.Context, chunks []DocumentChunk, config Config, cache *EmbeddingCache) (map[string][]float32, error) {
 	embeddings := make(map[string][]float32)
 	batchSize := 10 // Process 10 chunks at a time
 	maxRetries := 3
 
-	fmt.Printf("Processing %d chunks in batches of %d\n", len(chunks), batchSize)
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	report := progressReporter(config)
+	report.infof("Processing %d chunks in batches of %d (concurrency %d)", len(chunks), batchSize, concurrency)
+
+	var mu sync.Mutex // Guards embeddings and firstErr
+	var firstErr error
+	sem := make(chan struct{}, concurrency)
 
 	for i := 0; i < len(chunks); i += batchSize {
 		end := i + batchSize
@@ -69,45 +223,172 @@ func BatchEmbedChunks(chunks []DocumentChunk, config Config) (map[string][]float
 		}
 
 		batch := chunks[i:end]
-		fmt.Printf("Processing batch %d/%d (%d chunks)\n",
+		report.infof("Processing batch %d/%d (%d chunks)",
 			(i/batchSize)+1, (len(chunks)+batchSize-1)/batchSize, len(batch))
 
 		// Process each chunk in the batch with retries
+		var wg sync.WaitGroup
 		for _, chunk := range batch {
-			var embedding []float32
-			var err error
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(chunk DocumentChunk) {
+				defer wg.Done()
+				defer func() { <-sem }()
 
-			for retry := 0; retry < maxRetries; retry++ {
-				embedding, err = GetEmbedding(chunk.Content, config)
-				if err == nil {
-					break
+				// Stop picking up work once any worker has failed
+				mu.Lock()
+				failed := firstErr != nil
+				mu.Unlock()
+				if failed {
+					return
 				}
 
-				if retry < maxRetries-1 {
-					fmt.Printf("  Retry %d/%d for chunk %s: %v\n", retry+1, maxRetries, chunk.ID, err)
-					time.Sleep(time.Duration(retry+1) * time.Second) // Exponential backoff
-				}
-			}
+				embedding, err := embedChunkWithRetry(ctx, chunk, config, cache, maxRetries)
 
-			if err != nil {
-				return nil, fmt.Errorf("failed to get embedding for chunk %s after %d retries: %w", chunk.ID, maxRetries, err)
-			}
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					return
+				}
+				embeddings[chunk.ID] = embedding
+			}(chunk)
+		}
+		wg.Wait()
 
-			embeddings[chunk.ID] = embedding
+		if firstErr != nil {
+			return nil, firstErr
 		}
 
 		// Small delay between batches to be nice to the API
 		if end < len(chunks) {
-			time.Sleep(100 * time.Millisecond)
+			if err := sleepContext(ctx, 100*time.Millisecond); err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	return embeddings, nil
 }
 
-// CreateEmbeddingFunc creates an embedding function for chromem-go
+// embedChunkWithRetry embeds a single chunk, retrying with backoff on transient failures
+func embedChunkWithRetry(ctx context.Context, chunk DocumentChunk, config Config, cache *EmbeddingCache, maxRetries int) ([]float32, error) {
+	text := chunkEmbeddingText(chunk, config)
+	if embedding, ok := cache.Get(text, config); ok {
+		return embedding, nil
+	}
+
+	var embedding []float32
+	var err error
+
+	for retry := 0; retry < maxRetries; retry++ {
+		embedding, err = GetEmbedding(ctx, text, config)
+		if err == nil {
+			break
+		}
+		// A cancelled or expired context fails every retry the same way
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !isTransientEmbeddingError(err) {
+			return nil, fmt.Errorf("failed to get embedding for chunk %s: %w", chunk.ID, err)
+		}
+
+		if retry < maxRetries-1 {
+			progressReporter(config).warnf(chunk.FilePath, err, "  Retry %d/%d for chunk %s: %v", retry+1, maxRetries, chunk.ID, err)
+			if err := sleepContext(ctx, time.Duration(retry+1)*retryBackoff); err != nil { // Exponential backoff
+				return nil, err
+			}
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embedding for chunk %s after %d retries: %w", chunk.ID, maxRetries, err)
+	}
+
+	cache.Put(text, config, embedding)
+	return embedding, nil
+}
+
+// chunkEmbeddingText returns the text embedded for chunk. Under config.EmbedHeadings its heading
+// path is prepended, so that a chunk deep in a section matches queries about the section even
+// when its body never names it, and under config.ExtractTasks its tasks are appended; the stored
+// content is unaffected.
+func chunkEmbeddingText(chunk DocumentChunk, config Config) string {
+	content := chunk.Content
+	if config.EmbedHeadings && len(chunk.HeadingPath) > 0 {
+		content = strings.Join(chunk.HeadingPath, " > ") + "\n" + content
+	}
+	return EmbeddingText(withTaskText(content, chunk.Content, config), chunk.FilePath, config)
+}
+
+// EmbeddingText returns the text that is embedded for a document's content. The content is
+// normalized as configured by config.NormalizeText, matching how queries are embedded. When
+// config.EmbedPathWeight is positive the tokenized file name is prepended that many times
+// so that name-relevant queries surface short files; the stored content is unaffected.
+func EmbeddingText(content, filePath string, config Config) string {
+	content = NormalizeText(content, config.NormalizeText)
+	if config.EmbedPathWeight <= 0 {
+		return content
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	words := Tokenize(name)
+	if len(words) == 0 {
+		return content
+	}
+
+	title := strings.Join(words, " ")
+	var text strings.Builder
+	for i := 0; i < config.EmbedPathWeight; i++ {
+		text.WriteString(title)
+		text.WriteString("\n")
+	}
+	text.WriteString(content)
+	return text.String()
+}
+
+// getCachedEmbedding gets an embedding from the cache, falling back to the embedding API on a miss
+func getCachedEmbedding(ctx context.Context, text string, config Config, cache *EmbeddingCache) ([]float32, error) {
+	if embedding, ok := cache.Get(text, config); ok {
+		return embedding, nil
+	}
+	embedding, err := GetEmbedding(ctx, text, config)
+	if err != nil {
+		return nil, err
+	}
+	cache.Put(text, config, embedding)
+	return embedding, nil
+}
+
+// CreateEmbeddingFunc creates an embedding function for chromem-go. chromem-go calls it to
+// embed query text, so the vectors are kept in the in-process query embedding cache, sized by
+// config.QueryCacheSize, and a repeated query skips the embedding API.
 func CreateEmbeddingFunc(config Config) func(context.Context, string) ([]float32, error) {
 	return func(ctx context.Context, text string) ([]float32, error) {
-		return GetEmbedding(text, config)
+		text = NormalizeText(text, config.NormalizeText)
+		if embedding, ok := queryEmbeddings.get(text, config); ok {
+			return embedding, nil
+		}
+		embedding, err := GetEmbedding(ctx, text, config)
+		if err != nil {
+			return nil, err
+		}
+		queryEmbeddings.put(text, config, embedding)
+		return embedding, nil
+	}
+}
+
+// sleepContext waits for d, returning early with the context's error if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
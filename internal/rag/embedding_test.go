@@ -0,0 +1,143 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetEmbeddingOpenAIMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			http.Error(w, "bad auth: "+got, http.StatusUnauthorized)
+			return
+		}
+		var req OpenAIEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "text-embed" || req.Input != "hello" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"data":[{"embedding":[0.1,0.2,0.3],"index":0}]}`))
+	}))
+	defer server.Close()
+
+	config := Config{
+		OllamaURL:      server.URL,
+		EmbeddingModel: "text-embed",
+		EmbeddingMode:  EmbeddingModeOpenAI,
+		OpenAIAPIKey:   "secret",
+	}
+
+	got, err := GetEmbedding(context.Background(), "hello", config)
+	if err != nil {
+		t.Fatalf("unexpected embedding error: %v", err)
+	}
+	want := []float32{0.1, 0.2, 0.3}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected embedding: got %v, want %v", got, want)
+	}
+}
+
+func TestGetEmbeddingRejectsUnknownMode(t *testing.T) {
+	_, err := GetEmbedding(context.Background(), "hello", Config{EmbeddingMode: "bogus"})
+	if err == nil {
+		t.Fatalf("expected an error for an unknown embedding mode")
+	}
+}
+
+func TestBatchEmbedChunksConcurrently(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.Concurrency = 4
+
+	var chunks []DocumentChunk
+	for i := 0; i < 25; i++ {
+		chunks = append(chunks, DocumentChunk{
+			ID:      fmt.Sprintf("hash_%d", i),
+			Content: fmt.Sprintf("chunk number %d", i),
+		})
+	}
+
+	embeddings, err := BatchEmbedChunks(context.Background(), chunks, config, nil)
+	if err != nil {
+		t.Fatalf("unexpected embedding error: %v", err)
+	}
+	if len(embeddings) != len(chunks) {
+		t.Fatalf("unexpected embedding count: got %d, want %d", len(embeddings), len(chunks))
+	}
+	for _, chunk := range chunks {
+		want := testEmbedding(chunk.Content, 8)
+		if !reflect.DeepEqual(embeddings[chunk.ID], want) {
+			t.Fatalf("embedding for %s does not match its content", chunk.ID)
+		}
+	}
+}
+
+func TestGetOllamaEmbeddingReadsStreamedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		// A status object, then progress objects each repeating the full embedding, with one
+		// object split across writes
+		for _, part := range []string{
+			`{"status":"loading model"}` + "\n",
+			`{"embedding":[0.1,`,
+			`0.2,0.3]}` + "\n",
+			`{"embedding":[0.1,0.2,0.3],"done":true}` + "\n",
+		} {
+			w.Write([]byte(part))
+			flusher.Flush()
+		}
+	}))
+	defer server.Close()
+
+	got, err := GetEmbedding(context.Background(), "hello", Config{OllamaURL: server.URL, EmbeddingModel: "test-model"})
+	if err != nil {
+		t.Fatalf("unexpected embedding error: %v", err)
+	}
+	want := []float32{0.1, 0.2, 0.3}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected embedding: got %v, want %v", got, want)
+	}
+}
+
+func TestBatchEmbedChunksRetriesOnlyTransientErrors(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = backoff })
+
+	tests := []struct {
+		status       int
+		wantRequests int32
+	}{
+		{http.StatusBadRequest, 1},
+		{http.StatusNotFound, 1},
+		{http.StatusTooManyRequests, 3},
+		{http.StatusServiceUnavailable, 3},
+	}
+	for _, tt := range tests {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			http.Error(w, http.StatusText(tt.status), tt.status)
+		}))
+		config := Config{OllamaURL: server.URL, EmbeddingModel: "test-model"}
+		config.Progress = TextProgress(io.Discard, LogNormal)
+
+		_, err := BatchEmbedChunks(context.Background(), []DocumentChunk{{ID: "hash_0", Content: "hello"}}, config, nil)
+		server.Close()
+		var apiErr *EmbeddingAPIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+			t.Fatalf("status %d: expected the API error to be returned, got %v", tt.status, err)
+		}
+		if got := requests.Load(); got != tt.wantRequests {
+			t.Fatalf("status %d: expected %d request(s), got %d", tt.status, tt.wantRequests, got)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IndexEstimate projects the embedding work needed to index a folder
+type IndexEstimate struct {
+	Files  int
+	Chunks int // Embedding calls, one per chunk or per unchunked file
+	Tokens int
+	Bytes  int64
+	ETA    time.Duration
+}
+
+// EstimateIndex walks rootPath and chunks every file the way the indexer would, without
+// calling the embedding API. The ETA assumes every chunk is embedded, ignoring the cache and
+// unchanged files, and spreads embedTime per call across config.Concurrency workers.
+func EstimateIndex(rootPath string, config Config, embedTime time.Duration, maxTokensPerChunk, chunkOverlapPercent int, approxTokensPerChar float64) (*IndexEstimate, error) {
+	absRootPath, err := filepath.Abs(rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for %s: %w", rootPath, err)
+	}
+
+	mdFiles, err := findMarkdownFiles(absRootPath, config)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := NewTokenCounter(config, approxTokensPerChar)
+	progress := progressReporter(config)
+	estimate := &IndexEstimate{}
+	for _, filePath := range mdFiles {
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Printf("Warning: Could not read file %s: %v\n", filePath, err)
+			continue
+		}
+
+		chunks, tokens := planChunks(filePath, string(content), config, maxTokensPerChunk, chunkOverlapPercent, counter, progress)
+		estimate.Files++
+		estimate.Bytes += int64(len(content))
+		estimate.Chunks += chunks
+		estimate.Tokens += tokens
+	}
+
+	workers := max(1, config.Concurrency)
+	estimate.ETA = time.Duration(estimate.Chunks) * embedTime / time.Duration(workers)
+
+	return estimate, nil
+}
+
+// planChunks chunks a file the way the indexer would, returning the number of chunks it would
+// embed and their total tokens, reporting chunking progress to report. Frontmatter is not
+// embedded, so it does not count.
+func planChunks(filePath, content string, config Config, maxTokensPerChunk, chunkOverlapPercent int, counter TokenCounter, report ProgressFunc) (int, int) {
+	chunks, tokens := 0, 0
+	if isSourceFile(filePath, config) {
+		for _, chunk := range sourceCommentChunks(filePath, content, "", maxTokensPerChunk, chunkOverlapPercent, config.HeadingMaxLevel, config.MaxHeadings, counter, report) {
+			chunks++
+			tokens += chunk.TokenCount
+		}
+		return chunks, tokens
+	}
+
+	frontmatter, bodyOffset := ParseFrontmatter(content)
+	body := content[bodyOffset:]
+	maxTokensPerChunk = frontmatter.chunkTokenLimit(maxTokensPerChunk)
+	if estimatedTokens := counter.CountTokens(body); estimatedTokens <= maxTokensPerChunk {
+		return 1, estimatedTokens
+	}
+
+	for _, chunk := range chunkDocument(filePath, body, "", maxTokensPerChunk, chunkOverlapPercent, config.HeadingMaxLevel, config.MaxHeadings, counter, report) {
+		chunks++
+		tokens += chunk.TokenCount
+	}
+	return chunks, tokens
+}
+
+// ShowEstimate prints the projected cost of indexing rootPath
+func ShowEstimate(rootPath string, config Config, embedTime time.Duration, maxTokensPerChunk, chunkOverlapPercent int, approxTokensPerChar float64) error {
+	estimate, err := EstimateIndex(rootPath, config, embedTime, maxTokensPerChunk, chunkOverlapPercent, approxTokensPerChar)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Index Estimate")
+	fmt.Println("==============")
+	fmt.Printf("Path: %s\n", rootPath)
+	fmt.Printf("Files: %s (%s)\n", FormatNumber(estimate.Files), FormatBytes(estimate.Bytes))
+	fmt.Printf("Embedding calls: %s\n", FormatNumber(estimate.Chunks))
+	fmt.Printf("Estimated tokens: %s\n", FormatNumber(estimate.Tokens))
+	fmt.Printf("Estimated time: %s (%s per embedding, %d concurrent)\n",
+		estimate.ETA.Round(time.Second), embedTime, max(1, config.Concurrency))
+	fmt.Println("Cached embeddings and unchanged files are not taken into account.")
+
+	return nil
+}
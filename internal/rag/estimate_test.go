@@ -0,0 +1,57 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateIndexTotals(t *testing.T) {
+	large := strings.Repeat("# Section\n\nSome paragraph text that repeats. ", 1000)
+	root := writeTestFiles(t, map[string]string{
+		"small.md":      "Twelve chars",
+		"docs/large.md": large,
+		"notes.txt":     "Not markdown.",
+	})
+	config := Config{Concurrency: 2}
+
+	estimate, err := EstimateIndex(root, config, time.Second, 4000, 15, 0.25)
+	if err != nil {
+		t.Fatalf("unexpected estimate error: %v", err)
+	}
+
+	chunks := ChunkDocument("large.md", large, "", 4000, 15, 0, 0, HeuristicTokenCounter{TokensPerChar: 0.25})
+	wantTokens := EstimateTokenCount("Twelve chars", 0.25)
+	for _, chunk := range chunks {
+		wantTokens += chunk.TokenCount
+	}
+
+	if estimate.Files != 2 {
+		t.Fatalf("unexpected file count: got %d, want 2", estimate.Files)
+	}
+	if estimate.Chunks != len(chunks)+1 {
+		t.Fatalf("unexpected chunk count: got %d, want %d", estimate.Chunks, len(chunks)+1)
+	}
+	if estimate.Tokens != wantTokens {
+		t.Fatalf("unexpected token count: got %d, want %d", estimate.Tokens, wantTokens)
+	}
+	if want := time.Duration(estimate.Chunks) * time.Second / 2; estimate.ETA != want {
+		t.Fatalf("unexpected ETA: got %s, want %s", estimate.ETA, want)
+	}
+}
+
+func TestEstimateIndexQuietOmitsChunkingProgress(t *testing.T) {
+	root := writeTestFiles(t, map[string]string{
+		"large.md": strings.Repeat("# Section\n\nSome paragraph text that repeats. ", 1000),
+	})
+	config := Config{LogLevel: LogQuiet}
+
+	output := captureStdout(t, func() {
+		if _, err := EstimateIndex(root, config, time.Second, 4000, 15, 0.25); err != nil {
+			t.Fatalf("unexpected estimate error: %v", err)
+		}
+	})
+	if output != "" {
+		t.Fatalf("expected no chunking progress with -quiet, got:\n%s", output)
+	}
+}
@@ -0,0 +1,111 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// ChunkExplanation is a stored chunk as retrieval sees it, for debugging why it matched a query
+type ChunkExplanation struct {
+	ID         string
+	FilePath   string
+	Content    string
+	Metadata   map[string]string
+	Query      string
+	Similarity float32 // Cosine similarity of the chunk to Query, 0 when no query was given
+}
+
+// MCPExplainChunk looks up a stored chunk by chunkID, or by filePath and chunkIndex when chunkID is
+// empty, and when query is not empty embeds it to compute the chunk's similarity to it. The
+// similarity is the raw vector score, before any hybrid blending, boosts, or re-ranking.
+func MCPExplainChunk(config Config, chunkID, filePath string, chunkIndex int, query string) (*ChunkExplanation, error) {
+	collection, err := openCollection(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc chromem.Document
+	if chunkID != "" {
+		doc, err = collection.GetByID(context.Background(), chunkID)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %q not found; it may have been re-indexed since the search, so run rag_search again for current chunk IDs", chunkID)
+		}
+	} else {
+		doc, err = findChunkByIndex(collection, filePath, chunkIndex, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := []chromem.Result{{ID: doc.ID, Metadata: doc.Metadata, Content: doc.Content}}
+	resolveResultPaths(results, config)
+	explanation := &ChunkExplanation{
+		ID:       doc.ID,
+		FilePath: results[0].Metadata["file_path"],
+		Content:  doc.Content,
+		Metadata: results[0].Metadata,
+		Query:    query,
+	}
+	if query == "" {
+		return explanation, nil
+	}
+
+	queryEmbedding, err := CreateEmbeddingFunc(config)(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	explanation.Similarity, err = CosineSimilarity(queryEmbedding, doc.Embedding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare the query with chunk %s (was it indexed with another embedding model?): %w", doc.ID, err)
+	}
+	return explanation, nil
+}
+
+// findChunkByIndex returns the stored document of filePath with the given chunk_index; a file
+// indexed as a single document is chunk 0
+func findChunkByIndex(collection *chromem.Collection, filePath string, chunkIndex int, config Config) (chromem.Document, error) {
+	results, err := allDocuments(context.Background(), collection)
+	if err != nil {
+		return chromem.Document{}, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	filePath = resolveFilePath(filePath, config)
+	index := strconv.Itoa(chunkIndex)
+	for _, result := range results {
+		if resolveFilePath(result.Metadata["file_path"], config) == filePath && result.Metadata["chunk_index"] == index {
+			return chromem.Document{ID: result.ID, Metadata: result.Metadata, Embedding: result.Embedding, Content: result.Content}, nil
+		}
+	}
+	return chromem.Document{}, fmt.Errorf("no chunk %d of %s in the index; rag_list shows indexed files and their chunk counts", chunkIndex, filePath)
+}
+
+// formatExplanation renders an explained chunk with its similarity, metadata, and full content
+func formatExplanation(explanation *ChunkExplanation) string {
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("**Chunk ID:** `%s`\n", explanation.ID))
+	response.WriteString(fmt.Sprintf("**File:** `%s`\n", explanation.FilePath))
+	if explanation.Query != "" {
+		response.WriteString(fmt.Sprintf("**Similarity to \"%s\":** %.4f (vector score, before hybrid blending, boosts, or re-ranking)\n", explanation.Query, explanation.Similarity))
+	}
+
+	response.WriteString("\n**Metadata:**\n")
+	keys := make([]string, 0, len(explanation.Metadata))
+	for key := range explanation.Metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		response.WriteString(fmt.Sprintf("- %s: %s\n", key, explanation.Metadata[key]))
+	}
+
+	response.WriteString(fmt.Sprintf("\n**Content (%d characters, as stored):**\n", len(explanation.Content)))
+	response.WriteString("```markdown\n")
+	response.WriteString(explanation.Content)
+	response.WriteString("\n```")
+	return response.String()
+}
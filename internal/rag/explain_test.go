@@ -0,0 +1,47 @@
+package rag
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestMCPExplainChunkReportsContentMetadataAndSimilarity(t *testing.T) {
+	config := newTestConfig(t, 16)
+	guide := testDocument("/docs/guide.md", "aaaa1111", "Install the widget before configuring it.", 16)
+	faq := testDocument("/docs/faq.md", "bbbb2222", "Widgets ship with a default configuration.", 16)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{guide, faq})
+
+	query := "install widget"
+	explanation, err := MCPExplainChunk(config, guide.ID, "", 0, query)
+	if err != nil {
+		t.Fatalf("unexpected explain error: %v", err)
+	}
+	if explanation.Content != guide.Content || explanation.FilePath != "/docs/guide.md" || explanation.Metadata["file_hash"] != "aaaa1111" {
+		t.Fatalf("expected the stored guide chunk, got %+v", explanation)
+	}
+	want, err := CosineSimilarity(testEmbedding(query, 16), testEmbedding(guide.Content, 16))
+	if err != nil {
+		t.Fatalf("unexpected similarity error: %v", err)
+	}
+	if math.Abs(float64(explanation.Similarity-want)) > 1e-5 {
+		t.Fatalf("expected similarity %.4f, got %.4f", want, explanation.Similarity)
+	}
+
+	byIndex, err := MCPExplainChunk(config, "", "/docs/faq.md", 0, "")
+	if err != nil {
+		t.Fatalf("unexpected explain error: %v", err)
+	}
+	if byIndex.ID != faq.ID || byIndex.Similarity != 0 {
+		t.Fatalf("expected the faq chunk without a similarity, got %+v", byIndex)
+	}
+	if response := formatExplanation(byIndex); strings.Contains(response, "Similarity") || !strings.Contains(response, "- file_hash: bbbb2222") {
+		t.Fatalf("unexpected explanation:\n%s", response)
+	}
+
+	if _, err := MCPExplainChunk(config, "", "/docs/faq.md", 3, ""); err == nil || !strings.Contains(err.Error(), "no chunk 3 of /docs/faq.md") {
+		t.Fatalf("expected a clear error for a missing chunk index, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExportText writes the indexed content of every file to a plain text file under dir, one
+// chunk after another with its position and heading path in a comment, so what was indexed can
+// be read and grepped without the tool. Each export is named after the indexed file's stored path
+// with .txt appended; existing files in dir are overwritten but never removed.
+func ExportText(dir string, config Config) error {
+	report := progressReporter(config)
+	report.infof("Exporting indexed text to: %s", dir)
+	report.infof("Using database: %s", config.DBPath)
+
+	files, err := MCPListDocuments(config, "", 0)
+	if err != nil {
+		return err
+	}
+
+	exported, chunks := 0, 0
+	for _, inventory := range files {
+		path, err := exportTextPath(dir, inventory.FilePath)
+		if err != nil {
+			report.warnf(inventory.FilePath, err, "Warning: Not exporting %s: %v", inventory.FilePath, err)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(path, []byte(formatExportedText(inventory)), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		exported++
+		chunks += len(inventory.Chunks)
+	}
+
+	report.infof("✓ Exported %d chunks of %d files", chunks, exported)
+	return nil
+}
+
+// exportTextPath returns where the text of the indexed file at filePath is exported under dir.
+// Absolute paths are nested under dir as if it were the filesystem root.
+func exportTextPath(dir, filePath string) (string, error) {
+	rel := strings.TrimLeft(filepath.FromSlash(filePath[len(filepath.VolumeName(filePath)):]), string(filepath.Separator))
+	rel = filepath.Clean(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the export directory", filePath)
+	}
+	return filepath.Join(dir, rel) + ".txt", nil
+}
+
+// formatExportedText renders the chunks of an indexed file in chunk order, each after a comment
+// with its index, character range, and heading path
+func formatExportedText(inventory FileInventory) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("<!-- Indexed from %s (file hash %s, %d chunks) -->\n", inventory.FilePath, inventory.FileHash, len(inventory.Chunks)))
+	for _, chunk := range inventory.Chunks {
+		header := fmt.Sprintf("chunk %s", chunk.Metadata["chunk_index"])
+		if start, end := chunk.Metadata["start_offset"], chunk.Metadata["end_offset"]; start != "" && end != "" {
+			header += fmt.Sprintf(", characters %s-%s", start, end)
+		}
+		if headingPath := chunk.Metadata["heading_path"]; headingPath != "" {
+			header += ", heading: " + headingPath
+		}
+		text.WriteString(fmt.Sprintf("\n<!-- %s -->\n", header))
+		text.WriteString(chunk.Content)
+		if !strings.HasSuffix(chunk.Content, "\n") {
+			text.WriteString("\n")
+		}
+	}
+	return text.String()
+}
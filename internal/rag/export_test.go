@@ -0,0 +1,50 @@
+package rag
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestExportTextWritesChunksWithHeadingComments(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.Progress = TextProgress(io.Discard, LogNormal)
+	second := statsTestChunk("/docs/guide.md", "aaaa1111", 300, 1)
+	second.Content = "Run the installer."
+	second.Metadata["heading_path"] = "Guide > Install"
+	second.Metadata["start_offset"] = "120"
+	second.Metadata["end_offset"] = "138"
+	first := statsTestChunk("/docs/guide.md", "aaaa1111", 300, 0)
+	first.Content = "# Guide\n"
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		second,
+		first,
+		statsTestChunk("../outside.md", "bbbb2222", 10, 0),
+	})
+
+	dir := t.TempDir()
+	if err := ExportText(dir, config); err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	exported, err := os.ReadFile(filepath.Join(dir, "docs", "guide.md.txt"))
+	if err != nil {
+		t.Fatalf("expected the guide to be exported: %v", err)
+	}
+	text := string(exported)
+	want := "<!-- chunk 1, characters 120-138, heading: Guide > Install -->\nRun the installer.\n"
+	if !strings.HasPrefix(text, "<!-- Indexed from /docs/guide.md (file hash aaaa1111, 2 chunks) -->") || !strings.HasSuffix(text, want) {
+		t.Fatalf("unexpected export:\n%s", text)
+	}
+	if strings.Index(text, "# Guide") > strings.Index(text, "Run the installer.") {
+		t.Fatalf("expected chunks in chunk order:\n%s", text)
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "outside.md.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected a path escaping the export directory to be skipped, got %v", err)
+	}
+}
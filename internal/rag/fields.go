@@ -0,0 +1,134 @@
+package rag
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultFields are the metadata keys of each search result or listed chunk in -json output when
+// -fields is not given; text output then keeps its fixed layout
+const DefaultFields = "chunk_index,start_offset,end_offset,token_count,heading_path,is_chunk"
+
+// fieldLabels are the names metadata keys are shown under in text output; other keys are shown
+// as-is
+var fieldLabels = map[string]string{
+	"chunk_index":     "Chunk",
+	"start_offset":    "Start Offset",
+	"end_offset":      "End Offset",
+	"start_line":      "Start Line",
+	"end_line":        "End Line",
+	"token_count":     "Tokens",
+	"heading_path":    "Context",
+	"is_chunk":        "Chunked",
+	"source_comment":  "Source Comment",
+	"file_hash":       "File Hash",
+	"file_size":       "Size (bytes)",
+	"last_modified":   "Last Modified",
+	"indexed_at":      "Indexed",
+	"title":           "Title",
+	"tags":            "Tags",
+	"region":          "Region",
+	"open_tasks":      "Open Tasks",
+	"done_tasks":      "Done Tasks",
+	"task_text":       "Tasks",
+	"embedding_mode":  "Embedding Mode",
+	"embedding_model": "Embedding Model",
+}
+
+// numericFields and booleanFields are emitted as JSON numbers and booleans rather than strings
+var (
+	numericFields = map[string]bool{"chunk_index": true, "start_offset": true, "end_offset": true, "start_line": true, "end_line": true, "token_count": true, "file_size": true, "open_tasks": true, "done_tasks": true}
+	booleanFields = map[string]bool{"is_chunk": true, "source_comment": true}
+)
+
+// fileLevelFields describe a whole file, so file listings show them once per file
+var fileLevelFields = map[string]bool{"file_hash": true, "file_size": true, "last_modified": true, "embedding_mode": true, "embedding_model": true}
+
+// ParseFields splits a comma-separated list of metadata keys, dropping blanks and duplicates.
+// file_path identifies every result, so it is always shown and is dropped here.
+func ParseFields(list string) []string {
+	var fields []string
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || field == "file_path" || slices.Contains(fields, field) {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// outputFields returns the metadata keys configured for output, or the defaults
+func outputFields(config Config) []string {
+	if len(config.Fields) > 0 {
+		return config.Fields
+	}
+	return ParseFields(DefaultFields)
+}
+
+// metadataField is a selected metadata key and its stored value
+type metadataField struct {
+	Key    string
+	Value  string
+	Absent bool // The document has no value stored under Key
+}
+
+// Label returns the name the field is shown under in text output
+func (f metadataField) Label() string {
+	if label, ok := fieldLabels[f.Key]; ok {
+		return label
+	}
+	return f.Key
+}
+
+// selectFields returns the selected keys of metadata in the order they were selected, marking
+// those the document has no value for as absent
+func selectFields(metadata map[string]string, fields []string) []metadataField {
+	selected := make([]metadataField, 0, len(fields))
+	for _, key := range fields {
+		value, ok := metadata[key]
+		selected = append(selected, metadataField{Key: key, Value: value, Absent: !ok})
+	}
+	return selected
+}
+
+// formatFieldsInline renders the non-empty fields as "Label: value" pairs on one line
+func formatFieldsInline(fields []metadataField) string {
+	var parts []string
+	for _, field := range fields {
+		if field.Value != "" {
+			parts = append(parts, field.Label()+": "+field.Value)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// jsonValue returns the value a field is emitted with in JSON output, null when it is absent
+func (f metadataField) jsonValue() any {
+	if f.Absent {
+		return nil
+	}
+	if numericFields[f.Key] {
+		if n, err := strconv.ParseInt(f.Value, 10, 64); err == nil {
+			return n
+		}
+	}
+	if booleanFields[f.Key] {
+		return f.Value == "true"
+	}
+	return f.Value
+}
+
+// marshalJSONValue encodes v without escaping HTML characters, matching writeJSON
+func marshalJSONValue(buf *bytes.Buffer, v any) error {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // Encode appends a newline
+	return nil
+}
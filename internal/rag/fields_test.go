@@ -0,0 +1,115 @@
+package rag
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestParseFields(t *testing.T) {
+	got := ParseFields(" tags, file_path,,region,tags ")
+	want := []string{"tags", "region"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected fields: got %v, want %v", got, want)
+	}
+}
+
+func TestSearchDocumentsShowsSelectedFields(t *testing.T) {
+	config := newTestConfig(t, 16)
+	doc := testDocument("/docs/guide.md", "aaaa1111", "oauth provider setup", 16)
+	doc.Metadata["heading_path"] = "Guide > Setup"
+	doc.Metadata["tags"] = "auth,setup"
+	doc.Metadata["token_count"] = "42"
+	doc.Metadata["indexed_at"] = "2024-01-02T03:04:05Z"
+	writeTestDatabase(t, config.DBPath, []chromem.Document{doc})
+	config.Fields = []string{"tags", "token_count", "heading_path"}
+
+	text := captureStdout(t, func() {
+		if err := SearchDocuments("oauth provider setup", config); err != nil {
+			t.Fatalf("unexpected search error: %v", err)
+		}
+	})
+	for _, want := range []string{"File: /docs/guide.md", "Tags: auth,setup", "Tokens: 42", "Context: Guide > Setup"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %q in output:\n%s", want, text)
+		}
+	}
+	for _, unwanted := range []string{"Indexed", "Chunk", "File Hash"} {
+		if strings.Contains(text, unwanted) {
+			t.Fatalf("expected no %q in output:\n%s", unwanted, text)
+		}
+	}
+
+	config.JSON = true
+	output := captureStdout(t, func() {
+		if err := SearchDocuments("oauth provider setup", config); err != nil {
+			t.Fatalf("unexpected search error: %v", err)
+		}
+	})
+	var results struct {
+		Results []map[string]any `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(output), &results); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, output)
+	}
+	if len(results.Results) != 1 {
+		t.Fatalf("expected one result, got %d", len(results.Results))
+	}
+	want := map[string]any{
+		"file_path":    "/docs/guide.md",
+		"similarity":   results.Results[0]["similarity"],
+		"tags":         "auth,setup",
+		"token_count":  float64(42),
+		"heading_path": "Guide > Setup",
+	}
+	if !reflect.DeepEqual(results.Results[0], want) {
+		t.Fatalf("unexpected JSON result:\n got %v\nwant %v", results.Results[0], want)
+	}
+	if !strings.Contains(output, `"tags": "auth,setup",`) || strings.Index(output, `"tags"`) > strings.Index(output, `"heading_path"`) {
+		t.Fatalf("expected fields in the selected order:\n%s", output)
+	}
+}
+
+func TestSearchDocumentsDefaultLayoutWithoutFields(t *testing.T) {
+	config := newTestConfig(t, 16)
+	doc := testDocument("/docs/guide.md", "aaaa1111", "oauth provider setup", 16)
+	doc.Metadata["is_chunk"] = "true"
+	doc.Metadata["chunk_index"] = "2"
+	doc.Metadata["token_count"] = "42"
+	doc.Metadata["heading_path"] = "Guide > Setup"
+	doc.Metadata["start_offset"] = "100"
+	doc.Metadata["end_offset"] = "200"
+	doc.Metadata["file_size"] = "512"
+	writeTestDatabase(t, config.DBPath, []chromem.Document{doc})
+
+	text := captureStdout(t, func() {
+		if err := SearchDocuments("oauth provider setup", config); err != nil {
+			t.Fatalf("unexpected search error: %v", err)
+		}
+	})
+	for _, want := range []string{
+		"File: /docs/guide.md (chunk 2, 42 tokens, context: Guide > Setup)",
+		"   Size: 512 bytes",
+		"   Chunk Range: chars 100-200",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %q in output:\n%s", want, text)
+		}
+	}
+}
+
+func TestJSONChunkEmitsAbsentFieldsAsNull(t *testing.T) {
+	chunk := toJSONChunk(map[string]string{"file_path": "/docs/guide.md", "chunk_index": "0"}, []string{"chunk_index", "tags", "token_count"})
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	want := `{"file_path":"/docs/guide.md","chunk_index":0,"tags":null,"token_count":null}`
+	if string(data) != want {
+		t.Fatalf("unexpected JSON:\ngot  %s\nwant %s", data, want)
+	}
+}
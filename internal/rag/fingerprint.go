@@ -0,0 +1,158 @@
+package rag
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Collection metadata keys identifying the embeddings a database was built with
+const (
+	fingerprintModeKey      = "embedding_mode"
+	fingerprintModelKey     = "embedding_model"
+	fingerprintDimensionKey = "embedding_dimension"
+)
+
+// fingerprintProbeText is embedded once when a collection is created to learn the vector dimension
+const fingerprintProbeText = "embedding dimension probe"
+
+// embeddingFingerprint returns the collection metadata recording the embedding mode, model, and
+// vector dimension of config; a dimension of zero is left out
+func embeddingFingerprint(config Config, dimension int) map[string]string {
+	fingerprint := map[string]string{
+		fingerprintModeKey:  embeddingModeName(config),
+		fingerprintModelKey: config.EmbeddingModel,
+	}
+	if dimension > 0 {
+		fingerprint[fingerprintDimensionKey] = strconv.Itoa(dimension)
+	}
+	return fingerprint
+}
+
+// embeddingModeName returns the embedding mode of config, resolving the empty default to Ollama
+func embeddingModeName(config Config) string {
+	if config.EmbeddingMode == "" {
+		return EmbeddingModeOllama
+	}
+	return config.EmbeddingMode
+}
+
+// createFingerprintedCollection creates the documents collection with the embedding fingerprint
+// and chunk ID scheme of config. A dimension of 0, when the caller has not embedded anything yet,
+// comes from embedding a probe text; when that fails the collection is still created, recording
+// only the mode and model.
+func createFingerprintedCollection(ctx context.Context, db *chromem.DB, config Config, embeddingFunc chromem.EmbeddingFunc, dimension int) (*chromem.Collection, error) {
+	if dimension == 0 {
+		if probe, err := embeddingFunc(ctx, fingerprintProbeText); err != nil {
+			progressReporter(config).warnf("", err, "Warning: Could not determine embedding dimension: %v", err)
+		} else {
+			dimension = len(probe)
+		}
+	}
+
+	metadata := embeddingFingerprint(config, dimension)
+	metadata[chunkIDsKey] = chunkIDScheme(config)
+	collection, err := db.CreateCollection("documents", metadata, embeddingFunc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create collection: %w", err)
+	}
+	return collection, nil
+}
+
+// decodeDatabase decodes a database exported by chromem into persisted, a struct mirroring the
+// parts of chromem's export format the caller needs. chromem does not expose everything it stores
+// once loaded, such as collection metadata; fields missing from persisted are skipped.
+func decodeDatabase(reader io.ReadSeeker, persisted any) error {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		return err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var stream io.Reader = reader
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		stream = gzipReader
+	}
+	return gob.NewDecoder(stream).Decode(persisted)
+}
+
+// readCollectionMetadata returns the metadata of the documents collection in a database exported
+// by chromem, or nil when the collection has none
+func readCollectionMetadata(reader io.ReadSeeker) (map[string]string, error) {
+	var persisted struct {
+		Collections map[string]*struct {
+			Metadata map[string]string
+		}
+	}
+	if err := decodeDatabase(reader, &persisted); err != nil {
+		return nil, fmt.Errorf("failed to read database metadata: %w", err)
+	}
+	if collection := persisted.Collections["documents"]; collection != nil {
+		return collection.Metadata, nil
+	}
+	return nil, nil
+}
+
+// checkEmbeddingFingerprint returns an error when a collection fingerprinted with metadata was
+// indexed with a different embedding mode or model than config. Collections from before
+// fingerprints were recorded have none and always pass. action names what the caller is doing
+// for the error message, such as "querying".
+func checkEmbeddingFingerprint(metadata map[string]string, config Config, action string) error {
+	mode, model := metadata[fingerprintModeKey], metadata[fingerprintModelKey]
+	if mode == "" && model == "" {
+		return nil
+	}
+	if mode != embeddingModeName(config) || model != config.EmbeddingModel {
+		return fmt.Errorf("database was indexed with mode=%s/model=%s, but you're %s with mode=%s/model=%s",
+			mode, model, action, embeddingModeName(config), config.EmbeddingModel)
+	}
+	return nil
+}
+
+// fingerprintedEmbeddingFunc wraps embeddingFunc so that vectors whose dimension differs from the
+// one recorded in metadata are rejected instead of being compared against incompatible vectors
+func fingerprintedEmbeddingFunc(metadata map[string]string, config Config, embeddingFunc chromem.EmbeddingFunc) chromem.EmbeddingFunc {
+	dimension, err := strconv.Atoi(metadata[fingerprintDimensionKey])
+	if err != nil || dimension <= 0 {
+		return embeddingFunc
+	}
+	return func(ctx context.Context, text string) ([]float32, error) {
+		embedding, err := embeddingFunc(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		if len(embedding) != dimension {
+			return nil, fmt.Errorf("database was indexed with %d-dimensional vectors, but mode=%s/model=%s returns %d dimensions",
+				dimension, embeddingModeName(config), config.EmbeddingModel, len(embedding))
+		}
+		return embedding, nil
+	}
+}
+
+// verifiedEmbeddingFunc checks the embedding fingerprint of the database in file against config
+// and returns the embedding function to query it with
+func verifiedEmbeddingFunc(file io.ReadSeeker, config Config) (chromem.EmbeddingFunc, error) {
+	metadata, err := readCollectionMetadata(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEmbeddingFingerprint(metadata, config, "querying"); err != nil {
+		return nil, err
+	}
+	return fingerprintedEmbeddingFunc(metadata, config, CreateEmbeddingFunc(config)), nil
+}
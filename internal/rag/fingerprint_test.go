@@ -0,0 +1,57 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestEmbeddingFingerprintRejectsMismatchedQueries(t *testing.T) {
+	config := newTestConfig(t, 8)
+	docsDir := writeTestFiles(t, map[string]string{
+		"guide.md": "# Guide\n\nHow to configure the widget.\n",
+	})
+	captureStdout(t, func() {
+		if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+			t.Fatalf("unexpected indexing error: %v", err)
+		}
+	})
+
+	if _, err := MCPSearchDocumentsWithResults("widget", config, 5); err != nil {
+		t.Fatalf("matching fingerprint was rejected: %v", err)
+	}
+
+	otherModel := config
+	otherModel.EmbeddingModel = "other-model"
+	_, err := MCPSearchDocumentsWithResults("widget", otherModel, 5)
+	if err == nil || !strings.Contains(err.Error(), "indexed with mode=ollama/model=test-model, but you're querying with mode=ollama/model=other-model") {
+		t.Fatalf("expected model mismatch error, got %v", err)
+	}
+	captureStdout(t, func() {
+		err = IndexDocuments(docsDir, otherModel, 200, 15, 0.25)
+	})
+	if err == nil || !strings.Contains(err.Error(), "but you're indexing with") {
+		t.Fatalf("expected indexing with another model to be refused, got %v", err)
+	}
+
+	// Same mode and model, but the server now returns vectors of another dimension
+	otherDimension := config
+	otherDimension.OllamaURL = newTestOllamaServer(t, 16).URL
+	err = SearchDocuments("widget", otherDimension)
+	if err == nil || !strings.Contains(err.Error(), "indexed with 8-dimensional vectors") {
+		t.Fatalf("expected dimension mismatch error, got %v", err)
+	}
+}
+
+func TestEmbeddingFingerprintAllowsLegacyDatabases(t *testing.T) {
+	config := newTestConfig(t, 8)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/config.md", "hash1", "Configuration options for the server", 8),
+	})
+
+	config.EmbeddingModel = "any-model"
+	if _, err := MCPSearchDocumentsWithResults("configuration", config, 5); err != nil {
+		t.Fatalf("database without a fingerprint was rejected: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package rag
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Frontmatter holds the YAML or TOML frontmatter fields stored as document metadata, and the per-file
+// indexing settings read from rag_ keys
+type Frontmatter struct {
+	Title             string
+	Tags              []string
+	MaxTokensPerChunk int // From rag_max_tokens_per_chunk; 0 when absent or not a positive integer
+}
+
+// chunkTokenLimit returns the chunk size to index the file with: its rag_max_tokens_per_chunk
+// when set, and otherwise the global maxTokensPerChunk
+func (f Frontmatter) chunkTokenLimit(maxTokensPerChunk int) int {
+	if f.MaxTokensPerChunk > 0 {
+		return f.MaxTokensPerChunk
+	}
+	return maxTokensPerChunk
+}
+
+// ParseFrontmatter parses a leading "---" YAML or "+++" TOML frontmatter block, returning its
+// title and tags and the byte offset where the body starts (0 when there is no frontmatter). Only
+// flat keys are read. In YAML, tags may be an inline list ([a, b]), a block list of "- a" items,
+// or a comma-separated string; in TOML, an array that may span lines, or a string. A leading
+// UTF-8 byte order mark is skipped along with the frontmatter, so the body never starts with one.
+func ParseFrontmatter(content string) (Frontmatter, int) {
+	bom := 0
+	if strings.HasPrefix(content, utf8BOM) {
+		bom = len(utf8BOM)
+	}
+	frontmatter, offset := parseFrontmatterBlock(content[bom:])
+	return frontmatter, bom + offset
+}
+
+// parseFrontmatterBlock parses the frontmatter block at the very start of content
+func parseFrontmatterBlock(content string) (Frontmatter, int) {
+	firstLine, rest, ok := strings.Cut(content, "\n")
+	delimiter := strings.TrimRight(firstLine, "\r")
+	if !ok || (delimiter != "---" && delimiter != "+++") {
+		return Frontmatter{}, 0
+	}
+	toml := delimiter == "+++"
+
+	var parser frontmatterParser
+	offset := len(firstLine) + 1
+	for rest != "" {
+		line, next, found := strings.Cut(rest, "\n")
+		rest = next
+		offset += len(line)
+		if found {
+			offset++
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == delimiter || (!toml && trimmed == "...") {
+			return parser.frontmatter, offset
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if toml {
+			parser.tomlLine(trimmed)
+		} else {
+			parser.yamlLine(line, trimmed)
+		}
+	}
+
+	// No closing delimiter, so this was not frontmatter
+	return Frontmatter{}, 0
+}
+
+// frontmatterParser collects the recognized fields of a frontmatter block line by line
+type frontmatterParser struct {
+	frontmatter Frontmatter
+	currentKey  string // YAML key the following block list items belong to
+	inTable     bool   // Past a TOML [table] header, whose keys are not top-level
+	inTags      bool   // Inside a TOML tags array spanning lines
+}
+
+// yamlLine reads one non-blank line of a YAML block
+func (p *frontmatterParser) yamlLine(line, trimmed string) {
+	// Block list items belong to the most recent key
+	if strings.HasPrefix(trimmed, "- ") {
+		if p.currentKey == "tags" {
+			p.frontmatter.Tags = appendTags(p.frontmatter.Tags, strings.TrimPrefix(trimmed, "- "))
+		}
+		return
+	}
+	if line[0] == ' ' || line[0] == '\t' {
+		return
+	}
+
+	key, value, ok := strings.Cut(trimmed, ":")
+	if !ok {
+		return
+	}
+	p.currentKey = strings.ToLower(strings.TrimSpace(key))
+	value = strings.TrimSpace(value)
+	if p.currentKey == "tags" {
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	}
+	p.set(p.currentKey, value)
+}
+
+// tomlLine reads one non-blank line of a TOML block
+func (p *frontmatterParser) tomlLine(trimmed string) {
+	if p.inTags {
+		items, closed := strings.CutSuffix(trimmed, "]")
+		p.frontmatter.Tags = appendTags(p.frontmatter.Tags, items)
+		p.inTags = !closed
+		return
+	}
+	if strings.HasPrefix(trimmed, "[") {
+		p.inTable = true
+		return
+	}
+	if p.inTable {
+		return
+	}
+
+	key, value, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return
+	}
+	key = strings.ToLower(unquoteYAML(strings.TrimSpace(key)))
+	value = strings.TrimSpace(value)
+	if key == "tags" && strings.HasPrefix(value, "[") {
+		items, closed := strings.CutSuffix(value[1:], "]")
+		p.frontmatter.Tags = appendTags(p.frontmatter.Tags, items)
+		p.inTags = !closed
+		return
+	}
+	p.set(key, stripTOMLComment(value))
+}
+
+// set stores the value of a recognized key, the same way for either format
+func (p *frontmatterParser) set(key, value string) {
+	switch key {
+	case "title":
+		p.frontmatter.Title = unquoteYAML(value)
+	case "tags":
+		p.frontmatter.Tags = appendTags(p.frontmatter.Tags, value)
+	case "rag_max_tokens_per_chunk":
+		if limit, err := strconv.Atoi(unquoteYAML(value)); err == nil && limit > 0 {
+			p.frontmatter.MaxTokensPerChunk = limit
+		}
+	}
+}
+
+// stripTOMLComment removes a trailing "# comment" from a TOML value outside of a quoted string
+func stripTOMLComment(value string) string {
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[:end+2]
+		}
+		return value
+	}
+	if before, _, found := strings.Cut(value, "#"); found {
+		return strings.TrimSpace(before)
+	}
+	return value
+}
+
+// appendTags appends the comma-separated tags in value, dropping quotes and blanks
+func appendTags(tags []string, value string) []string {
+	for _, tag := range strings.Split(value, ",") {
+		if tag = unquoteYAML(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// unquoteYAML strips matching single or double quotes from a scalar value
+func unquoteYAML(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// hasTag reports whether the comma-separated tags metadata contains tag (case-insensitive)
+func hasTag(tags, tag string) bool {
+	for _, t := range strings.Split(tags, ",") {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
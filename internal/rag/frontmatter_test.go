@@ -0,0 +1,169 @@
+package rag
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const frontmatterFixture = `---
+title: "OAuth Setup"
+author: Jane Doe
+tags:
+  - auth
+  - 'security'
+---
+# OAuth
+
+Configure the provider.
+`
+
+func TestParseFrontmatter(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		content    string
+		want       Frontmatter
+		wantOffset int
+	}{
+		{
+			name:       "block list",
+			content:    frontmatterFixture,
+			want:       Frontmatter{Title: "OAuth Setup", Tags: []string{"auth", "security"}},
+			wantOffset: len("---\ntitle: \"OAuth Setup\"\nauthor: Jane Doe\ntags:\n  - auth\n  - 'security'\n---\n"),
+		},
+		{
+			name:       "inline list",
+			content:    "---\ntitle: Guide\ntags: [a, \"b\"]\n---\nBody",
+			want:       Frontmatter{Title: "Guide", Tags: []string{"a", "b"}},
+			wantOffset: len("---\ntitle: Guide\ntags: [a, \"b\"]\n---\n"),
+		},
+		{
+			name:    "no frontmatter",
+			content: "# Heading\n\n---\n\nBody",
+		},
+		{
+			name:    "unclosed block",
+			content: "---\ntitle: Guide\nBody without a closing delimiter",
+		},
+		{
+			name:       "byte order mark and CRLF",
+			content:    "\uFEFF---\r\ntitle: Guide\r\ntags: [a]\r\n---\r\nBody",
+			want:       Frontmatter{Title: "Guide", Tags: []string{"a"}},
+			wantOffset: len("\uFEFF---\r\ntitle: Guide\r\ntags: [a]\r\n---\r\n"),
+		},
+		{
+			name:       "byte order mark only",
+			content:    "\uFEFF# Heading\r\n",
+			wantOffset: len("\uFEFF"),
+		},
+		{
+			name:       "chunk size override",
+			content:    "---\nrag_max_tokens_per_chunk: 50\n---\nBody",
+			want:       Frontmatter{MaxTokensPerChunk: 50},
+			wantOffset: len("---\nrag_max_tokens_per_chunk: 50\n---\n"),
+		},
+		{
+			name:       "toml",
+			content:    "+++\ntitle = \"OAuth Setup\" # shown in results\ntags = [\"auth\", 'security']\nrag_max_tokens_per_chunk = 50\n+++\nBody",
+			want:       Frontmatter{Title: "OAuth Setup", Tags: []string{"auth", "security"}, MaxTokensPerChunk: 50},
+			wantOffset: len("+++\ntitle = \"OAuth Setup\" # shown in results\ntags = [\"auth\", 'security']\nrag_max_tokens_per_chunk = 50\n+++\n"),
+		},
+		{
+			name:       "toml multi-line array and table",
+			content:    "+++\ntags = [\n  \"a\",\n  \"b\",\n]\n[params]\ntitle = \"Not the title\"\n+++\nBody",
+			want:       Frontmatter{Tags: []string{"a", "b"}},
+			wantOffset: len("+++\ntags = [\n  \"a\",\n  \"b\",\n]\n[params]\ntitle = \"Not the title\"\n+++\n"),
+		},
+		{
+			name:    "mismatched delimiters",
+			content: "+++\ntitle = \"Guide\"\n---\nBody",
+		},
+		{
+			name:       "invalid chunk size override",
+			content:    "---\nrag_max_tokens_per_chunk: small\n---\nBody",
+			wantOffset: len("---\nrag_max_tokens_per_chunk: small\n---\n"),
+		},
+	} {
+		got, offset := ParseFrontmatter(tc.content)
+		if !reflect.DeepEqual(got, tc.want) || offset != tc.wantOffset {
+			t.Errorf("%s: got %+v at offset %d, want %+v at offset %d", tc.name, got, offset, tc.want, tc.wantOffset)
+		}
+	}
+}
+
+func TestIndexDocumentsStoresFrontmatterAndFiltersByTag(t *testing.T) {
+	config := newTestConfig(t, 16)
+	root := writeTestFiles(t, map[string]string{
+		"oauth.md":    frontmatterFixture,
+		"provider.md": "Configure the provider.",
+	})
+
+	if err := IndexDocuments(root, config, 4000, 15, 0.25); err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+
+	var oauth map[string]string
+	var content string
+	for _, result := range readTestDatabase(t, config) {
+		if result.Metadata["file_path"] == filepath.Join(root, "oauth.md") {
+			oauth, content = result.Metadata, result.Content
+		}
+	}
+	if oauth["title"] != "OAuth Setup" || oauth["tags"] != "auth,security" {
+		t.Fatalf("unexpected frontmatter metadata: title %q, tags %q", oauth["title"], oauth["tags"])
+	}
+	if content != "# OAuth\n\nConfigure the provider.\n" {
+		t.Fatalf("expected frontmatter to be stripped from content, got %q", content)
+	}
+	if start, _ := strconv.Atoi(oauth["start_offset"]); frontmatterFixture[start:] != content {
+		t.Fatalf("expected start_offset to point at the body in the file, got %d", start)
+	}
+
+	config.TagFilter = "Security"
+	results, err := MCPSearchDocumentsWithResults("configure the provider", config, 10)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if len(results) != 1 || filepath.Base(results[0].FilePath) != "oauth.md" {
+		t.Fatalf("expected only the tagged document, got %v", results)
+	}
+}
+
+func TestIndexDocumentsFrontmatterOverridesChunkSize(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.LogLevel = LogQuiet
+	var table strings.Builder
+	table.WriteString("# Rates\n\n| Region | Rate | Notes |\n|---|---|---|\n")
+	for i := 0; i < 60; i++ {
+		table.WriteString("| region-" + strconv.Itoa(i) + " | " + strconv.Itoa(i*3) + " | standard shipping applies |\n")
+	}
+	root := writeTestFiles(t, map[string]string{
+		"default.md":  table.String(),
+		"override.md": "---\nrag_max_tokens_per_chunk: 60\n---\n" + table.String(),
+		"invalid.md":  "---\nrag_max_tokens_per_chunk: -5\n---\n" + table.String(),
+	})
+
+	captureStdout(t, func() {
+		if err := IndexDocuments(root, config, 200, 15, 0.25); err != nil {
+			t.Fatalf("unexpected indexing error: %v", err)
+		}
+	})
+
+	chunks := make(map[string]int)
+	largest := make(map[string]int)
+	for _, doc := range readTestDatabase(t, config) {
+		name := filepath.Base(doc.Metadata["file_path"])
+		chunks[name]++
+		tokens, _ := strconv.Atoi(doc.Metadata["token_count"])
+		largest[name] = max(largest[name], tokens)
+	}
+	if chunks["override.md"] <= chunks["default.md"] || largest["override.md"] > 60 {
+		t.Fatalf("expected the override to give smaller chunks: %d chunks of up to %d tokens, against %d of up to %d by default",
+			chunks["override.md"], largest["override.md"], chunks["default.md"], largest["default.md"])
+	}
+	if chunks["invalid.md"] != chunks["default.md"] {
+		t.Fatalf("expected an invalid override to fall back to the global size: %d chunks, against %d", chunks["invalid.md"], chunks["default.md"])
+	}
+}
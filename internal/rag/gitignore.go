@@ -0,0 +1,108 @@
+package rag
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ignoreRule is a single .gitignore pattern
+type ignoreRule struct {
+	segments []string // Pattern split on "/", relative to the directory holding the .gitignore
+	negate   bool     // "!" pattern that re-includes a previously ignored path
+	dirOnly  bool     // Trailing "/" pattern that only matches directories
+}
+
+// gitignoreMatcher applies .gitignore files hierarchically, with rules from deeper directories
+// taking precedence over rules from their parents
+type gitignoreMatcher struct {
+	mu    sync.RWMutex
+	rules map[string][]ignoreRule // Slash-separated directory relative to the root ("" for the root)
+}
+
+func newGitignoreMatcher() *gitignoreMatcher {
+	return &gitignoreMatcher{rules: make(map[string][]ignoreRule)}
+}
+
+// loadDir reads the .gitignore in dir, if any, recording its rules under relDir
+func (m *gitignoreMatcher) loadDir(dir, relDir string) error {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.rules[relDir] = rules
+	m.mu.Unlock()
+	return nil
+}
+
+// parseIgnoreRule parses one .gitignore line, returning false for blank lines and comments
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// "\#" and "\!" escape a leading special character
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A pattern containing a slash is anchored to its .gitignore; otherwise it matches at any depth
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	rule.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	return rule, true
+}
+
+// ignored reports whether the slash-separated path relative to the root is ignored. The last
+// matching rule wins, walking from the root's .gitignore down to the path's own directory.
+func (m *gitignoreMatcher) ignored(relPath string, isDir bool) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	segments := strings.Split(relPath, "/")
+	ignored := false
+	for depth := 0; depth < len(segments); depth++ {
+		dir := strings.Join(segments[:depth], "/")
+		for _, rule := range m.rules[dir] {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if matchSegments(rule.segments, segments[depth:]) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,47 @@
+package rag
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindMarkdownFilesRespectsGitignore(t *testing.T) {
+	root := writeTestFiles(t, map[string]string{
+		".gitignore":              "# build output\nvendor/\n*.gen.md\n/TODO.md\n",
+		"README.md":               "readme",
+		"TODO.md":                 "anchored to the root",
+		"vendor/lib/README.md":    "vendored",
+		"api.gen.md":              "generated",
+		"docs/.gitignore":         "drafts/\n!keep.gen.md\n",
+		"docs/TODO.md":            "not matched by the anchored root pattern",
+		"docs/guide.md":           "guide",
+		"docs/keep.gen.md":        "re-included by negation",
+		"docs/drafts/wip.md":      "draft",
+		"other/drafts/visible.md": "nested rule does not apply to siblings",
+	})
+
+	config := Config{RespectGitignore: true}
+	files, err := findMarkdownFiles(root, config)
+	if err != nil {
+		t.Fatalf("unexpected walk error: %v", err)
+	}
+
+	var want []string
+	for _, relPath := range []string{"README.md", "docs/TODO.md", "docs/guide.md", "docs/keep.gen.md", "other/drafts/visible.md"} {
+		want = append(want, filepath.Join(root, filepath.FromSlash(relPath)))
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("unexpected files:\ngot  %v\nwant %v", files, want)
+	}
+
+	// Disabling the option indexes everything
+	config.RespectGitignore = false
+	files, err = findMarkdownFiles(root, config)
+	if err != nil {
+		t.Fatalf("unexpected walk error: %v", err)
+	}
+	if len(files) != 9 {
+		t.Fatalf("expected every markdown file without gitignore handling, got %d", len(files))
+	}
+}
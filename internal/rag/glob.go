@@ -0,0 +1,64 @@
+package rag
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchGlob reports whether the slash-separated relative path matches pattern. Patterns use
+// path.Match syntax per segment, "**" matches any number of directories, and a pattern without
+// a slash matches the base name at any depth (so "CHANGELOG.md" matches "a/b/CHANGELOG.md").
+func MatchGlob(pattern, relPath string) bool {
+	pattern = strings.Trim(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+// matchSegments matches path segments against pattern segments, expanding "**"
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// "**" consumes zero or more segments
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// matchAnyGlob reports whether relPath matches any of the patterns
+func matchAnyGlob(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldIndexPath applies the configured include and exclude globs to a path relative to the
+// index root. Exclude patterns always win over include patterns, and excluded directories are
+// not descended into. Include patterns only restrict files.
+func shouldIndexPath(relPath string, isDir bool, config Config) bool {
+	if matchAnyGlob(config.Exclude, relPath) {
+		return false
+	}
+	if isDir || len(config.Include) == 0 {
+		return true
+	}
+	return matchAnyGlob(config.Include, relPath)
+}
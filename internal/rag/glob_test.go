@@ -0,0 +1,26 @@
+package rag
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"node_modules/**", "node_modules/pkg/README.md", true},
+		{"node_modules/**", "node_modules", true},
+		{"node_modules/**", "docs/node_modules/pkg/README.md", false},
+		{"**/node_modules/**", "docs/node_modules/pkg/README.md", true},
+		{"CHANGELOG.md", "CHANGELOG.md", true},
+		{"CHANGELOG.md", "packages/api/CHANGELOG.md", true},
+		{"docs/*.md", "docs/guide.md", true},
+		{"docs/*.md", "docs/api/guide.md", false},
+		{"docs/**/*.md", "docs/api/v1/guide.md", true},
+		{"*.draft.md", "notes/idea.draft.md", true},
+	} {
+		if got := MatchGlob(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Presentations of rag_search results
+const (
+	GroupByFile  = "file"  // Files ranked by their best match, with their matching chunks
+	GroupByChunk = "chunk" // A flat ranked list of chunks, regardless of file
+)
+
+// capChunksPerFile keeps at most maxPerFile results of each file, dropping the lower-ranked rest
+// so that one large file cannot crowd out the others. Results must be in rank order.
+func capChunksPerFile(results []SearchResult, maxPerFile int) []SearchResult {
+	if maxPerFile <= 0 {
+		return results
+	}
+	perFile := make(map[string]int)
+	kept := make([]SearchResult, 0, len(results))
+	for _, result := range results {
+		if perFile[result.FilePath] >= maxPerFile {
+			continue
+		}
+		perFile[result.FilePath]++
+		kept = append(kept, result)
+	}
+	return kept
+}
+
+// formatFlatSearchResponse formats rag_search results as a flat ranked list of chunks, with the
+// same optional snippets, provenance, and retrieval cost as formatSearchResponse
+func formatFlatSearchResponse(query string, results []SearchResult, snippetLength int, provenance, cost bool) string {
+	matches := 0
+	for _, result := range results {
+		if result.NeighborOf == "" {
+			matches++
+		}
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("Found %d relevant chunk(s) for query: \"%s\"\n\n", matches, query))
+	if cost {
+		response.WriteString(fmt.Sprintf("**Estimated retrieval cost:** ~%d tokens to retrieve every match\n\n", retrievalCost([]FileSearchResults{{Chunks: results}})))
+	}
+
+	cumulative := 0
+	rank := 0 // Of the matches, leaving out adjacent chunks
+	for _, result := range results {
+		if result.NeighborOf != "" {
+			// Adjacent chunks carry the similarity of their match, so they are not ranked
+			response.WriteString(fmt.Sprintf("**Context:** `%s`\n", result.FilePath))
+			response.WriteString(fmt.Sprintf("- **Adjacent to:** `%s`\n", result.NeighborOf))
+		} else {
+			rank++
+			response.WriteString(fmt.Sprintf("**Result %d:** `%s`\n", rank, result.FilePath))
+			response.WriteString(fmt.Sprintf("- **Similarity:** %.4f\n", result.Similarity))
+		}
+		response.WriteString(fmt.Sprintf("- **Chunk ID:** `%s`\n", result.ID))
+		if result.IsChunk {
+			response.WriteString(fmt.Sprintf("- **Range:** characters %d-%d (%d tokens)\n", result.StartOffset, result.EndOffset, result.TokenCount))
+		} else {
+			response.WriteString("- **Type:** Complete file\n")
+		}
+		if result.HeadingPath != "" {
+			response.WriteString(fmt.Sprintf("- **Context:** %s\n", result.HeadingPath))
+		}
+		if len(result.Duplicates) > 0 {
+			response.WriteString(fmt.Sprintf("- **Same content at:** %s\n", formatPathList(result.Duplicates)))
+		}
+		if provenance {
+			response.WriteString(fmt.Sprintf("- **Embedding:** %s\n", formatProvenance(result)))
+		}
+		if cost && result.NeighborOf == "" {
+			cumulative += result.TokenCount
+			response.WriteString(fmt.Sprintf("- **Retrieval cost:** ~%d tokens (cumulative ~%d)\n", result.TokenCount, cumulative))
+		}
+		if result.NeighborOf != "" {
+			response.WriteString("- **Content:**\n\n")
+			for _, line := range strings.Split(strings.TrimSpace(result.Content), "\n") {
+				response.WriteString("    " + line + "\n")
+			}
+		} else if snippetLength > 0 {
+			response.WriteString(fmt.Sprintf("- **Snippet:** %s\n", previewText(result.Content, snippetLength)))
+		}
+		response.WriteString("\n")
+	}
+
+	response.WriteString("**Next Steps:**\n")
+	response.WriteString("Use the `rag_retrieve` tool with a `chunk_id` to get the content of a result.\n")
+
+	return response.String()
+}
@@ -0,0 +1,55 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestMCPSearchDocumentsCapsChunksPerFile(t *testing.T) {
+	config := newTestConfig(t, 32)
+	config.NoDedup = true
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testChunk("/docs/big.md", "big_0", "oauth provider setup steps", 0, 1000, 32),
+		testChunk("/docs/big.md", "big_1", "oauth provider setup notes", 1000, 2000, 32),
+		testChunk("/docs/big.md", "big_2", "oauth provider setup checklist", 2000, 3000, 32),
+		testDocument("/docs/faq.md", "faq", "oauth questions", 32),
+		testDocument("/docs/other.md", "other", "gardening in spring", 32),
+	})
+
+	config.MaxChunksPerFile = 1
+	results, err := MCPSearchDocumentsWithResults("oauth provider setup", config, 3)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	var files []string
+	for _, result := range results {
+		files = append(files, result.FilePath)
+	}
+	if want := "/docs/big.md,/docs/faq.md,/docs/other.md"; strings.Join(files, ",") != want {
+		t.Fatalf("expected one result per file, got %v", files)
+	}
+}
+
+func TestFormatFlatSearchResponseRanksChunksAcrossFiles(t *testing.T) {
+	results := []SearchResult{
+		{ID: "big_2", FilePath: "/docs/big.md", Similarity: 0.9, IsChunk: true, ChunkIndex: 2, StartOffset: 2000, EndOffset: 3000, TokenCount: 250},
+		{ID: "faq", FilePath: "/docs/faq.md", Similarity: 0.8, TokenCount: 40},
+		{ID: "big_0", FilePath: "/docs/big.md", Similarity: 0.7, IsChunk: true, StartOffset: 0, EndOffset: 1000, TokenCount: 250},
+	}
+
+	response := formatFlatSearchResponse("oauth", results, 0, false, true)
+	if !strings.HasPrefix(response, "Found 3 relevant chunk(s) for query: \"oauth\"") {
+		t.Fatalf("unexpected header:\n%s", response)
+	}
+	order := []string{"**Result 1:** `/docs/big.md`", "`big_2`", "**Result 2:** `/docs/faq.md`", "**Type:** Complete file", "**Result 3:** `/docs/big.md`", "`big_0`", "cumulative ~540"}
+	last := -1
+	for _, want := range order {
+		i := strings.Index(response, want)
+		if i <= last {
+			t.Fatalf("expected %q after the previous entries in rank order:\n%s", want, response)
+		}
+		last = i
+	}
+}
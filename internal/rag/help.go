@@ -9,17 +9,139 @@ func ShowHelp(maxTokensPerChunk, chunkOverlapPercent, maxContextTokens int) {
 	fmt.Println("  - Automatic chunking of large files (>1000 tokens) with semantic boundaries")
 	fmt.Println("  - Structure-aware splitting at headings and sentence boundaries")
 	fmt.Println("  - 15% overlap between chunks for better context preservation")
-	fmt.Println("  - Batch embedding processing with retry logic")
+	fmt.Println("  - Concurrent batch embedding processing with retry logic")
+	fmt.Println("  - Persistent embedding cache so unchanged content is not re-embedded")
+	fmt.Println("  - YAML frontmatter title and tags stored as metadata instead of being embedded")
+	fmt.Println("  - Per-file chunk size with a rag_max_tokens_per_chunk frontmatter key")
+	fmt.Println("  - Portable databases that store file paths relative to a -root directory")
+	fmt.Println("  - Progress bar with an ETA when indexing in a terminal (-quiet or -verbose print lines)")
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  -index <path>              Index all .md files in the specified folder recursively")
-	fmt.Println("  -query <text>              Search for documents similar to the query text")
+	fmt.Println("  -estimate <path>           Report files, chunks, tokens, and time to index a folder, without embedding")
+	fmt.Println("  -estimate-embed-time <d>   Assumed time per embedding call for -estimate (default: 200ms)")
+	fmt.Println("  -include <glob>            Only index files matching the glob (repeatable)")
+	fmt.Println("  -exclude <glob>            Skip files and folders matching the glob (repeatable, wins over -include)")
+	fmt.Println("                             Globs match relative paths; ** spans folders, and a glob without")
+	fmt.Println("                             a slash matches the name at any depth (e.g. CHANGELOG.md)")
+	fmt.Println("  -extensions <list>         Comma-separated file extensions to index (default: .md)")
+	fmt.Println("  -source-extensions <list>  Also index the comments of these source files as markdown, e.g. .go,.py")
+	fmt.Println("                             (//, #, /* */, and docstrings; off by default)")
+	fmt.Println("  -walk-concurrency <n>      Read n directories at once when finding files, for huge or network")
+	fmt.Println("                             file systems (default: 0, sequential)")
+	fmt.Println("  -skip-hidden               Skip files and folders whose names begin with \".\", such as .git/ and")
+	fmt.Println("                             .github/ (default: true, include them with -skip-hidden=false)")
+	fmt.Println("  -respect-gitignore         Skip paths ignored by .gitignore files when indexing (default: true,")
+	fmt.Println("                             disable with -respect-gitignore=false)")
+	fmt.Println("  -index-timeout <duration>  Abort indexing after the duration with a nonzero exit, saving the")
+	fmt.Println("                             files indexed so far (default: 0, no limit)")
+	fmt.Println("  -since <duration|time>     With -index, only index files modified within the duration (e.g. 24h)")
+	fmt.Println("                             or since the timestamp (e.g. 2006-01-02), skipping older files")
+	fmt.Println("                             without reading them, e.g. for a nightly run")
+	fmt.Println("  -flush-interval <n>        Save the database and embedding cache every n files while indexing, so")
+	fmt.Println("                             an interrupted run resumes from there (default: 100, 0 for only at the end)")
+	fmt.Println("  -quiet                     Only print warnings, failed files, and the final summary when indexing")
+	fmt.Println("  -verbose                   Also print debugging detail when indexing, such as where and why")
+	fmt.Println("                             each chunk was split")
+	fmt.Println("  -dry-run                   With -index, list the files and chunks that would be indexed and")
+	fmt.Println("                             which are unchanged, without embedding or writing the database")
+	fmt.Println("  -watch                     After -index, watch the folder and re-index created, modified, and")
+	fmt.Println("                             deleted files until Ctrl+C, saving after each quiet period")
+	fmt.Println("  -watch-debounce <duration> Quiet period before -watch re-indexes and saves (default: 1s)")
+	fmt.Println("  -query <text>              Search for documents similar to the query text; - reads the query")
+	fmt.Println("                             from standard input, e.g. cat question.txt | ./rag -query -")
+	fmt.Println("  -offset <n>                Skip the n best -query results to see the next page of 10")
+	fmt.Println("  -hybrid                    Blend BM25 keyword scores with vector similarity when searching")
+	fmt.Println("  -hybrid-alpha <0-1>        Weight of vector similarity in hybrid search (default: 0.5)")
+	fmt.Println("  -heading-filter <text>     Only return chunks whose heading path contains the text")
+	fmt.Println("  -open-tasks                Only return chunks containing open task-list items (- [ ])")
+	fmt.Println("  -link-scheme <scheme>      Print search results as editor URIs, e.g. file:///path#L240-L260")
+	fmt.Println("  -min-similarity <score>    Drop CLI search results scoring below the score")
+	fmt.Println("  -prefer-region <region>    Boost chunks from the beginning, middle, or end of their document,")
+	fmt.Println("                             e.g. beginning to favor introductions for summary queries")
+	fmt.Println("  -recency-boost <0-1>       Favor recently modified documents: scores are multiplied by")
+	fmt.Println("                             1 - boost + boost * 0.5^(age in days / half-life) (default: 0, off)")
+	fmt.Println("  -recency-half-life <days>  Age at which the recency decay reaches one half (default: 30)")
+	fmt.Println("  -query-cache-size <n>      Query embeddings the MCP server keeps in memory, so an agent repeating")
+	fmt.Println("                             a query skips the embedding call (default: 256, 0 to disable)")
+	fmt.Println("  -mcp-min-similarity <n>    Default minimum score for rag_search when the caller omits")
+	fmt.Println("                             min_similarity; a min_similarity argument always overrides it")
+	fmt.Println("  -min-docs-for-search <n>   Make rag_search report the index as not ready until it holds n")
+	fmt.Println("                             documents, instead of ranking a tiny index (default: 1)")
+	fmt.Println("  -chunk-order <order>       Order chunks within a file in MCP results by position (default)")
+	fmt.Println("                             or by similarity, most relevant first")
+	fmt.Println("  -mixed-entries <type>      When a file matches as both a stale whole-file entry and chunks,")
+	fmt.Println("                             keep only its chunks (default) or only the whole file in MCP results")
+	fmt.Println("  -rerank                    Re-sort the retrieved results of -query and rag_search by BM25")
+	fmt.Println("                             keyword overlap with the query, so exact-word matches come first")
+	fmt.Println("  -no-dedup                  Keep MCP search results that mostly overlap a higher-ranked chunk")
+	fmt.Println("                             of the same file (by default they are dropped)")
+	fmt.Println("  -json                      Print -query, -list, and -stats output as JSON for scripting")
+	fmt.Println("  -fields <keys>             Comma-separated metadata keys shown by -query and -list instead")
+	fmt.Println("                             of the default layout, e.g. heading_path,tags,region (-json")
+	fmt.Println("                             default: chunk_index,start_offset,end_offset,token_count,")
+	fmt.Println("                             heading_path,is_chunk)")
+	fmt.Println("                             embedding_mode,embedding_model show what produced each vector")
 	fmt.Println("  -list                      List all documents in the database")
 	fmt.Println("  -stats                     Show statistics about the database contents")
+	fmt.Println("  -cluster <k>               Group the indexed chunks into k topic clusters with k-means over their")
+	fmt.Println("                             stored embeddings, showing each cluster's size and representative")
+	fmt.Println("                             files and headings")
+	fmt.Println("  -merge <path>              Merge documents from another database into the database")
+	fmt.Println("  -export-text <dir>         Write the indexed chunks of every file to <dir>/<file path>.txt, each")
+	fmt.Println("                             under a comment with its range and heading path, to read or grep")
+	fmt.Println("                             exactly what was indexed")
+	fmt.Println("  -delete <file>             Remove every chunk of an indexed file from the database, e.g. a")
+	fmt.Println("                             document that became sensitive; -index adds it back if it still exists")
+	fmt.Println("  -prune                     Remove documents whose source files no longer exist")
+	fmt.Println("                             (combine with -index to prune after indexing)")
+	fmt.Println("  -prune-after <duration>    Grace period a file must stay missing before it is pruned (e.g. 72h)")
+	fmt.Println("  -heading-max-level <n>     Deepest heading level (1-6) kept in chunk context (default: all)")
+	fmt.Println("  -max-headings <n>          Headings per file used for splitting and chunk context; later ones")
+	fmt.Println("                             are ignored in heading-dense files (default: 10000, 0 for no limit)")
+	fmt.Println("  -chunk-ids <scheme>        Chunk IDs: file (default) for <file hash>_<index>, or content for a")
+	fmt.Println("                             hash of normalized content, heading path, and index that every machine")
+	fmt.Println("                             computes alike, or stable for a hash of the chunk's own text and")
+	fmt.Println("                             file path that survives edits elsewhere in the file; fixed per database")
+	fmt.Println("  -accurate-tokens           Size chunks with a tokenizer-based estimate instead of 4 chars per token;")
+	fmt.Println("                             better for code-heavy and CJK documents")
+	fmt.Println("  -extract-tasks             Record each chunk's task-list items (- [ ] and - [x]) as open_tasks,")
+	fmt.Println("                             done_tasks, and task_text metadata, for -open-tasks, and embed them")
+	fmt.Println("                             with the chunk; unchanged files keep their old metadata until they")
+	fmt.Println("                             change or the database is rebuilt")
+	fmt.Println("  -dedup-files               Index files with identical content, such as copied LICENSE files,")
+	fmt.Println("                             once; search results list the copies under duplicate_paths")
+	fmt.Println("  -warn-chunks-per-file <n>  Warn about files that produce more than n chunks (default: 0, off)")
+	fmt.Println("  -no-cache                  Disable the persistent embedding cache (<db>.embcache)")
+	fmt.Println("  -cache-max-entries <n>     Maximum cached embeddings, least recently used evicted (default: 100000)")
+	fmt.Println("  -cache-max-age <duration>  Evict cached embeddings unused for longer than this, e.g. 720h")
+	fmt.Println("                             (default: 0, no limit)")
+	fmt.Println("  -clear-cache               Delete the embedding cache; combine with -index to rebuild it")
+	fmt.Println("  -config <path>             YAML or JSON config file (default: ./.mcp-rag.yaml if present)")
 	fmt.Println("  -db <path>                 Path to database file (default: ./rag.db)")
+	fmt.Println("  -root <dir>                Store indexed file paths relative to the directory, and resolve")
+	fmt.Println("                             relative paths against it, so the database works in any checkout")
+	fmt.Println("                             (default: absolute paths; relative ones resolve from the working")
+	fmt.Println("                             directory)")
 	fmt.Println("  -ollama-url <url>          Ollama API URL (default: http://localhost:11434/api/embeddings)")
 	fmt.Println("  -embedding-model <model>   Embedding model name (default: nomic-embed-text)")
+	fmt.Println("  -embedding-mode <mode>     Embedding API mode: ollama or openai (default: ollama)")
+	fmt.Println("  -concurrency <n>           Number of concurrent embedding requests (default: 4)")
+	fmt.Println("  -check                     Embed a test string to check that the embedding API is reachable and")
+	fmt.Println("                             the model is available, then exit; -index runs this check first in")
+	fmt.Println("                             ollama mode")
+	fmt.Println("  -embed-path-weight <n>     Fold the file name into embedded text n times (default: 0, disabled)")
+	fmt.Println("                             Changes stored vectors, so reindex after changing it")
+	fmt.Println("  -embed-headings            Prepend each chunk's heading path (e.g. Authentication > OAuth) to the")
+	fmt.Println("                             text embedded for it, not to its stored content; reindex after changing")
+	fmt.Println("  -normalize-text <mode>     Normalize document and query text before embedding: off (default),")
+	fmt.Println("                             whitespace, or markdown to also strip formatting; re-index after changing")
 	fmt.Println("  -mcp                       Run as MCP server (enables MCP protocol endpoints)")
+	fmt.Println("  -mcp-transport <t>         MCP transport: stdio (default) or http for a shared network service")
+	fmt.Println("  -mcp-addr <addr>           Listen address for the http transport (default: :8080); the endpoint")
+	fmt.Println("                             is /mcp and SIGINT or SIGTERM shuts down after in-flight requests")
+	fmt.Println("  -slow-log <duration>       Log embeddings, queries, and database loads slower than the duration")
+	fmt.Println("                             to stderr, e.g. 2s to spot a degraded Ollama (default: 0, off)")
 	fmt.Println("  -version                   Show version")
 	fmt.Println("  -help                      Show this help message")
 	fmt.Println()
@@ -27,23 +149,56 @@ func ShowHelp(maxTokensPerChunk, chunkOverlapPercent, maxContextTokens int) {
 	fmt.Println("  RAG_DB_PATH               Database file path")
 	fmt.Println("  RAG_OLLAMA_URL            Ollama API URL")
 	fmt.Println("  RAG_EMBEDDING_MODEL       Embedding model name")
+	fmt.Println("  RAG_EMBEDDING_MODE        Embedding API mode (ollama or openai)")
+	fmt.Println("  RAG_OPENAI_API_KEY        Bearer token for OpenAI-compatible embedding APIs")
+	fmt.Println("  RAG_CONCURRENCY           Number of concurrent embedding requests")
+	fmt.Println("  RAG_CONFIG                Config file path")
 	fmt.Println()
-	fmt.Println("Priority: Command line arguments > Environment variables > Defaults")
+	fmt.Println("Priority: Command line arguments > Environment variables > Config file > Defaults")
+	fmt.Println()
+	fmt.Println("Config File:")
+	fmt.Println("  Uses the flag names as keys: ollama-url, embedding-model, embedding-mode, db, concurrency")
+	fmt.Println("  Example .mcp-rag.yaml:")
+	fmt.Println("    embedding-model: mxbai-embed-large")
+	fmt.Println("    db: ./docs.db")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  ./rag -index /path/to/documents")
+	fmt.Println("  ./rag -index /path/to/documents -exclude \"**/node_modules/**\" -exclude CHANGELOG.md")
+	fmt.Println("  ./rag -index /path/to/documents -extensions .md,.mdx,.markdown,.txt")
+	fmt.Println("  ./rag -estimate /path/to/documents -estimate-embed-time 500ms")
 	fmt.Println("  ./rag -query \"machine learning concepts\"")
+	fmt.Println("  ./rag -query \"ERR_CONN_RESET\" -hybrid")
+	fmt.Println("  ./rag -query \"apt packages\" -heading-filter Installation")
+	fmt.Println("  ./rag -query \"oauth setup\" -link-scheme file")
+	fmt.Println("  ./rag -query \"oauth setup\" -json | jq '.results[].file_path'")
+	fmt.Println("  ./rag -check -embedding-model nomic-embed-text")
 	fmt.Println("  ./rag -list")
 	fmt.Println("  ./rag -stats")
+	fmt.Println("  ./rag -cluster 8")
+	fmt.Println("  ./rag -index ./docs -prune")
+	fmt.Println("  ./rag -index ./docs -since 24h")
+	fmt.Println("  ./rag -merge /path/to/other.db")
+	fmt.Println("  ./rag -export-text ./indexed-text && grep -rn \"retry\" ./indexed-text")
 	fmt.Println("  ./rag -index ./docs -db /tmp/my-rag.db")
 	fmt.Println("  RAG_DB_PATH=/tmp/rag.db ./rag -list")
+	fmt.Println("  ./rag -embedding-mode openai -ollama-url https://gateway/v1/embeddings -query \"auth\"")
 	fmt.Println()
 	fmt.Println("Chunking Configuration:")
 	fmt.Printf("  Max tokens per chunk: %d\n", maxTokensPerChunk)
 	fmt.Printf("  Chunk overlap: %d%%\n", chunkOverlapPercent)
 	fmt.Printf("  Context window limit: %d tokens\n", maxContextTokens)
 	fmt.Println()
+	fmt.Println("Exit Codes:")
+	fmt.Println("  0  Success")
+	fmt.Println("  1  Any failure not listed below")
+	fmt.Println("  2  Database not found; run -index first")
+	fmt.Println("  3  Embedding API unreachable or refused the request, e.g. Ollama not running or the")
+	fmt.Println("     model not pulled")
+	fmt.Println("  4  Invalid flags, flag combinations, or config file")
+	fmt.Println()
 	fmt.Println("Requirements:")
 	fmt.Println("  - Ollama must be running locally on the specified port")
+	fmt.Println("    (or an OpenAI-compatible endpoint when using -embedding-mode openai)")
 	fmt.Println("  - The embedding model must be available in Ollama")
 }
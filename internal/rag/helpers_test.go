@@ -0,0 +1,178 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// testEmbedding returns a deterministic bag-of-words embedding so that texts
+// sharing words are more similar than texts that do not
+func testEmbedding(text string, dim int) []float32 {
+	embedding := make([]float32, dim)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		embedding[h.Sum32()%uint32(dim)]++
+	}
+	if len(words) == 0 {
+		embedding[0] = 1
+	}
+	return embedding
+}
+
+// newTestOllamaServer starts a fake Ollama embeddings endpoint producing dim-sized vectors
+func newTestOllamaServer(t testing.TB, dim int) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(OllamaEmbeddingResponse{Embedding: testEmbedding(req.Prompt, dim)})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newTestConfig returns a config using a fake Ollama server and a database in a temp directory
+func newTestConfig(t testing.TB, dim int) Config {
+	t.Helper()
+
+	server := newTestOllamaServer(t, dim)
+	return Config{
+		OllamaURL:      server.URL,
+		EmbeddingModel: "test-model",
+		DBPath:         filepath.Join(t.TempDir(), "rag.db"),
+	}
+}
+
+// writeTestDatabase writes a database containing the given documents to dbPath
+func writeTestDatabase(t testing.TB, dbPath string, docs []chromem.Document) {
+	t.Helper()
+	writeTestDatabaseWithMetadata(t, dbPath, nil, docs)
+}
+
+// writeTestDatabaseWithMetadata is writeTestDatabase with collection metadata, such as a fingerprint
+func writeTestDatabaseWithMetadata(t testing.TB, dbPath string, metadata map[string]string, docs []chromem.Document) {
+	t.Helper()
+
+	db := chromem.NewDB()
+	collection, err := db.CreateCollection("documents", metadata, nil)
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	for _, doc := range docs {
+		if err := collection.AddDocument(context.Background(), doc); err != nil {
+			t.Fatalf("failed to add document %s: %v", doc.ID, err)
+		}
+	}
+
+	file, err := os.Create(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create database file: %v", err)
+	}
+	defer file.Close()
+
+	if err := db.ExportToWriter(file, true, ""); err != nil {
+		t.Fatalf("failed to save database: %v", err)
+	}
+}
+
+// readTestDatabase loads every document stored in the database at dbPath
+func readTestDatabase(t testing.TB, config Config) []chromem.Result {
+	t.Helper()
+
+	db := chromem.NewDB()
+	file, err := os.Open(config.DBPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer file.Close()
+
+	if err := db.ImportFromReader(file, ""); err != nil {
+		t.Fatalf("failed to load database: %v", err)
+	}
+
+	collection := db.GetCollection("documents", CreateEmbeddingFunc(config))
+	if collection == nil || collection.Count() == 0 {
+		return nil
+	}
+
+	results, err := collection.Query(context.Background(), "text document file", collection.Count(), nil, nil)
+	if err != nil {
+		t.Fatalf("failed to get documents: %v", err)
+	}
+	return results
+}
+
+// testDocument builds a stored document the way the indexer does for a small file
+func testDocument(filePath, fileHash, content string, dim int) chromem.Document {
+	return chromem.Document{
+		ID: fileHash,
+		Metadata: map[string]string{
+			"file_path":   filePath,
+			"file_hash":   fileHash,
+			"chunk_index": "0",
+			"is_chunk":    "false",
+		},
+		Embedding: testEmbedding(content, dim),
+		Content:   content,
+	}
+}
+
+// writeTestFiles writes the given files (relative path -> content) under a new temp directory
+func writeTestFiles(t testing.TB, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for relPath, content := range files {
+		path := filepath.Join(root, relPath)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+	return root
+}
+
+// captureStdout returns everything fn prints to stdout
+func captureStdout(t testing.TB, fn func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	fn()
+	writer.Close()
+	return <-output
+}
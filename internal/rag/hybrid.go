@@ -0,0 +1,130 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// BM25 tuning parameters
+const (
+	bm25K1 = 1.2  // Term frequency saturation
+	bm25B  = 0.75 // Document length normalization
+)
+
+// Search modes accepted by rag_search
+const (
+	SearchModeVector = "vector"
+	SearchModeHybrid = "hybrid"
+)
+
+// Tokenize splits text into lowercase terms for lexical scoring
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// bm25Scores scores every document against the query terms using BM25 over an
+// in-memory inverted index built from the documents' content
+func bm25Scores(query string, docs []chromem.Result) []float64 {
+	scores := make([]float64, len(docs))
+	if len(docs) == 0 {
+		return scores
+	}
+
+	// Build the inverted index: term -> document index -> term frequency
+	index := make(map[string]map[int]int)
+	docLengths := make([]int, len(docs))
+	totalLength := 0
+	for i, doc := range docs {
+		terms := Tokenize(doc.Content)
+		docLengths[i] = len(terms)
+		totalLength += len(terms)
+		for _, term := range terms {
+			if index[term] == nil {
+				index[term] = make(map[int]int)
+			}
+			index[term][i]++
+		}
+	}
+	avgLength := float64(totalLength) / float64(len(docs))
+	if avgLength == 0 {
+		return scores
+	}
+
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := index[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (float64(len(docs))-df+0.5)/(df+0.5))
+		for i, tf := range postings {
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(docLengths[i])/avgLength)
+			scores[i] += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + norm)
+		}
+	}
+
+	return scores
+}
+
+// hybridQuery ranks every document in the collection by alpha * cosine similarity plus
+// (1 - alpha) * BM25 score normalized to [0, 1], returning the top maxResults with the
+// blended score as their similarity
+func hybridQuery(ctx context.Context, collection *chromem.Collection, queryText string, maxResults int, alpha float64) ([]chromem.Result, error) {
+	count := collection.Count()
+	if count == 0 {
+		return nil, nil
+	}
+
+	// Score every document by vector similarity
+	results, err := collection.Query(ctx, queryText, count, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collection: %w", err)
+	}
+
+	lexical := bm25Scores(queryText, results)
+	maxLexical := 0.0
+	for _, score := range lexical {
+		maxLexical = math.Max(maxLexical, score)
+	}
+
+	for i := range results {
+		normalized := 0.0
+		if maxLexical > 0 {
+			normalized = lexical[i] / maxLexical
+		}
+		results[i].Similarity = float32(alpha*float64(results[i].Similarity) + (1-alpha)*normalized)
+	}
+
+	sortResults(results)
+
+	if maxResults < len(results) {
+		results = results[:maxResults]
+	}
+	return results, nil
+}
+
+// HybridSearch searches for documents using a blend of vector similarity and BM25
+// lexical scoring weighted by alpha (1 is pure vector, 0 is pure lexical)
+func HybridSearch(queryText string, config Config, maxResults int, alpha float64) ([]SearchResult, error) {
+	return hybridSearch(context.Background(), queryText, config, maxResults, alpha)
+}
+
+// hybridSearch is HybridSearch stopping when ctx is cancelled
+func hybridSearch(ctx context.Context, queryText string, config Config, maxResults int, alpha float64) ([]SearchResult, error) {
+	config.Hybrid = true
+	config.HybridAlpha = alpha
+	return searchDocumentsWithResults(ctx, queryText, config, maxResults)
+}
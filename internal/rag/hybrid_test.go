@@ -0,0 +1,41 @@
+package rag
+
+import (
+	"context"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestBM25ScoresFavorRareExactTerms(t *testing.T) {
+	docs := []chromem.Result{
+		{Content: "the server returned an error while connecting"},
+		{Content: "connection failed with ERR_CONN_RESET from the server"},
+		{Content: "the server started"},
+	}
+
+	scores := bm25Scores("server ERR_CONN_RESET", docs)
+
+	if !(scores[1] > scores[0] && scores[1] > scores[2]) {
+		t.Fatalf("expected the document with the exact rare term to score highest, got %v", scores)
+	}
+}
+
+func TestHybridQueryLexicalOnlyRanksKeywordMatchFirst(t *testing.T) {
+	config := newTestConfig(t, 4)
+	collection := newTestCollection(t, config, []chromem.Document{
+		testDocument("/docs/general.md", "aaaa1111", "general notes about connecting to servers", 4),
+		testDocument("/docs/errors.md", "bbbb2222", "troubleshooting ERR_CONN_RESET failures", 4),
+	})
+
+	results, err := hybridQuery(context.Background(), collection, "ERR_CONN_RESET", 2, 0)
+	if err != nil {
+		t.Fatalf("unexpected hybrid query error: %v", err)
+	}
+	if results[0].Metadata["file_path"] != "/docs/errors.md" {
+		t.Fatalf("unexpected top result: %s", results[0].Metadata["file_path"])
+	}
+	if results[0].Similarity != 1 {
+		t.Fatalf("expected the best lexical match to have a normalized score of 1, got %f", results[0].Similarity)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -14,12 +16,47 @@ import (
 	"github.com/philippgille/chromem-go"
 )
 
-// IndexDocuments indexes all markdown files in the specified directory
+// IndexDocuments indexes all markdown files (or files with the configured extensions) in the specified directory
 func IndexDocuments(rootPath string, config Config, maxTokensPerChunk, chunkOverlapPercent int, approxTokensPerChar float64) error {
-	fmt.Printf("Starting to index documents in: %s\n", rootPath)
-	fmt.Printf("Using database: %s\n", config.DBPath)
-	fmt.Printf("Using Ollama URL: %s\n", config.OllamaURL)
-	fmt.Printf("Using embedding model: %s\n", config.EmbeddingModel)
+	return IndexDocumentsContext(context.Background(), rootPath, config, maxTokensPerChunk, chunkOverlapPercent, approxTokensPerChar)
+}
+
+// IndexDocumentsContext is IndexDocuments stopping early when ctx is canceled. What was indexed
+// before the cancellation is saved, so that the next run resumes from there.
+func IndexDocumentsContext(ctx context.Context, rootPath string, config Config, maxTokensPerChunk, chunkOverlapPercent int, approxTokensPerChar float64) error {
+	if config.DryRun {
+		absRootPath, err := filepath.Abs(rootPath)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path for %s: %w", rootPath, err)
+		}
+		return showDryRun(absRootPath, config, maxTokensPerChunk, chunkOverlapPercent, approxTokensPerChar)
+	}
+
+	// Everything below reports through one reporter, which config carries to the functions it calls
+	report := progressReporter(config)
+	config.Progress = report
+
+	report(ProgressEvent{Kind: ProgressIndexStarted, FilePath: rootPath, Message: fmt.Sprintf("Starting to index documents in: %s", rootPath)})
+	report.infof("Using database: %s", config.DBPath)
+	report.infof("Using Ollama URL: %s", config.OllamaURL)
+	report.infof("Using embedding model: %s", config.EmbeddingModel)
+
+	if config.IndexTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.IndexTimeout)
+		defer cancel()
+	}
+
+	// Fail now, not partway through, when Ollama is not running or the model is not pulled. The
+	// dimension found is recorded if a new collection is created, instead of probing again.
+	probedDimension := 0
+	if config.EmbeddingMode == "" || config.EmbeddingMode == EmbeddingModeOllama {
+		size, err := preflightEmbedding(ctx, config)
+		if err != nil {
+			return err
+		}
+		probedDimension = size
+	}
 
 	// Convert rootPath to absolute path
 	absRootPath, err := filepath.Abs(rootPath)
@@ -29,184 +66,477 @@ func IndexDocuments(rootPath string, config Config, maxTokensPerChunk, chunkOver
 
 	// Initialize chromem-go database
 	db := chromem.NewDB()
+	dimension := &embeddingDimension{}
 
 	// Load existing database if it exists
 	if _, err := os.Stat(config.DBPath); err == nil {
-		fmt.Println("Loading existing database...")
+		report.infof("Loading existing database...")
 		file, err := os.Open(config.DBPath)
 		if err != nil {
 			return fmt.Errorf("failed to open existing database: %w", err)
 		}
-		defer file.Close()
 
-		err = db.ImportFromReader(file, "")
+		err = importDatabase(db, file, config.DBPath, config)
 		if err != nil {
-			fmt.Printf("Warning: Could not load existing database: %v\n", err)
+			report.warnf("", err, "Warning: Could not load existing database: %v", err)
 			// Continue with fresh database
 			db = chromem.NewDB()
+		} else {
+			// Adding vectors from another embedding model would leave the database unsearchable
+			metadata, err := readCollectionMetadata(file)
+			if err == nil {
+				err = checkEmbeddingFingerprint(metadata, config, "indexing")
+			}
+			if err == nil {
+				err = checkChunkIDScheme(metadata, config)
+			}
+			if err == nil {
+				dimension.size, err = storedEmbeddingDimension(file, metadata)
+			}
+			if err != nil {
+				file.Close()
+				return err
+			}
 		}
+		file.Close()
 	}
 
 	// Find all .md files
-	var mdFiles []string
-	err = filepath.Walk(absRootPath, func(path string, info os.FileInfo, err error) error {
+	mdFiles, err := findMarkdownFiles(absRootPath, config)
+	if err != nil {
+		return err
+	}
+	allFiles := mdFiles
+	if !config.Since.IsZero() {
+		found := len(mdFiles)
+		mdFiles = filesModifiedSince(mdFiles, config.Since)
+		report.infof("Skipping %d of %d files not modified since %s", found-len(mdFiles), found, config.Since.Format(time.RFC3339))
+	}
+
+	// Load the embedding cache so unchanged content is not re-embedded
+	var cache *EmbeddingCache
+	if !config.NoCache {
+		cache, err = LoadEmbeddingCache(config, config.CacheMaxEntries)
+		if err != nil {
+			report.warnf("", err, "Warning: Could not load embedding cache, starting fresh: %v", err)
+			cache = NewEmbeddingCache(config.DBPath+EmbeddingCacheSuffix, config.CacheMaxEntries)
+		}
+	}
+
+	// Create embedding function for Ollama
+	embeddingFunc := CreateEmbeddingFunc(config)
+
+	collection := db.GetCollection("documents", embeddingFunc)
+	if collection == nil {
+		collection, err = createFingerprintedCollection(ctx, db, config, embeddingFunc, probedDimension)
 		if err != nil {
 			return err
 		}
-		if strings.HasSuffix(strings.ToLower(path), ".md") {
-			// Convert to absolute path
-			absPath, err := filepath.Abs(path)
+	}
+
+	counter := NewTokenCounter(config, approxTokensPerChar)
+
+	// Under -dedup-files, files with the same content as an earlier file are indexed only once.
+	// Copies are found among all files, not only those -since selects, and the files sharing
+	// content with a selected one are indexed again to keep their duplicate_paths current.
+	var original map[string]string
+	var duplicates map[string][]string
+	if config.DedupFiles {
+		original, duplicates = findDuplicateFiles(allFiles)
+		if !config.Since.IsZero() {
+			links, err := duplicateLinks(collection, config)
 			if err != nil {
-				fmt.Printf("Warning: Could not get absolute path for %s: %v\n", path, err)
-				return nil
+				report.warnf("", err, "Warning: Could not read the stored duplicate_paths: %v", err)
 			}
-			mdFiles = append(mdFiles, absPath)
+			mdFiles = duplicateGroupFiles(mdFiles, allFiles, original, duplicates, links)
+		}
+	}
+
+	var overChunked []string
+	indexed := 0
+	for i, filePath := range mdFiles {
+		// A file interrupted by the deadline or a signal stored nothing, so it is not counted as indexed
+		if ctx.Err() != nil {
+			break
+		}
+		report(ProgressEvent{
+			Kind:      ProgressFileStarted,
+			FilePath:  filePath,
+			FileIndex: i + 1,
+			FileCount: len(mdFiles),
+			Message:   fmt.Sprintf("Processing (%d/%d): %s", i+1, len(mdFiles), filePath),
+		})
+
+		if firstPath, ok := original[filePath]; ok {
+			// Documents of the copy from a run without -dedup-files would repeat the original's
+			if err := deleteFileDocuments(collection, filePath, config); err != nil {
+				report.warnf(filePath, err, "Warning: Could not remove the old chunks of %s: %v", filePath, err)
+			}
+			report.skipf(filePath, nil, "  Same content as %s, listed in its duplicate_paths", firstPath)
+			continue
+		}
+
+		chunks := indexFile(ctx, collection, filePath, duplicates[filePath], config, cache, dimension, maxTokensPerChunk, chunkOverlapPercent, counter)
+		if dimension.err != nil {
+			return fmt.Errorf("failed to index %s: %w", filePath, dimension.err)
+		}
+		if ctx.Err() == nil {
+			indexed++
+		}
+		if config.WarnChunksPerFile > 0 && chunks > config.WarnChunksPerFile {
+			report.warnf(filePath, nil, "⚠️  WARNING: %s produced %d chunks, more than the %d chunk threshold", filePath, chunks, config.WarnChunksPerFile)
+			overChunked = append(overChunked, fmt.Sprintf("%s (%d chunks)", filePath, chunks))
+		}
+
+		// Save progress periodically so a crash loses at most the files since the last flush
+		if config.FlushInterval > 0 && (i+1)%config.FlushInterval == 0 && i+1 < len(mdFiles) && ctx.Err() == nil {
+			if err := saveIndexProgress(db, cache, config.DBPath, report); err != nil {
+				return err
+			}
+			report(ProgressEvent{
+				Kind:      ProgressSaved,
+				FileIndex: i + 1,
+				FileCount: len(mdFiles),
+				Message:   fmt.Sprintf("  Saved progress: %d of %d files", i+1, len(mdFiles)),
+			})
 		}
-		return nil
+	}
+
+	// Remove documents for files that were deleted from disk; a partial run cannot tell which are missing
+	stopped := ctx.Err() != nil
+	if config.Prune && !stopped {
+		if _, err := pruneMissingFiles(collection, config, time.Now(), report); err != nil {
+			return err
+		}
+	}
+
+	if err := saveIndexProgress(db, cache, config.DBPath, report); err != nil {
+		return err
+	}
+
+	if stopped {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("indexing timed out after %s with %d of %d files indexed; partial progress saved to %s: %w",
+				config.IndexTimeout, indexed, len(mdFiles), config.DBPath, ctx.Err())
+		}
+		return fmt.Errorf("indexing interrupted with %d of %d files indexed; partial progress saved to %s, and indexing again resumes from the embedding cache: %w",
+			indexed, len(mdFiles), config.DBPath, ctx.Err())
+	}
+
+	report(ProgressEvent{
+		Kind:      ProgressIndexDone,
+		FileCount: len(mdFiles),
+		Message:   fmt.Sprintf("✓ Successfully indexed %d documents and saved to %s", len(mdFiles), config.DBPath),
 	})
+	if len(overChunked) > 0 {
+		report.infof("⚠️  %d files exceeded %d chunks, check them for pathological content or chunk settings:", len(overChunked), config.WarnChunksPerFile)
+		for _, file := range overChunked {
+			report.infof("   %s", file)
+		}
+	}
+	reportMemoryUsage(report)
+	return nil
+}
+
+// saveIndexProgress saves the embedding cache and database of an indexing run. The database is
+// replaced atomically, so a run stopped mid-save keeps the previous copy; rerunning the index
+// re-embeds nothing the cache already holds, picking up where the run stopped.
+func saveIndexProgress(db *chromem.DB, cache *EmbeddingCache, dbPath string, report ProgressFunc) error {
+	if err := cache.Save(); err != nil {
+		report.warnf("", err, "Warning: Could not save embedding cache: %v", err)
+	}
+	return saveDatabaseAtomic(db, dbPath)
+}
+
+// indexFile reads, chunks, embeds, and stores a single file, returning the number of chunks it
+// produced (0 if it was skipped). duplicates are the other files with the same content, recorded
+// in metadata under -dedup-files. All file content and chunk data is scoped to this call so it
+// can be released as soon as the file is stored.
+func indexFile(ctx context.Context, collection *chromem.Collection, filePath string, duplicates []string, config Config, cache *EmbeddingCache, dimension *embeddingDimension, maxTokensPerChunk, chunkOverlapPercent int, counter TokenCounter) int {
+	report := progressReporter(config)
+
+	// Read file content
+	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to walk directory: %w", err)
+		report.skipf(filePath, err, "Warning: Could not read file %s: %v", filePath, err)
+		return 0
 	}
 
-	// Create embedding function for Ollama
-	embeddingFunc := CreateEmbeddingFunc(config)
+	// Create file hash
+	hash := sha256.Sum256(content)
+	fileHash := hex.EncodeToString(hash[:])
 
-	collection, err := db.GetOrCreateCollection("documents", nil, embeddingFunc)
+	// Get file info
+	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to create collection: %w", err)
+		report.skipf(filePath, err, "Warning: Could not get file info for %s: %v", filePath, err)
+		return 0
 	}
 
-	for i, filePath := range mdFiles {
-		fmt.Printf("Processing (%d/%d): %s\n", i+1, len(mdFiles), filePath)
+	// Check if file needs chunking; the raw bytes are not referenced past this point
+	contentStr := string(content)
 
-		// Read file content
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			fmt.Printf("Warning: Could not read file %s: %v\n", filePath, err)
-			continue
-		}
+	if isSourceFile(filePath, config) {
+		return indexSourceFile(ctx, collection, filePath, duplicates, contentStr, fileHash, fileInfo, config, cache, dimension, maxTokensPerChunk, chunkOverlapPercent, counter)
+	}
 
-		// Create file hash
-		hash := sha256.Sum256(content)
-		fileHash := hex.EncodeToString(hash[:])
+	// Frontmatter is stored as metadata rather than embedded; offsets stay relative to the whole file
+	frontmatter, bodyOffset := ParseFrontmatter(contentStr)
+	body := contentStr[bodyOffset:]
+	tags := strings.Join(frontmatter.Tags, ",")
+	if frontmatter.MaxTokensPerChunk > 0 {
+		report.infof("  Chunk size set to %d tokens by rag_max_tokens_per_chunk", frontmatter.MaxTokensPerChunk)
+	}
+	maxTokensPerChunk = frontmatter.chunkTokenLimit(maxTokensPerChunk)
 
-		// Get file info
-		fileInfo, err := os.Stat(filePath)
-		if err != nil {
-			fmt.Printf("Warning: Could not get file info for %s: %v\n", filePath, err)
-			continue
-		}
+	estimatedTokens := counter.CountTokens(body)
 
-		// Check if file needs chunking
-		contentStr := string(content)
-		estimatedTokens := EstimateTokenCount(contentStr, approxTokensPerChar)
+	report.infof("  File size: %d bytes, estimated tokens: %d", len(contentStr), estimatedTokens)
 
-		fmt.Printf("  File size: %d bytes, estimated tokens: %d\n", len(content), estimatedTokens)
+	if estimatedTokens > maxTokensPerChunk {
+		report.infof("  Large file detected, chunking into smaller pieces...")
 
-		if estimatedTokens > maxTokensPerChunk {
-			fmt.Printf("  Large file detected, chunking into smaller pieces...\n")
+		// Chunk the document
+		chunks := chunkDocument(filePath, body, fileHash, maxTokensPerChunk, chunkOverlapPercent, config.HeadingMaxLevel, config.MaxHeadings, counter, report)
+		for i := range chunks {
+			chunks[i].StartOffset += bodyOffset
+			chunks[i].EndOffset += bodyOffset
+		}
+		assignChunkIDs(chunks, contentStr, config)
+		report(ProgressEvent{Kind: ProgressFileChunked, FilePath: filePath, Chunks: len(chunks), Message: fmt.Sprintf("  Created %d chunks", len(chunks))})
 
-			// Chunk the document
-			chunks := ChunkDocument(filePath, contentStr, fileHash, maxTokensPerChunk, chunkOverlapPercent, approxTokensPerChar)
-			fmt.Printf("  Created %d chunks\n", len(chunks))
+		// Get embeddings for all chunks in batches
+		embeddings, err := BatchEmbedChunks(ctx, chunks, config, cache)
+		if err != nil {
+			report.skipf(filePath, err, "Warning: Could not get embeddings for %s: %v", filePath, err)
+			return 0
+		}
+		if err := dimension.checkAll(embeddings); err != nil {
+			report.skipf(filePath, err, "Warning: Not storing %s: %v", filePath, err)
+			return 0
+		}
+		report(ProgressEvent{Kind: ProgressFileEmbedded, FilePath: filePath, Chunks: len(embeddings), Message: fmt.Sprintf("  Embedded %d chunks", len(embeddings))})
+		if err := dropReplacedDocuments(collection, filePath, config); err != nil {
+			report.skipf(filePath, err, "Warning: Could not remove the old chunks of %s: %v", filePath, err)
+			return 0
+		}
 
-			// Get embeddings for all chunks in batches
-			embeddings, err := BatchEmbedChunks(chunks, config)
-			if err != nil {
-				fmt.Printf("Warning: Could not get embeddings for %s: %v\n", filePath, err)
+		// Add each chunk to the collection
+		for _, chunk := range chunks {
+			embedding, exists := embeddings[chunk.ID]
+			if !exists {
+				report.warnf(filePath, nil, "Warning: No embedding found for chunk %s", chunk.ID)
 				continue
 			}
 
-			// Add each chunk to the collection
-			for _, chunk := range chunks {
-				embedding, exists := embeddings[chunk.ID]
-				if !exists {
-					fmt.Printf("Warning: No embedding found for chunk %s\n", chunk.ID)
-					continue
-				}
-
-				// Create metadata for chunk
-				headingPathStr := ""
-				if len(chunk.HeadingPath) > 0 {
-					headingPathStr = strings.Join(chunk.HeadingPath, " > ")
-				}
-
-				err = collection.AddDocument(context.Background(), chromem.Document{
-					ID: chunk.ID,
-					Metadata: map[string]string{
-						"file_path":     chunk.FilePath,
-						"file_hash":     chunk.FileHash,
-						"chunk_index":   strconv.Itoa(chunk.ChunkIndex),
-						"file_size":     fmt.Sprintf("%d", fileInfo.Size()),
-						"last_modified": fileInfo.ModTime().Format(time.RFC3339),
-						"indexed_at":    chunk.CreatedAt.Format(time.RFC3339),
-						"start_offset":  strconv.Itoa(chunk.StartOffset),
-						"end_offset":    strconv.Itoa(chunk.EndOffset),
-						"token_count":   strconv.Itoa(chunk.TokenCount),
-						"heading_path":  headingPathStr,
-						"is_chunk":      "true",
-					},
-					Embedding: embedding,
-					Content:   chunk.Content,
-				})
-				if err != nil {
-					fmt.Printf("Warning: Could not add chunk %s to collection: %v\n", chunk.ID, err)
-					continue
-				}
+			// Create metadata for chunk
+			headingPathStr := ""
+			if len(chunk.HeadingPath) > 0 {
+				headingPathStr = strings.Join(chunk.HeadingPath, " > ")
 			}
 
-			fmt.Printf("✓ Indexed: %s (%d chunks, hash: %s)\n", filePath, len(chunks), fileHash[:8])
-		} else {
-			// Handle small files as before (single chunk)
-			fmt.Printf("  Small file, indexing as single document\n")
-
-			// Get embedding from Ollama
-			embedding, err := GetEmbedding(contentStr, config)
-			if err != nil {
-				fmt.Printf("Warning: Could not get embedding for %s: %v\n", filePath, err)
-				continue
+			metadata := map[string]string{
+				"file_path":       storedFilePath(chunk.FilePath, config),
+				"file_hash":       chunk.FileHash,
+				"chunk_index":     strconv.Itoa(chunk.ChunkIndex),
+				"file_size":       fmt.Sprintf("%d", fileInfo.Size()),
+				"last_modified":   fileInfo.ModTime().Format(time.RFC3339),
+				"indexed_at":      chunk.CreatedAt.Format(time.RFC3339),
+				"start_offset":    strconv.Itoa(chunk.StartOffset),
+				"end_offset":      strconv.Itoa(chunk.EndOffset),
+				"token_count":     strconv.Itoa(chunk.TokenCount),
+				"heading_path":    headingPathStr,
+				"region":          chunkRegion(chunk.StartOffset, chunk.EndOffset, len(contentStr)),
+				"title":           frontmatter.Title,
+				"tags":            tags,
+				"is_chunk":        "true",
+				"embedding_mode":  embeddingModeName(config),
+				"embedding_model": config.EmbeddingModel,
 			}
+			addTaskMetadata(metadata, chunk.Content, config)
+			addDuplicateMetadata(metadata, duplicates, config)
 
-			// Add to collection with individual metadata fields
 			err = collection.AddDocument(context.Background(), chromem.Document{
-				ID: fileHash,
-				Metadata: map[string]string{
-					"file_path":     filePath,
-					"file_hash":     fileHash,
-					"chunk_index":   "0",
-					"file_size":     fmt.Sprintf("%d", fileInfo.Size()),
-					"last_modified": fileInfo.ModTime().Format(time.RFC3339),
-					"indexed_at":    time.Now().Format(time.RFC3339),
-					"start_offset":  "0",
-					"end_offset":    strconv.Itoa(len(content)),
-					"token_count":   strconv.Itoa(estimatedTokens),
-					"heading_path":  "",
-					"is_chunk":      "false",
-				},
+				ID:        chunk.ID,
+				Metadata:  metadata,
 				Embedding: embedding,
-				Content:   contentStr,
+				Content:   chunk.Content,
 			})
 			if err != nil {
-				fmt.Printf("Warning: Could not add document %s to collection: %v\n", filePath, err)
+				report.warnf(filePath, err, "Warning: Could not add chunk %s to collection: %v", chunk.ID, err)
 				continue
 			}
+		}
+
+		report(ProgressEvent{Kind: ProgressFileDone, FilePath: filePath, Chunks: len(chunks), Message: fmt.Sprintf("✓ Indexed: %s (%d chunks, hash: %s)", filePath, len(chunks), fileHash[:8])})
+		return len(chunks)
+	} else {
+		// Handle small files as before (single chunk)
+		report.infof("  Small file, indexing as single document")
+
+		// Get embedding from the cache or Ollama
+		embedding, err := getCachedEmbedding(ctx, EmbeddingText(withTaskText(body, body, config), filePath, config), config, cache)
+		if err != nil {
+			report.skipf(filePath, err, "Warning: Could not get embedding for %s: %v", filePath, err)
+			return 0
+		}
+		if err := dimension.check(embedding); err != nil {
+			report.skipf(filePath, err, "Warning: Not storing %s: %v", filePath, err)
+			return 0
+		}
+		report(ProgressEvent{Kind: ProgressFileEmbedded, FilePath: filePath, Chunks: 1, Message: "  Embedded 1 document"})
+		if err := dropReplacedDocuments(collection, filePath, config); err != nil {
+			report.skipf(filePath, err, "Warning: Could not remove the old chunks of %s: %v", filePath, err)
+			return 0
+		}
 
-			fmt.Printf("✓ Indexed: %s (single document, hash: %s)\n", filePath, fileHash[:8])
+		// Add to collection with individual metadata fields
+		metadata := map[string]string{
+			"file_path":       storedFilePath(filePath, config),
+			"file_hash":       fileHash,
+			"chunk_index":     "0",
+			"file_size":       fmt.Sprintf("%d", fileInfo.Size()),
+			"last_modified":   fileInfo.ModTime().Format(time.RFC3339),
+			"indexed_at":      time.Now().Format(time.RFC3339),
+			"start_offset":    strconv.Itoa(bodyOffset),
+			"end_offset":      strconv.Itoa(len(contentStr)),
+			"token_count":     strconv.Itoa(estimatedTokens),
+			"heading_path":    "",
+			"title":           frontmatter.Title,
+			"tags":            tags,
+			"is_chunk":        "false",
+			"embedding_mode":  embeddingModeName(config),
+			"embedding_model": config.EmbeddingModel,
 		}
+		addTaskMetadata(metadata, body, config)
+		addDuplicateMetadata(metadata, duplicates, config)
+
+		err = collection.AddDocument(context.Background(), chromem.Document{
+			ID:        fileHash,
+			Metadata:  metadata,
+			Embedding: embedding,
+			Content:   body,
+		})
+		if err != nil {
+			report.skipf(filePath, err, "Warning: Could not add document %s to collection: %v", filePath, err)
+			return 0
+		}
+
+		report(ProgressEvent{Kind: ProgressFileDone, FilePath: filePath, Chunks: 1, Message: fmt.Sprintf("✓ Indexed: %s (single document, hash: %s)", filePath, fileHash[:8])})
+		return 1
 	}
+}
 
-	// Save database
-	file, err := os.Create(config.DBPath)
-	if err != nil {
-		return fmt.Errorf("failed to create database file: %w", err)
+// indexSourceFile embeds and stores the comment blocks of a source file, returning the number of
+// chunks it produced. Chunks record the source lines of their comment alongside the usual offsets.
+func indexSourceFile(ctx context.Context, collection *chromem.Collection, filePath string, duplicates []string, content, fileHash string, fileInfo os.FileInfo, config Config, cache *EmbeddingCache, dimension *embeddingDimension, maxTokensPerChunk, chunkOverlapPercent int, counter TokenCounter) int {
+	report := progressReporter(config)
+	chunks := sourceCommentChunks(filePath, content, fileHash, maxTokensPerChunk, chunkOverlapPercent, config.HeadingMaxLevel, config.MaxHeadings, counter, report)
+	assignChunkIDs(chunks, content, config)
+	if len(chunks) == 0 {
+		report.skipf(filePath, nil, "  No comments found, skipping")
+		return 0
 	}
-	defer file.Close()
+	report(ProgressEvent{Kind: ProgressFileChunked, FilePath: filePath, Chunks: len(chunks), Message: fmt.Sprintf("  Source file, indexing %d comment chunks", len(chunks))})
 
-	err = db.ExportToWriter(file, true, "")
+	embeddings, err := BatchEmbedChunks(ctx, chunks, config, cache)
 	if err != nil {
-		return fmt.Errorf("failed to save database: %w", err)
+		report.skipf(filePath, err, "Warning: Could not get embeddings for %s: %v", filePath, err)
+		return 0
+	}
+	if err := dimension.checkAll(embeddings); err != nil {
+		report.skipf(filePath, err, "Warning: Not storing %s: %v", filePath, err)
+		return 0
+	}
+	report(ProgressEvent{Kind: ProgressFileEmbedded, FilePath: filePath, Chunks: len(embeddings), Message: fmt.Sprintf("  Embedded %d chunks", len(embeddings))})
+	if err := dropReplacedDocuments(collection, filePath, config); err != nil {
+		report.skipf(filePath, err, "Warning: Could not remove the old chunks of %s: %v", filePath, err)
+		return 0
 	}
 
-	fmt.Printf("✓ Successfully indexed %d documents and saved to %s\n", len(mdFiles), config.DBPath)
-	return nil
+	for _, chunk := range chunks {
+		embedding, exists := embeddings[chunk.ID]
+		if !exists {
+			report.warnf(filePath, nil, "Warning: No embedding found for chunk %s", chunk.ID)
+			continue
+		}
+
+		startLine, endLine := LineRange(content, chunk.StartOffset, chunk.EndOffset)
+		metadata := map[string]string{
+			"file_path":       storedFilePath(chunk.FilePath, config),
+			"file_hash":       chunk.FileHash,
+			"chunk_index":     strconv.Itoa(chunk.ChunkIndex),
+			"file_size":       fmt.Sprintf("%d", fileInfo.Size()),
+			"last_modified":   fileInfo.ModTime().Format(time.RFC3339),
+			"indexed_at":      chunk.CreatedAt.Format(time.RFC3339),
+			"start_offset":    strconv.Itoa(chunk.StartOffset),
+			"end_offset":      strconv.Itoa(chunk.EndOffset),
+			"start_line":      strconv.Itoa(startLine),
+			"end_line":        strconv.Itoa(endLine),
+			"token_count":     strconv.Itoa(chunk.TokenCount),
+			"heading_path":    strings.Join(chunk.HeadingPath, " > "),
+			"region":          chunkRegion(chunk.StartOffset, chunk.EndOffset, len(content)),
+			"source_comment":  "true",
+			"is_chunk":        "true",
+			"embedding_mode":  embeddingModeName(config),
+			"embedding_model": config.EmbeddingModel,
+		}
+		addTaskMetadata(metadata, chunk.Content, config)
+		addDuplicateMetadata(metadata, duplicates, config)
+
+		err = collection.AddDocument(context.Background(), chromem.Document{
+			ID:        chunk.ID,
+			Metadata:  metadata,
+			Embedding: embedding,
+			Content:   chunk.Content,
+		})
+		if err != nil {
+			report.warnf(filePath, err, "Warning: Could not add chunk %s to collection: %v", chunk.ID, err)
+			continue
+		}
+	}
+
+	report(ProgressEvent{Kind: ProgressFileDone, FilePath: filePath, Chunks: len(chunks), Message: fmt.Sprintf("✓ Indexed: %s (%d comment chunks, hash: %s)", filePath, len(chunks), fileHash[:8])})
+	return len(chunks)
+}
+
+// ParseExtensions parses a comma-separated list of file extensions, normalizing each to a
+// lowercase name with a leading dot
+func ParseExtensions(list string) []string {
+	var extensions []string
+	for _, ext := range strings.Split(list, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions = append(extensions, ext)
+	}
+	return extensions
+}
+
+// hasIndexedExtension reports whether path ends in one of the extensions, case-insensitively.
+// An empty list indexes markdown (.md) files only.
+func hasIndexedExtension(path string, extensions []string) bool {
+	if len(extensions) == 0 {
+		extensions = []string{".md"}
+	}
+
+	lowerPath := strings.ToLower(path)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lowerPath, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportMemoryUsage reports the process memory usage, useful for tracking large indexing runs
+func reportMemoryUsage(report ProgressFunc) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	report.infof("Memory usage: heap %s, total allocated %s, system %s",
+		FormatBytes(int64(m.HeapAlloc)), FormatBytes(int64(m.TotalAlloc)), FormatBytes(int64(m.Sys)))
 }
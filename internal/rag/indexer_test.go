@@ -0,0 +1,404 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestIndexDocumentsEmbedPathWeightSurfacesShortFileByName(t *testing.T) {
+	for _, tc := range []struct {
+		weight   int
+		wantBase string
+	}{
+		{weight: 0, wantBase: "database.md"},
+		{weight: 2, wantBase: "oauth-setup.md"},
+	} {
+		config := newTestConfig(t, 64)
+		config.EmbedPathWeight = tc.weight
+		root := writeTestFiles(t, map[string]string{
+			"oauth-setup.md": "Configure the provider.",
+			"database.md":    "Database setup guide.",
+		})
+
+		if err := IndexDocuments(root, config, 4000, 15, 0.25); err != nil {
+			t.Fatalf("unexpected indexing error: %v", err)
+		}
+
+		results, err := MCPSearchDocumentsWithResults("oauth setup", config, 2)
+		if err != nil {
+			t.Fatalf("unexpected search error: %v", err)
+		}
+		if got := filepath.Base(results[0].FilePath); got != tc.wantBase {
+			t.Fatalf("weight %d: unexpected top result: got %s, want %s", tc.weight, got, tc.wantBase)
+		}
+	}
+}
+
+func TestIndexDocumentsEmbedHeadingsMatchesSectionTerms(t *testing.T) {
+	var content strings.Builder
+	content.WriteString("# Authentication\n\n## OAuth\n\n")
+	for i := 0; i < 8; i++ {
+		content.WriteString(strings.Repeat("Refresh tokens expire after one hour and must be renewed. ", 6) + "\n\n")
+	}
+
+	similarity := make(map[bool]float32)
+	for _, embedHeadings := range []bool{false, true} {
+		config := newTestConfig(t, 64)
+		config.LogLevel = LogQuiet
+		config.EmbedHeadings = embedHeadings
+		root := writeTestFiles(t, map[string]string{"auth.md": content.String()})
+		captureStdout(t, func() {
+			if err := IndexDocuments(root, config, 200, 15, 0.25); err != nil {
+				t.Fatalf("unexpected indexing error: %v", err)
+			}
+		})
+
+		results, err := MCPSearchDocumentsWithResults("oauth", config, 20)
+		if err != nil {
+			t.Fatalf("unexpected search error: %v", err)
+		}
+		// The last chunk is deep in the section, and its body never mentions OAuth
+		last := results[0]
+		for _, result := range results {
+			if result.ChunkIndex > last.ChunkIndex {
+				last = result
+			}
+		}
+		if last.ChunkIndex == 0 || strings.Contains(strings.ToLower(last.Content), "oauth") || !strings.Contains(last.HeadingPath, "OAuth") {
+			t.Fatalf("expected a later chunk under the OAuth heading without the term in its body, got %+v", last)
+		}
+		similarity[embedHeadings] = last.Similarity
+	}
+
+	if similarity[false] != 0 || similarity[true] <= 0 {
+		t.Fatalf("expected -embed-headings to match the heading term, got similarity %.3f with it and %.3f without", similarity[true], similarity[false])
+	}
+}
+
+func TestIndexDocumentsMapsBOMAndCRLFOffsetsToFile(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.LogLevel = LogQuiet
+	var content strings.Builder
+	content.WriteString("\uFEFF---\r\ntitle: Windows Notes\r\n---\r\n# Guide\r\n\r\n")
+	for i := 0; i < 8; i++ {
+		content.WriteString("## Step " + string(rune('A'+i)) + "\r\n\r\n")
+		content.WriteString(strings.Repeat("Run the installer and accept the defaults. ", 6) + "\r\n\r\n")
+	}
+	root := writeTestFiles(t, map[string]string{"windows.md": content.String()})
+
+	captureStdout(t, func() {
+		if err := IndexDocuments(root, config, 100, 15, 0.25); err != nil {
+			t.Fatalf("unexpected indexing error: %v", err)
+		}
+	})
+
+	original, err := os.ReadFile(filepath.Join(root, "windows.md"))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	docs := readTestDatabase(t, config)
+	if len(docs) < 2 {
+		t.Fatalf("expected the file to be chunked, got %d documents", len(docs))
+	}
+	for _, doc := range docs {
+		result := toSearchResult(doc)
+		if got := string(original[result.StartOffset:result.EndOffset]); got != doc.Content {
+			t.Fatalf("chunk %s offsets %d-%d do not map back to its content:\n%q\nvs\n%q", doc.ID, result.StartOffset, result.EndOffset, got, doc.Content)
+		}
+		if strings.HasPrefix(doc.Content, "\uFEFF") || strings.Contains(doc.Content, "title:") {
+			t.Fatalf("chunk %s kept the byte order mark or frontmatter: %q", doc.ID, doc.Content)
+		}
+		if doc.Metadata["title"] != "Windows Notes" {
+			t.Fatalf("chunk %s: expected the frontmatter title, got %v", doc.ID, doc.Metadata)
+		}
+		// The first chunk starts at the top heading, so only later chunks have heading context
+		if result.ChunkIndex > 0 && !strings.HasPrefix(doc.Metadata["heading_path"], "Guide > Step ") {
+			t.Fatalf("chunk %s: expected heading context under Guide, got %q", doc.ID, doc.Metadata["heading_path"])
+		}
+		if strings.Contains(doc.Metadata["heading_path"], "\r") {
+			t.Fatalf("chunk %s heading path kept a carriage return: %q", doc.ID, doc.Metadata["heading_path"])
+		}
+	}
+}
+
+func TestIndexDocumentsSkipsExcludedGlobs(t *testing.T) {
+	config := newTestConfig(t, 16)
+	config.Include = []string{"docs/**"}
+	config.Exclude = []string{"**/node_modules/**", "CHANGELOG.md"}
+	root := writeTestFiles(t, map[string]string{
+		"docs/guide.md":                   "Guide content.",
+		"docs/CHANGELOG.md":               "Generated changelog.",
+		"docs/node_modules/pkg/README.md": "Vendored readme.",
+		"notes/todo.md":                   "Outside the include glob.",
+	})
+
+	if err := IndexDocuments(root, config, 4000, 15, 0.25); err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+
+	results := readTestDatabase(t, config)
+	if len(results) != 1 || results[0].Metadata["file_path"] != filepath.Join(root, "docs", "guide.md") {
+		var paths []string
+		for _, result := range results {
+			paths = append(paths, result.Metadata["file_path"])
+		}
+		t.Fatalf("expected only docs/guide.md to be indexed, got %v", paths)
+	}
+}
+
+func TestIndexDocumentsExtensions(t *testing.T) {
+	files := map[string]string{
+		"guide.md":       "Markdown guide.",
+		"notes.markdown": "Long-form markdown notes.",
+		"page.mdx":       "import Chart from './chart'\n\n# Usage\n\n<Chart data={[1, 2]} />\n",
+	}
+
+	for _, tc := range []struct {
+		extensions []string
+		want       int
+	}{
+		{extensions: nil, want: 1},
+		{extensions: ParseExtensions(".md, MARKDOWN,.mdx"), want: 3},
+	} {
+		config := newTestConfig(t, 16)
+		config.Extensions = tc.extensions
+		root := writeTestFiles(t, files)
+
+		if err := IndexDocuments(root, config, 4000, 15, 0.25); err != nil {
+			t.Fatalf("unexpected indexing error: %v", err)
+		}
+
+		results := readTestDatabase(t, config)
+		if len(results) != tc.want {
+			t.Fatalf("extensions %v: unexpected document count: got %d, want %d", tc.extensions, len(results), tc.want)
+		}
+	}
+}
+
+func TestIndexDocumentsWarnsAboutFilesOverChunkThreshold(t *testing.T) {
+	config := newTestConfig(t, 16)
+	config.WarnChunksPerFile = 3
+	root := writeTestFiles(t, map[string]string{
+		"huge.md":  strings.Repeat("A sentence of filler text. ", 400),
+		"small.md": "Short file.",
+	})
+
+	var err error
+	output := captureStdout(t, func() {
+		err = IndexDocuments(root, config, 200, 15, 0.25)
+	})
+	if err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+
+	hugePath := filepath.Join(root, "huge.md")
+	if !strings.Contains(output, "WARNING: "+hugePath+" produced") {
+		t.Fatalf("expected a warning naming %s, got:\n%s", hugePath, output)
+	}
+	if strings.Contains(output, "WARNING: "+filepath.Join(root, "small.md")) {
+		t.Fatalf("did not expect a warning for small.md")
+	}
+}
+
+func TestIndexDocumentsTimeoutSavesPartialProgress(t *testing.T) {
+	// The fake embedding API answers quickly except for "slow" text, which outlasts the timeout
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(req.Prompt, "slow") {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		json.NewEncoder(w).Encode(OllamaEmbeddingResponse{Embedding: testEmbedding(req.Prompt, 8)})
+	}))
+	t.Cleanup(server.Close)
+
+	config := newTestConfig(t, 8)
+	config.OllamaURL = server.URL
+	config.NoCache = true
+	config.IndexTimeout = 200 * time.Millisecond
+	root := writeTestFiles(t, map[string]string{
+		"a-fast.md": "quick notes",
+		"b-slow.md": "slow notes",
+		"c-fast.md": "never reached",
+	})
+
+	var err error
+	start := time.Now()
+	captureStdout(t, func() {
+		err = IndexDocuments(root, config, 4000, 15, 0.25)
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("indexing ignored the timeout and ran for %s", elapsed)
+	}
+
+	var saved []string
+	for _, result := range readTestDatabase(t, config) {
+		saved = append(saved, filepath.Base(result.Metadata["file_path"]))
+	}
+	if len(saved) != 1 || saved[0] != "a-fast.md" {
+		t.Fatalf("expected only the file indexed before the timeout to be saved, got %v", saved)
+	}
+}
+
+func TestIndexDocumentsInterruptSavesProgressAndResumes(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.FlushInterval = 1
+	root := writeTestFiles(t, map[string]string{
+		"a.md": "first notes",
+		"b.md": "second notes",
+		"c.md": "interrupted notes",
+	})
+
+	// The fake embedding API interrupts the run on the last file, after checking it was flushed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var flushed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(req.Prompt, "interrupted") {
+			_, err := os.Stat(config.DBPath)
+			flushed.Store(err == nil)
+			cancel()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		json.NewEncoder(w).Encode(OllamaEmbeddingResponse{Embedding: testEmbedding(req.Prompt, 8)})
+	}))
+	t.Cleanup(server.Close)
+	config.OllamaURL = server.URL
+
+	var err error
+	captureStdout(t, func() {
+		err = IndexDocumentsContext(ctx, root, config, 4000, 15, 0.25)
+	})
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "indexing interrupted with 2 of 3 files indexed") {
+		t.Fatalf("expected an interruption error, got %v", err)
+	}
+	if !flushed.Load() {
+		t.Fatalf("expected the database to be saved before the last file with -flush-interval 1")
+	}
+
+	// Indexing again embeds only the file the interruption stopped, besides the preflight check
+	var embedded []string
+	resumeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Prompt != preflightText {
+			embedded = append(embedded, req.Prompt)
+		}
+		json.NewEncoder(w).Encode(OllamaEmbeddingResponse{Embedding: testEmbedding(req.Prompt, 8)})
+	}))
+	t.Cleanup(resumeServer.Close)
+	config.OllamaURL = resumeServer.URL
+
+	captureStdout(t, func() {
+		err = IndexDocuments(root, config, 4000, 15, 0.25)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error resuming: %v", err)
+	}
+	if len(embedded) != 1 || embedded[0] != "interrupted notes" {
+		t.Fatalf("expected only the interrupted file to be embedded on resume, got %q", embedded)
+	}
+	if docs := readTestDatabase(t, config); len(docs) != 3 {
+		t.Fatalf("expected all 3 files indexed after resuming, found %d documents", len(docs))
+	}
+}
+
+func BenchmarkIndexFile(b *testing.B) {
+	config := newTestConfig(b, 64)
+	config.NoCache = true
+	root := writeTestFiles(b, map[string]string{
+		"large.md": strings.Repeat("# Section\n\nSome paragraph text that repeats. ", 2000),
+	})
+	filePath := filepath.Join(root, "large.md")
+
+	collection, err := chromem.NewDB().CreateCollection("documents", nil, CreateEmbeddingFunc(config))
+	if err != nil {
+		b.Fatalf("failed to create collection: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		indexFile(context.Background(), collection, filePath, nil, config, nil, nil, 4000, 15, HeuristicTokenCounter{TokensPerChar: 0.25})
+	}
+}
+
+func TestIndexDocumentsReportsProgressEvents(t *testing.T) {
+	config := newTestConfig(t, 8)
+	var events []ProgressEvent
+	config.Progress = func(event ProgressEvent) {
+		events = append(events, event)
+	}
+	docsDir := writeTestFiles(t, map[string]string{
+		"small.md": "# Small\n\nA short note.\n",
+		"large.md": "# Large\n\n" + strings.Repeat("A sentence that fills the large file. ", 60),
+	})
+
+	output := captureStdout(t, func() {
+		if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+			t.Fatalf("unexpected indexing error: %v", err)
+		}
+	})
+	if output != "" {
+		t.Fatalf("expected progress to go to the reporter only, got:\n%s", output)
+	}
+
+	kinds := make(map[string][]ProgressKind)
+	var started, done int
+	for _, event := range events {
+		switch event.Kind {
+		case ProgressIndexStarted:
+			started++
+		case ProgressIndexDone:
+			done++
+			if event.FileCount != 2 {
+				t.Fatalf("expected the done event to count 2 files, got %d", event.FileCount)
+			}
+		case ProgressFileStarted, ProgressFileChunked, ProgressFileEmbedded, ProgressFileDone:
+			kinds[filepath.Base(event.FilePath)] = append(kinds[filepath.Base(event.FilePath)], event.Kind)
+		}
+	}
+	if started != 1 || done != 1 || events[0].Kind != ProgressIndexStarted {
+		t.Fatalf("expected one started event first and one done event, got %d and %d", started, done)
+	}
+	if want := []ProgressKind{ProgressFileStarted, ProgressFileEmbedded, ProgressFileDone}; !reflect.DeepEqual(kinds["small.md"], want) {
+		t.Fatalf("small.md events = %v, want %v", kinds["small.md"], want)
+	}
+	if want := []ProgressKind{ProgressFileStarted, ProgressFileChunked, ProgressFileEmbedded, ProgressFileDone}; !reflect.DeepEqual(kinds["large.md"], want) {
+		t.Fatalf("large.md events = %v, want %v", kinds["large.md"], want)
+	}
+}
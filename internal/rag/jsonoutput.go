@@ -0,0 +1,98 @@
+package rag
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strconv"
+)
+
+// JSONChunk is the machine-readable form of a stored chunk or search hit. It is encoded as an
+// object holding file_path, similarity for search hits, and then the selected metadata fields in
+// the order they were selected, null when a chunk has no value for one.
+type JSONChunk struct {
+	FilePath   string
+	Similarity *float32 // Only set for search results
+	Fields     []metadataField
+}
+
+// MarshalJSON encodes the chunk with its fields in order and typed by key
+func (c JSONChunk) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"file_path":`)
+	if err := marshalJSONValue(&buf, c.FilePath); err != nil {
+		return nil, err
+	}
+	if c.Similarity != nil {
+		buf.WriteString(`,"similarity":`)
+		if err := marshalJSONValue(&buf, *c.Similarity); err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range c.Fields {
+		buf.WriteByte(',')
+		if err := marshalJSONValue(&buf, field.Key); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(':')
+		if err := marshalJSONValue(&buf, field.jsonValue()); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// JSONSearchResults is the -json output of a CLI search
+type JSONSearchResults struct {
+	Query           string      `json:"query"`
+	Offset          int         `json:"offset,omitempty"` // Best results skipped before Results
+	HasMore         bool        `json:"has_more"`         // Whether results follow Results
+	Results         []JSONChunk `json:"results"`
+	NoResultsReason string      `json:"no_results_reason,omitempty"` // Why Results is empty
+}
+
+// JSONFile is the -json output for one file when listing documents
+type JSONFile struct {
+	FilePath     string      `json:"file_path"`
+	FileHash     string      `json:"file_hash"`
+	FileSize     int64       `json:"file_size"`
+	LastModified string      `json:"last_modified"`
+	Chunks       []JSONChunk `json:"chunks"`
+}
+
+// toJSONChunk converts the metadata of a stored document into its JSON form with the selected fields
+func toJSONChunk(metadata map[string]string, fields []string) JSONChunk {
+	return JSONChunk{
+		FilePath: metadata["file_path"],
+		Fields:   selectFields(metadata, fields),
+	}
+}
+
+// toJSONFiles converts a file inventory into its JSON form
+func toJSONFiles(files []FileInventory, fields []string) []JSONFile {
+	jsonFiles := make([]JSONFile, 0, len(files))
+	for _, inventory := range files {
+		fileSize, _ := strconv.ParseInt(inventory.FileSize, 10, 64)
+		jsonFile := JSONFile{
+			FilePath:     inventory.FilePath,
+			FileHash:     inventory.FileHash,
+			FileSize:     fileSize,
+			LastModified: inventory.LastModified,
+			Chunks:       make([]JSONChunk, 0, len(inventory.Chunks)),
+		}
+		for _, chunk := range inventory.Chunks {
+			jsonFile.Chunks = append(jsonFile.Chunks, toJSONChunk(chunk.Metadata, fields))
+		}
+		jsonFiles = append(jsonFiles, jsonFile)
+	}
+	return jsonFiles
+}
+
+// writeJSON writes v to stdout as indented JSON
+func writeJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false) // Keep heading paths like "Guide > Setup" readable
+	return encoder.Encode(v)
+}
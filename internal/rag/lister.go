@@ -3,9 +3,7 @@ package rag
 import (
 	"context"
 	"fmt"
-	"os"
 	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/philippgille/chromem-go"
@@ -13,32 +11,24 @@ import (
 
 // ListDocuments lists all documents in the database
 func ListDocuments(config Config) error {
+	if config.JSON {
+		files, err := MCPListDocuments(config, "", 0)
+		if err != nil {
+			return err
+		}
+		return writeJSON(map[string][]JSONFile{"files": toJSONFiles(files, outputFields(config))})
+	}
+
 	fmt.Println("Database Contents")
 	fmt.Println("=================")
 	fmt.Printf("Database: %s\n\n", config.DBPath)
 
-	// Load database
-	if _, err := os.Stat(config.DBPath); os.IsNotExist(err) {
-		fmt.Println("Database not found. Please run indexing first with -index")
-		return nil
-	}
-
-	db := chromem.NewDB()
-	file, err := os.Open(config.DBPath)
+	collection, release, err := loadCollection(config)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
-	defer file.Close()
+	defer release()
 
-	err = db.ImportFromReader(file, "")
-	if err != nil {
-		return fmt.Errorf("failed to load database: %w", err)
-	}
-
-	// Create embedding function for Ollama (needed for GetCollection)
-	embeddingFunc := CreateEmbeddingFunc(config)
-
-	collection := db.GetCollection("documents", embeddingFunc)
 	if collection == nil {
 		fmt.Println("No documents collection found in database.")
 		return nil
@@ -53,73 +43,55 @@ func ListDocuments(config Config) error {
 
 	fmt.Printf("Total documents: %d\n\n", count)
 
-	// Get all documents by querying with a generic term that should match most content
-	results, err := collection.Query(context.Background(), "text document file", count, nil, nil)
+	results, err := allDocuments(context.Background(), collection)
 	if err != nil {
 		return fmt.Errorf("failed to get documents: %w", err)
 	}
 
 	// Group results by file for better display
-	fileGroups := make(map[string][]chromem.Result)
-	for _, result := range results {
-		filePath := result.Metadata["file_path"]
-		fileGroups[filePath] = append(fileGroups[filePath], result)
+	files := groupDocumentsByFile(results)
+
+	// File-level fields are shown once per file rather than with every chunk, and the chunk
+	// index labels each chunk. Without -fields, chunks are shown in a fixed layout instead.
+	var chunkFields []string
+	for _, field := range config.Fields {
+		if !fileLevelFields[field] && field != "chunk_index" {
+			chunkFields = append(chunkFields, field)
+		}
 	}
 
-	// Sort file paths for consistent display
-	var sortedFiles []string
-	for filePath := range fileGroups {
-		sortedFiles = append(sortedFiles, filePath)
-	}
-	sort.Strings(sortedFiles)
-
 	fileIndex := 1
 	totalChunks := 0
 
 	// Display each file and its chunks
-	for _, filePath := range sortedFiles {
-		fileResults := fileGroups[filePath]
-
-		// Sort chunks by chunk index
-		sort.Slice(fileResults, func(i, j int) bool {
-			indexI, _ := strconv.Atoi(fileResults[i].Metadata["chunk_index"])
-			indexJ, _ := strconv.Atoi(fileResults[j].Metadata["chunk_index"])
-			return indexI < indexJ
-		})
+	for _, inventory := range files {
+		filePath := inventory.FilePath
+		fileResults := inventory.Chunks
 
 		isChunked := len(fileResults) > 1 || fileResults[0].Metadata["is_chunk"] == "true"
 
 		fmt.Printf("File %d: %s\n", fileIndex, filePath)
-		fmt.Printf("  File Hash:      %s\n", fileResults[0].Metadata["file_hash"])
-		fmt.Printf("  File Size:      %s bytes\n", fileResults[0].Metadata["file_size"])
-		fmt.Printf("  Last Modified:  %s\n", fileResults[0].Metadata["last_modified"])
+		fmt.Printf("  File Hash:      %s\n", inventory.FileHash)
+		fmt.Printf("  File Size:      %s bytes\n", inventory.FileSize)
+		fmt.Printf("  Last Modified:  %s\n", inventory.LastModified)
 
 		if isChunked {
 			fmt.Printf("  Chunks:         %d\n", len(fileResults))
 
 			for j, result := range fileResults {
-				chunkIndex := result.Metadata["chunk_index"]
-				tokenCount := result.Metadata["token_count"]
-				headingPath := result.Metadata["heading_path"]
-				startOffset := result.Metadata["start_offset"]
-				endOffset := result.Metadata["end_offset"]
-
-				fmt.Printf("    Chunk %s: %s tokens, chars %s-%s\n",
-					chunkIndex, tokenCount, startOffset, endOffset)
-
-				if headingPath != "" {
-					fmt.Printf("      Context: %s\n", headingPath)
+				if len(config.Fields) == 0 {
+					fmt.Printf("    Chunk %s: %s tokens, chars %s-%s\n",
+						result.Metadata["chunk_index"], result.Metadata["token_count"], result.Metadata["start_offset"], result.Metadata["end_offset"])
+					if headingPath := result.Metadata["heading_path"]; headingPath != "" {
+						fmt.Printf("      Context: %s\n", headingPath)
+					}
+				} else {
+					fmt.Printf("    Chunk %s: %s\n", result.Metadata["chunk_index"], formatFieldsInline(selectFields(result.Metadata, chunkFields)))
 				}
 
 				// Show preview of first chunk only to avoid clutter
 				if j == 0 {
-					content := result.Content
-					if len(content) > 100 {
-						content = content[:100] + "..."
-					}
-					content = strings.ReplaceAll(content, "\n", " ")
-					content = strings.ReplaceAll(content, "\r", "")
-					fmt.Printf("      Preview: %s\n", content)
+					fmt.Printf("      Preview: %s\n", previewText(result.Content, 100))
 				}
 
 				totalChunks++
@@ -127,18 +99,18 @@ func ListDocuments(config Config) error {
 		} else {
 			// Single document (not chunked)
 			result := fileResults[0]
-			tokenCount := result.Metadata["token_count"]
-			fmt.Printf("  Token Count:    %s\n", tokenCount)
-			fmt.Printf("  Indexed At:     %s\n", result.Metadata["indexed_at"])
+			if len(config.Fields) == 0 {
+				fmt.Printf("  Token Count:    %s\n", result.Metadata["token_count"])
+				fmt.Printf("  Indexed At:     %s\n", result.Metadata["indexed_at"])
+			}
+			for _, field := range selectFields(result.Metadata, chunkFields) {
+				if field.Value != "" {
+					fmt.Printf("  %-15s %s\n", field.Label()+":", field.Value)
+				}
+			}
 
 			// Show content preview
-			content := result.Content
-			if len(content) > 100 {
-				content = content[:100] + "..."
-			}
-			content = strings.ReplaceAll(content, "\n", " ")
-			content = strings.ReplaceAll(content, "\r", "")
-			fmt.Printf("  Content Preview: %s\n", content)
+			fmt.Printf("  Content Preview: %s\n", previewText(result.Content, 100))
 
 			totalChunks++
 		}
@@ -147,7 +119,89 @@ func ListDocuments(config Config) error {
 		fileIndex++
 	}
 
-	fmt.Printf("Summary: %d files, %d total chunks/documents\n", len(sortedFiles), totalChunks)
+	fmt.Printf("Summary: %d files, %d total chunks/documents\n", len(files), totalChunks)
 
 	return nil
 }
+
+// FileInventory represents an indexed file along with its stored chunks
+type FileInventory struct {
+	FilePath     string
+	FileHash     string
+	FileSize     string
+	LastModified string
+	Chunks       []chromem.Result // Sorted by chunk index
+}
+
+// groupDocumentsByFile groups documents by file path, sorted by path with chunks sorted by chunk index
+func groupDocumentsByFile(results []chromem.Result) []FileInventory {
+	fileGroups := make(map[string][]chromem.Result)
+	for _, result := range results {
+		filePath := result.Metadata["file_path"]
+		fileGroups[filePath] = append(fileGroups[filePath], result)
+	}
+
+	// Sort file paths for consistent display
+	var sortedFiles []string
+	for filePath := range fileGroups {
+		sortedFiles = append(sortedFiles, filePath)
+	}
+	sort.Strings(sortedFiles)
+
+	files := make([]FileInventory, 0, len(sortedFiles))
+	for _, filePath := range sortedFiles {
+		fileResults := fileGroups[filePath]
+
+		// Sort chunks by chunk index
+		sort.Slice(fileResults, func(i, j int) bool {
+			return compareDocuments(fileResults[i], fileResults[j]) < 0
+		})
+
+		files = append(files, FileInventory{
+			FilePath:     filePath,
+			FileHash:     fileResults[0].Metadata["file_hash"],
+			FileSize:     fileResults[0].Metadata["file_size"],
+			LastModified: fileResults[0].Metadata["last_modified"],
+			Chunks:       fileResults,
+		})
+	}
+
+	return files
+}
+
+// MCPListDocuments returns the file inventory for MCP, optionally filtered by path prefix
+// and limited to maxFiles files (0 means no limit)
+func MCPListDocuments(config Config, pathPrefix string, maxFiles int) ([]FileInventory, error) {
+	collection, release, err := loadCollection(config)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if collection == nil {
+		return nil, fmt.Errorf("documents collection not found in database")
+	}
+
+	count := collection.Count()
+	if count == 0 {
+		return nil, nil
+	}
+
+	results, err := allDocuments(context.Background(), collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	var files []FileInventory
+	for _, inventory := range groupDocumentsByFile(results) {
+		if pathPrefix != "" && !strings.HasPrefix(inventory.FilePath, pathPrefix) {
+			continue
+		}
+		if maxFiles > 0 && len(files) >= maxFiles {
+			break
+		}
+		files = append(files, inventory)
+	}
+
+	return files, nil
+}
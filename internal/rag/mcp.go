@@ -2,19 +2,52 @@ package rag
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/philippgille/chromem-go"
 )
 
+// Orders for chunks within a file in rag_search results
+const (
+	ChunkOrderPosition   = "position"
+	ChunkOrderSimilarity = "similarity"
+)
+
+// Entry types kept when a file matches through both a whole-file entry and chunk entries, as a
+// file re-indexed under different chunking can until its stale entries are pruned
+const (
+	MixedEntriesChunks = "chunks"
+	MixedEntriesFile   = "file"
+)
+
+// Transports the MCP server can listen on
+const (
+	MCPTransportStdio = "stdio"
+	MCPTransportHTTP  = "http"
+)
+
+// mcpShutdownTimeout bounds how long the HTTP transport waits for in-flight requests on shutdown
+const mcpShutdownTimeout = 10 * time.Second
+
+// DefaultSnippetLength is the number of characters of matched content rag_search includes
+// when include_snippet is set without a snippet_length
+const DefaultSnippetLength = 300
+
 // SearchResult represents a search result with file and chunk information
 type SearchResult struct {
+	ID          string // Chunk ID, accepted by rag_retrieve as chunk_id
 	FilePath    string
 	Similarity  float32
 	IsChunk     bool
@@ -23,16 +56,25 @@ type SearchResult struct {
 	EndOffset   int
 	TokenCount  int
 	HeadingPath string
+	Content     string
+	NeighborOf  string            // ID of the matched chunk this adjacent chunk was included as context for
+	Duplicates  []string          // Other files with the same content, indexed once under -dedup-files
+	Metadata    map[string]string // Every metadata key stored for the chunk, such as title, tags, and last_modified
+
+	// Embedding that produced the stored vector, empty for documents indexed before it was recorded
+	EmbeddingMode  string
+	EmbeddingModel string
 }
 
 // FileSearchResults groups search results by file
 type FileSearchResults struct {
-	FilePath string
-	Chunks   []SearchResult
+	FilePath   string
+	Duplicates []string // Other files with the same content, indexed once under -dedup-files
+	Chunks     []SearchResult
 }
 
 // RunMCPServer starts the MCP server with RAG tools
-func RunMCPServer(config Config) error {
+func RunMCPServer(config Config, maxTokensPerChunk, chunkOverlapPercent int, approxTokensPerChar float64) error {
 	// Create a new MCP server
 	s := server.NewMCPServer(
 		"Markdown RAG Server",
@@ -51,14 +93,102 @@ func RunMCPServer(config Config) error {
 		mcp.WithNumber("max_results",
 			mcp.Description("Maximum number of results to return (default: 10)"),
 		),
+		mcp.WithNumber("offset",
+			mcp.Description("Skip this many of the best results, to page through them with max_results; the response says whether more follow (default: 0)"),
+		),
+		mcp.WithString("search_mode",
+			mcp.Description("Search mode: 'vector' for semantic similarity or 'hybrid' to blend in BM25 keyword matching (default: vector)"),
+			mcp.Enum(SearchModeVector, SearchModeHybrid),
+		),
+		mcp.WithNumber("alpha",
+			mcp.Description("Weight of vector similarity in hybrid mode, from 0 (keywords only) to 1 (vectors only)"),
+		),
+		mcp.WithString("heading_filter",
+			mcp.Description("Only return chunks whose heading path contains this text (case-insensitive), e.g. 'Installation'"),
+		),
+		mcp.WithString("tag",
+			mcp.Description("Only return documents whose frontmatter tags include this tag (case-insensitive)"),
+		),
+		mcp.WithBoolean("open_tasks",
+			mcp.Description("Only return chunks containing unchecked task-list items (- [ ]); needs an index built with -extract-tasks (default: false)"),
+		),
+		mcp.WithBoolean("include_snippet",
+			mcp.Description("Include the start of each matched chunk's content so relevance can be judged without rag_retrieve (default: false)"),
+		),
+		mcp.WithNumber("snippet_length",
+			mcp.Description("Maximum characters per snippet when include_snippet is true (default: 300)"),
+		),
+		mcp.WithNumber("min_similarity",
+			mcp.Description("Drop results scoring below this similarity (default: the server's configured minimum)"),
+		),
+		mcp.WithString("prefer_region",
+			mcp.Description("Boost chunks from this part of their document, e.g. beginning for summary questions"),
+			mcp.Enum(RegionBeginning, RegionMiddle, RegionEnd),
+		),
+		mcp.WithNumber("recency_boost",
+			mcp.Description("Favor recently modified documents, from 0 (off) to 1. Each score is multiplied by 1 - recency_boost + recency_boost * 0.5^(age in days / half-life), so newly modified documents keep their similarity and older ones lose up to recency_boost of it (default: the server's configured boost)"),
+		),
+		mcp.WithNumber("recency_half_life_days",
+			mcp.Description("Age in days at which the recency decay reaches one half (default: the server's configured half-life)"),
+		),
+		mcp.WithBoolean("rerank",
+			mcp.Description("Re-sort the retrieved results by BM25 keyword overlap with the query, so chunks using its exact words come first; only the results up to offset + max_results are re-sorted (default: the server's -rerank setting)"),
+		),
+		mcp.WithString("output",
+			mcp.Description("Response format: 'markdown' to describe the matches, or 'plan' for JSON rag_retrieve calls covering the top matches within token_budget (default: markdown)"),
+			mcp.Enum(SearchOutputMarkdown, SearchOutputPlan),
+		),
+		mcp.WithNumber("token_budget",
+			mcp.Description("Maximum total tokens the rag_retrieve calls of a plan may return, and the neighbor chunks include_neighbors adds (default: 8000)"),
+		),
+		mcp.WithBoolean("include_provenance",
+			mcp.Description("Show the embedding mode and model that produced each match's vector, to interpret scores in merged databases (default: false)"),
+		),
+		mcp.WithBoolean("include_cost",
+			mcp.Description("Show the estimated tokens rag_retrieve would return for each match, from its indexed token_count, with a running total in result order, to prioritize cheap retrievals (default: false)"),
+		),
+		mcp.WithBoolean("include_neighbors",
+			mcp.Description("Also return the chunks immediately before and after each matched chunk in its file, with their content, marked as context (default: false)"),
+		),
+		mcp.WithString("group_by",
+			mcp.Description("Present results as files ranked by their best match, each with its matching chunks, or as a flat ranked list of the best chunks regardless of file (default: file)"),
+			mcp.Enum(GroupByFile, GroupByChunk),
+		),
+		mcp.WithNumber("max_chunks_per_file",
+			mcp.Description("Return at most this many results from any one file, so a large file cannot crowd out the others; lower-ranked results of other files fill the freed places (default: no limit)"),
+		),
+	)
+
+	// Add the multi-query search tool
+	multiSearchTool := mcp.NewTool("rag_multi_search",
+		mcp.WithDescription("Search with several phrasings of the same question and fuse their rankings with reciprocal rank fusion, to find relevant chunks a single phrasing misses. Returns each chunk once, with the rank every query gave it."),
+		mcp.WithArray("queries",
+			mcp.Required(),
+			mcp.Description("Variants of the search query, such as rephrasings, synonyms, or more and less specific wordings"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithNumber("max_results",
+			mcp.Description("Maximum number of fused results to return (default: 10)"),
+		),
+		mcp.WithString("heading_filter",
+			mcp.Description("Only return chunks whose heading path contains this text (case-insensitive), e.g. 'Installation'"),
+		),
+		mcp.WithString("tag",
+			mcp.Description("Only return documents whose frontmatter tags include this tag (case-insensitive)"),
+		),
+		mcp.WithNumber("min_similarity",
+			mcp.Description("Drop each query's results scoring below this similarity (default: the server's configured minimum)"),
+		),
 	)
 
 	// Add the file retrieval tool
 	retrieveTool := mcp.NewTool("rag_retrieve",
-		mcp.WithDescription("Retrieve specific content from a file, optionally specifying start and end positions for chunked content."),
+		mcp.WithDescription("Retrieve specific content from a file, optionally specifying start and end positions for chunked content, or retrieve a chunk by the chunk_id rag_search returned."),
 		mcp.WithString("file_path",
-			mcp.Required(),
-			mcp.Description("The path to the file to retrieve content from"),
+			mcp.Description("The path to the file to retrieve content from (required unless chunk_id is given)"),
+		),
+		mcp.WithString("chunk_id",
+			mcp.Description("ID of a chunk from rag_search results; retrieves that chunk instead of a file range"),
 		),
 		mcp.WithNumber("start_offset",
 			mcp.Description("Starting character position (0-based). If not specified, returns from beginning of file."),
@@ -66,6 +196,79 @@ func RunMCPServer(config Config) error {
 		mcp.WithNumber("end_offset",
 			mcp.Description("Ending character position (0-based). If not specified, returns to end of file."),
 		),
+		mcp.WithNumber("context_chars",
+			mcp.Description("Also return up to this many characters before and after the range or chunk, for surrounding context (default: 0)"),
+		),
+		mcp.WithBoolean("snap_to_lines",
+			mcp.Description("Widen the range or chunk to whole lines, so content never starts or ends mid-line; the reported range is adjusted to match (default: false, exact offsets)"),
+		),
+		mcp.WithBoolean("show_diff",
+			mcp.Description("If the file changed since it was indexed, also return a unified diff from the indexed text of the chunk or range to the file's current text"),
+		),
+	)
+
+	// Add the section retrieval tool
+	sectionTool := mcp.NewTool("rag_retrieve_section",
+		mcp.WithDescription("Retrieve the section of a markdown file under a heading, from the heading to the next heading of the same or higher level, without needing character offsets."),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("The path to the file to retrieve the section from"),
+		),
+		mcp.WithString("heading",
+			mcp.Required(),
+			mcp.Description("Heading text, matched case-insensitively, or a heading path such as 'API > Authentication' to pick among sections with the same heading"),
+		),
+	)
+
+	// Add the document inventory tool
+	listTool := mcp.NewTool("rag_list",
+		mcp.WithDescription("List the files currently indexed in the RAG database with their chunk counts, sizes, and modification times."),
+		mcp.WithString("path_prefix",
+			mcp.Description("Only list files whose path starts with this prefix"),
+		),
+		mcp.WithNumber("max_files",
+			mcp.Description("Maximum number of files to return (default: all)"),
+		),
+	)
+
+	// Add the database statistics tool
+	statsTool := mcp.NewTool("rag_stats",
+		mcp.WithDescription("Get statistics about the RAG database: file and chunk counts, token totals, and file size aggregates."),
+	)
+
+	// Add the reindex tool
+	reindexTool := mcp.NewTool("rag_reindex",
+		mcp.WithDescription("Index the markdown files in a directory, as the -index CLI option does, and summarize the files added, updated, unchanged, and skipped. Unchanged content is served from the embedding cache."),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Directory to index"),
+		),
+	)
+
+	// Add the delete tool
+	deleteTool := mcp.NewTool("rag_delete",
+		mcp.WithDescription("Remove every chunk of a file from the RAG index and save it, without reindexing, for example when a document becomes sensitive or obsolete. The file on disk is not touched, so delete or exclude it too or the next reindex adds it back."),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path of the indexed file to remove, as rag_search and rag_list report it"),
+		),
+	)
+
+	// Add the chunk explanation tool
+	explainTool := mcp.NewTool("rag_explain",
+		mcp.WithDescription("Show exactly what a search result matched: the full stored content of a chunk, all of its metadata, and, when a query is given, the chunk's vector similarity to that query. Useful for debugging why a chunk ranked where it did."),
+		mcp.WithString("chunk_id",
+			mcp.Description("ID of a chunk from rag_search results (required unless file_path is given)"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("Path of an indexed file, to pick its chunk by chunk_index instead of by ID"),
+		),
+		mcp.WithNumber("chunk_index",
+			mcp.Description("Index of the chunk within file_path, as search results report it (default: 0)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Query to compute the chunk's similarity to, embedded the same way rag_search embeds queries"),
+		),
 	)
 
 	// Add the search tool handler
@@ -77,77 +280,143 @@ func RunMCPServer(config Config) error {
 
 		maxResults := request.GetInt("max_results", 10)
 
-		// Perform the search
-		results, err := MCPSearchDocumentsWithResults(query, config, maxResults)
+		callConfig := searchCallConfig(config, request)
+		callConfig.MaxChunksPerFile = request.GetInt("max_chunks_per_file", 0)
+
+		groupBy := request.GetString("group_by", GroupByFile)
+		if groupBy != GroupByFile && groupBy != GroupByChunk {
+			return mcp.NewToolResultError(fmt.Sprintf("Unknown group_by: %s", groupBy)), nil
+		}
+
+		searchMode := request.GetString("search_mode", SearchModeVector)
+		alpha := request.GetFloat("alpha", config.HybridAlpha)
+
+		// Perform the search, asking for one extra result to learn whether another page follows
+		var results []SearchResult
+		switch searchMode {
+		case SearchModeVector:
+			results, err = searchDocumentsWithResults(ctx, query, callConfig, maxResults+1)
+		case SearchModeHybrid:
+			results, err = hybridSearch(ctx, query, callConfig, maxResults+1, alpha)
+		default:
+			return mcp.NewToolResultError(fmt.Sprintf("Unknown search_mode: %s", searchMode)), nil
+		}
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
 		}
+		hasMore := len(results) > maxResults
+		if hasMore {
+			results = results[:maxResults]
+		}
 
-		// Group results by file
-		fileResults := groupResultsByFile(results)
+		tokenBudget := request.GetInt("token_budget", DefaultPlanTokenBudget)
+		if request.GetBool("include_neighbors", false) {
+			results, err = MCPIncludeNeighbors(callConfig, results, tokenBudget)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to include neighbor chunks: %v", err)), nil
+			}
+		}
 
-		// Format the response
-		var response strings.Builder
-		response.WriteString(fmt.Sprintf("Found %d relevant file(s) for query: \"%s\"\n\n", len(fileResults), query))
-
-		for i, fileResult := range fileResults {
-			response.WriteString(fmt.Sprintf("**File %d:** `%s`\n", i+1, fileResult.FilePath))
-
-			if len(fileResult.Chunks) == 1 && !fileResult.Chunks[0].IsChunk {
-				// Entire file match
-				chunk := fileResult.Chunks[0]
-				response.WriteString(fmt.Sprintf("- **Similarity:** %.4f\n", chunk.Similarity))
-				response.WriteString("- **Type:** Complete file\n")
-			} else {
-				// Multiple chunks or single chunk
-				response.WriteString(fmt.Sprintf("- **Relevant chunks:** %d\n", len(fileResult.Chunks)))
-				for j, chunk := range fileResult.Chunks {
-					response.WriteString(fmt.Sprintf("  - **Chunk %d:**\n", j+1))
-					response.WriteString(fmt.Sprintf("    - Similarity: %.4f\n", chunk.Similarity))
-					response.WriteString(fmt.Sprintf("    - Range: characters %d-%d (%d tokens)\n",
-						chunk.StartOffset, chunk.EndOffset, chunk.TokenCount))
-					if chunk.HeadingPath != "" {
-						response.WriteString(fmt.Sprintf("    - Context: %s\n", chunk.HeadingPath))
-					}
-				}
+		if request.GetString("output", SearchOutputMarkdown) == SearchOutputPlan {
+			plan := buildRetrievePlan(query, results, tokenBudget)
+			text, err := formatRetrievePlan(plan)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to format plan: %v", err)), nil
 			}
-			response.WriteString("\n")
+			return mcp.NewToolResultText(text), nil
 		}
 
-		response.WriteString("**Next Steps:**\n")
-		response.WriteString("Use the `rag_retrieve` tool to get the actual content from specific files and ranges.\n")
-		response.WriteString("Example: `rag_retrieve` with `file_path` and optionally `start_offset` and `end_offset`\n")
+		snippetLength := 0
+		if request.GetBool("include_snippet", false) {
+			snippetLength = request.GetInt("snippet_length", DefaultSnippetLength)
+		}
+		provenance := request.GetBool("include_provenance", false)
+		cost := request.GetBool("include_cost", false)
 
-		return mcp.NewToolResultText(response.String()), nil
+		var response string
+		if groupBy == GroupByChunk {
+			response = formatFlatSearchResponse(query, results, snippetLength, provenance, cost)
+		} else {
+			fileResults := groupResultsByFile(results, config.ChunkOrder, config.MixedEntries)
+			response = formatSearchResponse(query, fileResults, snippetLength, provenance, cost)
+		}
+		return mcp.NewToolResultText(response + formatSearchPage(callConfig.SearchOffset, maxResults, hasMore)), nil
 	})
 
-	// Add the retrieve tool handler
-	s.AddTool(retrieveTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		filePath, err := request.RequireString("file_path")
+	// Add the multi-query search tool handler
+	s.AddTool(multiSearchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		queries := nonEmptyQueries(request.GetStringSlice("queries", nil))
+		if len(queries) == 0 {
+			return mcp.NewToolResultError("Error getting queries parameter: at least one non-empty query is required"), nil
+		}
+
+		maxResults := request.GetInt("max_results", 10)
+		callConfig := searchCallConfig(config, request)
+
+		results, err := multiSearch(ctx, queries, callConfig, maxResults)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Error getting file_path parameter: %v", err)), nil
+			return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
 		}
 
+		return mcp.NewToolResultText(formatMultiSearchResponse(queries, results)), nil
+	})
+
+	// Add the retrieve tool handler
+	s.AddTool(retrieveTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		contextChars := max(request.GetInt("context_chars", 0), 0)
+		snapToLines := request.GetBool("snap_to_lines", false)
+		showDiff := request.GetBool("show_diff", false)
+
+		var filePath string
 		var startOffset, endOffset *int
+		var indexed *MCPSearchResult
+		if chunkID := request.GetString("chunk_id", ""); chunkID != "" {
+			chunk, err := MCPRetrieveChunk(config, chunkID)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Retrieval failed: %v", err)), nil
+			}
+			if contextChars == 0 && !snapToLines {
+				response := formatChunkResponse(chunkID, chunk)
+				if showDiff {
+					response += formatDrift(chunk, nil)
+				}
+				return mcp.NewToolResultText(response), nil
+			}
+			indexed = chunk
+
+			// Context and whole lines come from the file, around the range the chunk was indexed from
+			filePath = chunk.FilePath
+			start, _ := strconv.Atoi(chunk.Metadata["start_offset"])
+			end, _ := strconv.Atoi(chunk.Metadata["end_offset"])
+			startOffset, endOffset = &start, &end
+		} else {
+			var err error
+			filePath, err = request.RequireString("file_path")
+			if err != nil {
+				return mcp.NewToolResultError("Either file_path or chunk_id is required"), nil
+			}
+			// Relative paths, as in a database indexed with -root, are read from the root
+			filePath = resolveFilePath(filePath, config)
 
-		// Get optional start_offset
-		if args := request.GetArguments(); args != nil {
-			if startFloat, ok := args["start_offset"].(float64); ok {
-				start := int(startFloat)
-				startOffset = &start
+			// Get optional start_offset
+			if args := request.GetArguments(); args != nil {
+				if startFloat, ok := args["start_offset"].(float64); ok {
+					start := int(startFloat)
+					startOffset = &start
+				}
 			}
-		}
 
-		// Get optional end_offset
-		if args := request.GetArguments(); args != nil {
-			if endFloat, ok := args["end_offset"].(float64); ok {
-				end := int(endFloat)
-				endOffset = &end
+			// Get optional end_offset
+			if args := request.GetArguments(); args != nil {
+				if endFloat, ok := args["end_offset"].(float64); ok {
+					end := int(endFloat)
+					endOffset = &end
+				}
 			}
 		}
 
 		// Retrieve the content
-		content, err := MCPRetrieveFileContent(filePath, startOffset, endOffset)
+		content, start, end, err := MCPRetrieveFileRange(filePath, startOffset, endOffset, contextChars, snapToLines)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("Retrieval failed: %v", err)), nil
 		}
@@ -157,15 +426,14 @@ func RunMCPServer(config Config) error {
 		response.WriteString(fmt.Sprintf("**File:** `%s`\n", filePath))
 
 		if startOffset != nil || endOffset != nil {
-			start := 0
-			if startOffset != nil {
-				start = *startOffset
+			response.WriteString(fmt.Sprintf("**Range:** characters %d-%d", start, end))
+			if contextChars > 0 {
+				response.WriteString(fmt.Sprintf(" (including up to %d characters of context on each side)", contextChars))
 			}
-			end := len(content)
-			if endOffset != nil {
-				end = *endOffset
+			if snapToLines {
+				response.WriteString(" (widened to whole lines)")
 			}
-			response.WriteString(fmt.Sprintf("**Range:** characters %d-%d\n", start, end))
+			response.WriteString("\n")
 		} else {
 			response.WriteString("**Range:** Complete file\n")
 		}
@@ -176,106 +444,597 @@ func RunMCPServer(config Config) error {
 		response.WriteString(content)
 		response.WriteString("\n```")
 
+		if showDiff {
+			var err error
+			if indexed == nil {
+				indexed, err = MCPFindIndexedRange(config, filePath, startOffset, endOffset)
+			}
+			response.WriteString(formatDrift(indexed, err))
+		}
+
+		return mcp.NewToolResultText(response.String()), nil
+	})
+
+	// Add the section retrieval tool handler
+	s.AddTool(sectionTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		filePath, err := request.RequireString("file_path")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Error getting file_path parameter: %v", err)), nil
+		}
+		heading, err := request.RequireString("heading")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Error getting heading parameter: %v", err)), nil
+		}
+
+		section, err := MCPRetrieveSection(resolveFilePath(filePath, config), heading)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Retrieval failed: %v", err)), nil
+		}
+
+		return mcp.NewToolResultText(formatSectionResponse(filePath, section)), nil
+	})
+
+	// Add the list tool handler
+	s.AddTool(listTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		pathPrefix := request.GetString("path_prefix", "")
+		maxFiles := request.GetInt("max_files", 0)
+
+		// List every matching file so the total can be reported, then show the first maxFiles
+		files, err := MCPListDocuments(config, pathPrefix, 0)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("List failed: %v", err)), nil
+		}
+
+		return mcp.NewToolResultText(formatListResponse(files, pathPrefix, maxFiles)), nil
+	})
+
+	// Add the stats tool handler
+	s.AddTool(statsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		stats, err := ComputeStats(config)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Stats failed: %v", err)), nil
+		}
+
+		if stats.TotalChunks == 0 {
+			return mcp.NewToolResultText("No documents found in the database.\n"), nil
+		}
+
+		// Format the response
+		var response strings.Builder
+		response.WriteString("**Document Overview:**\n")
+		response.WriteString(fmt.Sprintf("- **Unique files:** %d\n", stats.UniqueFiles))
+		response.WriteString(fmt.Sprintf("- **Total chunks:** %d\n", stats.TotalChunks))
+		response.WriteString(fmt.Sprintf("- **Chunked files:** %d\n", stats.ChunkedFiles))
+		response.WriteString(fmt.Sprintf("- **Single doc files:** %d\n\n", stats.SingleDocFiles))
+
+		response.WriteString("**Token Statistics:**\n")
+		response.WriteString(fmt.Sprintf("- **Total tokens:** %d\n", stats.TotalTokens))
+		response.WriteString(fmt.Sprintf("- **Min tokens/chunk:** %d\n", stats.MinTokens))
+		response.WriteString(fmt.Sprintf("- **Max tokens/chunk:** %d\n", stats.MaxTokens))
+		response.WriteString(fmt.Sprintf("- **Avg tokens/chunk:** %.0f\n\n", stats.AvgTokensPerChunk))
+
+		response.WriteString("**File Size Statistics:**\n")
+		response.WriteString(fmt.Sprintf("- **Min file size:** %d bytes\n", stats.MinFileSize))
+		response.WriteString(fmt.Sprintf("- **Max file size:** %d bytes\n", stats.MaxFileSize))
+		response.WriteString(fmt.Sprintf("- **Avg file size:** %d bytes\n", stats.AvgFileSize))
+		response.WriteString(fmt.Sprintf("- **Total indexed:** %d bytes\n\n", stats.TotalFileSize))
+
+		response.WriteString("**Embedding Cache:**\n")
+		response.WriteString(fmt.Sprintf("- **Cached embeddings:** %d\n", stats.CacheEntries))
+		response.WriteString(fmt.Sprintf("- **Cache size:** %d bytes\n", stats.CacheSize))
+
+		if len(stats.Warnings) > 0 {
+			response.WriteString("\n**Warnings:**\n")
+			for _, warning := range stats.Warnings {
+				response.WriteString(fmt.Sprintf("- %s\n", strings.TrimPrefix(warning, "Warning: ")))
+			}
+		}
+
 		return mcp.NewToolResultText(response.String()), nil
 	})
 
+	// Add the reindex tool handler
+	s.AddTool(reindexTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path, err := request.RequireString("path")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Error getting path parameter: %v", err)), nil
+		}
+
+		summary, err := ReindexDocuments(path, config, maxTokensPerChunk, chunkOverlapPercent, approxTokensPerChar)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Reindex failed: %v", err)), nil
+		}
+
+		return mcp.NewToolResultText(formatReindexSummary(summary)), nil
+	})
+
+	// Add the delete tool handler
+	s.AddTool(deleteTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		filePath, err := request.RequireString("file_path")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Error getting file_path parameter: %v", err)), nil
+		}
+
+		removed, err := DeleteFile(filePath, config)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Delete failed: %v", err)), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Removed %d chunk(s) of `%s` from the index.\n", removed, filePath)), nil
+	})
+
+	// Add the chunk explanation tool handler
+	s.AddTool(explainTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		chunkID := request.GetString("chunk_id", "")
+		filePath := request.GetString("file_path", "")
+		if chunkID == "" && filePath == "" {
+			return mcp.NewToolResultError("Either chunk_id or file_path is required"), nil
+		}
+
+		explanation, err := MCPExplainChunk(config, chunkID, filePath, request.GetInt("chunk_index", 0), request.GetString("query", ""))
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Explain failed: %v", err)), nil
+		}
+
+		return mcp.NewToolResultText(formatExplanation(explanation)), nil
+	})
+
+	if config.MCPTransport == MCPTransportHTTP {
+		return serveHTTP(s, config.MCPAddr)
+	}
+
 	// Start the stdio server
 	return server.ServeStdio(s)
 }
 
+// serveHTTP serves the MCP server over streamable HTTP on addr until SIGINT or SIGTERM, then
+// shuts down gracefully, letting in-flight requests finish within mcpShutdownTimeout
+func serveHTTP(s *server.MCPServer, addr string) error {
+	httpServer := server.NewStreamableHTTPServer(s)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Fprintf(os.Stderr, "MCP server listening on http://%s/mcp\n", addr)
+		errCh <- httpServer.Start(addr)
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("failed to serve MCP over HTTP: %w", err)
+	case <-ctx.Done():
+	}
+
+	fmt.Fprintln(os.Stderr, "Shutting down MCP server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), mcpShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down MCP server: %w", err)
+	}
+	return nil
+}
+
+// formatSearchResponse formats grouped rag_search results as markdown, including up to
+// snippetLength characters of each match's content when snippetLength is positive, the
+// embedding that produced each match when provenance is set, and the estimated tokens of
+// retrieving each match, with a running total, when cost is set
+func formatSearchResponse(query string, fileResults []FileSearchResults, snippetLength int, provenance, cost bool) string {
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("Found %d relevant file(s) for query: \"%s\"\n\n", len(fileResults), query))
+	if cost {
+		response.WriteString(fmt.Sprintf("**Estimated retrieval cost:** ~%d tokens to retrieve every match\n\n", retrievalCost(fileResults)))
+	}
+
+	cumulative := 0
+
+	for i, fileResult := range fileResults {
+		response.WriteString(fmt.Sprintf("**File %d:** `%s`\n", i+1, fileResult.FilePath))
+		if len(fileResult.Duplicates) > 0 {
+			response.WriteString(fmt.Sprintf("- **Same content at:** %s\n", formatPathList(fileResult.Duplicates)))
+		}
+
+		if len(fileResult.Chunks) == 1 && !fileResult.Chunks[0].IsChunk {
+			// Entire file match
+			chunk := fileResult.Chunks[0]
+			response.WriteString(fmt.Sprintf("- **Similarity:** %.4f\n", chunk.Similarity))
+			response.WriteString("- **Type:** Complete file\n")
+			response.WriteString(fmt.Sprintf("- **Chunk ID:** `%s`\n", chunk.ID))
+			if provenance {
+				response.WriteString(fmt.Sprintf("- **Embedding:** %s\n", formatProvenance(chunk)))
+			}
+			if cost {
+				cumulative += chunk.TokenCount
+				response.WriteString(fmt.Sprintf("- **Retrieval cost:** ~%d tokens (cumulative ~%d)\n", chunk.TokenCount, cumulative))
+			}
+			if snippetLength > 0 {
+				response.WriteString(fmt.Sprintf("- **Snippet:** %s\n", previewText(chunk.Content, snippetLength)))
+			}
+		} else {
+			// Multiple chunks or single chunk
+			response.WriteString(fmt.Sprintf("- **Relevant chunks:** %d\n", len(fileResult.Chunks)))
+			for j, chunk := range fileResult.Chunks {
+				if chunk.NeighborOf != "" {
+					// Adjacent chunks carry the similarity of their match, so it is not shown
+					response.WriteString(fmt.Sprintf("  - **Chunk %d (context):**\n", j+1))
+					response.WriteString(fmt.Sprintf("    - Adjacent to: `%s`\n", chunk.NeighborOf))
+				} else {
+					response.WriteString(fmt.Sprintf("  - **Chunk %d:**\n", j+1))
+					response.WriteString(fmt.Sprintf("    - Similarity: %.4f\n", chunk.Similarity))
+				}
+				response.WriteString(fmt.Sprintf("    - Chunk ID: `%s`\n", chunk.ID))
+				response.WriteString(fmt.Sprintf("    - Range: characters %d-%d (%d tokens)\n",
+					chunk.StartOffset, chunk.EndOffset, chunk.TokenCount))
+				if chunk.HeadingPath != "" {
+					response.WriteString(fmt.Sprintf("    - Context: %s\n", chunk.HeadingPath))
+				}
+				if provenance {
+					response.WriteString(fmt.Sprintf("    - Embedding: %s\n", formatProvenance(chunk)))
+				}
+				if cost && chunk.NeighborOf == "" {
+					cumulative += chunk.TokenCount
+					response.WriteString(fmt.Sprintf("    - Retrieval cost: ~%d tokens (cumulative ~%d)\n", chunk.TokenCount, cumulative))
+				}
+				if chunk.NeighborOf != "" {
+					response.WriteString("    - Content:\n\n")
+					for _, line := range strings.Split(strings.TrimSpace(chunk.Content), "\n") {
+						response.WriteString("      " + line + "\n")
+					}
+				} else if snippetLength > 0 {
+					response.WriteString(fmt.Sprintf("    - Snippet: %s\n", previewText(chunk.Content, snippetLength)))
+				}
+			}
+		}
+		response.WriteString("\n")
+	}
+
+	response.WriteString("**Next Steps:**\n")
+	response.WriteString("Use the `rag_retrieve` tool to get the actual content from specific files and ranges.\n")
+	response.WriteString("Example: `rag_retrieve` with a `chunk_id`, or with `file_path` and optionally `start_offset` and `end_offset`\n")
+
+	return response.String()
+}
+
+// retrievalCost estimates the tokens rag_retrieve returns for every match in fileResults from
+// their indexed token counts. Adjacent chunks are left out, as their content is already included.
+func retrievalCost(fileResults []FileSearchResults) int {
+	total := 0
+	for _, fileResult := range fileResults {
+		for _, chunk := range fileResult.Chunks {
+			if chunk.NeighborOf == "" {
+				total += chunk.TokenCount
+			}
+		}
+	}
+	return total
+}
+
+// formatProvenance describes the embedding that produced a result's vector as mode/model
+func formatProvenance(result SearchResult) string {
+	if result.EmbeddingMode == "" && result.EmbeddingModel == "" {
+		return "unknown (indexed before provenance was recorded)"
+	}
+	return result.EmbeddingMode + "/" + result.EmbeddingModel
+}
+
+// formatSectionResponse formats a rag_retrieve_section result as markdown
+func formatSectionResponse(filePath string, section *Section) string {
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("**File:** `%s`\n", filePath))
+	response.WriteString(fmt.Sprintf("**Section:** %s\n", section.HeadingPath))
+	response.WriteString(fmt.Sprintf("**Range:** characters %d-%d\n", section.StartOffset, section.EndOffset))
+	if section.OtherMatches > 0 {
+		response.WriteString(fmt.Sprintf("**Note:** %d later section(s) also match; pass a heading path such as \"Parent > Heading\" to choose one\n", section.OtherMatches))
+	}
+	response.WriteString(fmt.Sprintf("**Content Length:** %d characters\n\n", len(section.Content)))
+	response.WriteString("**Content:**\n")
+	response.WriteString("```markdown\n")
+	response.WriteString(section.Content)
+	response.WriteString("\n```")
+	return response.String()
+}
+
+// formatListResponse formats the rag_list inventory of files, showing at most maxFiles of them
+// (0 means no limit) while still reporting how many matched
+func formatListResponse(files []FileInventory, pathPrefix string, maxFiles int) string {
+	var response strings.Builder
+	if pathPrefix != "" {
+		response.WriteString(fmt.Sprintf("Found %d indexed file(s) under `%s`", len(files), pathPrefix))
+	} else {
+		response.WriteString(fmt.Sprintf("Found %d indexed file(s)", len(files)))
+	}
+	if maxFiles > 0 && len(files) > maxFiles {
+		response.WriteString(fmt.Sprintf("; showing the first %d (limited by max_files)", maxFiles))
+		files = files[:maxFiles]
+	}
+	response.WriteString("\n\n")
+
+	for i, inventory := range files {
+		response.WriteString(fmt.Sprintf("**File %d:** `%s`\n", i+1, inventory.FilePath))
+		response.WriteString(fmt.Sprintf("- **Chunks:** %d\n", len(inventory.Chunks)))
+		response.WriteString(fmt.Sprintf("- **Size:** %s bytes\n", inventory.FileSize))
+		response.WriteString(fmt.Sprintf("- **Last Modified:** %s\n\n", inventory.LastModified))
+	}
+	return response.String()
+}
+
+// formatSearchPage tells whether results follow the page of maxResults starting at offset
+func formatSearchPage(offset, maxResults int, hasMore bool) string {
+	if hasMore {
+		return fmt.Sprintf("\n**More results available:** search again with offset %d for the next page.\n", offset+maxResults)
+	}
+	if offset > 0 {
+		return "\n**No more results** after this page.\n"
+	}
+	return ""
+}
+
+// searchCallConfig applies the per-call rag_search arguments on top of the server configuration.
+// Without a min_similarity argument the server's MCP default is used rather than the CLI one.
+func searchCallConfig(config Config, request mcp.CallToolRequest) Config {
+	config.HeadingFilter = request.GetString("heading_filter", "")
+	config.TagFilter = request.GetString("tag", "")
+	config.OpenTasksFilter = request.GetBool("open_tasks", false)
+	config.MinSimilarity = request.GetFloat("min_similarity", config.MCPMinSimilarity)
+	config.SearchOffset = max(request.GetInt("offset", 0), 0)
+	config.PreferRegion = request.GetString("prefer_region", config.PreferRegion)
+	config.RecencyBoost = request.GetFloat("recency_boost", config.RecencyBoost)
+	config.RecencyHalfLife = request.GetFloat("recency_half_life_days", config.RecencyHalfLife)
+	// rerank turns the lexical re-ranker on or off, leaving a custom re-ranker in place
+	lexical := config.ReRanker == ReRanker(LexicalReRanker{})
+	rerank := request.GetBool("rerank", lexical)
+	if rerank && config.ReRanker == nil {
+		config.ReRanker = LexicalReRanker{}
+	} else if !rerank && lexical {
+		config.ReRanker = nil
+	}
+	return config
+}
+
 // MCPSearchDocumentsWithResults searches for documents and returns structured results for MCP
 func MCPSearchDocumentsWithResults(queryText string, config Config, maxResults int) ([]SearchResult, error) {
-	// Load database
-	if _, err := os.Stat(config.DBPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("database not found. Please run indexing first with -index")
-	}
+	return searchDocumentsWithResults(context.Background(), queryText, config, maxResults)
+}
 
-	db := chromem.NewDB()
-	file, err := os.Open(config.DBPath)
+// searchDocumentsWithResults is MCPSearchDocumentsWithResults stopping when ctx is cancelled
+func searchDocumentsWithResults(ctx context.Context, queryText string, config Config, maxResults int) ([]SearchResult, error) {
+	collection, err := openSearchCollection(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	err = db.ImportFromReader(file, "")
+	// The page of results ends after the offset plus maxResults
+	offset := max(config.SearchOffset, 0)
+	searchResults, funnel, err := searchCollection(ctx, collection, queryText, offset+maxResults, config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load database: %w", err)
+		return nil, err
 	}
 
-	// Create embedding function for Ollama
-	embeddingFunc := CreateEmbeddingFunc(config)
+	if len(searchResults) == 0 {
+		return nil, fmt.Errorf("no similar documents found: %s", funnel.noResultsReason(config))
+	}
+	if offset >= len(searchResults) {
+		return nil, fmt.Errorf("no results at offset %d: the query matched %d", offset, len(searchResults))
+	}
+	return searchResults[offset:], nil
+}
 
-	collection := db.GetCollection("documents", embeddingFunc)
-	if collection == nil {
-		return nil, fmt.Errorf("documents collection not found in database")
+// openSearchCollection loads the database for searching, refusing one indexed with another
+// embedding model or holding fewer than config.MinDocsForSearch documents
+func openSearchCollection(config Config) (*chromem.Collection, error) {
+	collection, err := loadSearchCollection(config)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get collection count to determine max results
 	count := collection.Count()
-
 	if count == 0 {
 		return nil, fmt.Errorf("no documents found in the database")
 	}
+	// Rankings over a handful of documents look authoritative but say little
+	if count < config.MinDocsForSearch {
+		return nil, fmt.Errorf("index not ready: it holds %d documents, but searching needs at least %d; index more documents or lower -min-docs-for-search", count, config.MinDocsForSearch)
+	}
+	return collection, nil
+}
 
-	// Limit results to available documents
-	if maxResults > count {
-		maxResults = count
+// loadSearchCollection loads the documents collection of the database, refusing a database
+// indexed with another embedding model
+func loadSearchCollection(config Config) (*chromem.Collection, error) {
+	db, file, err := openDatabase(config)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	// Search for similar documents
-	results, err := collection.Query(context.Background(), queryText, maxResults, nil, nil)
+	// Create embedding function for Ollama, refusing a database indexed with another model
+	embeddingFunc, err := verifiedEmbeddingFunc(file, config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query collection: %w", err)
+		return nil, err
 	}
 
-	if len(results) == 0 {
-		return nil, fmt.Errorf("no similar documents found")
+	collection := db.GetCollection("documents", embeddingFunc)
+	if collection == nil {
+		return nil, fmt.Errorf("documents collection not found in database")
+	}
+	return collection, nil
+}
+
+// searchCollection returns up to maxResults results for queryText, with overlapping chunks
+// dropped unless config.NoDedup is set and the configured re-ranker applied, and how many
+// results survived each stage of the query
+func searchCollection(ctx context.Context, collection *chromem.Collection, queryText string, maxResults int, config Config) ([]SearchResult, searchFunnel, error) {
+	// Over-fetch so that dropping overlapping chunks still leaves maxResults results
+	queryLimit := maxResults
+	if !config.NoDedup {
+		queryLimit = maxResults * 2
+	}
+	// Over-fetch further so that capping the results of each file still leaves maxResults results
+	if config.MaxChunksPerFile > 0 {
+		queryLimit *= 4
+	}
+
+	// Search for similar documents
+	results, funnel, err := queryCollection(ctx, collection, queryText, queryLimit, config)
+	if err != nil {
+		return nil, funnel, err
 	}
 
 	// Convert to SearchResult structs
 	searchResults := make([]SearchResult, 0, len(results))
 	for _, result := range results {
-		isChunk := result.Metadata["is_chunk"] == "true"
+		searchResults = append(searchResults, toSearchResult(result))
+	}
+	// Reconcile before dedup, which would otherwise let whichever entry type ranks first absorb
+	// the other
+	searchResults = reconcileMixedEntries(searchResults, config.MixedEntries)
+	if !config.NoDedup {
+		searchResults = dedupOverlappingChunks(searchResults)
+	}
+	searchResults = capChunksPerFile(searchResults, config.MaxChunksPerFile)
+	if len(searchResults) > maxResults {
+		searchResults = searchResults[:maxResults]
+	}
 
-		searchResult := SearchResult{
-			FilePath:    result.Metadata["file_path"],
-			Similarity:  result.Similarity,
-			IsChunk:     isChunk,
-			HeadingPath: result.Metadata["heading_path"],
-		}
+	searchResults, err = reRanker(config).ReRank(ctx, queryText, searchResults)
+	if err != nil {
+		return nil, funnel, fmt.Errorf("failed to re-rank results: %w", err)
+	}
+	return searchResults, funnel, nil
+}
 
-		if isChunk {
-			if chunkIndex, err := strconv.Atoi(result.Metadata["chunk_index"]); err == nil {
-				searchResult.ChunkIndex = chunkIndex
-			}
-			if startOffset, err := strconv.Atoi(result.Metadata["start_offset"]); err == nil {
-				searchResult.StartOffset = startOffset
-			}
-			if endOffset, err := strconv.Atoi(result.Metadata["end_offset"]); err == nil {
-				searchResult.EndOffset = endOffset
-			}
-			if tokenCount, err := strconv.Atoi(result.Metadata["token_count"]); err == nil {
-				searchResult.TokenCount = tokenCount
-			}
+// toSearchResult converts a chromem query result into a SearchResult
+func toSearchResult(result chromem.Result) SearchResult {
+	isChunk := result.Metadata["is_chunk"] == "true"
+
+	searchResult := SearchResult{
+		ID:          result.ID,
+		FilePath:    result.Metadata["file_path"],
+		Similarity:  result.Similarity,
+		IsChunk:     isChunk,
+		HeadingPath: result.Metadata["heading_path"],
+		Content:     result.Content,
+		Duplicates:  duplicatePaths(result.Metadata),
+		Metadata:    result.Metadata,
+
+		EmbeddingMode:  result.Metadata["embedding_mode"],
+		EmbeddingModel: result.Metadata["embedding_model"],
+	}
+
+	if isChunk {
+		if chunkIndex, err := strconv.Atoi(result.Metadata["chunk_index"]); err == nil {
+			searchResult.ChunkIndex = chunkIndex
 		}
+		if startOffset, err := strconv.Atoi(result.Metadata["start_offset"]); err == nil {
+			searchResult.StartOffset = startOffset
+		}
+		if endOffset, err := strconv.Atoi(result.Metadata["end_offset"]); err == nil {
+			searchResult.EndOffset = endOffset
+		}
+	}
+	if tokenCount, err := strconv.Atoi(result.Metadata["token_count"]); err == nil {
+		searchResult.TokenCount = tokenCount
+	}
+
+	return searchResult
+}
+
+// MCPRetrieveChunk looks up a chunk by ID, returning its stored content and metadata
+func MCPRetrieveChunk(config Config, chunkID string) (*MCPSearchResult, error) {
+	collection, err := openCollection(config)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := collection.GetByID(context.Background(), chunkID)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %q not found; it may have been re-indexed since the search, so run rag_search again for current chunk IDs", chunkID)
+	}
+
+	results := []chromem.Result{{ID: doc.ID, Metadata: doc.Metadata, Content: doc.Content}}
+	resolveResultPaths(results, config)
+	return &MCPSearchResult{
+		Content:  doc.Content,
+		FilePath: results[0].Metadata["file_path"],
+		IsChunk:  doc.Metadata["is_chunk"] == "true",
+		Metadata: results[0].Metadata,
+	}, nil
+}
+
+// formatDrift renders how the file of an indexed document changed since indexing, or why that
+// could not be determined when lookupErr is set
+func formatDrift(doc *MCPSearchResult, lookupErr error) string {
+	if lookupErr != nil {
+		return fmt.Sprintf("\n\n**Changes since indexing:** unavailable: %v", lookupErr)
+	}
+	drift, err := MCPIndexedDrift(doc)
+	if err != nil {
+		return fmt.Sprintf("\n\n**Changes since indexing:** unavailable: %v", err)
+	}
+	if !drift.Changed {
+		return "\n\n**Changes since indexing:** none, the file matches the index"
+	}
+	if drift.Diff == "" {
+		return "\n\n**Changes since indexing:** the file changed, but not within this range"
+	}
+	return fmt.Sprintf("\n\n**Changes since indexing:**\n```diff\n%s```", drift.Diff)
+}
 
-		searchResults = append(searchResults, searchResult)
+// openCollection loads the database and returns its documents collection
+func openCollection(config Config) (*chromem.Collection, error) {
+	collection, release, err := loadCollection(config)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	return searchResults, nil
+	if collection == nil {
+		return nil, fmt.Errorf("documents collection not found in database")
+	}
+	return collection, nil
+}
+
+// formatChunkResponse renders a chunk retrieved by ID in the same layout as a file range
+func formatChunkResponse(chunkID string, chunk *MCPSearchResult) string {
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("**File:** `%s`\n", chunk.FilePath))
+	response.WriteString(fmt.Sprintf("**Chunk ID:** `%s`\n", chunkID))
+	response.WriteString(fmt.Sprintf("**Range:** characters %s-%s\n", chunk.Metadata["start_offset"], chunk.Metadata["end_offset"]))
+	if headingPath := chunk.Metadata["heading_path"]; headingPath != "" {
+		response.WriteString(fmt.Sprintf("**Context:** %s\n", headingPath))
+	}
+	response.WriteString(fmt.Sprintf("**Content Length:** %d characters\n\n", len(chunk.Content)))
+	response.WriteString("**Content:**\n")
+	response.WriteString("```markdown\n")
+	response.WriteString(chunk.Content)
+	response.WriteString("\n```")
+	return response.String()
 }
 
 // MCPRetrieveFileContent retrieves content from a file with optional range
 func MCPRetrieveFileContent(filePath string, startOffset, endOffset *int) (string, error) {
+	content, _, _, err := MCPRetrieveFileRange(filePath, startOffset, endOffset, 0, false)
+	return content, err
+}
+
+// MCPRetrieveFileRange retrieves content from a file with optional range, widened by up to
+// contextChars on each side and, with snapToLines, out to the start and end of the lines it
+// touches. It returns the byte range actually returned after clamping to the file and snapping
+// to character boundaries.
+func MCPRetrieveFileRange(filePath string, startOffset, endOffset *int, contextChars int, snapToLines bool) (string, int, int, error) {
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", fmt.Errorf("file not found: %s", filePath)
+		return "", 0, 0, fmt.Errorf("file not found: %s", filePath)
 	}
 
 	// Read the file
 	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read file %s: %w", filePath, err)
+		return "", 0, 0, fmt.Errorf("failed to read file %s: %w", filePath, err)
 	}
 
 	contentStr := string(content)
@@ -310,11 +1069,31 @@ func MCPRetrieveFileContent(filePath string, startOffset, endOffset *int) (strin
 		start = end
 	}
 
-	return contentStr[start:end], nil
+	// Offsets are byte positions, so widen the range to whole UTF-8 characters
+	start = SnapToRuneStart(contentStr, start)
+	end = SnapToRuneEnd(contentStr, end)
+
+	// Widen the range by contextChars characters on each side, keeping it within the file
+	for i := 0; i < contextChars && start > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(contentStr[:start])
+		start -= size
+	}
+	for i := 0; i < contextChars && end < contentLen; i++ {
+		_, size := utf8.DecodeRuneInString(contentStr[end:])
+		end += size
+	}
+
+	if snapToLines {
+		start, end = SnapToLines(contentStr, start, end)
+	}
+
+	return contentStr[start:end], start, end, nil
 }
 
-// groupResultsByFile groups search results by file path and sorts chunks by position
-func groupResultsByFile(results []SearchResult) []FileSearchResults {
+// groupResultsByFile groups search results by file path and sorts chunks within each file by
+// position, or by similarity when chunkOrder is ChunkOrderSimilarity. A file matching through both
+// entry types keeps only those mixedEntries names, chunks unless it is MixedEntriesFile.
+func groupResultsByFile(results []SearchResult, chunkOrder, mixedEntries string) []FileSearchResults {
 	fileMap := make(map[string][]SearchResult)
 
 	// Group by file path
@@ -325,32 +1104,121 @@ func groupResultsByFile(results []SearchResult) []FileSearchResults {
 	// Convert to slice and sort chunks within each file
 	fileResults := make([]FileSearchResults, 0, len(fileMap))
 	for filePath, chunks := range fileMap {
-		// Sort chunks by start offset
-		sort.Slice(chunks, func(i, j int) bool {
-			if chunks[i].IsChunk && chunks[j].IsChunk {
+		chunks = reconcileMixedEntries(chunks, mixedEntries)
+		if chunkOrder == ChunkOrderSimilarity {
+			// Most relevant first, falling back to position for equal scores
+			sort.SliceStable(chunks, func(i, j int) bool {
+				if chunks[i].Similarity != chunks[j].Similarity {
+					return chunks[i].Similarity > chunks[j].Similarity
+				}
 				return chunks[i].StartOffset < chunks[j].StartOffset
-			}
-			// Non-chunks come first
-			if !chunks[i].IsChunk && chunks[j].IsChunk {
-				return true
-			}
-			if chunks[i].IsChunk && !chunks[j].IsChunk {
-				return false
-			}
-			// Both non-chunks, sort by similarity
-			return chunks[i].Similarity > chunks[j].Similarity
-		})
+			})
+		} else {
+			sortChunksByPosition(chunks)
+		}
 
 		fileResults = append(fileResults, FileSearchResults{
-			FilePath: filePath,
-			Chunks:   chunks,
+			FilePath:   filePath,
+			Duplicates: chunks[0].Duplicates,
+			Chunks:     chunks,
 		})
 	}
 
-	// Sort files by best similarity score
+	// Sort files by their best similarity score, whether it came from a chunk or a whole file,
+	// falling back to the file path so ties are ordered consistently
 	sort.Slice(fileResults, func(i, j int) bool {
-		return fileResults[i].Chunks[0].Similarity > fileResults[j].Chunks[0].Similarity
+		bestI := bestSimilarity(fileResults[i].Chunks)
+		bestJ := bestSimilarity(fileResults[j].Chunks)
+		if bestI != bestJ {
+			return bestI > bestJ
+		}
+		return fileResults[i].FilePath < fileResults[j].FilePath
 	})
 
 	return fileResults
 }
+
+// reconcileMixedEntries drops the whole-file or chunk results of each file that matched through
+// both, so the file is not counted twice, keeping the order of the rest
+func reconcileMixedEntries(results []SearchResult, mixedEntries string) []SearchResult {
+	hasChunks := make(map[string]bool)
+	hasFile := make(map[string]bool)
+	for _, result := range results {
+		if result.IsChunk {
+			hasChunks[result.FilePath] = true
+		} else {
+			hasFile[result.FilePath] = true
+		}
+	}
+
+	keepChunks := mixedEntries != MixedEntriesFile
+	kept := make([]SearchResult, 0, len(results))
+	for _, result := range results {
+		if hasChunks[result.FilePath] && hasFile[result.FilePath] && result.IsChunk != keepChunks {
+			continue
+		}
+		kept = append(kept, result)
+	}
+	return kept
+}
+
+// sortChunksByPosition sorts whole-file results first, then chunks by start offset. The sort is
+// stable, so chunks that compare equal keep their ranked order.
+func sortChunksByPosition(chunks []SearchResult) {
+	sort.SliceStable(chunks, func(i, j int) bool {
+		if chunks[i].IsChunk && chunks[j].IsChunk {
+			return chunks[i].StartOffset < chunks[j].StartOffset
+		}
+		// Non-chunks come first
+		if !chunks[i].IsChunk && chunks[j].IsChunk {
+			return true
+		}
+		if chunks[i].IsChunk && !chunks[j].IsChunk {
+			return false
+		}
+		// Both non-chunks, sort by similarity
+		return chunks[i].Similarity > chunks[j].Similarity
+	})
+}
+
+// bestSimilarity returns the highest similarity among the given results
+func bestSimilarity(results []SearchResult) float32 {
+	best := results[0].Similarity
+	for _, result := range results[1:] {
+		if result.Similarity > best {
+			best = result.Similarity
+		}
+	}
+	return best
+}
+
+// formatReindexSummary renders a reindex summary as markdown, listing every file that changed
+func formatReindexSummary(summary *ReindexSummary) string {
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("Reindexed `%s`\n\n", summary.RootPath))
+	response.WriteString(fmt.Sprintf("- **Added:** %d\n", len(summary.Added)))
+	response.WriteString(fmt.Sprintf("- **Updated:** %d\n", len(summary.Updated)))
+	response.WriteString(fmt.Sprintf("- **Unchanged:** %d\n", len(summary.Unchanged)))
+	response.WriteString(fmt.Sprintf("- **Skipped:** %d\n", len(summary.Skipped)))
+	response.WriteString(fmt.Sprintf("- **Removed:** %d\n", len(summary.Removed)))
+
+	for _, section := range []struct {
+		title string
+		items []string
+	}{
+		{"Added", summary.Added},
+		{"Updated", summary.Updated},
+		{"Skipped", summary.Skipped},
+		{"Removed", summary.Removed},
+		{"Warnings", summary.Warnings},
+	} {
+		if len(section.items) == 0 {
+			continue
+		}
+		response.WriteString(fmt.Sprintf("\n**%s:**\n", section.title))
+		for _, item := range section.items {
+			response.WriteString(fmt.Sprintf("- %s\n", item))
+		}
+	}
+	return response.String()
+}
@@ -0,0 +1,722 @@
+package rag
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+func TestMCPRetrieveFileContentReturnsValidUTF8ForIndexedEmoji(t *testing.T) {
+	config := newTestConfig(t, 8)
+	docsDir := t.TempDir()
+	filePath := filepath.Join(docsDir, "emoji.md")
+
+	var content strings.Builder
+	content.WriteString("# Emoji Notes\n\n")
+	for i := 0; i < 200; i++ {
+		content.WriteString("Ship it 🚀 café 日本語 ")
+	}
+	if err := os.WriteFile(filePath, []byte(content.String()), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+
+	results := readTestDatabase(t, config)
+	if len(results) < 2 {
+		t.Fatalf("expected the fixture to be chunked, got %d documents", len(results))
+	}
+
+	for _, result := range results {
+		if !utf8.ValidString(result.Content) {
+			t.Fatalf("chunk %s has invalid UTF-8 content", result.ID)
+		}
+
+		start, _ := strconv.Atoi(result.Metadata["start_offset"])
+		end, _ := strconv.Atoi(result.Metadata["end_offset"])
+		retrieved, err := MCPRetrieveFileContent(filePath, &start, &end)
+		if err != nil {
+			t.Fatalf("unexpected retrieval error: %v", err)
+		}
+		if !utf8.ValidString(retrieved) {
+			t.Fatalf("retrieved range %d-%d is invalid UTF-8", start, end)
+		}
+	}
+}
+
+func TestMCPRetrieveFileContentSnapsArbitraryOffsetsToRuneBoundaries(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "emoji.md")
+	content := "a🚀b é 日本"
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	for start := 0; start <= len(content); start++ {
+		for end := start; end <= len(content); end++ {
+			s, e := start, end
+			retrieved, err := MCPRetrieveFileContent(filePath, &s, &e)
+			if err != nil {
+				t.Fatalf("unexpected retrieval error: %v", err)
+			}
+			if !utf8.ValidString(retrieved) {
+				t.Fatalf("retrieved range %d-%d is invalid UTF-8: %q", start, end, retrieved)
+			}
+		}
+	}
+}
+
+func TestGroupResultsByFileRanksFilesByBestSimilarity(t *testing.T) {
+	results := []SearchResult{
+		{FilePath: "/docs/whole.md", Similarity: 0.5},
+		{FilePath: "/docs/chunked.md", Similarity: 0.3, IsChunk: true, StartOffset: 0},
+		{FilePath: "/docs/chunked.md", Similarity: 0.9, IsChunk: true, StartOffset: 500},
+	}
+
+	grouped := groupResultsByFile(results, ChunkOrderPosition, MixedEntriesChunks)
+
+	if len(grouped) != 2 {
+		t.Fatalf("unexpected file count: got %d, want 2", len(grouped))
+	}
+	if grouped[0].FilePath != "/docs/chunked.md" {
+		t.Fatalf("expected the file with the best chunk first, got %s", grouped[0].FilePath)
+	}
+	if grouped[0].Chunks[0].StartOffset != 0 || grouped[0].Chunks[1].StartOffset != 500 {
+		t.Fatalf("expected chunks within a file to stay ordered by position")
+	}
+}
+
+func TestGroupResultsByFileReconcilesMixedEntries(t *testing.T) {
+	// guide.md was re-indexed as chunks but its stale whole-file entry remains
+	results := []SearchResult{
+		{ID: "old", FilePath: "/docs/guide.md", Similarity: 0.8},
+		{ID: "new_0", FilePath: "/docs/guide.md", Similarity: 0.7, IsChunk: true, StartOffset: 0},
+		{ID: "new_1", FilePath: "/docs/guide.md", Similarity: 0.6, IsChunk: true, StartOffset: 400},
+		{ID: "other", FilePath: "/docs/other.md", Similarity: 0.5},
+	}
+
+	for _, tc := range []struct {
+		mixedEntries string
+		want         []string
+	}{
+		{MixedEntriesChunks, []string{"new_0", "new_1"}},
+		{MixedEntriesFile, []string{"old"}},
+	} {
+		grouped := groupResultsByFile(append([]SearchResult(nil), results...), ChunkOrderPosition, tc.mixedEntries)
+		if len(grouped) != 2 || grouped[0].FilePath != "/docs/guide.md" {
+			t.Fatalf("%s: unexpected grouping %+v", tc.mixedEntries, grouped)
+		}
+		var got []string
+		for _, chunk := range grouped[0].Chunks {
+			got = append(got, chunk.ID)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: kept %v, want %v", tc.mixedEntries, got, tc.want)
+		}
+		if len(grouped[1].Chunks) != 1 || grouped[1].Chunks[0].ID != "other" {
+			t.Fatalf("%s: a file with one entry type was changed: %+v", tc.mixedEntries, grouped[1])
+		}
+	}
+}
+
+func TestSearchReconcilesMixedEntriesBeforeDedup(t *testing.T) {
+	for _, tc := range []struct {
+		mixedEntries string
+		fileContent  string // Content of the stale whole-file entry
+		chunkContent string // Content of the first chunk
+		want         []string
+	}{
+		// The whole-file entry ranks first, yet the chunks are kept
+		{MixedEntriesChunks, "quarterly budget forecast", "quarterly budget notes", []string{"new_0", "new_1"}},
+		// The chunks rank first, yet the whole-file entry is kept
+		{MixedEntriesFile, "quarterly budget notes", "quarterly budget forecast", []string{"old"}},
+	} {
+		config := newTestConfig(t, 32)
+		config.MixedEntries = tc.mixedEntries
+		writeTestDatabase(t, config.DBPath, []chromem.Document{
+			testDocument("/docs/guide.md", "old", tc.fileContent, 32),
+			testChunk("/docs/guide.md", "new_0", tc.chunkContent, 0, 400, 32),
+			testChunk("/docs/guide.md", "new_1", "quarterly budget review", 400, 800, 32),
+		})
+
+		results, err := MCPSearchDocumentsWithResults("quarterly budget forecast", config, 5)
+		if err != nil {
+			t.Fatalf("%s: unexpected search error: %v", tc.mixedEntries, err)
+		}
+		var got []string
+		for _, result := range results {
+			got = append(got, result.ID)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: kept %v, want %v", tc.mixedEntries, got, tc.want)
+		}
+	}
+}
+
+func TestSearchCallConfigMinSimilarityDefaults(t *testing.T) {
+	config := Config{MinSimilarity: 0.2, MCPMinSimilarity: 0.7}
+
+	var request mcp.CallToolRequest
+	request.Params.Arguments = map[string]any{"query": "oauth"}
+	if got := searchCallConfig(config, request).MinSimilarity; got != 0.7 {
+		t.Fatalf("expected the MCP default rather than the CLI default, got %v", got)
+	}
+
+	request.Params.Arguments = map[string]any{"query": "oauth", "min_similarity": 0.5}
+	if got := searchCallConfig(config, request).MinSimilarity; got != 0.5 {
+		t.Fatalf("expected the per-call argument to override the MCP default, got %v", got)
+	}
+}
+
+func TestGroupResultsByFileChunkOrder(t *testing.T) {
+	results := []SearchResult{
+		{FilePath: "/docs/guide.md", IsChunk: true, ChunkIndex: 0, StartOffset: 0, Similarity: 0.4},
+		{FilePath: "/docs/guide.md", IsChunk: true, ChunkIndex: 2, StartOffset: 8000, Similarity: 0.9},
+		{FilePath: "/docs/guide.md", IsChunk: true, ChunkIndex: 1, StartOffset: 4000, Similarity: 0.6},
+	}
+
+	for _, tc := range []struct {
+		order string
+		want  []int
+	}{
+		{order: ChunkOrderPosition, want: []int{0, 1, 2}},
+		{order: ChunkOrderSimilarity, want: []int{2, 1, 0}},
+	} {
+		grouped := groupResultsByFile(append([]SearchResult(nil), results...), tc.order, MixedEntriesChunks)
+		var got []int
+		for _, chunk := range grouped[0].Chunks {
+			got = append(got, chunk.ChunkIndex)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s order: got chunks %v, want %v", tc.order, got, tc.want)
+		}
+	}
+}
+
+func TestFormatSearchResponseSnippets(t *testing.T) {
+	fileResults := []FileSearchResults{{
+		FilePath: "/docs/guide.md",
+		Chunks: []SearchResult{{
+			FilePath:   "/docs/guide.md",
+			IsChunk:    true,
+			Similarity: 0.8,
+			Content:    "# Setup\nInstall the CLI\r\nthen configure the token.",
+		}},
+	}}
+
+	if got := formatSearchResponse("setup", fileResults, 0, false, false); strings.Contains(got, "Snippet") {
+		t.Fatalf("expected no snippet when disabled, got:\n%s", got)
+	}
+
+	got := formatSearchResponse("setup", fileResults, 20, false, false)
+	if !strings.Contains(got, "    - Snippet: # Setup Install the ...\n") {
+		t.Fatalf("expected a truncated single-line snippet, got:\n%s", got)
+	}
+}
+
+func TestFormatSearchResponseRetrievalCost(t *testing.T) {
+	fileResults := []FileSearchResults{
+		{
+			FilePath: "/docs/guide.md",
+			Chunks: []SearchResult{
+				{ID: "g_0", FilePath: "/docs/guide.md", IsChunk: true, Similarity: 0.9, TokenCount: 120},
+				{ID: "g_1", FilePath: "/docs/guide.md", IsChunk: true, NeighborOf: "g_0", TokenCount: 80},
+				{ID: "g_3", FilePath: "/docs/guide.md", IsChunk: true, Similarity: 0.7, TokenCount: 45},
+			},
+		},
+		{
+			FilePath: "/docs/faq.md",
+			Chunks:   []SearchResult{{ID: "faq", FilePath: "/docs/faq.md", Similarity: 0.6, TokenCount: 300}},
+		},
+	}
+
+	got := formatSearchResponse("setup", fileResults, 0, false, true)
+	cumulative := regexp.MustCompile(`Retrieval cost:\*?\*? ~(\d+) tokens \(cumulative ~(\d+)\)`).FindAllStringSubmatch(got, -1)
+	if len(cumulative) != 3 {
+		t.Fatalf("expected a cost for each of the 3 matches but not the adjacent chunk, got:\n%s", got)
+	}
+	sum := 0
+	for _, match := range cumulative {
+		tokens, _ := strconv.Atoi(match[1])
+		sum += tokens
+		if running, _ := strconv.Atoi(match[2]); running != sum {
+			t.Fatalf("cumulative estimate %d does not match the running sum %d:\n%s", running, sum, got)
+		}
+	}
+	if sum != 465 || retrievalCost(fileResults) != sum {
+		t.Fatalf("expected a total of 465 tokens, summed %d and estimated %d", sum, retrievalCost(fileResults))
+	}
+	if !strings.Contains(got, "**Estimated retrieval cost:** ~465 tokens") {
+		t.Fatalf("expected the total estimate, got:\n%s", got)
+	}
+
+	if got := formatSearchResponse("setup", fileResults, 0, false, false); strings.Contains(got, "Retrieval cost") {
+		t.Fatalf("expected no cost when disabled, got:\n%s", got)
+	}
+}
+
+func TestBuildRetrievePlanReferencesValidRangesWithinBudget(t *testing.T) {
+	config := newTestConfig(t, 8)
+	var content strings.Builder
+	for i := 0; i < 12; i++ {
+		content.WriteString("## Section " + strconv.Itoa(i) + "\n\n")
+		content.WriteString(strings.Repeat("Deployment notes cover rollout steps and rollback. ", 20))
+		content.WriteString("\n\n")
+	}
+	docsDir := writeTestFiles(t, map[string]string{
+		"guide.md": content.String(),
+		"short.md": "# Short\n\nA brief note about deployments.\n",
+	})
+
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+	results, err := MCPSearchDocumentsWithResults("deployment rollback", config, 20)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if len(results) < 3 {
+		t.Fatalf("expected several results, got %d", len(results))
+	}
+
+	budget := 500
+	plan := buildRetrievePlan("deployment rollback", results, budget)
+	if len(plan.Calls) == 0 || plan.Skipped == 0 {
+		t.Fatalf("expected the budget to admit some results and skip others, got %d calls and %d skipped", len(plan.Calls), plan.Skipped)
+	}
+	if len(plan.Calls)+plan.Skipped != len(results) {
+		t.Fatalf("expected every result to be planned or skipped, got %d + %d of %d", len(plan.Calls), plan.Skipped, len(results))
+	}
+
+	total := 0
+	for _, call := range plan.Calls {
+		if call.Tool != "rag_retrieve" {
+			t.Fatalf("unexpected tool %q", call.Tool)
+		}
+		total += call.TokenCount
+
+		data, err := os.ReadFile(call.Arguments.FilePath)
+		if err != nil {
+			t.Fatalf("plan references unreadable file %s: %v", call.Arguments.FilePath, err)
+		}
+		start, end := call.Arguments.StartOffset, call.Arguments.EndOffset
+		if (start == nil) != (end == nil) {
+			t.Fatalf("call for %s has only one offset", call.Arguments.FilePath)
+		}
+		if start == nil && call.TokenCount == 0 {
+			t.Fatalf("whole-file call for %s does not count its tokens against the budget", call.Arguments.FilePath)
+		}
+		if start != nil && (*start < 0 || *start >= *end || *end > len(data)) {
+			t.Fatalf("call for %s has invalid range %d-%d in a %d byte file", call.Arguments.FilePath, *start, *end, len(data))
+		}
+		if _, err := MCPRetrieveFileContent(call.Arguments.FilePath, start, end); err != nil {
+			t.Fatalf("planned call fails: %v", err)
+		}
+	}
+	if total != plan.TotalTokens || total > budget {
+		t.Fatalf("expected total tokens %d to match the plan (%d) and fit the budget %d", total, plan.TotalTokens, budget)
+	}
+}
+
+func TestMCPRetrieveChunkRoundTripsSearchResultIDs(t *testing.T) {
+	config := newTestConfig(t, 8)
+	var content strings.Builder
+	for i := 0; i < 6; i++ {
+		content.WriteString("## Part " + strconv.Itoa(i) + "\n\n")
+		content.WriteString(strings.Repeat("Release checklists list the steps before tagging. ", 20))
+		content.WriteString("\n\n")
+	}
+	docsDir := writeTestFiles(t, map[string]string{"release.md": content.String()})
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+
+	results, err := MCPSearchDocumentsWithResults("release checklist", config, 5)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if len(results) == 0 {
+		t.Fatalf("expected search results")
+	}
+	for _, result := range results {
+		if result.ID == "" {
+			t.Fatalf("result for %s has no chunk ID", result.FilePath)
+		}
+		chunk, err := MCPRetrieveChunk(config, result.ID)
+		if err != nil {
+			t.Fatalf("unexpected retrieval error for %s: %v", result.ID, err)
+		}
+		if chunk.Content != result.Content || chunk.FilePath != result.FilePath {
+			t.Fatalf("chunk %s does not match its search result", result.ID)
+		}
+		if chunk.Metadata["start_offset"] != strconv.Itoa(result.StartOffset) {
+			t.Fatalf("chunk %s starts at %s, search reported %d", result.ID, chunk.Metadata["start_offset"], result.StartOffset)
+		}
+	}
+
+	if _, err := MCPRetrieveChunk(config, "deadbeef_99"); err == nil || !strings.Contains(err.Error(), `chunk "deadbeef_99" not found`) {
+		t.Fatalf("expected a clear error for an unknown chunk ID, got %v", err)
+	}
+}
+
+func TestMCPRetrieveFileRangeAddsContext(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "context.md")
+	content := "abc🚀defghij"
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name                     string
+		start, end, contextChars int
+		want                     string
+		wantStart, wantEnd       int
+	}{
+		{name: "no context", start: 8, end: 10, want: "ef", wantStart: 8, wantEnd: 10},
+		{name: "snapped to rune boundaries", start: 8, end: 10, contextChars: 2, want: "🚀defgh", wantStart: 3, wantEnd: 12},
+		{name: "clamped to file bounds", start: 1, end: 2, contextChars: 100, want: content, wantStart: 0, wantEnd: len(content)},
+	} {
+		start, end := tc.start, tc.end
+		got, gotStart, gotEnd, err := MCPRetrieveFileRange(filePath, &start, &end, tc.contextChars, false)
+		if err != nil {
+			t.Fatalf("%s: unexpected retrieval error: %v", tc.name, err)
+		}
+		if got != tc.want || gotStart != tc.wantStart || gotEnd != tc.wantEnd {
+			t.Fatalf("%s: got %q at %d-%d, want %q at %d-%d", tc.name, got, gotStart, gotEnd, tc.want, tc.wantStart, tc.wantEnd)
+		}
+	}
+}
+
+func TestMCPRetrieveFileRangeSnapsToLines(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "lines.md")
+	content := "first line\nsecond line\nthird line"
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name               string
+		start, end         int
+		snap               bool
+		want               string
+		wantStart, wantEnd int
+	}{
+		{name: "exact offsets by default", start: 14, end: 20, want: "ond li", wantStart: 14, wantEnd: 20},
+		{name: "mid-line range", start: 14, end: 20, snap: true, want: "second line\n", wantStart: 11, wantEnd: 23},
+		{name: "range across lines", start: 3, end: 14, snap: true, want: "first line\nsecond line\n", wantStart: 0, wantEnd: 23},
+		{name: "already whole lines", start: 11, end: 23, snap: true, want: "second line\n", wantStart: 11, wantEnd: 23},
+		{name: "last line without newline", start: 25, end: 26, snap: true, want: "third line", wantStart: 23, wantEnd: len(content)},
+		{name: "empty range", start: 5, end: 5, snap: true, want: "first line\n", wantStart: 0, wantEnd: 11},
+	} {
+		start, end := tc.start, tc.end
+		got, gotStart, gotEnd, err := MCPRetrieveFileRange(filePath, &start, &end, 0, tc.snap)
+		if err != nil {
+			t.Fatalf("%s: unexpected retrieval error: %v", tc.name, err)
+		}
+		if got != tc.want || gotStart != tc.wantStart || gotEnd != tc.wantEnd {
+			t.Fatalf("%s: got %q at %d-%d, want %q at %d-%d", tc.name, got, gotStart, gotEnd, tc.want, tc.wantStart, tc.wantEnd)
+		}
+	}
+}
+
+func TestMCPIncludeNeighborsAddsAdjacentChunksAsContext(t *testing.T) {
+	config := newTestConfig(t, 32)
+	chunks := []string{
+		"introduction to the team",
+		"hiring plans for spring",
+		"quarterly budget forecast",
+		"office move logistics",
+	}
+	var docs []chromem.Document
+	for i, content := range chunks {
+		doc := testChunk("/docs/plan.md", "abc_"+strconv.Itoa(i), content, i*100, i*100+100, 32)
+		doc.Metadata["file_hash"] = "abc"
+		doc.Metadata["chunk_index"] = strconv.Itoa(i)
+		doc.Metadata["token_count"] = "10"
+		docs = append(docs, doc)
+	}
+	docs = append(docs, testDocument("/docs/other.md", "other", "gardening in spring", 32))
+	writeTestDatabase(t, config.DBPath, docs)
+
+	results, err := MCPSearchDocumentsWithResults("quarterly budget forecast", config, 1)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "abc_2" {
+		t.Fatalf("expected abc_2 as the only match, got %+v", results)
+	}
+
+	withNeighbors, err := MCPIncludeNeighbors(config, results, 100)
+	if err != nil {
+		t.Fatalf("unexpected error including neighbors: %v", err)
+	}
+	var got []string
+	for _, result := range withNeighbors {
+		got = append(got, result.ID+"<"+result.NeighborOf)
+	}
+	if want := []string{"abc_2<", "abc_1<abc_2", "abc_3<abc_2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("results with neighbors = %v, want %v", got, want)
+	}
+
+	response := formatSearchResponse("quarterly budget forecast", groupResultsByFile(withNeighbors, ChunkOrderPosition, MixedEntriesChunks), 0, false, false)
+	for _, want := range []string{"**Chunk 1 (context):**", "Adjacent to: `abc_2`", "hiring plans for spring", "office move logistics"} {
+		if !strings.Contains(response, want) {
+			t.Fatalf("response missing %q:\n%s", want, response)
+		}
+	}
+
+	// Only one neighbor fits a budget of 15 tokens
+	withNeighbors, err = MCPIncludeNeighbors(config, results, 15)
+	if err != nil {
+		t.Fatalf("unexpected error including neighbors: %v", err)
+	}
+	if len(withNeighbors) != 2 || withNeighbors[1].ID != "abc_1" {
+		t.Fatalf("expected only the preceding neighbor within the budget, got %+v", withNeighbors)
+	}
+}
+
+func TestSearchResultsReportEmbeddingProvenance(t *testing.T) {
+	config := newTestConfig(t, 8)
+	docsDir := writeTestFiles(t, map[string]string{"deploy.md": "# Deploy\n\nShip the release to production.\n"})
+	captureStdout(t, func() {
+		if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+			t.Fatalf("unexpected indexing error: %v", err)
+		}
+	})
+
+	results, err := MCPSearchDocumentsWithResults("deploy release", config, 5)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if len(results) != 1 || results[0].EmbeddingMode != EmbeddingModeOllama || results[0].EmbeddingModel != "test-model" {
+		t.Fatalf("expected provenance ollama/test-model, got %+v", results)
+	}
+
+	grouped := groupResultsByFile(results, ChunkOrderPosition, MixedEntriesChunks)
+	if got := formatSearchResponse("deploy release", grouped, 0, true, false); !strings.Contains(got, "- **Embedding:** ollama/test-model") {
+		t.Fatalf("response missing provenance:\n%s", got)
+	}
+	if got := formatSearchResponse("deploy release", grouped, 0, false, false); strings.Contains(got, "**Embedding:**") {
+		t.Fatalf("provenance shown without being requested:\n%s", got)
+	}
+
+	config.Fields = ParseFields("embedding_mode,embedding_model")
+	output := captureStdout(t, func() {
+		if err := SearchDocuments("deploy release", config); err != nil {
+			t.Fatalf("unexpected search error: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Embedding Mode: ollama") || !strings.Contains(output, "Embedding Model: test-model") {
+		t.Fatalf("search output missing provenance fields:\n%s", output)
+	}
+}
+
+func TestMCPRetrieveSectionByHeading(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "guide.md")
+	content := "# Guide\n\nIntro.\n\n## Setup\n\nInstall it.\n\n### Linux\n\nUse apt.\n\n## API\n\n### Setup\n\nCreate a key.\n\n## Usage\n\nRun it.\n"
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	section, err := MCPRetrieveSection(filePath, "setup")
+	if err != nil {
+		t.Fatalf("unexpected retrieval error: %v", err)
+	}
+	want := "## Setup\n\nInstall it.\n\n### Linux\n\nUse apt.\n\n"
+	if section.Content != want || section.HeadingPath != "Guide > Setup" || section.OtherMatches != 1 {
+		t.Fatalf("got %q at %q with %d other matches, want %q at %q with 1", section.Content, section.HeadingPath, section.OtherMatches, want, "Guide > Setup")
+	}
+	if content[section.StartOffset:section.EndOffset] != section.Content {
+		t.Fatalf("range %d-%d does not match the section content", section.StartOffset, section.EndOffset)
+	}
+
+	section, err = MCPRetrieveSection(filePath, "API > Setup")
+	if err != nil {
+		t.Fatalf("unexpected retrieval error: %v", err)
+	}
+	if want := "### Setup\n\nCreate a key.\n\n"; section.Content != want || section.OtherMatches != 0 {
+		t.Fatalf("got %q with %d other matches, want %q", section.Content, section.OtherMatches, want)
+	}
+
+	if _, err := MCPRetrieveSection(filePath, "Deploy"); err == nil || !strings.Contains(err.Error(), "its headings are: Guide, Setup, Linux, API, Setup, Usage") {
+		t.Fatalf("expected the available headings to be listed, got %v", err)
+	}
+}
+
+func TestMCPSearchRequiresMinimumDocuments(t *testing.T) {
+	config := newTestConfig(t, 8)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/only.md", "abc", "The only indexed note", 8),
+	})
+
+	config.MinDocsForSearch = 1
+	if results, err := MCPSearchDocumentsWithResults("indexed note", config, 5); err != nil || len(results) != 1 {
+		t.Fatalf("expected the default minimum to allow searching, got %v, %v", results, err)
+	}
+
+	config.MinDocsForSearch = 3
+	_, err := MCPSearchDocumentsWithResults("indexed note", config, 5)
+	if err == nil || !strings.Contains(err.Error(), "index not ready: it holds 1 documents, but searching needs at least 3") {
+		t.Fatalf("expected an index not ready error, got %v", err)
+	}
+}
+
+func TestMCPSearchOffsetPagesThroughRankedResults(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.NoDedup = true
+	var docs []chromem.Document
+	for i, content := range []string{"deploy the api", "deploy the worker", "deploy the web app", "rotate the keys"} {
+		docs = append(docs, testDocument("/docs/"+strconv.Itoa(i)+".md", "hash"+strconv.Itoa(i), content, 8))
+	}
+	writeTestDatabase(t, config.DBPath, docs)
+
+	all, err := MCPSearchDocumentsWithResults("deploy", config, 4)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+
+	var paged []SearchResult
+	for offset := 0; offset < 4; offset += 2 {
+		config.SearchOffset = offset
+		page, err := MCPSearchDocumentsWithResults("deploy", config, 2)
+		if err != nil {
+			t.Fatalf("offset %d: unexpected search error: %v", offset, err)
+		}
+		paged = append(paged, page...)
+	}
+	if !reflect.DeepEqual(searchResultIDs(paged), searchResultIDs(all)) {
+		t.Fatalf("pages %v do not match the full ranking %v", searchResultIDs(paged), searchResultIDs(all))
+	}
+
+	config.SearchOffset = 4
+	if _, err := MCPSearchDocumentsWithResults("deploy", config, 2); err == nil || !strings.Contains(err.Error(), "no results at offset 4: the query matched 4") {
+		t.Fatalf("expected an offset past the results to be reported, got %v", err)
+	}
+
+	if got := formatSearchPage(0, 2, true); !strings.Contains(got, "offset 2 for the next page") {
+		t.Fatalf("expected the next offset, got %q", got)
+	}
+	if got := formatSearchPage(2, 2, false); !strings.Contains(got, "No more results") {
+		t.Fatalf("expected the last page to be marked, got %q", got)
+	}
+}
+
+func searchResultIDs(results []SearchResult) []string {
+	var ids []string
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+func TestMCPMultiSearchFusesQueryRankings(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.NoDedup = true
+	var docs []chromem.Document
+	for i, content := range []string{"deploy the api", "rotate the keys", "back up the database"} {
+		docs = append(docs, testDocument("/docs/"+strconv.Itoa(i)+".md", "hash"+strconv.Itoa(i), content, 8))
+	}
+	writeTestDatabase(t, config.DBPath, docs)
+
+	// A single query keeps its own ranking
+	single, err := MCPSearchDocumentsWithResults("deploy", config, 3)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	fusedSingle, err := MCPMultiSearch([]string{"deploy", "  "}, config, 3)
+	if err != nil {
+		t.Fatalf("unexpected multi-search error: %v", err)
+	}
+	var fusedIDs []string
+	for _, result := range fusedSingle {
+		fusedIDs = append(fusedIDs, result.ID)
+	}
+	if !reflect.DeepEqual(fusedIDs, searchResultIDs(single)) {
+		t.Fatalf("fusing one query ranked %v, expected %v", fusedIDs, searchResultIDs(single))
+	}
+
+	results, err := MCPMultiSearch([]string{"deploy", "rotate keys"}, config, 3)
+	if err != nil {
+		t.Fatalf("unexpected multi-search error: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected each chunk once, got %d results", len(results))
+	}
+	seen := make(map[string]bool)
+	for i, result := range results {
+		if seen[result.ID] {
+			t.Fatalf("chunk %s returned twice", result.ID)
+		}
+		seen[result.ID] = true
+		if len(result.Contributions) != 2 {
+			t.Fatalf("expected both queries to rank %s, got %+v", result.ID, result.Contributions)
+		}
+		var want float64
+		for _, contribution := range result.Contributions {
+			want += 1.0 / float64(rrfK+contribution.Rank)
+		}
+		if result.FusedScore != want {
+			t.Fatalf("%s: fused score %f, expected %f", result.ID, result.FusedScore, want)
+		}
+		if i > 0 && result.FusedScore > results[i-1].FusedScore {
+			t.Fatalf("results are not sorted by fused score: %v", results)
+		}
+	}
+
+	response := formatMultiSearchResponse([]string{"deploy", "rotate keys"}, results)
+	if !strings.Contains(response, "\"rotate keys\": rank ") || !strings.Contains(response, "returned by 2 of 2 queries") {
+		t.Fatalf("expected per-query contributions in the response, got:\n%s", response)
+	}
+
+	if _, err := MCPMultiSearch([]string{" "}, config, 3); err == nil {
+		t.Fatal("expected an error without a non-empty query")
+	}
+}
+
+func TestFormatListResponseReportsTotalWhenLimited(t *testing.T) {
+	files := []FileInventory{{FilePath: "/docs/a.md"}, {FilePath: "/docs/b.md"}, {FilePath: "/docs/c.md"}}
+
+	response := formatListResponse(files, "/docs", 2)
+	if !strings.HasPrefix(response, "Found 3 indexed file(s) under `/docs`; showing the first 2 (limited by max_files)") {
+		t.Fatalf("expected the total and the limit in the header, got:\n%s", response)
+	}
+	if strings.Contains(response, "/docs/c.md") {
+		t.Fatalf("expected only the first 2 files, got:\n%s", response)
+	}
+
+	if response := formatListResponse(files, "", 5); !strings.HasPrefix(response, "Found 3 indexed file(s)\n\n") {
+		t.Fatalf("expected no limit note when every file is shown, got:\n%s", response)
+	}
+}
+
+func TestMCPRetrieveFileRangeCountsContextInCharacters(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "cjk.md")
+	content := "日本語のテキスト"
+	if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	// 語 is bytes 6-9; two characters of context on each side reach 日 and テ
+	start, end := 6, 9
+	got, gotStart, gotEnd, err := MCPRetrieveFileRange(filePath, &start, &end, 2, false)
+	if err != nil {
+		t.Fatalf("unexpected retrieval error: %v", err)
+	}
+	if got != "日本語のテ" || gotStart != 0 || gotEnd != 15 {
+		t.Fatalf("got %q at %d-%d, want %q at 0-15", got, gotStart, gotEnd, "日本語のテ")
+	}
+	if !utf8.ValidString(got) {
+		t.Fatalf("expected whole characters, got %q", got)
+	}
+}
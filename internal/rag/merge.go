@@ -0,0 +1,226 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// MergeDatabase imports the documents from another database into the configured database.
+// Documents that already exist with the same file hash are skipped, and the merge is
+// rejected when the two databases were indexed with different embedding models, vector
+// dimensions, or chunk ID schemes. Only what the databases store is compared, so merging
+// never calls the embedding API. A new database takes over the other's collection metadata.
+func MergeDatabase(otherDBPath string, config Config) error {
+	fmt.Printf("Merging database: %s\n", otherDBPath)
+	fmt.Printf("Into database: %s\n", config.DBPath)
+
+	// Load the database to merge from
+	if _, err := os.Stat(otherDBPath); os.IsNotExist(err) {
+		return fmt.Errorf("database to merge not found: %s", otherDBPath)
+	}
+
+	otherFile, err := os.Open(otherDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database to merge: %w", err)
+	}
+	defer otherFile.Close()
+
+	otherResults, err := storedDocuments(otherFile)
+	if err != nil {
+		return fmt.Errorf("failed to load database to merge: %w", err)
+	}
+	if len(otherResults) == 0 {
+		fmt.Println("No documents found in the database to merge.")
+		return nil
+	}
+	otherMetadata, err := readCollectionMetadata(otherFile)
+	if err != nil {
+		return err
+	}
+	otherDimension, err := storedEmbeddingDimension(otherFile, otherMetadata)
+	if err != nil {
+		return err
+	}
+
+	// Create embedding function for Ollama
+	embeddingFunc := CreateEmbeddingFunc(config)
+
+	// Load the target database, starting fresh if it does not exist yet
+	db := chromem.NewDB()
+	if _, err := os.Stat(config.DBPath); err == nil {
+		file, err := os.Open(config.DBPath)
+		if err != nil {
+			return fmt.Errorf("failed to open database: %w", err)
+		}
+		defer file.Close()
+
+		err = importDatabase(db, file, config.DBPath, config)
+		if err != nil {
+			return fmt.Errorf("failed to load database: %w", err)
+		}
+
+		// Vectors of different dimensions come from different models and cannot be searched together
+		metadata, err := readCollectionMetadata(file)
+		if err != nil {
+			return err
+		}
+		dimension, err := storedEmbeddingDimension(file, metadata)
+		if err != nil {
+			return err
+		}
+		if dimension > 0 && otherDimension > 0 && dimension != otherDimension {
+			return fmt.Errorf("%s stores %d-dimensional vectors, but %s stores %d-dimensional vectors; were they indexed with the same embedding model?",
+				config.DBPath, dimension, otherDBPath, otherDimension)
+		}
+		if err := checkMergeMetadata(config.DBPath, metadata, otherDBPath, otherMetadata); err != nil {
+			return err
+		}
+	}
+
+	collection := db.GetCollection("documents", embeddingFunc)
+	if collection == nil {
+		// The fingerprint and chunk ID scheme keep later searches and re-indexing checked
+		metadata := make(map[string]string, len(otherMetadata)+1)
+		for key, value := range otherMetadata {
+			metadata[key] = value
+		}
+		if metadata[fingerprintDimensionKey] == "" && otherDimension > 0 && metadata[fingerprintModelKey] != "" {
+			metadata[fingerprintDimensionKey] = strconv.Itoa(otherDimension)
+		}
+		collection, err = db.CreateCollection("documents", metadata, embeddingFunc)
+		if err != nil {
+			return fmt.Errorf("failed to create collection: %w", err)
+		}
+	}
+
+	merged := 0
+	skipped := 0
+	for _, result := range otherResults {
+		id := result.ID
+
+		// Resolve ID collisions by file hash: the same hash means the same content
+		if existing, err := collection.GetByID(context.Background(), id); err == nil {
+			if existing.Metadata["file_hash"] == result.Metadata["file_hash"] {
+				skipped++
+				continue
+			}
+			id = fmt.Sprintf("%s_%s", id, result.Metadata["file_hash"])
+			if _, err := collection.GetByID(context.Background(), id); err == nil {
+				skipped++
+				continue
+			}
+		}
+
+		err = collection.AddDocument(context.Background(), chromem.Document{
+			ID:        id,
+			Metadata:  result.Metadata,
+			Embedding: result.Embedding,
+			Content:   result.Content,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add document %s: %w", result.ID, err)
+		}
+		merged++
+	}
+
+	if err := saveDatabaseAtomic(db, config.DBPath); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Merged %d documents, skipped %d duplicates\n", merged, skipped)
+	return nil
+}
+
+// checkMergeMetadata returns an error when the collection metadata of the databases at path and
+// otherPath records different embedding models or chunk ID schemes. Databases from before the
+// fingerprint was recorded have none to compare; databases from before the scheme was recorded
+// use file IDs.
+func checkMergeMetadata(path string, metadata map[string]string, otherPath string, otherMetadata map[string]string) error {
+	mode, model := metadata[fingerprintModeKey], metadata[fingerprintModelKey]
+	otherMode, otherModel := otherMetadata[fingerprintModeKey], otherMetadata[fingerprintModelKey]
+	if model != "" && otherModel != "" && (mode != otherMode || model != otherModel) {
+		return fmt.Errorf("%s was indexed with mode=%s/model=%s, but %s with mode=%s/model=%s; were they indexed with the same embedding model?",
+			path, mode, model, otherPath, otherMode, otherModel)
+	}
+
+	scheme, otherScheme := metadata[chunkIDsKey], otherMetadata[chunkIDsKey]
+	if scheme == "" {
+		scheme = ChunkIDsFile
+	}
+	if otherScheme == "" {
+		otherScheme = ChunkIDsFile
+	}
+	if scheme != otherScheme {
+		return fmt.Errorf("%s uses %s chunk IDs, but %s uses %s chunk IDs; merging would store chunks under mixed IDs", path, scheme, otherPath, otherScheme)
+	}
+	return nil
+}
+
+// storedDocuments returns the documents of the database in reader, sorted like allDocuments,
+// without loading it into chromem, which would need an embedding query to list them
+func storedDocuments(reader io.ReadSeeker) ([]chromem.Result, error) {
+	var persisted struct {
+		Collections map[string]*struct {
+			Documents map[string]*struct {
+				ID        string
+				Metadata  map[string]string
+				Embedding []float32
+				Content   string
+			}
+		}
+	}
+	if err := decodeDatabase(reader, &persisted); err != nil {
+		return nil, err
+	}
+	collection := persisted.Collections["documents"]
+	if collection == nil {
+		return nil, nil
+	}
+
+	results := make([]chromem.Result, 0, len(collection.Documents))
+	for _, doc := range collection.Documents {
+		results = append(results, chromem.Result{
+			ID:        doc.ID,
+			Metadata:  doc.Metadata,
+			Embedding: doc.Embedding,
+			Content:   doc.Content,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return compareDocuments(results[i], results[j]) < 0
+	})
+	return results, nil
+}
+
+// saveDatabaseAtomic writes the database to a temporary file and renames it into place
+// so that an interrupted save never leaves a truncated database behind
+func saveDatabaseAtomic(db *chromem.DB, dbPath string) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(dbPath), filepath.Base(dbPath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create database file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	err = db.ExportToWriter(tmpFile, true, "")
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package rag
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestMergeDatabaseMergesCompatibleDatabases(t *testing.T) {
+	config := newTestConfig(t, 8)
+	otherDBPath := filepath.Join(t.TempDir(), "other.db")
+
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/a.md", "aaaa1111", "alpha notes", 8),
+		testDocument("/docs/shared.md", "cccc3333", "shared notes", 8),
+	})
+	writeTestDatabase(t, otherDBPath, []chromem.Document{
+		testDocument("/docs/b.md", "bbbb2222", "beta notes", 8),
+		testDocument("/docs/shared.md", "cccc3333", "shared notes", 8),
+	})
+
+	if err := MergeDatabase(otherDBPath, config); err != nil {
+		t.Fatalf("unexpected merge error: %v", err)
+	}
+
+	results := readTestDatabase(t, config)
+	if len(results) != 3 {
+		t.Fatalf("unexpected document count after merge: got %d, want 3", len(results))
+	}
+
+	files := make(map[string]bool)
+	for _, result := range results {
+		files[result.Metadata["file_path"]] = true
+	}
+	for _, want := range []string{"/docs/a.md", "/docs/b.md", "/docs/shared.md"} {
+		if !files[want] {
+			t.Fatalf("expected %s in merged database, got %v", want, files)
+		}
+	}
+}
+
+func TestMergeDatabaseRejectsIncompatibleDimensions(t *testing.T) {
+	config := newTestConfig(t, 8)
+	otherDBPath := filepath.Join(t.TempDir(), "other.db")
+
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/a.md", "aaaa1111", "alpha notes", 8),
+	})
+	writeTestDatabase(t, otherDBPath, []chromem.Document{
+		testDocument("/docs/b.md", "bbbb2222", "beta notes", 4),
+	})
+
+	err := MergeDatabase(otherDBPath, config)
+	if err == nil {
+		t.Fatalf("expected merge of incompatible databases to fail")
+	}
+	if !strings.Contains(err.Error(), "same embedding model") {
+		t.Fatalf("unexpected merge error: %v", err)
+	}
+
+	results := readTestDatabase(t, config)
+	if len(results) != 1 {
+		t.Fatalf("database should be unchanged after a rejected merge: got %d documents, want 1", len(results))
+	}
+}
+
+func TestMergeDatabaseDoesNotCallEmbeddingAPI(t *testing.T) {
+	config := newTestConfig(t, 8)
+	offline := config
+	offline.OllamaURL = "http://127.0.0.1:1/api/embeddings"
+	otherDBPath := filepath.Join(t.TempDir(), "other.db")
+
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/a.md", "aaaa1111", "alpha notes", 8),
+	})
+	writeTestDatabase(t, otherDBPath, []chromem.Document{
+		testDocument("/docs/b.md", "bbbb2222", "beta notes", 8),
+	})
+
+	if err := MergeDatabase(otherDBPath, offline); err != nil {
+		t.Fatalf("merging should compare stored vectors without the embedding API, got %v", err)
+	}
+	if results := readTestDatabase(t, config); len(results) != 2 {
+		t.Fatalf("unexpected document count after merge: got %d, want 2", len(results))
+	}
+}
+
+func TestMergeDatabaseRejectsMismatchedMetadata(t *testing.T) {
+	fingerprint := map[string]string{
+		fingerprintModeKey:      EmbeddingModeOllama,
+		fingerprintModelKey:     "test-model",
+		fingerprintDimensionKey: "8",
+		chunkIDsKey:             ChunkIDsFile,
+	}
+	for _, tc := range []struct {
+		name    string
+		key     string
+		value   string
+		wantErr string
+	}{
+		{name: "model", key: fingerprintModelKey, value: "other-model", wantErr: "model=other-model"},
+		{name: "chunk ids", key: chunkIDsKey, value: ChunkIDsStable, wantErr: "uses stable chunk IDs"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := newTestConfig(t, 8)
+			otherDBPath := filepath.Join(t.TempDir(), "other.db")
+			otherMetadata := map[string]string{}
+			for key, value := range fingerprint {
+				otherMetadata[key] = value
+			}
+			otherMetadata[tc.key] = tc.value
+
+			writeTestDatabaseWithMetadata(t, config.DBPath, fingerprint, []chromem.Document{
+				testDocument("/docs/a.md", "aaaa1111", "alpha notes", 8),
+			})
+			writeTestDatabaseWithMetadata(t, otherDBPath, otherMetadata, []chromem.Document{
+				testDocument("/docs/b.md", "bbbb2222", "beta notes", 8),
+			})
+
+			err := MergeDatabase(otherDBPath, config)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+			if results := readTestDatabase(t, config); len(results) != 1 {
+				t.Fatalf("database should be unchanged after a rejected merge: got %d documents, want 1", len(results))
+			}
+		})
+	}
+}
+
+func TestMergeDatabaseCopiesMetadataIntoNewDatabase(t *testing.T) {
+	config := newTestConfig(t, 8)
+	otherDBPath := filepath.Join(t.TempDir(), "other.db")
+	writeTestDatabaseWithMetadata(t, otherDBPath, map[string]string{
+		fingerprintModeKey:  EmbeddingModeOllama,
+		fingerprintModelKey: "test-model",
+		chunkIDsKey:         ChunkIDsStable,
+	}, []chromem.Document{
+		testDocument("/docs/b.md", "bbbb2222", "beta notes", 8),
+	})
+
+	if err := MergeDatabase(otherDBPath, config); err != nil {
+		t.Fatalf("unexpected merge error: %v", err)
+	}
+
+	file, err := os.Open(config.DBPath)
+	if err != nil {
+		t.Fatalf("failed to open merged database: %v", err)
+	}
+	defer file.Close()
+	metadata, err := readCollectionMetadata(file)
+	if err != nil {
+		t.Fatalf("failed to read merged metadata: %v", err)
+	}
+	want := map[string]string{
+		fingerprintModeKey:      EmbeddingModeOllama,
+		fingerprintModelKey:     "test-model",
+		fingerprintDimensionKey: "8",
+		chunkIDsKey:             ChunkIDsStable,
+	}
+	if !reflect.DeepEqual(metadata, want) {
+		t.Fatalf("unexpected metadata of the new database: got %v, want %v", metadata, want)
+	}
+}
@@ -0,0 +1,137 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// rrfK damps the weight reciprocal rank fusion gives the very top ranks, so that a chunk ranked
+// well by several queries beats one ranked first by a single query. 60 is the value from the
+// original reciprocal rank fusion paper.
+const rrfK = 60
+
+// QueryContribution is where one query variant ranked a fused result
+type QueryContribution struct {
+	Query      string
+	Rank       int // 1-based rank among that query's results
+	Similarity float32
+}
+
+// MultiSearchResult is a search result ranked by reciprocal rank fusion over several queries
+type MultiSearchResult struct {
+	SearchResult
+	FusedScore    float64 // Sum of 1/(rrfK+rank) over the queries that returned the chunk
+	Contributions []QueryContribution
+}
+
+// MCPMultiSearch runs each of queries against the database and fuses their rankings with
+// reciprocal rank fusion, returning up to maxResults chunks by fused score. A chunk returned by
+// several queries appears once, with the rank each query gave it. Queries that match nothing
+// only contribute nothing; an error is returned when none match.
+func MCPMultiSearch(queries []string, config Config, maxResults int) ([]MultiSearchResult, error) {
+	return multiSearch(context.Background(), queries, config, maxResults)
+}
+
+// multiSearch is MCPMultiSearch stopping when ctx is cancelled
+func multiSearch(ctx context.Context, queries []string, config Config, maxResults int) ([]MultiSearchResult, error) {
+	variants := nonEmptyQueries(queries)
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("queries must include at least one non-empty query")
+	}
+
+	collection, err := openSearchCollection(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each query ranks more chunks than are returned, so that chunks ranked fairly well by
+	// several queries can overtake the top of a single one
+	perQuery := maxResults * 2
+
+	fused := make(map[string]*MultiSearchResult)
+	var order []string
+	for _, query := range variants {
+		results, _, err := searchCollection(ctx, collection, query, perQuery, config)
+		if err != nil {
+			return nil, fmt.Errorf("query %q failed: %w", query, err)
+		}
+		for i, result := range results {
+			entry, ok := fused[result.ID]
+			if !ok {
+				entry = &MultiSearchResult{SearchResult: result}
+				fused[result.ID] = entry
+				order = append(order, result.ID)
+			}
+			entry.FusedScore += 1.0 / float64(rrfK+i+1)
+			entry.Contributions = append(entry.Contributions, QueryContribution{Query: query, Rank: i + 1, Similarity: result.Similarity})
+			// Keep the best similarity any query gave the chunk
+			entry.Similarity = max(entry.Similarity, result.Similarity)
+		}
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("no similar documents found for any of the %d queries", len(variants))
+	}
+
+	merged := make([]MultiSearchResult, 0, len(order))
+	for _, id := range order {
+		merged = append(merged, *fused[id])
+	}
+	// Ties keep the order chunks were first seen in, so earlier queries win them
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].FusedScore > merged[j].FusedScore
+	})
+	if len(merged) > maxResults {
+		merged = merged[:maxResults]
+	}
+	return merged, nil
+}
+
+// nonEmptyQueries returns queries trimmed of surrounding space, without the empty ones
+func nonEmptyQueries(queries []string) []string {
+	var variants []string
+	for _, query := range queries {
+		if query = strings.TrimSpace(query); query != "" {
+			variants = append(variants, query)
+		}
+	}
+	return variants
+}
+
+// formatMultiSearchResponse formats fused rag_multi_search results as markdown, listing the rank
+// and similarity each query gave every chunk
+func formatMultiSearchResponse(queries []string, results []MultiSearchResult) string {
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("Found %d relevant chunk(s) for %d queries, ranked by reciprocal rank fusion:\n", len(results), len(queries)))
+	for _, query := range queries {
+		response.WriteString(fmt.Sprintf("- \"%s\"\n", query))
+	}
+	response.WriteString("\n")
+
+	for i, result := range results {
+		response.WriteString(fmt.Sprintf("**Result %d:** `%s`\n", i+1, result.FilePath))
+		if len(result.Duplicates) > 0 {
+			response.WriteString(fmt.Sprintf("- **Same content at:** %s\n", formatPathList(result.Duplicates)))
+		}
+		response.WriteString(fmt.Sprintf("- **Fused score:** %.4f (returned by %d of %d queries)\n", result.FusedScore, len(result.Contributions), len(queries)))
+		response.WriteString(fmt.Sprintf("- **Chunk ID:** `%s`\n", result.ID))
+		if result.IsChunk {
+			response.WriteString(fmt.Sprintf("- **Range:** characters %d-%d (%d tokens)\n", result.StartOffset, result.EndOffset, result.TokenCount))
+		} else {
+			response.WriteString("- **Type:** Complete file\n")
+		}
+		if result.HeadingPath != "" {
+			response.WriteString(fmt.Sprintf("- **Context:** %s\n", result.HeadingPath))
+		}
+		response.WriteString("- **Contributions:**\n")
+		for _, contribution := range result.Contributions {
+			response.WriteString(fmt.Sprintf("  - \"%s\": rank %d, similarity %.4f\n", contribution.Query, contribution.Rank, contribution.Similarity))
+		}
+		response.WriteString("\n")
+	}
+
+	response.WriteString("**Next Steps:**\n")
+	response.WriteString("Use the `rag_retrieve` tool with a `chunk_id` to get the content of a result.\n")
+	return response.String()
+}
@@ -0,0 +1,79 @@
+package rag
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// MCPIncludeNeighbors adds the chunks immediately before and after each matched chunk in results,
+// marked as context for it and placed right after it. Neighbors are taken in rank order until
+// their tokens would exceed tokenBudget; chunks already in results are not repeated.
+func MCPIncludeNeighbors(config Config, results []SearchResult, tokenBudget int) ([]SearchResult, error) {
+	collection, err := openCollection(config)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(results))
+	for _, result := range results {
+		seen[result.ID] = true
+	}
+
+	withNeighbors := make([]SearchResult, 0, len(results))
+	tokens := 0
+	for _, result := range results {
+		withNeighbors = append(withNeighbors, result)
+		if !result.IsChunk || result.NeighborOf != "" {
+			continue
+		}
+
+		for _, neighbor := range neighborChunks(collection, result, config) {
+			if seen[neighbor.ID] || tokens+neighbor.TokenCount > tokenBudget {
+				continue
+			}
+			seen[neighbor.ID] = true
+			tokens += neighbor.TokenCount
+			withNeighbors = append(withNeighbors, neighbor)
+		}
+	}
+	return withNeighbors, nil
+}
+
+// neighborChunks returns the stored chunks adjacent to result in the same version of its file.
+// Chunk IDs need not encode the chunk index, so neighbors are found by metadata, querying with
+// the stored embedding of result rather than embedding anything.
+func neighborChunks(collection *chromem.Collection, result SearchResult, config Config) []SearchResult {
+	doc, err := collection.GetByID(context.Background(), result.ID)
+	if err != nil {
+		return nil
+	}
+
+	var neighbors []SearchResult
+	for _, index := range []int{result.ChunkIndex - 1, result.ChunkIndex + 1} {
+		if index < 0 {
+			continue
+		}
+		where := map[string]string{
+			"file_hash":   doc.Metadata["file_hash"],
+			"chunk_index": strconv.Itoa(index),
+			"is_chunk":    "true",
+		}
+		matches, err := collection.QueryEmbedding(context.Background(), doc.Embedding, 1, where, nil)
+		if err != nil || len(matches) == 0 {
+			continue
+		}
+		resolveResultPaths(matches, config)
+		if matches[0].Metadata["file_path"] != result.FilePath {
+			continue
+		}
+
+		neighbor := toSearchResult(matches[0])
+		// Neighbors rank with the chunk they accompany so that they stay beside it in the response
+		neighbor.Similarity = result.Similarity
+		neighbor.NeighborOf = result.ID
+		neighbors = append(neighbors, neighbor)
+	}
+	return neighbors
+}
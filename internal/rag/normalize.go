@@ -0,0 +1,57 @@
+package rag
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Text normalization applied to embedded text, for documents and queries alike
+const (
+	NormalizeOff        = "off"        // Embed text verbatim
+	NormalizeWhitespace = "whitespace" // Collapse runs of whitespace to single spaces
+	NormalizeMarkdown   = "markdown"   // Strip markdown formatting, then collapse whitespace
+)
+
+// IsValidNormalization reports whether mode is one of the text normalization modes
+func IsValidNormalization(mode string) bool {
+	return mode == NormalizeOff || mode == NormalizeWhitespace || mode == NormalizeMarkdown
+}
+
+// markdownReplacements rewrite markdown syntax to the plain text it renders as, applied in order
+var markdownReplacements = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile("(?m)^\\s*(```|~~~).*$"), ""},                     // Code fences, keeping the code
+	{regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`), "$1"},                  // Images, keeping the alt text
+	{regexp.MustCompile(`\[([^\]]+)\](\([^)]*\)|\[[^\]]*\])`), "$1"},      // Inline and reference links
+	{regexp.MustCompile(`<(https?://[^>]+)>`), "$1"},                      // Autolinks
+	{regexp.MustCompile(`</?[a-zA-Z][^>]*>`), ""},                         // HTML tags
+	{regexp.MustCompile(`(?m)^\s*([-*_]\s*){3,}$`), ""},                   // Horizontal rules
+	{regexp.MustCompile(`(?m)^\s*\|?(\s*:?-+:?\s*\|)+\s*:?-*:?\s*$`), ""}, // Table delimiter rows
+	{regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`), ""},                     // Heading markers
+	{regexp.MustCompile(`(?m)^\s*(>\s?)+`), ""},                           // Blockquotes
+	{regexp.MustCompile(`(?m)^\s*([-*+]|\d+[.)])\s+(\[[ xX]\]\s+)?`), ""}, // List markers and task boxes
+	{regexp.MustCompile(`\*\*([^*]+)\*\*`), "$1"},                         // Bold
+	{regexp.MustCompile(`__([^_]+)__`), "$1"},                             // Bold
+	{regexp.MustCompile(`\*([^*\s][^*]*)\*`), "$1"},                       // Italics
+	{regexp.MustCompile(`\b_([^_]+)_\b`), "$1"},                           // Italics, leaving snake_case alone
+	{regexp.MustCompile(`~~([^~]+)~~`), "$1"},                             // Strikethrough
+	{regexp.MustCompile("`+([^`]+)`+"), "$1"},                             // Inline code
+	{regexp.MustCompile(`\s*\|\s*`), " "},                                 // Table cell separators
+}
+
+// NormalizeText rewrites text for embedding according to mode, so that formatted and plain
+// versions of the same words embed alike. Unknown modes leave text unchanged.
+func NormalizeText(text, mode string) string {
+	switch mode {
+	case NormalizeMarkdown:
+		for _, r := range markdownReplacements {
+			text = r.pattern.ReplaceAllString(text, r.replacement)
+		}
+		return strings.Join(strings.Fields(text), " ")
+	case NormalizeWhitespace:
+		return strings.Join(strings.Fields(text), " ")
+	}
+	return text
+}
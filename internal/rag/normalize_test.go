@@ -0,0 +1,57 @@
+package rag
+
+import "testing"
+
+func TestNormalizeText(t *testing.T) {
+	for _, tc := range []struct {
+		mode, text, want string
+	}{
+		{NormalizeOff, "  keep\n\n **as is**  ", "  keep\n\n **as is**  "},
+		{NormalizeWhitespace, "  collapse \t runs\n\nof   **space**  ", "collapse runs of **space**"},
+		{NormalizeMarkdown, "## Setup *guide*\n\n- [x] Run `make build`\n> see [the docs](https://example.com/docs)", "Setup guide Run make build see the docs"},
+		{NormalizeMarkdown, "| Key | Value |\n| --- | --- |\n| file_path | ~~old~~ __new__ |", "Key Value file_path old new"},
+		{NormalizeMarkdown, "```go\nfmt.Println(x)\n```\n![diagram](img.png) <b>bold</b>", "fmt.Println(x) diagram bold"},
+	} {
+		if got := NormalizeText(tc.text, tc.mode); got != tc.want {
+			t.Fatalf("NormalizeText(%q, %s) = %q, want %q", tc.text, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizedMarkdownQueryMatchesPlainQuery(t *testing.T) {
+	config := newTestConfig(t, 64)
+	docsDir := writeTestFiles(t, map[string]string{
+		"deploy.md":   "# Deploy\n\nRun the **deploy** script with `kubectl apply` to roll out.\n",
+		"rollback.md": "# Rollback\n\nUse the rollback guide when a release fails.\n",
+	})
+	config.NormalizeText = NormalizeMarkdown
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+
+	search := func(query string) []SearchResult {
+		results, err := MCPSearchDocumentsWithResults(query, config, 2)
+		if err != nil {
+			t.Fatalf("unexpected search error: %v", err)
+		}
+		return results
+	}
+	plain := search("deploy script kubectl apply")
+	formatted := search("## **Deploy** script\n\n- [`kubectl apply`](https://kubernetes.io/docs/reference/kubectl/)")
+	if len(plain) != len(formatted) {
+		t.Fatalf("expected the same number of results, got %d and %d", len(plain), len(formatted))
+	}
+	for i := range plain {
+		if plain[i].FilePath != formatted[i].FilePath || plain[i].Similarity != formatted[i].Similarity {
+			t.Fatalf("result %d differs: plain %s (%.4f), formatted %s (%.4f)", i,
+				plain[i].FilePath, plain[i].Similarity, formatted[i].FilePath, formatted[i].Similarity)
+		}
+	}
+
+	// Without normalization the link URL's words dilute the formatted query
+	config.NormalizeText = NormalizeOff
+	unnormalized := search("## **Deploy** script\n\n- [`kubectl apply`](https://kubernetes.io/docs/reference/kubectl/)")
+	if unnormalized[0].Similarity >= plain[0].Similarity {
+		t.Fatalf("expected the raw formatted query to match less closely, got %.4f vs %.4f", unnormalized[0].Similarity, plain[0].Similarity)
+	}
+}
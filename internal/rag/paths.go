@@ -0,0 +1,89 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// storedFilePath returns the file_path metadata recorded for the file at absPath. With
+// config.Root set, files below the root are stored relative to it with forward slashes, so the
+// database works wherever the same tree is checked out; other files keep their absolute path.
+func storedFilePath(absPath string, config Config) string {
+	if config.Root == "" {
+		return absPath
+	}
+	rel, err := filepath.Rel(config.Root, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return absPath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// resolveFilePath returns the path on disk of a stored file_path. Absolute paths, which every
+// database indexed without -root holds, are returned unchanged; relative ones are resolved
+// against config.Root, or the working directory when no root is set.
+func resolveFilePath(stored string, config Config) string {
+	if stored == "" || filepath.IsAbs(stored) {
+		return stored
+	}
+	root := config.Root
+	if root == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return stored
+		}
+		root = wd
+	}
+	return filepath.Join(root, filepath.FromSlash(stored))
+}
+
+// resolveResultPaths points the file_path and duplicate_paths of every result at the files on
+// disk. Metadata maps are shared with the stored documents, so a result whose paths change gets
+// a copy.
+func resolveResultPaths(results []chromem.Result, config Config) {
+	for i := range results {
+		stored := results[i].Metadata["file_path"]
+		resolved := resolveFilePath(stored, config)
+		duplicates := duplicatePaths(results[i].Metadata)
+		for j, path := range duplicates {
+			duplicates[j] = resolveFilePath(path, config)
+		}
+		resolvedDuplicates := strings.Join(duplicates, "\n")
+		if resolved == stored && resolvedDuplicates == results[i].Metadata[duplicatePathsKey] {
+			continue
+		}
+		metadata := make(map[string]string, len(results[i].Metadata))
+		for k, v := range results[i].Metadata {
+			metadata[k] = v
+		}
+		metadata["file_path"] = resolved
+		if len(duplicates) > 0 {
+			metadata[duplicatePathsKey] = resolvedDuplicates
+		}
+		results[i].Metadata = metadata
+	}
+}
+
+// knownFilePath reports whether resolveFilePath finds the file a stored file_path was indexed
+// from. A relative path resolved against the working directory rather than the root it was
+// stored under may point anywhere, so it cannot tell whether the file still exists.
+func knownFilePath(stored string, config Config) bool {
+	return filepath.IsAbs(stored) || config.Root != ""
+}
+
+// deleteFileDocuments deletes every document of the file at absPath, whether stored under its
+// path relative to the root or, from a run before the root was set, its absolute path
+func deleteFileDocuments(collection *chromem.Collection, absPath string, config Config) error {
+	stored := storedFilePath(absPath, config)
+	if err := collection.Delete(context.Background(), map[string]string{"file_path": stored}, nil); err != nil {
+		return err
+	}
+	if stored == absPath {
+		return nil
+	}
+	return collection.Delete(context.Background(), map[string]string{"file_path": absPath}, nil)
+}
@@ -0,0 +1,89 @@
+package rag
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestStoredFilePathIsRelativeToRoot(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "repo")
+	config := Config{Root: root}
+
+	tests := []struct {
+		absPath string
+		want    string
+	}{
+		{filepath.Join(root, "docs", "guide.md"), "docs/guide.md"},
+		{filepath.Join(string(filepath.Separator), "elsewhere", "notes.md"), filepath.Join(string(filepath.Separator), "elsewhere", "notes.md")},
+		{filepath.Join(string(filepath.Separator), "repo-old", "a.md"), filepath.Join(string(filepath.Separator), "repo-old", "a.md")},
+	}
+	for _, tt := range tests {
+		if got := storedFilePath(tt.absPath, config); got != tt.want {
+			t.Errorf("storedFilePath(%q) = %q, want %q", tt.absPath, got, tt.want)
+		}
+	}
+
+	if got := storedFilePath(tests[0].absPath, Config{}); got != tests[0].absPath {
+		t.Errorf("expected absolute paths to be stored without a root, got %q", got)
+	}
+	if got := resolveFilePath("docs/guide.md", config); got != tests[0].absPath {
+		t.Errorf("expected relative paths to resolve against the root, got %q", got)
+	}
+	if got := resolveFilePath(tests[1].absPath, config); got != tests[1].absPath {
+		t.Errorf("expected absolute paths from older databases to be kept, got %q", got)
+	}
+}
+
+func TestIndexWithRootIsPortableAcrossCheckouts(t *testing.T) {
+	files := map[string]string{"docs/guide.md": "# Guide\n\nInstall the widget before configuring it.\n"}
+	first := writeTestFiles(t, files)
+	config := newTestConfig(t, 8)
+	config.Progress = TextProgress(io.Discard, LogNormal)
+	config.Root = first
+
+	if err := IndexDocuments(first, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected index error: %v", err)
+	}
+	docs := readTestDatabase(t, config)
+	if len(docs) != 1 || docs[0].Metadata["file_path"] != "docs/guide.md" {
+		t.Fatalf("expected the path to be stored relative to the root, got %v", docs)
+	}
+
+	// The same database, used from another checkout of the tree
+	config.Root = writeTestFiles(t, files)
+	want := filepath.Join(config.Root, "docs", "guide.md")
+	results, err := Search("install the widget", config, SearchOptions{})
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if results[0].FilePath != want || results[0].Metadata["file_path"] != want {
+		t.Fatalf("expected the result to resolve against the new root %s, got %+v", want, results[0])
+	}
+	chunk, err := MCPRetrieveChunk(config, docs[0].ID)
+	if err != nil {
+		t.Fatalf("unexpected retrieve error: %v", err)
+	}
+	if chunk.FilePath != want {
+		t.Fatalf("expected the retrieved chunk to resolve to %s, got %s", want, chunk.FilePath)
+	}
+
+	removed, err := DeleteFile("docs/guide.md", config)
+	if err != nil || removed != 1 {
+		t.Fatalf("expected deleting by relative path to remove 1 chunk, got %d, %v", removed, err)
+	}
+}
+
+func TestDeleteFileWithRootRemovesAbsolutePathDocuments(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.Root = filepath.Join(string(filepath.Separator), "docs")
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		statsTestChunk("/docs/legacy.md", "aaaa1111", 300, 0),
+	})
+
+	if removed, err := DeleteFile("/docs/legacy.md", config); err != nil || removed != 1 {
+		t.Fatalf("expected the absolute-path document to be removed, got %d, %v", removed, err)
+	}
+}
@@ -0,0 +1,75 @@
+package rag
+
+import "encoding/json"
+
+// Output formats for rag_search results
+const (
+	SearchOutputMarkdown = "markdown"
+	SearchOutputPlan     = "plan"
+)
+
+// DefaultPlanTokenBudget is the number of tokens a rag_search plan covers when the caller gives no token_budget
+const DefaultPlanTokenBudget = 8000
+
+// RetrievePlan is a sequence of rag_retrieve calls an agent can execute to read the top search
+// results without further round-trips
+type RetrievePlan struct {
+	Query       string         `json:"query"`
+	TokenBudget int            `json:"token_budget"`
+	TotalTokens int            `json:"total_tokens"`
+	Calls       []RetrieveCall `json:"calls"`
+	Skipped     int            `json:"skipped"` // Results left out because they did not fit the budget
+}
+
+// RetrieveCall is one planned rag_retrieve call
+type RetrieveCall struct {
+	Tool       string            `json:"tool"`
+	Arguments  RetrieveArguments `json:"arguments"`
+	TokenCount int               `json:"token_count"`
+	Similarity float32           `json:"similarity"`
+	Context    bool              `json:"context,omitempty"` // Adjacent to a match rather than a match itself
+}
+
+// RetrieveArguments are the rag_retrieve arguments for a planned call; offsets are omitted to
+// retrieve a whole file
+type RetrieveArguments struct {
+	FilePath    string `json:"file_path"`
+	StartOffset *int   `json:"start_offset,omitempty"`
+	EndOffset   *int   `json:"end_offset,omitempty"`
+}
+
+// buildRetrievePlan plans rag_retrieve calls for results in rank order, skipping any result that
+// would take the plan past tokenBudget so that smaller, lower-ranked results can still fit
+func buildRetrievePlan(query string, results []SearchResult, tokenBudget int) RetrievePlan {
+	plan := RetrievePlan{Query: query, TokenBudget: tokenBudget, Calls: []RetrieveCall{}}
+	for _, result := range results {
+		if plan.TotalTokens+result.TokenCount > tokenBudget {
+			plan.Skipped++
+			continue
+		}
+
+		args := RetrieveArguments{FilePath: result.FilePath}
+		if result.IsChunk {
+			args.StartOffset = &result.StartOffset
+			args.EndOffset = &result.EndOffset
+		}
+		plan.Calls = append(plan.Calls, RetrieveCall{
+			Tool:       "rag_retrieve",
+			Arguments:  args,
+			TokenCount: result.TokenCount,
+			Similarity: result.Similarity,
+			Context:    result.NeighborOf != "",
+		})
+		plan.TotalTokens += result.TokenCount
+	}
+	return plan
+}
+
+// formatRetrievePlan renders a plan as indented JSON
+func formatRetrievePlan(plan RetrievePlan) (string, error) {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
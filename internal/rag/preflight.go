@@ -0,0 +1,69 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// preflightText is the text embedded to check the embedding backend
+const preflightText = "embedding preflight check"
+
+// PreflightEmbedding embeds a short test text to check that the embedding API is reachable and
+// serves config.EmbeddingModel, so that a misconfigured backend fails before any work is done
+// rather than partway through indexing
+func PreflightEmbedding(config Config) error {
+	_, err := preflightEmbedding(context.Background(), config)
+	return err
+}
+
+// preflightEmbedding runs the preflight check under ctx and returns the dimension of the model's
+// vectors
+func preflightEmbedding(ctx context.Context, config Config) (int, error) {
+	embedding, err := GetEmbedding(ctx, preflightText, config)
+	if err != nil {
+		// A deadline or interruption says nothing about the backend
+		if ctx.Err() != nil {
+			return 0, err
+		}
+		return 0, explainPreflightError(err, config)
+	}
+	if len(embedding) == 0 {
+		return 0, fmt.Errorf("embedding API at %s returned an empty embedding for model %q", config.OllamaURL, config.EmbeddingModel)
+	}
+	return len(embedding), nil
+}
+
+// explainPreflightError turns a failed preflight embedding into an error saying what to fix
+func explainPreflightError(err error, config Config) error {
+	ollama := config.EmbeddingMode == "" || config.EmbeddingMode == EmbeddingModeOllama
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if ollama {
+			return fmt.Errorf("cannot reach Ollama at %s; is `ollama serve` running? %w", config.OllamaURL, err)
+		}
+		return fmt.Errorf("cannot reach the embeddings API at %s: %w", config.OllamaURL, err)
+	}
+
+	var apiErr *EmbeddingAPIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound && ollama {
+		return fmt.Errorf("model %q not found at %s; run `ollama pull %s`, or check -ollama-url: %w", config.EmbeddingModel, config.OllamaURL, config.EmbeddingModel, err)
+	}
+	return fmt.Errorf("embedding check failed for model %q at %s: %w", config.EmbeddingModel, config.OllamaURL, err)
+}
+
+// CheckEmbedding runs the preflight check and reports the result
+func CheckEmbedding(config Config) error {
+	report := progressReporter(config)
+	report.infof("Checking embedding model %s at %s", config.EmbeddingModel, config.OllamaURL)
+
+	dimension, err := preflightEmbedding(context.Background(), config)
+	if err != nil {
+		return err
+	}
+	report.infof("✓ Embedding API works: model %s returns %d-dimensional vectors", config.EmbeddingModel, dimension)
+	return nil
+}
@@ -0,0 +1,108 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPreflightEmbeddingExplainsFailures(t *testing.T) {
+	config := newTestConfig(t, 8)
+	if err := PreflightEmbedding(config); err != nil {
+		t.Fatalf("unexpected preflight error: %v", err)
+	}
+
+	missingModel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model \"test-model\" not found, try pulling it first"}`, http.StatusNotFound)
+	}))
+	defer missingModel.Close()
+	config.OllamaURL = missingModel.URL
+	if err := PreflightEmbedding(config); err == nil || !strings.Contains(err.Error(), "run `ollama pull test-model`") {
+		t.Fatalf("expected a hint to pull the model, got %v", err)
+	}
+
+	stopped := httptest.NewServer(http.NotFoundHandler())
+	config.OllamaURL = stopped.URL
+	stopped.Close()
+	if err := PreflightEmbedding(config); err == nil || !strings.Contains(err.Error(), "cannot reach Ollama at "+stopped.URL) {
+		t.Fatalf("expected an unreachable server error, got %v", err)
+	}
+}
+
+func TestIndexDocumentsRunsPreflightFirst(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.Progress = TextProgress(io.Discard, LogNormal)
+	config.OllamaURL = "http://127.0.0.1:1/api/embeddings"
+	docsDir := writeTestFiles(t, map[string]string{"a.md": "# A\n\nFirst file.\n"})
+
+	err := IndexDocuments(docsDir, config, 200, 15, 0.25)
+	if err == nil || !strings.Contains(err.Error(), "cannot reach Ollama") {
+		t.Fatalf("expected indexing to stop at the preflight check, got %v", err)
+	}
+	if _, err := os.Stat(config.DBPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no database to be written, got %v", err)
+	}
+}
+
+func TestIndexDocumentsPreflightHonorsIndexTimeout(t *testing.T) {
+	// The fake embedding API never answers, so only the deadline ends the preflight check
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	config := newTestConfig(t, 8)
+	config.Progress = TextProgress(io.Discard, LogNormal)
+	config.OllamaURL = server.URL
+	config.IndexTimeout = 100 * time.Millisecond
+	docsDir := writeTestFiles(t, map[string]string{"a.md": "# A\n\nFirst file.\n"})
+
+	start := time.Now()
+	err := IndexDocuments(docsDir, config, 200, 15, 0.25)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the preflight check to stop at the deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("the preflight check ignored the timeout and ran for %s", elapsed)
+	}
+}
+
+func TestIndexDocumentsReusesPreflightDimensionForNewCollection(t *testing.T) {
+	var probes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Prompt == preflightText || strings.Contains(req.Prompt, fingerprintProbeText) {
+			probes.Add(1)
+		}
+		json.NewEncoder(w).Encode(OllamaEmbeddingResponse{Embedding: testEmbedding(req.Prompt, 8)})
+	}))
+	defer server.Close()
+
+	config := newTestConfig(t, 8)
+	config.Progress = TextProgress(io.Discard, LogNormal)
+	config.OllamaURL = server.URL
+	docsDir := writeTestFiles(t, map[string]string{"a.md": "# A\n\nFirst file.\n"})
+
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := probes.Load(); got != 1 {
+		t.Fatalf("expected one probe embedding before indexing, got %d", got)
+	}
+}
@@ -0,0 +1,117 @@
+package rag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ProgressKind identifies what an indexing progress event reports
+type ProgressKind string
+
+const (
+	ProgressIndexStarted ProgressKind = "index_started" // An indexing run started on FilePath, the root
+	ProgressFileStarted  ProgressKind = "file_started"  // FilePath, number FileIndex of FileCount, is being indexed
+	ProgressFileChunked  ProgressKind = "file_chunked"  // FilePath was split into Chunks chunks
+	ProgressFileEmbedded ProgressKind = "file_embedded" // The Chunks chunks of FilePath were embedded
+	ProgressFileSkipped  ProgressKind = "file_skipped"  // FilePath stored nothing, because of Err when set
+	ProgressFileDone     ProgressKind = "file_done"     // FilePath was stored as Chunks documents
+	ProgressSaved        ProgressKind = "saved"         // The database was saved after FileIndex of FileCount files
+	ProgressWarning      ProgressKind = "warning"       // Something went wrong without stopping the run
+	ProgressInfo         ProgressKind = "info"          // Detail about the run, such as settings or batch progress
+	ProgressDebug        ProgressKind = "debug"         // Fine-grained detail for debugging, such as chunk split points
+	ProgressIndexDone    ProgressKind = "index_done"    // The run indexed all FileCount files
+)
+
+// ProgressEvent is a structured report of indexing progress. Fields that do not apply to Kind are
+// left zero.
+type ProgressEvent struct {
+	Kind      ProgressKind `json:"kind"`
+	FilePath  string       `json:"file_path,omitempty"`
+	FileIndex int          `json:"file_index,omitempty"` // 1-based position of FilePath among the files found
+	FileCount int          `json:"file_count,omitempty"` // Number of files found to index
+	Chunks    int          `json:"chunks,omitempty"`
+	Err       error        `json:"-"`
+	Message   string       `json:"message"` // The line TextProgress prints for the event
+}
+
+// ProgressFunc receives the progress events of an indexing run. Set one on the config used for
+// indexing to render progress another way, or to silence it:
+//
+//	config.Progress = func(rag.ProgressEvent) {}
+//	err := rag.IndexDocuments(rootPath, config, maxTokensPerChunk, chunkOverlapPercent, approxTokensPerChar)
+//
+// Events are delivered one at a time, in order, from the goroutine running the index.
+type ProgressFunc func(ProgressEvent)
+
+// Log levels of text progress output
+const (
+	LogQuiet   = "quiet"   // Warnings, files that failed, and the final summary only
+	LogNormal  = "normal"  // Progress of every file and batch
+	LogVerbose = "verbose" // Also debugging detail, such as where each chunk was split
+)
+
+// IsValidLogLevel reports whether level is one of the log levels
+func IsValidLogLevel(level string) bool {
+	return level == LogQuiet || level == LogNormal || level == LogVerbose
+}
+
+// TextProgress returns a ProgressFunc that writes the messages of the events shown at level to
+// w, one per line. An empty level is LogNormal.
+func TextProgress(w io.Writer, level string) ProgressFunc {
+	return func(event ProgressEvent) {
+		if showAtLevel(event, level) {
+			fmt.Fprintln(w, event.Message)
+		}
+	}
+}
+
+// showAtLevel reports whether text progress at level shows event
+func showAtLevel(event ProgressEvent, level string) bool {
+	switch event.Kind {
+	case ProgressWarning, ProgressIndexDone:
+		return true
+	case ProgressFileSkipped:
+		return event.Err != nil || level != LogQuiet
+	case ProgressDebug:
+		return level == LogVerbose
+	}
+	return level != LogQuiet
+}
+
+// progressReporter returns the configured progress reporter, defaulting to a progress bar when
+// standard output is a terminal at the normal log level, and to text at config.LogLevel otherwise
+func progressReporter(config Config) ProgressFunc {
+	if config.Progress != nil {
+		return config.Progress
+	}
+	if (config.LogLevel == "" || config.LogLevel == LogNormal) && isTerminal(os.Stdout) {
+		return stdoutBar()
+	}
+	return TextProgress(os.Stdout, config.LogLevel)
+}
+
+// stdoutBar is the one progress bar on standard output, shared by every reporter so that the
+// events of a run, including those of concurrent embedding workers, update the same bar
+var stdoutBar = sync.OnceValue(func() ProgressFunc { return BarProgress(os.Stdout) })
+
+// infof reports a ProgressInfo event with a formatted message
+func (p ProgressFunc) infof(format string, args ...any) {
+	p(ProgressEvent{Kind: ProgressInfo, Message: fmt.Sprintf(format, args...)})
+}
+
+// debugf reports a ProgressDebug event with a formatted message
+func (p ProgressFunc) debugf(format string, args ...any) {
+	p(ProgressEvent{Kind: ProgressDebug, Message: fmt.Sprintf(format, args...)})
+}
+
+// warnf reports a ProgressWarning event about filePath, which may be empty, caused by err
+func (p ProgressFunc) warnf(filePath string, err error, format string, args ...any) {
+	p(ProgressEvent{Kind: ProgressWarning, FilePath: filePath, Err: err, Message: fmt.Sprintf(format, args...)})
+}
+
+// skipf reports that filePath stored nothing because of err, which may be nil
+func (p ProgressFunc) skipf(filePath string, err error, format string, args ...any) {
+	p(ProgressEvent{Kind: ProgressFileSkipped, FilePath: filePath, Err: err, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,52 @@
+package rag
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIndexDocumentsLogLevels(t *testing.T) {
+	docsDir := writeTestFiles(t, map[string]string{
+		"large.md": "# Large\n\n" + strings.Repeat("A sentence that fills the large file. ", 60),
+	})
+
+	indexOutput := func(level string) string {
+		config := newTestConfig(t, 8)
+		config.LogLevel = level
+		return captureStdout(t, func() {
+			if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+				t.Fatalf("unexpected indexing error: %v", err)
+			}
+		})
+	}
+
+	quiet := indexOutput(LogQuiet)
+	if !strings.Contains(quiet, "✓ Successfully indexed 1 documents") || strings.Contains(quiet, "Processing (") {
+		t.Fatalf("expected quiet output to hold only the summary, got:\n%s", quiet)
+	}
+
+	normal := indexOutput(LogNormal)
+	if !strings.Contains(normal, "Processing (1/1)") || strings.Contains(normal, "split at") {
+		t.Fatalf("expected normal output to show files but not split points, got:\n%s", normal)
+	}
+
+	verbose := indexOutput(LogVerbose)
+	if !strings.Contains(verbose, "Processing (1/1)") || !strings.Contains(verbose, "Chunk 0: offsets 0-") || !strings.Contains(verbose, "split at ") {
+		t.Fatalf("expected verbose output to show split points, got:\n%s", verbose)
+	}
+}
+
+func TestTextProgressQuietShowsFailuresOnly(t *testing.T) {
+	var out strings.Builder
+	report := TextProgress(&out, LogQuiet)
+	report.infof("Using database: rag.db")
+	report.skipf("/docs/empty.go", nil, "  No comments found, skipping")
+	report.skipf("/docs/bad.md", errors.New("permission denied"), "Warning: Could not read file /docs/bad.md")
+	report.warnf("", nil, "Warning: Could not save embedding cache")
+
+	want := "Warning: Could not read file /docs/bad.md\nWarning: Could not save embedding cache\n"
+	if out.String() != want {
+		t.Fatalf("quiet output = %q, want %q", out.String(), want)
+	}
+}
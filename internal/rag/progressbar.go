@@ -0,0 +1,129 @@
+package rag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	progressBarWidth  = 30 // Characters in the bar itself
+	progressETAWindow = 20 // Most recent files whose durations are averaged for the ETA
+)
+
+// BarProgress returns a ProgressFunc that draws the files and chunks of an indexing run as a
+// progress bar with an ETA on one redrawn line of w, meant for a terminal. Once files are being
+// indexed their per-file messages are folded into the bar; warnings, failed files, and the
+// final summary are still printed as lines above it, as is everything before the first file.
+func BarProgress(w io.Writer) ProgressFunc {
+	return newProgressBar(w, time.Now).report
+}
+
+// progressBar is the state of a BarProgress. Embedding workers report concurrently, so every
+// event is handled under mu.
+type progressBar struct {
+	mu        sync.Mutex
+	w         io.Writer
+	now       func() time.Time
+	fileIndex int // 1-based index of the file being indexed, 0 before the first
+	fileCount int
+	chunks    int             // Chunks embedded so far
+	started   time.Time       // When the current file started
+	durations []time.Duration // Times of the most recent files, up to progressETAWindow
+	drawn     bool            // Whether the bar is on the current line
+}
+
+// newProgressBar returns a progress bar writing to w and timing files with now
+func newProgressBar(w io.Writer, now func() time.Time) *progressBar {
+	return &progressBar{w: w, now: now}
+}
+
+// report updates the bar for event, printing the events it does not fold in as lines
+func (b *progressBar) report(event ProgressEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch event.Kind {
+	case ProgressFileStarted:
+		if event.FileCount == 0 {
+			// A file outside a counted run, such as one re-indexed by -watch
+			b.writeLine(event.Message)
+			return
+		}
+		now := b.now()
+		if b.fileIndex > 0 {
+			b.durations = append(b.durations, now.Sub(b.started))
+			if len(b.durations) > progressETAWindow {
+				b.durations = b.durations[1:]
+			}
+		}
+		b.fileIndex, b.fileCount, b.started = event.FileIndex, event.FileCount, now
+		b.draw(event.FileIndex - 1)
+	case ProgressFileEmbedded:
+		b.chunks += event.Chunks
+		if b.fileIndex > 0 {
+			b.draw(b.fileIndex - 1)
+		}
+	case ProgressIndexDone:
+		if b.fileIndex > 0 {
+			// Leave the finished bar above the summary
+			b.draw(b.fileCount)
+			fmt.Fprintln(b.w)
+		}
+		fmt.Fprintln(b.w, event.Message)
+		b.fileIndex, b.fileCount, b.chunks, b.durations, b.drawn = 0, 0, 0, nil, false
+	default:
+		// Per-file detail is what the bar replaces; everything else stays a line
+		if b.fileIndex == 0 || showAtLevel(event, LogQuiet) {
+			b.writeLine(event.Message)
+		}
+	}
+}
+
+// draw redraws the bar with done of the files finished
+func (b *progressBar) draw(done int) {
+	filled := 0
+	if b.fileCount > 0 {
+		filled = progressBarWidth * done / b.fileCount
+	}
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+	fmt.Fprintf(b.w, "\r\033[K%s %d/%d files, %d chunks embedded, ETA %s", bar, done, b.fileCount, b.chunks, b.eta(done))
+	b.drawn = true
+}
+
+// eta estimates the time left for the files after the first done from the average time of the
+// most recent files
+func (b *progressBar) eta(done int) string {
+	if done >= b.fileCount {
+		return "0s"
+	}
+	if len(b.durations) == 0 {
+		return "--"
+	}
+	var total time.Duration
+	for _, d := range b.durations {
+		total += d
+	}
+	remaining := total / time.Duration(len(b.durations)) * time.Duration(b.fileCount-done)
+	return remaining.Round(time.Second).String()
+}
+
+// writeLine writes message on its own line of w, moving the bar below it
+func (b *progressBar) writeLine(message string) {
+	if b.drawn {
+		fmt.Fprint(b.w, "\r\033[K")
+	}
+	fmt.Fprintln(b.w, message)
+	if b.drawn && b.fileIndex > 0 {
+		b.draw(b.fileIndex - 1)
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
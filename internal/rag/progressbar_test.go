@@ -0,0 +1,76 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgressBarFoldsFileDetailAndEstimatesTimeLeft(t *testing.T) {
+	var out strings.Builder
+	clock := time.Unix(0, 0)
+	bar := newProgressBar(&out, func() time.Time { return clock })
+
+	bar.report(ProgressEvent{Kind: ProgressInfo, Message: "Using database: rag.db"})
+	for i := 1; i <= 3; i++ {
+		bar.report(ProgressEvent{Kind: ProgressFileStarted, FileIndex: i, FileCount: 4, Message: "Processing"})
+		bar.report(ProgressEvent{Kind: ProgressInfo, Message: "Processing batch 1/1"})
+		bar.report(ProgressEvent{Kind: ProgressFileEmbedded, Chunks: 2, Message: "  Embedded 2 chunks"})
+		bar.report(ProgressEvent{Kind: ProgressFileDone, Message: "✓ Indexed"})
+		clock = clock.Add(time.Duration(i) * 10 * time.Second)
+	}
+	bar.report(ProgressEvent{Kind: ProgressFileStarted, FileIndex: 4, FileCount: 4, Message: "Processing"})
+	bar.report(ProgressEvent{Kind: ProgressWarning, Message: "Warning: slow file"})
+
+	text := out.String()
+	if !strings.HasPrefix(text, "Using database: rag.db\n") {
+		t.Fatalf("expected lines before the first file to be printed, got %q", text)
+	}
+	if strings.Contains(text, "Processing batch") || strings.Contains(text, "✓ Indexed") {
+		t.Fatalf("expected per-file detail to be folded into the bar, got %q", text)
+	}
+	if !strings.Contains(text, "Warning: slow file\n") {
+		t.Fatalf("expected warnings to be printed as lines, got %q", text)
+	}
+	// Files took 10s, 20s, and 30s, so the last one is estimated at their 20s average
+	if want := "3/4 files, 6 chunks embedded, ETA 20s"; !strings.HasSuffix(text, want) {
+		t.Fatalf("expected the bar to end with %q, got %q", want, text)
+	}
+
+	bar.report(ProgressEvent{Kind: ProgressIndexDone, Message: "✓ Successfully indexed 4 documents"})
+	if want := "4/4 files, 6 chunks embedded, ETA 0s\n✓ Successfully indexed 4 documents\n"; !strings.HasSuffix(out.String(), want) {
+		t.Fatalf("expected the finished bar above the summary, got %q", out.String())
+	}
+}
+
+func TestProgressBarHandlesConcurrentEvents(t *testing.T) {
+	var out strings.Builder
+	bar := newProgressBar(&out, time.Now)
+	bar.report(ProgressEvent{Kind: ProgressFileStarted, FileIndex: 1, FileCount: 1, Message: "Processing"})
+
+	// Embedding workers report retries and embedded chunks at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				bar.report(ProgressEvent{Kind: ProgressWarning, Message: fmt.Sprintf("Warning: retry %d of worker %d", j, worker)})
+				bar.report(ProgressEvent{Kind: ProgressFileEmbedded, Chunks: 1})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	text := out.String()
+	for i := 0; i < 8; i++ {
+		if want := fmt.Sprintf("\033[KWarning: retry 19 of worker %d\n", i); !strings.Contains(text, want) {
+			t.Fatalf("expected the warnings of worker %d on whole lines, got %q", i, text)
+		}
+	}
+	if want := "0/1 files, 160 chunks embedded"; !strings.Contains(text[strings.LastIndex(text, "\r"):], want) {
+		t.Fatalf("expected the bar to count every chunk, got %q", text[strings.LastIndex(text, "\r"):])
+	}
+}
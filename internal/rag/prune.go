@@ -0,0 +1,187 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// PruneDocuments removes documents whose source files no longer exist on disk
+func PruneDocuments(config Config) error {
+	report := progressReporter(config)
+	report.infof("Pruning missing files")
+	report.infof("Using database: %s", config.DBPath)
+
+	db, file, err := openDatabase(config)
+	if err != nil {
+		return err
+	}
+	file.Close()
+
+	// Create embedding function for Ollama (needed for GetCollection)
+	embeddingFunc := CreateEmbeddingFunc(config)
+
+	collection := db.GetCollection("documents", embeddingFunc)
+	if collection == nil {
+		report.infof("No documents collection found in database.")
+		return nil
+	}
+
+	changed, err := pruneMissingFiles(collection, config, time.Now(), report)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+
+	// Save database
+	outFile, err := os.Create(config.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to create database file: %w", err)
+	}
+	defer outFile.Close()
+
+	err = db.ExportToWriter(outFile, true, "")
+	if err != nil {
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+
+	return nil
+}
+
+// pruneMissingFiles deletes all chunks belonging to files that no longer exist and removes
+// missing copies from duplicate_paths. When config.PruneAfter is positive, missing files are
+// first stamped with missing_since and only pruned once they have been missing for longer than
+// that, reporting each file it changes to report. Returns whether the collection was modified.
+func pruneMissingFiles(collection *chromem.Collection, config Config, now time.Time, report ProgressFunc) (bool, error) {
+	grace := config.PruneAfter
+	count := collection.Count()
+	if count == 0 {
+		report.infof("No documents found in the database.")
+		return false, nil
+	}
+
+	results, err := allDocuments(context.Background(), collection)
+	if err != nil {
+		return false, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	// Group chunks by stored file path
+	chunksByFile := make(map[string][]chromem.Result)
+	for _, result := range results {
+		filePath := result.Metadata["file_path"]
+		chunksByFile[filePath] = append(chunksByFile[filePath], result)
+	}
+
+	var filePaths []string
+	for filePath := range chunksByFile {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	changed := false
+	prunedFiles := 0
+	removedChunks := 0
+	unknown := 0
+	for _, filePath := range filePaths {
+		chunks := chunksByFile[filePath]
+		if !knownFilePath(filePath, config) {
+			unknown++
+			continue
+		}
+		missingSince := chunks[0].Metadata["missing_since"]
+
+		if _, err := os.Stat(resolveFilePath(filePath, config)); !os.IsNotExist(err) {
+			// The file is back, so forget that it was ever missing
+			if missingSince != "" {
+				if err := stampMissingSince(collection, chunks, ""); err != nil {
+					return changed, err
+				}
+				report.infof("✓ Restored: %s (no longer missing)", filePath)
+				changed = true
+			}
+			continue
+		}
+
+		if grace > 0 {
+			since, err := time.Parse(time.RFC3339, missingSince)
+			if err != nil {
+				// First time this file is seen missing: start the grace period
+				if err := stampMissingSince(collection, chunks, now.Format(time.RFC3339)); err != nil {
+					return changed, err
+				}
+				report.infof("? Missing: %s (will be pruned if still missing after %s)", filePath, grace)
+				changed = true
+				continue
+			}
+			if now.Sub(since) < grace {
+				report.infof("? Missing: %s (missing since %s, within grace period)", filePath, missingSince)
+				continue
+			}
+		}
+
+		err := collection.Delete(context.Background(), map[string]string{"file_path": filePath}, nil)
+		if err != nil {
+			return changed, fmt.Errorf("failed to delete chunks for %s: %w", filePath, err)
+		}
+		report.infof("✗ Pruned: %s (%d chunks)", filePath, len(chunks))
+		changed = true
+		prunedFiles++
+		removedChunks += len(chunks)
+	}
+
+	// Copies listed in duplicate_paths have no chunks to stamp, so a missing copy is unlisted at once
+	unlisted, err := unlistDuplicatePaths(collection, func(path string) bool {
+		if !knownFilePath(path, config) {
+			return false
+		}
+		_, err := os.Stat(resolveFilePath(path, config))
+		return os.IsNotExist(err)
+	})
+	if err != nil {
+		return changed, err
+	}
+	for _, path := range unlisted {
+		report.infof("✗ Pruned: %s (copy listed in duplicate_paths)", path)
+		changed = true
+		prunedFiles++
+	}
+
+	if unknown > 0 {
+		report.warnf("", nil, "Warning: Kept %d files stored relative to -root; pass the -root they were indexed under to prune them", unknown)
+	}
+	report.infof("✓ Pruned %d missing files (%d chunks removed)", prunedFiles, removedChunks)
+	return changed, nil
+}
+
+// stampMissingSince rewrites the chunks of a file with the given missing_since value,
+// removing the stamp when missingSince is empty
+func stampMissingSince(collection *chromem.Collection, chunks []chromem.Result, missingSince string) error {
+	for _, chunk := range chunks {
+		metadata := make(map[string]string, len(chunk.Metadata)+1)
+		for k, v := range chunk.Metadata {
+			metadata[k] = v
+		}
+		if missingSince == "" {
+			delete(metadata, "missing_since")
+		} else {
+			metadata["missing_since"] = missingSince
+		}
+
+		err := collection.AddDocument(context.Background(), chromem.Document{
+			ID:        chunk.ID,
+			Metadata:  metadata,
+			Embedding: chunk.Embedding,
+			Content:   chunk.Content,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update chunk %s: %w", chunk.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package rag
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// newTestCollection creates an in-memory collection containing the given documents
+func newTestCollection(t *testing.T, config Config, docs []chromem.Document) *chromem.Collection {
+	t.Helper()
+
+	collection, err := chromem.NewDB().CreateCollection("documents", nil, CreateEmbeddingFunc(config))
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	for _, doc := range docs {
+		if err := collection.AddDocument(context.Background(), doc); err != nil {
+			t.Fatalf("failed to add document %s: %v", doc.ID, err)
+		}
+	}
+	return collection
+}
+
+func TestPruneMissingFilesWaitsForGracePeriod(t *testing.T) {
+	config := newTestConfig(t, 8)
+	missingPath := filepath.Join(t.TempDir(), "moved.md")
+	collection := newTestCollection(t, config, []chromem.Document{
+		testDocument(missingPath, "aaaa1111", "temporarily unavailable notes", 8),
+	})
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	grace := 24 * time.Hour
+
+	// First run stamps the file as missing but keeps it
+	if _, err := pruneMissingFiles(collection, Config{PruneAfter: grace}, start, TextProgress(io.Discard, LogNormal)); err != nil {
+		t.Fatalf("unexpected prune error: %v", err)
+	}
+	doc, err := collection.GetByID(context.Background(), "aaaa1111")
+	if err != nil {
+		t.Fatalf("file was pruned before its grace period: %v", err)
+	}
+	if doc.Metadata["missing_since"] != start.Format(time.RFC3339) {
+		t.Fatalf("unexpected missing_since stamp: %q", doc.Metadata["missing_since"])
+	}
+
+	// A later run within the grace period still keeps it
+	if _, err := pruneMissingFiles(collection, Config{PruneAfter: grace}, start.Add(12*time.Hour), TextProgress(io.Discard, LogNormal)); err != nil {
+		t.Fatalf("unexpected prune error: %v", err)
+	}
+	if collection.Count() != 1 {
+		t.Fatalf("file was pruned within its grace period")
+	}
+
+	// Once the grace period has elapsed the file is pruned
+	if _, err := pruneMissingFiles(collection, Config{PruneAfter: grace}, start.Add(25*time.Hour), TextProgress(io.Discard, LogNormal)); err != nil {
+		t.Fatalf("unexpected prune error: %v", err)
+	}
+	if collection.Count() != 0 {
+		t.Fatalf("expected the file to be pruned after its grace period")
+	}
+}
+
+func TestPruneMissingFilesClearsStampWhenFileReturns(t *testing.T) {
+	config := newTestConfig(t, 8)
+	filePath := filepath.Join(t.TempDir(), "flaky.md")
+	collection := newTestCollection(t, config, []chromem.Document{
+		testDocument(filePath, "aaaa1111", "notes on a flaky mount", 8),
+	})
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := pruneMissingFiles(collection, Config{PruneAfter: time.Hour}, start, TextProgress(io.Discard, LogNormal)); err != nil {
+		t.Fatalf("unexpected prune error: %v", err)
+	}
+
+	if err := os.WriteFile(filePath, []byte("notes on a flaky mount"), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	if _, err := pruneMissingFiles(collection, Config{PruneAfter: time.Hour}, start.Add(2*time.Hour), TextProgress(io.Discard, LogNormal)); err != nil {
+		t.Fatalf("unexpected prune error: %v", err)
+	}
+
+	doc, err := collection.GetByID(context.Background(), "aaaa1111")
+	if err != nil {
+		t.Fatalf("restored file was pruned: %v", err)
+	}
+	if _, ok := doc.Metadata["missing_since"]; ok {
+		t.Fatalf("expected missing_since to be cleared once the file returned")
+	}
+}
+
+func TestPruneMissingFilesUnlistsMissingCopies(t *testing.T) {
+	config := newTestConfig(t, 8)
+	dir := t.TempDir()
+	primaryPath := filepath.Join(dir, "guide.md")
+	copyPath := filepath.Join(dir, "copy.md")
+	for _, path := range []string{primaryPath, copyPath} {
+		if err := os.WriteFile(path, []byte("same content"), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	doc := testDocument(primaryPath, "aaaa1111", "same content", 8)
+	doc.Metadata[duplicatePathsKey] = copyPath + "\n" + filepath.Join(dir, "deleted.md")
+	collection := newTestCollection(t, config, []chromem.Document{doc})
+
+	changed, err := pruneMissingFiles(collection, Config{}, time.Now(), TextProgress(io.Discard, LogNormal))
+	if err != nil {
+		t.Fatalf("unexpected prune error: %v", err)
+	}
+	if !changed {
+		t.Fatalf("expected pruning a missing copy to change the collection")
+	}
+	doc, err = collection.GetByID(context.Background(), "aaaa1111")
+	if err != nil {
+		t.Fatalf("the file with the missing copy was pruned: %v", err)
+	}
+	if doc.Metadata[duplicatePathsKey] != copyPath {
+		t.Fatalf("expected only the existing copy in duplicate_paths, got %q", doc.Metadata[duplicatePathsKey])
+	}
+}
+
+func TestPruneMissingFilesKeepsRelativePathsWithoutRoot(t *testing.T) {
+	config := newTestConfig(t, 8)
+	root := writeTestFiles(t, map[string]string{"docs/guide.md": "guide notes"})
+	doc := testDocument("docs/guide.md", "aaaa1111", "guide notes", 8)
+	doc.Metadata[duplicatePathsKey] = "docs/copy.md"
+	collection := newTestCollection(t, config, []chromem.Document{doc})
+
+	// Run from another directory without -root, the relative paths cannot be checked
+	t.Chdir(t.TempDir())
+	if _, err := pruneMissingFiles(collection, Config{}, time.Now(), TextProgress(io.Discard, LogNormal)); err != nil {
+		t.Fatalf("unexpected prune error: %v", err)
+	}
+	doc, err := collection.GetByID(context.Background(), "aaaa1111")
+	if err != nil {
+		t.Fatalf("a file stored relative to -root was pruned without one: %v", err)
+	}
+	if doc.Metadata[duplicatePathsKey] != "docs/copy.md" {
+		t.Fatalf("a copy stored relative to -root was unlisted without one: %q", doc.Metadata[duplicatePathsKey])
+	}
+
+	// With the root they were indexed under, only the missing copy goes
+	if _, err := pruneMissingFiles(collection, Config{Root: root}, time.Now(), TextProgress(io.Discard, LogNormal)); err != nil {
+		t.Fatalf("unexpected prune error: %v", err)
+	}
+	doc, err = collection.GetByID(context.Background(), "aaaa1111")
+	if err != nil {
+		t.Fatalf("an existing file was pruned: %v", err)
+	}
+	if doc.Metadata[duplicatePathsKey] != "" {
+		t.Fatalf("expected the missing copy to be unlisted, got %q", doc.Metadata[duplicatePathsKey])
+	}
+}
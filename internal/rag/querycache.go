@@ -0,0 +1,80 @@
+package rag
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultQueryCacheSize is the number of query embeddings kept in memory by default
+const DefaultQueryCacheSize = 256
+
+// queryEmbeddings caches the query embeddings of this process. Every search loads the database
+// afresh, so the cache outlives any one collection.
+var queryEmbeddings = newQueryEmbeddingCache()
+
+// queryEmbeddingCache is an in-memory least recently used cache of query embeddings, keyed like
+// the embedding cache by text, model, and mode, and by the embedding API URL. It is safe for
+// concurrent use.
+type queryEmbeddingCache struct {
+	mu      sync.Mutex
+	order   *list.List // Most recently used at the front
+	entries map[string]*list.Element
+}
+
+// queryEmbeddingEntry is a cached query embedding and its key, kept in the recency list
+type queryEmbeddingEntry struct {
+	key       string
+	embedding []float32
+}
+
+// newQueryEmbeddingCache returns an empty query embedding cache
+func newQueryEmbeddingCache() *queryEmbeddingCache {
+	return &queryEmbeddingCache{
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// queryEmbeddingKey identifies the embedding of text under config. The URL is part of the key,
+// so that two servers offering different models under one name never share vectors.
+func queryEmbeddingKey(text string, config Config) string {
+	return embeddingCacheKey(config.OllamaURL+"\x00"+text, config)
+}
+
+// get returns the cached embedding of text, if present. config.QueryCacheSize <= 0 disables
+// the cache.
+func (c *queryEmbeddingCache) get(text string, config Config) ([]float32, bool) {
+	if config.QueryCacheSize <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[queryEmbeddingKey(text, config)]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*queryEmbeddingEntry).embedding, true
+}
+
+// put caches the embedding of text, evicting the least recently used entries beyond
+// config.QueryCacheSize. Callers must not modify embedding afterwards.
+func (c *queryEmbeddingCache) put(text string, config Config, embedding []float32) {
+	if config.QueryCacheSize <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := queryEmbeddingKey(text, config)
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*queryEmbeddingEntry).embedding = embedding
+		c.order.MoveToFront(element)
+	} else {
+		c.entries[key] = c.order.PushFront(&queryEmbeddingEntry{key: key, embedding: embedding})
+	}
+	for c.order.Len() > config.QueryCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*queryEmbeddingEntry).key)
+	}
+}
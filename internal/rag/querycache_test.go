@@ -0,0 +1,92 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCountingOllamaServer starts a fake Ollama embeddings endpoint that answers after latency
+// and counts the embedding requests it receives
+func newCountingOllamaServer(t testing.TB, dim int, latency time.Duration, requests *atomic.Int64) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests.Add(1)
+		time.Sleep(latency)
+		json.NewEncoder(w).Encode(OllamaEmbeddingResponse{Embedding: testEmbedding(req.Prompt, dim)})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCreateEmbeddingFuncCachesRepeatedQueries(t *testing.T) {
+	var requests atomic.Int64
+	server := newCountingOllamaServer(t, 8, 0, &requests)
+	config := Config{OllamaURL: server.URL, EmbeddingModel: "test-model", QueryCacheSize: 2}
+	embed := CreateEmbeddingFunc(config)
+
+	queries := []string{"oauth setup", "oauth setup", "install", "deploy", "deploy", "oauth setup"}
+	for _, query := range queries {
+		embedding, err := embed(context.Background(), query)
+		if err != nil {
+			t.Fatalf("unexpected embedding error: %v", err)
+		}
+		if len(embedding) != 8 {
+			t.Fatalf("expected an 8-dimensional embedding, got %d", len(embedding))
+		}
+	}
+	// The repeated queries are served from the cache, except the last, which two newer queries evicted
+	if got := requests.Load(); got != 4 {
+		t.Fatalf("expected 4 embedding requests, got %d", got)
+	}
+
+	// Another model must not reuse the vectors
+	other := config
+	other.EmbeddingModel = "other-model"
+	if _, err := CreateEmbeddingFunc(other)(context.Background(), "deploy"); err != nil {
+		t.Fatalf("unexpected embedding error: %v", err)
+	}
+	if got := requests.Load(); got != 5 {
+		t.Fatalf("expected the other model to request an embedding, got %d requests", got)
+	}
+
+	disabled := config
+	disabled.QueryCacheSize = 0
+	for range 2 {
+		if _, err := CreateEmbeddingFunc(disabled)(context.Background(), "uncached query"); err != nil {
+			t.Fatalf("unexpected embedding error: %v", err)
+		}
+	}
+	if got := requests.Load(); got != 7 {
+		t.Fatalf("expected every query to be embedded with the cache disabled, got %d requests", got)
+	}
+}
+
+// BenchmarkRepeatedQueryEmbedding embeds the same query over and over against an embedding API
+// with a 2ms round trip, roughly a local Ollama, with and without the query embedding cache
+func BenchmarkRepeatedQueryEmbedding(b *testing.B) {
+	for _, size := range []int{0, DefaultQueryCacheSize} {
+		b.Run(fmt.Sprintf("cache=%d", size), func(b *testing.B) {
+			var requests atomic.Int64
+			server := newCountingOllamaServer(b, 768, 2*time.Millisecond, &requests)
+			embed := CreateEmbeddingFunc(Config{OllamaURL: server.URL, EmbeddingModel: "test-model", QueryCacheSize: size})
+			b.ResetTimer()
+			for range b.N {
+				if _, err := embed(context.Background(), "how do I configure oauth for the api gateway"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
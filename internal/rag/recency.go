@@ -0,0 +1,42 @@
+package rag
+
+import (
+	"math"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// DefaultRecencyHalfLifeDays is the age at which a document's recency decay reaches one half
+const DefaultRecencyHalfLifeDays = 30.0
+
+// recencyDecay is the weight of a document of the given age: 1 when just modified, halving
+// every half-life. Documents dated in the future count as just modified.
+func recencyDecay(age time.Duration, halfLifeDays float64) float64 {
+	if halfLifeDays <= 0 {
+		halfLifeDays = DefaultRecencyHalfLifeDays
+	}
+	days := max(age.Hours()/24, 0)
+	return math.Pow(0.5, days/halfLifeDays)
+}
+
+// boostRecency rescales every result's score toward newer documents and re-sorts the results.
+// With boost b between 0 and 1 and the decay d of the document's last_modified age, the score
+// becomes similarity * (1 - b + b*d): at 0 scores are unchanged, and at 1 a document one
+// half-life old keeps half its score. A just-modified document always keeps its full score.
+// Results without a parsable last_modified are left as they are.
+func boostRecency(results []chromem.Result, boost, halfLifeDays float64, now time.Time) {
+	boost = min(max(boost, 0), 1)
+	if boost == 0 {
+		return
+	}
+	for i := range results {
+		modified, err := time.Parse(time.RFC3339, results[i].Metadata["last_modified"])
+		if err != nil {
+			continue
+		}
+		decay := recencyDecay(now.Sub(modified), halfLifeDays)
+		results[i].Similarity *= float32(1 - boost + boost*decay)
+	}
+	sortResults(results)
+}
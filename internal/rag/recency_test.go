@@ -0,0 +1,90 @@
+package rag
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestBoostRecencyDecaysOlderDocuments(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	result := func(path, modified string) chromem.Result {
+		return chromem.Result{ID: path, Similarity: 0.8, Metadata: map[string]string{"file_path": path, "last_modified": modified}}
+	}
+	results := []chromem.Result{
+		result("/docs/old.md", now.AddDate(0, 0, -30).Format(time.RFC3339)),
+		result("/docs/new.md", now.Format(time.RFC3339)),
+		result("/docs/undated.md", ""),
+	}
+
+	boostRecency(results, 1, 30, now)
+
+	want := map[string]float64{"/docs/new.md": 0.8, "/docs/undated.md": 0.8, "/docs/old.md": 0.4}
+	for _, r := range results {
+		if math.Abs(float64(r.Similarity)-want[r.ID]) > 1e-6 {
+			t.Fatalf("%s: got score %.4f, want %.4f", r.ID, r.Similarity, want[r.ID])
+		}
+	}
+	if results[len(results)-1].ID != "/docs/old.md" {
+		t.Fatalf("expected the old document to rank last, got %v", results)
+	}
+
+	// A partial boost keeps most of an old document's score
+	partial := []chromem.Result{result("/docs/old.md", now.AddDate(0, 0, -30).Format(time.RFC3339))}
+	boostRecency(partial, 0.2, 30, now)
+	if math.Abs(float64(partial[0].Similarity)-0.72) > 1e-6 {
+		t.Fatalf("expected 0.8 * (1 - 0.2 + 0.2*0.5) = 0.72, got %.4f", partial[0].Similarity)
+	}
+}
+
+func TestQueryCollectionRecencyBoostFavorsNewerDocuments(t *testing.T) {
+	config := newTestConfig(t, 16)
+	old := testDocument("/docs/a-runbook.md", "aaaa1111_0", "restart the service", 16)
+	old.Metadata["last_modified"] = time.Now().AddDate(-1, 0, 0).Format(time.RFC3339)
+	recent := testDocument("/docs/b-runbook.md", "bbbb2222_0", "restart the service", 16)
+	recent.Metadata["last_modified"] = time.Now().Format(time.RFC3339)
+	collection := newTestCollection(t, config, []chromem.Document{old, recent})
+
+	// With equal scores the old runbook wins on path order until recency is boosted
+	for _, tc := range []struct {
+		boost float64
+		want  string
+	}{
+		{boost: 0, want: "/docs/a-runbook.md"},
+		{boost: 0.1, want: "/docs/b-runbook.md"},
+	} {
+		config.RecencyBoost = tc.boost
+		results, _, err := queryCollection(context.Background(), collection, "restart the service", 1, config)
+		if err != nil {
+			t.Fatalf("unexpected query error: %v", err)
+		}
+		if len(results) != 1 || results[0].Metadata["file_path"] != tc.want {
+			t.Fatalf("recency boost %v: expected %s first, got %v", tc.boost, tc.want, results)
+		}
+	}
+}
+
+func TestQueryCollectionAppliesMinSimilarityBeforeRecencyBoost(t *testing.T) {
+	config := newTestConfig(t, 16)
+	old := testDocument("/docs/runbook.md", "aaaa1111_0", "restart the service", 16)
+	old.Metadata["last_modified"] = time.Now().AddDate(-1, 0, 0).Format(time.RFC3339)
+	collection := newTestCollection(t, config, []chromem.Document{old})
+
+	// The year-old runbook's boosted score falls far below the threshold, but its similarity does not
+	config.RecencyBoost = 1
+	config.RecencyHalfLife = 30
+	config.MinSimilarity = 0.5
+	results, _, err := queryCollection(context.Background(), collection, "restart the service", 1, config)
+	if err != nil {
+		t.Fatalf("unexpected query error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected min_similarity to compare the raw similarity, got %v", results)
+	}
+	if results[0].Similarity >= 0.5 {
+		t.Fatalf("expected the recency boost to apply after the threshold, got score %.4f", results[0].Similarity)
+	}
+}
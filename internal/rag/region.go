@@ -0,0 +1,49 @@
+package rag
+
+import "github.com/philippgille/chromem-go"
+
+// Coarse regions of a document a chunk can sit in
+const (
+	RegionBeginning = "beginning"
+	RegionMiddle    = "middle"
+	RegionEnd       = "end"
+)
+
+// regionBoost is added to the score of chunks in the preferred region
+const regionBoost = 0.05
+
+// IsValidRegion reports whether region is one of the named document regions
+func IsValidRegion(region string) bool {
+	return region == RegionBeginning || region == RegionMiddle || region == RegionEnd
+}
+
+// chunkRegion places the chunk spanning [start, end) of a document of the given length in the
+// beginning, middle, or end third, judged by the chunk's midpoint
+func chunkRegion(start, end, length int) string {
+	if length <= 0 {
+		return RegionBeginning
+	}
+	midpoint := float64(start+end) / 2 / float64(length)
+	switch {
+	case midpoint < 1.0/3:
+		return RegionBeginning
+	case midpoint < 2.0/3:
+		return RegionMiddle
+	default:
+		return RegionEnd
+	}
+}
+
+// boostRegion raises the score of chunks stored in region by regionBoost and re-sorts the
+// results. Whole-file documents have no region and are left as they are.
+func boostRegion(results []chromem.Result, region string) {
+	if region == "" {
+		return
+	}
+	for i := range results {
+		if results[i].Metadata["region"] == region {
+			results[i].Similarity += regionBoost
+		}
+	}
+	sortResults(results)
+}
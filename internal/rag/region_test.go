@@ -0,0 +1,51 @@
+package rag
+
+import (
+	"context"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestChunkRegion(t *testing.T) {
+	for _, tc := range []struct {
+		start, end int
+		want       string
+	}{
+		{start: 0, end: 300, want: RegionBeginning},
+		{start: 300, end: 600, want: RegionMiddle},
+		{start: 600, end: 900, want: RegionEnd},
+		{start: 0, end: 900, want: RegionMiddle},
+	} {
+		if got := chunkRegion(tc.start, tc.end, 900); got != tc.want {
+			t.Fatalf("chunk %d-%d: got %s, want %s", tc.start, tc.end, got, tc.want)
+		}
+	}
+}
+
+func TestQueryCollectionPreferRegionBoostsBeginningChunks(t *testing.T) {
+	config := newTestConfig(t, 16)
+	appendix := testDocument("/docs/a-guide.md", "aaaa1111_3", "project summary and goals", 16)
+	appendix.Metadata["region"] = RegionEnd
+	intro := testDocument("/docs/b-guide.md", "bbbb2222_0", "project summary and goals", 16)
+	intro.Metadata["region"] = RegionBeginning
+	collection := newTestCollection(t, config, []chromem.Document{appendix, intro})
+
+	// With equal scores the appendix wins on path order until the beginning region is preferred
+	for _, tc := range []struct {
+		region string
+		want   string
+	}{
+		{region: "", want: "/docs/a-guide.md"},
+		{region: RegionBeginning, want: "/docs/b-guide.md"},
+	} {
+		config.PreferRegion = tc.region
+		results, _, err := queryCollection(context.Background(), collection, "project summary and goals", 1, config)
+		if err != nil {
+			t.Fatalf("unexpected query error: %v", err)
+		}
+		if len(results) != 1 || results[0].Metadata["file_path"] != tc.want {
+			t.Fatalf("prefer region %q: expected %s first, got %v", tc.region, tc.want, results)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// reindexMu serializes reindex runs, which would otherwise race to save the same database
+var reindexMu sync.Mutex
+
+// ReindexSummary describes how a reindex run changed the database, by file path
+type ReindexSummary struct {
+	RootPath  string
+	Added     []string // Files indexed for the first time
+	Updated   []string // Files whose content changed since they were last indexed
+	Unchanged []string // Files whose content was already indexed
+	Skipped   []string // Files found on disk that produced no documents
+	Removed   []string // Files under the root that are no longer indexed
+	Warnings  []string // Warnings the indexer reported
+}
+
+// ReindexDocuments runs the -index logic on rootPath and reports which files it added, updated,
+// or skipped. The indexer's progress goes to config.Progress when set and is otherwise discarded,
+// never reaching standard output, which carries the MCP stdio transport.
+func ReindexDocuments(rootPath string, config Config, maxTokensPerChunk, chunkOverlapPercent int, approxTokensPerChar float64) (*ReindexSummary, error) {
+	info, err := os.Stat(rootPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("path %s does not exist", rootPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", rootPath, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("path %s is not a directory", rootPath)
+	}
+
+	absRootPath, err := filepath.Abs(rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for %s: %w", rootPath, err)
+	}
+
+	reindexMu.Lock()
+	defer reindexMu.Unlock()
+
+	before, err := indexedFileHashes(absRootPath, config)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := findMarkdownFiles(absRootPath, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	progress := config.Progress
+	config.Progress = func(event ProgressEvent) {
+		if event.Kind == ProgressWarning || (event.Kind == ProgressFileSkipped && event.Err != nil) {
+			warnings = append(warnings, strings.TrimSpace(event.Message))
+		}
+		if progress != nil {
+			progress(event)
+		}
+	}
+	if err := IndexDocuments(absRootPath, config, maxTokensPerChunk, chunkOverlapPercent, approxTokensPerChar); err != nil {
+		return nil, err
+	}
+
+	after, err := indexedFileHashes(absRootPath, config)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &ReindexSummary{RootPath: absRootPath, Warnings: warnings}
+	for _, path := range files {
+		switch {
+		case len(after[path]) == 0:
+			summary.Skipped = append(summary.Skipped, path)
+		case len(before[path]) == 0:
+			summary.Added = append(summary.Added, path)
+		case hasNewHash(before[path], after[path]):
+			summary.Updated = append(summary.Updated, path)
+		default:
+			summary.Unchanged = append(summary.Unchanged, path)
+		}
+	}
+	for path := range before {
+		if len(after[path]) == 0 {
+			summary.Removed = append(summary.Removed, path)
+		}
+	}
+	slices.Sort(summary.Removed)
+
+	return summary, nil
+}
+
+// hasNewHash reports whether after holds a content hash that before does not
+func hasNewHash(before, after map[string]struct{}) bool {
+	for hash := range after {
+		if _, ok := before[hash]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// indexedFileHashes maps the path of every file indexed under absRootPath to the content hashes
+// its documents were indexed with; documents of an earlier version of a file can remain
+// alongside the current ones, so a path may have several
+func indexedFileHashes(absRootPath string, config Config) (map[string]map[string]struct{}, error) {
+	hashes := make(map[string]map[string]struct{})
+	collection, release, err := loadCollection(config)
+	if errors.Is(err, ErrDatabaseNotFound) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if collection == nil || collection.Count() == 0 {
+		return hashes, nil
+	}
+
+	results, err := allDocuments(context.Background(), collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	prefix := absRootPath + string(filepath.Separator)
+	for _, result := range results {
+		if filePath := resolveFilePath(result.Metadata["file_path"], config); strings.HasPrefix(filePath, prefix) {
+			if hashes[filePath] == nil {
+				hashes[filePath] = make(map[string]struct{})
+			}
+			hashes[filePath][result.Metadata["file_hash"]] = struct{}{}
+		}
+	}
+	return hashes, nil
+}
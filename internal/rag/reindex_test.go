@@ -0,0 +1,71 @@
+package rag
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReindexDocumentsSummarizesChanges(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.SourceExtensions = []string{".go"}
+	docsDir := writeTestFiles(t, map[string]string{
+		"same.md":    "# Same\n\nThis note does not change.\n",
+		"changed.md": "# Changed\n\nThe first version.\n",
+	})
+	if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected indexing error: %v", err)
+	}
+
+	changed := filepath.Join(docsDir, "changed.md")
+	added := filepath.Join(docsDir, "added.md")
+	uncommented := filepath.Join(docsDir, "main.go")
+	if err := os.WriteFile(changed, []byte("# Changed\n\nThe second version.\n"), 0o644); err != nil {
+		t.Fatalf("failed to update fixture: %v", err)
+	}
+	if err := os.WriteFile(added, []byte("# Added\n\nA new note.\n"), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	if err := os.WriteFile(uncommented, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	var summary *ReindexSummary
+	var err error
+	output := captureStdout(t, func() {
+		summary, err = ReindexDocuments(docsDir, config, 200, 15, 0.25)
+	})
+	if err != nil {
+		t.Fatalf("unexpected reindex error: %v", err)
+	}
+	if output != "" {
+		t.Fatalf("expected reindexing to keep standard output clear, got %q", output)
+	}
+
+	want := &ReindexSummary{
+		RootPath:  docsDir,
+		Added:     []string{added},
+		Updated:   []string{changed},
+		Unchanged: []string{filepath.Join(docsDir, "same.md")},
+		Skipped:   []string{uncommented},
+	}
+	summary.Warnings = nil
+	if !reflect.DeepEqual(summary, want) {
+		t.Fatalf("unexpected summary:\n got %+v\nwant %+v", summary, want)
+	}
+}
+
+func TestReindexDocumentsRejectsInvalidPaths(t *testing.T) {
+	config := newTestConfig(t, 8)
+	docsDir := writeTestFiles(t, map[string]string{"note.md": "# Note\n"})
+
+	for _, path := range []string{filepath.Join(docsDir, "missing"), filepath.Join(docsDir, "note.md")} {
+		if _, err := ReindexDocuments(path, config, 200, 15, 0.25); err == nil {
+			t.Fatalf("expected an error for %s", path)
+		}
+	}
+	if _, err := os.Stat(config.DBPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no database to be written for invalid paths")
+	}
+}
@@ -0,0 +1,75 @@
+package rag
+
+import (
+	"context"
+	"sort"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// ReRanker post-processes search results after the initial vector or hybrid retrieval and
+// before they are grouped and formatted, for example to re-score them with a cross-encoder.
+// Implementations may reorder, re-score, or drop results.
+//
+// To register one, set it on the config used for searching:
+//
+//	config.ReRanker = myReRanker{endpoint: "http://localhost:8080/rerank"}
+//	err := rag.RunMCPServer(config, maxTokensPerChunk, chunkOverlapPercent, approxTokensPerChar)
+//
+// It then applies to rag_search and -query, and to MCPSearchDocumentsWithResults, HybridSearch,
+// and Search.
+type ReRanker interface {
+	ReRank(ctx context.Context, query string, results []SearchResult) ([]SearchResult, error)
+}
+
+// NoopReRanker returns results unchanged; it is used when no re-ranker is configured
+type NoopReRanker struct{}
+
+// ReRank implements ReRanker
+func (NoopReRanker) ReRank(ctx context.Context, query string, results []SearchResult) ([]SearchResult, error) {
+	return results, nil
+}
+
+// LexicalReRanker re-sorts results by their BM25 term overlap with the query, so that chunks
+// using the query's exact words rise above chunks that are only close in embedding space. It is
+// what -rerank and the rag_search rerank argument enable.
+type LexicalReRanker struct{}
+
+// ReRank implements ReRanker
+func (LexicalReRanker) ReRank(ctx context.Context, query string, results []SearchResult) ([]SearchResult, error) {
+	return Rerank(results, query), nil
+}
+
+// Rerank re-sorts results by BM25 score against query, with term statistics taken from the
+// results alone, so its cost is bounded by the number of results rather than the database size.
+// Results scoring the same, such as those sharing no term with the query, keep their order.
+// Similarity scores are left unchanged.
+func Rerank(results []SearchResult, query string) []SearchResult {
+	docs := make([]chromem.Result, len(results))
+	for i, result := range results {
+		docs[i] = chromem.Result{Content: result.Content}
+	}
+	scores := bm25Scores(query, docs)
+
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+
+	reranked := make([]SearchResult, len(results))
+	for i, index := range order {
+		reranked[i] = results[index]
+	}
+	return reranked
+}
+
+// reRanker returns the configured re-ranker, defaulting to NoopReRanker
+func reRanker(config Config) ReRanker {
+	if config.ReRanker == nil {
+		return NoopReRanker{}
+	}
+	return config.ReRanker
+}
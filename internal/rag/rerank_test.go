@@ -0,0 +1,138 @@
+package rag
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// reverseReRanker reverses the result order so tests can tell whether the hook ran
+type reverseReRanker struct {
+	calls int
+	ctx   context.Context // Context of the latest call
+}
+
+func (r *reverseReRanker) ReRank(ctx context.Context, query string, results []SearchResult) ([]SearchResult, error) {
+	r.calls++
+	r.ctx = ctx
+	reversed := make([]SearchResult, len(results))
+	for i, result := range results {
+		reversed[len(results)-1-i] = result
+	}
+	return reversed, nil
+}
+
+func TestMCPSearchDocumentsWithResultsAppliesReRanker(t *testing.T) {
+	config := newTestConfig(t, 16)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/oauth.md", "aaaa1111", "oauth provider setup", 16),
+		testDocument("/docs/garden.md", "bbbb2222", "gardening notes", 16),
+	})
+
+	results, err := MCPSearchDocumentsWithResults("oauth provider setup", config, 2)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if results[0].FilePath != "/docs/oauth.md" {
+		t.Fatalf("unexpected top result without a re-ranker: %s", results[0].FilePath)
+	}
+
+	reRanker := &reverseReRanker{}
+	config.ReRanker = reRanker
+	results, err = MCPSearchDocumentsWithResults("oauth provider setup", config, 2)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if reRanker.calls != 1 || results[0].FilePath != "/docs/garden.md" {
+		t.Fatalf("expected the re-ranker to reverse the results, got %s first after %d calls", results[0].FilePath, reRanker.calls)
+	}
+}
+
+func TestRerankMovesKeywordExactResultToTop(t *testing.T) {
+	results := []SearchResult{
+		{ID: "vague", Similarity: 0.9, Content: "Networking problems can cause failures when talking to services."},
+		{ID: "other", Similarity: 0.8, Content: "Restart the service after changing its configuration."},
+		{ID: "exact", Similarity: 0.7, Content: "ERR_CONN_RESET means the server closed the connection; raise the timeout."},
+	}
+
+	reranked := Rerank(results, "ERR_CONN_RESET timeout")
+	if got := searchResultIDs(reranked); !reflect.DeepEqual(got, []string{"exact", "vague", "other"}) {
+		t.Fatalf("expected the keyword match first and the rest in their original order, got %v", got)
+	}
+	if reranked[0].Similarity != 0.7 || results[0].ID != "vague" {
+		t.Fatalf("expected similarities and the input order to be left unchanged")
+	}
+}
+
+func TestSearchWithLexicalReRankerPromotesKeywordMatch(t *testing.T) {
+	config := newTestConfig(t, 8)
+	query := "ERR_CONN_RESET timeout"
+	// The vague document sits exactly on the query's embedding, so vector search ranks it first
+	vague := testDocument("/docs/network.md", "aaaa1111", "Networking problems cause failures.", 8)
+	vague.Embedding = testEmbedding(query, 8)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		vague,
+		testDocument("/docs/errors.md", "bbbb2222", "ERR_CONN_RESET: the server closed the connection; raise the timeout.", 8),
+	})
+
+	results, err := Search(query, config, SearchOptions{})
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if results[0].FilePath != "/docs/network.md" {
+		t.Fatalf("expected vector search to rank the vague document first, got %s", results[0].FilePath)
+	}
+
+	var request mcp.CallToolRequest
+	request.Params.Arguments = map[string]any{"query": query, "rerank": true}
+	results, err = Search(query, searchCallConfig(config, request), SearchOptions{})
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if results[0].FilePath != "/docs/errors.md" {
+		t.Fatalf("expected re-ranking to put the keyword match first, got %s", results[0].FilePath)
+	}
+
+	// rerank false turns off a server-wide -rerank
+	config.ReRanker = LexicalReRanker{}
+	request.Params.Arguments = map[string]any{"query": query, "rerank": false}
+	if callConfig := searchCallConfig(config, request); callConfig.ReRanker != nil {
+		t.Fatalf("expected rerank false to disable the lexical re-ranker, got %T", callConfig.ReRanker)
+	}
+}
+
+func TestSearchReRankerKeepsCustomReRankerAndCallContext(t *testing.T) {
+	config := newTestConfig(t, 16)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/oauth.md", "aaaa1111", "oauth provider setup", 16),
+	})
+	reRanker := &reverseReRanker{}
+	config.ReRanker = reRanker
+
+	var request mcp.CallToolRequest
+	request.Params.Arguments = map[string]any{"query": "oauth", "rerank": true}
+	callConfig := searchCallConfig(config, request)
+	if callConfig.ReRanker != reRanker {
+		t.Fatalf("expected rerank true to keep the configured re-ranker, got %T", callConfig.ReRanker)
+	}
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "call")
+	if _, err := searchDocumentsWithResults(ctx, "oauth", callConfig, 1); err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if reRanker.ctx == nil || reRanker.ctx.Value(key{}) != "call" {
+		t.Fatalf("expected the re-ranker to receive the call's context")
+	}
+
+	reRanker.ctx = nil
+	if _, err := SearchContext(ctx, "oauth", callConfig, SearchOptions{MaxResults: 1}); err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if reRanker.ctx == nil || reRanker.ctx.Value(key{}) != "call" {
+		t.Fatalf("expected SearchContext to re-rank under the caller's context")
+	}
+}
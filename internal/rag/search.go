@@ -3,60 +3,174 @@ package rag
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/philippgille/chromem-go"
 )
 
-// SearchDocuments searches for documents similar to the query text
-func SearchDocuments(queryText string, config Config) error {
-	fmt.Printf("Searching for: %s\n", queryText)
-	fmt.Printf("Using database: %s\n", config.DBPath)
+// StdinQuery is the query argument that makes SearchDocuments read the query from standard input
+const StdinQuery = "-"
+
+// DefaultSearchMaxResults is the number of results Search returns when SearchOptions.MaxResults
+// is not set
+const DefaultSearchMaxResults = 10
+
+// SearchOptions narrow and page the results of Search. They take the place of the matching
+// Config fields.
+type SearchOptions struct {
+	MaxResults    int     // Results to return (0 for DefaultSearchMaxResults)
+	Offset        int     // Skip this many of the best results, to page through them
+	MinSimilarity float64 // Drop results scoring below this (0 to keep all)
+	HeadingFilter string  // Only return chunks whose heading path contains this text
+	TagFilter     string  // Only return chunks whose frontmatter tags include this tag
+	OpenTasks     bool    // Only return chunks containing open task-list items
+}
 
-	// Load database
-	if _, err := os.Stat(config.DBPath); os.IsNotExist(err) {
-		return fmt.Errorf("database not found. Please run indexing first with -index")
-	}
+// Search returns the documents most similar to queryText, best first, for programs embedding
+// the package. config selects the database, the embedding model, and vector or hybrid search;
+// opts narrow and page the results:
+//
+//	results, err := rag.Search("configure oauth", config, rag.SearchOptions{MaxResults: 5, TagFilter: "auth"})
+//
+// An error explains why nothing matched, as rag_search reports it.
+func Search(queryText string, config Config, opts SearchOptions) ([]SearchResult, error) {
+	return SearchContext(context.Background(), queryText, config, opts)
+}
 
-	db := chromem.NewDB()
-	file, err := os.Open(config.DBPath)
+// SearchContext is Search embedding the query and re-ranking under ctx, so that cancelling ctx
+// stops the search
+func SearchContext(ctx context.Context, queryText string, config Config, opts SearchOptions) ([]SearchResult, error) {
+	page, err := searchPage(ctx, queryText, config, opts)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, err
+	}
+	if page.Matched == 0 {
+		return nil, fmt.Errorf("no similar documents found: %s", page.Funnel.noResultsReason(page.Config))
+	}
+	if len(page.Results) == 0 {
+		return nil, fmt.Errorf("no results at offset %d: the query matched %d", page.Offset, page.Matched)
+	}
+	return page.Results, nil
+}
+
+// rankedPage is one page of search results and what is needed to describe it
+type rankedPage struct {
+	Results []SearchResult
+	Offset  int
+	Matched int  // Results ranked through the end of the page, plus one when more follow
+	HasMore bool // Results follow the page
+	Funnel  searchFunnel
+	Config  Config // The search configuration with the options applied
+}
+
+// searchPage runs a search for Search and SearchDocuments. An empty database gives an empty page
+// rather than an error.
+func searchPage(ctx context.Context, queryText string, config Config, opts SearchOptions) (rankedPage, error) {
+	config.SearchOffset = max(opts.Offset, 0)
+	config.MinSimilarity = opts.MinSimilarity
+	config.HeadingFilter = opts.HeadingFilter
+	config.TagFilter = opts.TagFilter
+	config.OpenTasksFilter = opts.OpenTasks
+	maxResults := opts.MaxResults
+	if maxResults <= 0 {
+		maxResults = DefaultSearchMaxResults
 	}
-	defer file.Close()
+	page := rankedPage{Offset: config.SearchOffset, Config: config}
 
-	err = db.ImportFromReader(file, "")
+	collection, err := loadSearchCollection(config)
 	if err != nil {
-		return fmt.Errorf("failed to load database: %w", err)
+		return page, err
 	}
 
-	// Create embedding function for Ollama
-	embeddingFunc := CreateEmbeddingFunc(config)
+	// Search for similar documents through the requested page, plus one to tell whether more follow
+	results, funnel, err := queryCollection(ctx, collection, queryText, page.Offset+maxResults+1, config)
+	if err != nil {
+		return page, err
+	}
+	page.Funnel = funnel
+	page.Matched = len(results)
+	page.HasMore = len(results) > page.Offset+maxResults
+
+	// The results through the end of the page are re-ranked together, as rag_search does
+	ranked := make([]SearchResult, 0, len(results))
+	for _, result := range results[:min(page.Offset+maxResults, len(results))] {
+		ranked = append(ranked, toSearchResult(result))
+	}
+	ranked, err = reRanker(config).ReRank(ctx, queryText, ranked)
+	if err != nil {
+		return page, fmt.Errorf("failed to re-rank results: %w", err)
+	}
+	page.Results = ranked[min(page.Offset, len(ranked)):]
+	return page, nil
+}
 
-	collection := db.GetCollection("documents", embeddingFunc)
-	if collection == nil {
-		return fmt.Errorf("documents collection not found in database")
+// SearchDocuments searches for documents similar to the query text and prints the results. A
+// query of StdinQuery is read from standard input instead.
+func SearchDocuments(queryText string, config Config) error {
+	if queryText == StdinQuery {
+		var err error
+		queryText, err = readQuery(os.Stdin)
+		if err != nil {
+			return err
+		}
+	}
+	if !config.JSON {
+		fmt.Printf("Searching for: %s\n", queryText)
+		fmt.Printf("Using database: %s\n", config.DBPath)
 	}
 
-	// Get collection count to determine max results
-	count := collection.Count()
+	page, err := searchPage(context.Background(), queryText, config, SearchOptions{
+		Offset:        config.SearchOffset,
+		MinSimilarity: config.MinSimilarity,
+		HeadingFilter: config.HeadingFilter,
+		TagFilter:     config.TagFilter,
+		OpenTasks:     config.OpenTasksFilter,
+	})
+	if err != nil {
+		return err
+	}
+	offset, results := page.Offset, page.Results
 
-	if count == 0 {
+	if page.Funnel.Documents == 0 {
+		if config.JSON {
+			return writeJSON(JSONSearchResults{Query: queryText, Results: []JSONChunk{}})
+		}
 		fmt.Println("No documents found in the database.")
 		return nil
 	}
 
-	// Limit results to available documents (max 10)
-	maxResults := MinInt(10, count)
+	fields := outputFields(config)
+	if config.JSON {
+		output := JSONSearchResults{Query: queryText, Offset: offset, HasMore: page.HasMore, Results: make([]JSONChunk, 0, len(results))}
+		if page.Matched == 0 {
+			output.NoResultsReason = page.Funnel.noResultsReason(config)
+		} else if len(results) == 0 {
+			output.NoResultsReason = fmt.Sprintf("no results at offset %d: the query matched %d", offset, page.Matched)
+		}
+		for _, result := range results {
+			chunk := toJSONChunk(result.Metadata, fields)
+			chunk.Similarity = &result.Similarity
+			output.Results = append(output.Results, chunk)
+		}
+		return writeJSON(output)
+	}
 
-	// Search for similar documents
-	results, err := collection.Query(context.Background(), queryText, maxResults, nil, nil)
-	if err != nil {
-		return fmt.Errorf("failed to query collection: %w", err)
+	scoreLabel := "Similarity"
+	if config.Hybrid {
+		scoreLabel = "Hybrid Score"
 	}
 
+	if page.Matched == 0 {
+		fmt.Printf("No similar documents found: %s\n", page.Funnel.noResultsReason(config))
+		return nil
+	}
 	if len(results) == 0 {
-		fmt.Println("No similar documents found.")
+		fmt.Printf("No results at offset %d: the query matched %d\n", offset, page.Matched)
 		return nil
 	}
 
@@ -64,35 +178,292 @@ func SearchDocuments(queryText string, config Config) error {
 	fmt.Println("===============")
 
 	for i, result := range results {
-		isChunk := result.Metadata["is_chunk"] == "true"
-		chunkInfo := ""
+		if len(config.Fields) == 0 {
+			printSearchResult(offset+i+1, result, scoreLabel, config)
+			continue
+		}
+		fmt.Printf("\n%d. File: %s\n", offset+i+1, fileReference(result.Metadata, config.LinkScheme))
+		fmt.Printf("   %s: %.4f\n", scoreLabel, result.Similarity)
+		for _, field := range selectFields(result.Metadata, fields) {
+			if field.Value != "" {
+				fmt.Printf("   %s: %s\n", field.Label(), field.Value)
+			}
+		}
+	}
+	if page.HasMore {
+		fmt.Printf("\nMore results available: use -offset %d for the next page\n", offset+DefaultSearchMaxResults)
+	}
+
+	return nil
+}
+
+// printSearchResult prints a search result in the layout used when -fields is not given
+func printSearchResult(rank int, result SearchResult, scoreLabel string, config Config) {
+	isChunk := result.Metadata["is_chunk"] == "true"
+	chunkInfo := ""
 
-		if isChunk {
-			chunkIndex := result.Metadata["chunk_index"]
-			tokenCount := result.Metadata["token_count"]
-			headingPath := result.Metadata["heading_path"]
+	if isChunk {
+		chunkIndex := result.Metadata["chunk_index"]
+		tokenCount := result.Metadata["token_count"]
+		headingPath := result.Metadata["heading_path"]
 
-			chunkInfo = fmt.Sprintf(" (chunk %s, %s tokens", chunkIndex, tokenCount)
-			if headingPath != "" {
-				chunkInfo += fmt.Sprintf(", context: %s", headingPath)
-			}
-			chunkInfo += ")"
+		chunkInfo = fmt.Sprintf(" (chunk %s, %s tokens", chunkIndex, tokenCount)
+		if headingPath != "" {
+			chunkInfo += fmt.Sprintf(", context: %s", headingPath)
 		}
+		chunkInfo += ")"
+	}
+
+	fmt.Printf("\n%d. File: %s%s\n", rank, fileReference(result.Metadata, config.LinkScheme), chunkInfo)
+	fmt.Printf("   %s: %.4f\n", scoreLabel, result.Similarity)
+	fmt.Printf("   Size: %s bytes\n", result.Metadata["file_size"])
+	fmt.Printf("   Last Modified: %s\n", result.Metadata["last_modified"])
+	fmt.Printf("   Indexed: %s\n", result.Metadata["indexed_at"])
 
-		fmt.Printf("\n%d. File: %s%s\n", i+1, result.Metadata["file_path"], chunkInfo)
-		fmt.Printf("   Similarity: %.4f\n", result.Similarity)
-		fmt.Printf("   Size: %s bytes\n", result.Metadata["file_size"])
-		fmt.Printf("   Last Modified: %s\n", result.Metadata["last_modified"])
-		fmt.Printf("   Indexed: %s\n", result.Metadata["indexed_at"])
+	if isChunk {
+		startOffset := result.Metadata["start_offset"]
+		endOffset := result.Metadata["end_offset"]
+		fmt.Printf("   Chunk Range: chars %s-%s\n", startOffset, endOffset)
+	}
+}
+
+// readQuery reads a query from r, such as a question piped to -query -, trimming the
+// surrounding whitespace and trailing newline; line breaks within the query are kept
+func readQuery(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read query from standard input: %w", err)
+	}
+	query := strings.TrimSpace(string(data))
+	if query == "" {
+		return "", fmt.Errorf("query read from standard input is empty")
+	}
+	return query, nil
+}
+
+// fileReference returns the file path of a result, or an editor URI anchored to the result's
+// lines when a link scheme is configured and the file can still be read
+func fileReference(metadata map[string]string, scheme string) string {
+	filePath := metadata["file_path"]
+	if scheme == "" {
+		return filePath
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return filePath
+	}
+
+	start, end := 0, len(content)
+	if metadata["is_chunk"] == "true" {
+		start, _ = strconv.Atoi(metadata["start_offset"])
+		end, _ = strconv.Atoi(metadata["end_offset"])
+	}
+	startLine, endLine := LineRange(string(content), start, end)
+	return EditorURI(scheme, filePath, startLine, endLine)
+}
+
+// searchFunnel counts the results surviving each stage of queryCollection, so that an empty
+// result can be explained
+type searchFunnel struct {
+	Documents      int     // Documents in the collection
+	Candidates     int     // Results returned by the vector or hybrid query
+	AfterHeading   int     // Candidates left after the heading filter
+	AfterTag       int     // Candidates left after the tag filter
+	AfterTasks     int     // Candidates left after the open tasks filter
+	AfterThreshold int     // Candidates left after the min similarity threshold
+	BestSimilarity float32 // Highest score among candidates reaching the threshold
+}
 
-		if isChunk {
-			startOffset := result.Metadata["start_offset"]
-			endOffset := result.Metadata["end_offset"]
-			fmt.Printf("   Chunk Range: chars %s-%s\n", startOffset, endOffset)
+// noResultsReason explains which stage of the search left no results
+func (f searchFunnel) noResultsReason(config Config) string {
+	switch {
+	case f.Documents == 0:
+		return "database empty"
+	case f.Candidates == 0:
+		return "the query returned no candidates"
+	case f.AfterHeading == 0:
+		return fmt.Sprintf("%d candidate(s) but all excluded by heading filter %q", f.Candidates, config.HeadingFilter)
+	case f.AfterTag == 0:
+		return fmt.Sprintf("%d candidate(s) but all excluded by tag filter %q", f.AfterHeading, config.TagFilter)
+	case f.AfterTasks == 0:
+		return fmt.Sprintf("%d candidate(s) but none contain open tasks (was the index built with -extract-tasks?)", f.AfterTag)
+	case f.AfterThreshold == 0:
+		return fmt.Sprintf("%d candidate(s) but all below min_similarity %.4f (best %.4f)", f.AfterTasks, config.MinSimilarity, f.BestSimilarity)
+	}
+	return ""
+}
+
+// queryCollection queries the collection using vector or hybrid search as configured, then
+// applies any post-query filters, returning at most maxResults results and how many survived
+// each stage
+func queryCollection(ctx context.Context, collection *chromem.Collection, queryText string, maxResults int, config Config) ([]chromem.Result, searchFunnel, error) {
+	defer logSlow(config, "query", fmt.Sprintf("%q", queryText), time.Now())
+	count := collection.Count()
+	funnel := searchFunnel{Documents: count}
+	if count == 0 {
+		return nil, funnel, nil
+	}
+
+	// Post-query filters and the region and recency boosts need the full candidate set so
+	// filtered-out results don't crowd out matches
+	nCandidates := maxResults
+	if config.HeadingFilter != "" || config.TagFilter != "" || config.OpenTasksFilter || config.PreferRegion != "" || config.RecencyBoost > 0 {
+		nCandidates = count
+	}
+	if nCandidates > count {
+		nCandidates = count
+	}
+
+	var results []chromem.Result
+	var err error
+	if config.Hybrid {
+		results, err = hybridQuery(ctx, collection, queryText, nCandidates, config.HybridAlpha)
+	} else {
+		results, err = vectorQuery(ctx, collection, queryText, nCandidates)
+	}
+	if err != nil {
+		return nil, funnel, fmt.Errorf("failed to query collection: %w", err)
+	}
+	funnel.Candidates = len(results)
+
+	results = filterByHeading(results, config.HeadingFilter)
+	funnel.AfterHeading = len(results)
+	results = filterByTag(results, config.TagFilter)
+	funnel.AfterTag = len(results)
+	results = filterByOpenTasks(results, config.OpenTasksFilter)
+	funnel.AfterTasks = len(results)
+	if len(results) > 0 {
+		// Results are sorted, so the first is the best
+		funnel.BestSimilarity = results[0].Similarity
+	}
+	results = filterBySimilarity(results, config.MinSimilarity)
+	funnel.AfterThreshold = len(results)
+	// The threshold applies to the raw similarity, before the boosts
+	boostRegion(results, config.PreferRegion)
+	boostRecency(results, config.RecencyBoost, config.RecencyHalfLife, time.Now())
+
+	if len(results) > maxResults {
+		results = results[:maxResults]
+	}
+	resolveResultPaths(results, config)
+	return results, funnel, nil
+}
+
+// vectorQuery returns the nResults documents most similar to queryText in a deterministic order.
+// chromem picks arbitrarily among documents with equal scores, so one extra result is fetched to
+// detect a tie across the cutoff, in which case every document is ranked.
+func vectorQuery(ctx context.Context, collection *chromem.Collection, queryText string, nResults int) ([]chromem.Result, error) {
+	count := collection.Count()
+	results, err := collection.Query(ctx, queryText, min(nResults+1, count), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	sortResults(results)
+
+	if len(results) > nResults && nResults > 0 && results[nResults].Similarity == results[nResults-1].Similarity && len(results) < count {
+		results, err = collection.Query(ctx, queryText, count, nil, nil)
+		if err != nil {
+			return nil, err
 		}
+		sortResults(results)
 	}
 
-	return nil
+	if len(results) > nResults {
+		results = results[:nResults]
+	}
+	return results, nil
+}
+
+// sortResults orders results by descending similarity, breaking ties with compareDocuments so
+// that equal scores always come out in the same order
+func sortResults(results []chromem.Result) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Similarity != results[j].Similarity {
+			return results[i].Similarity > results[j].Similarity
+		}
+		return compareDocuments(results[i], results[j]) < 0
+	})
+}
+
+// compareDocuments orders documents by file path, chunk index, and ID, giving every document
+// in a collection a fixed position
+func compareDocuments(a, b chromem.Result) int {
+	if c := strings.Compare(a.Metadata["file_path"], b.Metadata["file_path"]); c != 0 {
+		return c
+	}
+	indexA, _ := strconv.Atoi(a.Metadata["chunk_index"])
+	indexB, _ := strconv.Atoi(b.Metadata["chunk_index"])
+	if indexA != indexB {
+		return indexA - indexB
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// allDocuments returns every document in the collection ordered by compareDocuments. chromem
+// has no listing call, so this runs a query that ranks all documents and then discards the ranking.
+func allDocuments(ctx context.Context, collection *chromem.Collection) ([]chromem.Result, error) {
+	count := collection.Count()
+	if count == 0 {
+		return nil, nil
+	}
+
+	// Get all documents by querying with a generic term that should match most content
+	results, err := collection.Query(ctx, "text document file", count, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return compareDocuments(results[i], results[j]) < 0
+	})
+	return results, nil
+}
+
+// filterByHeading keeps results whose heading_path contains filter (case-insensitive).
+// heading_path is stored as a joined string, so this substring match runs after the query.
+func filterByHeading(results []chromem.Result, filter string) []chromem.Result {
+	if filter == "" {
+		return results
+	}
+
+	filter = strings.ToLower(filter)
+	filtered := results[:0]
+	for _, result := range results {
+		if strings.Contains(strings.ToLower(result.Metadata["heading_path"]), filter) {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+// filterByTag keeps results whose frontmatter tags include tag (case-insensitive)
+func filterByTag(results []chromem.Result, tag string) []chromem.Result {
+	if tag == "" {
+		return results
+	}
+
+	filtered := results[:0]
+	for _, result := range results {
+		if hasTag(result.Metadata["tags"], tag) {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+// filterBySimilarity keeps results scoring at least minSimilarity
+func filterBySimilarity(results []chromem.Result, minSimilarity float64) []chromem.Result {
+	if minSimilarity <= 0 {
+		return results
+	}
+
+	filtered := results[:0]
+	for _, result := range results {
+		if float64(result.Similarity) >= minSimilarity {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
 }
 
 // MCPSearchResult represents the result of an MCP search
@@ -106,27 +477,12 @@ type MCPSearchResult struct {
 
 // MCPSearchDocuments searches for documents and returns the content for MCP
 func MCPSearchDocuments(queryText string, config Config) (*MCPSearchResult, error) {
-	// Load database
-	if _, err := os.Stat(config.DBPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("database not found. Please run indexing first with -index")
-	}
-
-	db := chromem.NewDB()
-	file, err := os.Open(config.DBPath)
+	collection, release, err := loadCollection(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	err = db.ImportFromReader(file, "")
-	if err != nil {
-		return nil, fmt.Errorf("failed to load database: %w", err)
-	}
-
-	// Create embedding function for Ollama
-	embeddingFunc := CreateEmbeddingFunc(config)
+	defer release()
 
-	collection := db.GetCollection("documents", embeddingFunc)
 	if collection == nil {
 		return nil, fmt.Errorf("documents collection not found in database")
 	}
@@ -148,6 +504,7 @@ func MCPSearchDocuments(queryText string, config Config) (*MCPSearchResult, erro
 		return nil, fmt.Errorf("no similar documents found")
 	}
 
+	resolveResultPaths(results, config)
 	result := results[0]
 	isChunk := result.Metadata["is_chunk"] == "true"
 
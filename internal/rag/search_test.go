@@ -0,0 +1,250 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestQueryCollectionHeadingFilter(t *testing.T) {
+	config := newTestConfig(t, 16)
+	install := testDocument("/docs/install.md", "aaaa1111", "install the packages with apt", 16)
+	install.Metadata["heading_path"] = "Guide > Installation"
+	usage := testDocument("/docs/usage.md", "bbbb2222", "install packages then run the tool", 16)
+	usage.Metadata["heading_path"] = "Guide > Usage"
+	collection := newTestCollection(t, config, []chromem.Document{install, usage})
+
+	for _, hybrid := range []bool{false, true} {
+		config.Hybrid = hybrid
+		config.HeadingFilter = "installation"
+
+		// Ask for a single result so the filter must look past the top vector match
+		results, _, err := queryCollection(context.Background(), collection, "install packages then run the tool", 1, config)
+		if err != nil {
+			t.Fatalf("unexpected query error: %v", err)
+		}
+		if len(results) != 1 || results[0].Metadata["file_path"] != "/docs/install.md" {
+			t.Fatalf("hybrid %v: expected only the Installation chunk, got %v", hybrid, results)
+		}
+	}
+}
+
+func TestFileReferenceEditorURI(t *testing.T) {
+	root := writeTestFiles(t, map[string]string{
+		"guide.md": "# Guide\n\nIntro line\n\n## Setup\n\nStep one\nStep two\n",
+	})
+	filePath := filepath.Join(root, "guide.md")
+	content, _ := os.ReadFile(filePath)
+	start := strings.Index(string(content), "## Setup")
+
+	result := chromem.Result{Metadata: map[string]string{
+		"file_path":    filePath,
+		"is_chunk":     "true",
+		"start_offset": strconv.Itoa(start),
+		"end_offset":   strconv.Itoa(len(content)),
+	}}
+
+	if got := fileReference(result.Metadata, ""); got != filePath {
+		t.Fatalf("expected the plain path without a scheme, got %s", got)
+	}
+	want := "file://" + filepath.ToSlash(filePath) + "#L5-L8"
+	if got := fileReference(result.Metadata, "file"); got != want {
+		t.Fatalf("unexpected editor URI: got %s, want %s", got, want)
+	}
+}
+
+func TestQueryCollectionMinSimilarity(t *testing.T) {
+	config := newTestConfig(t, 16)
+	collection := newTestCollection(t, config, []chromem.Document{
+		testDocument("/docs/match.md", "aaaa1111", "oauth provider setup", 16),
+		testDocument("/docs/other.md", "bbbb2222", "unrelated gardening notes", 16),
+	})
+
+	config.MinSimilarity = 0.9
+	results, _, err := queryCollection(context.Background(), collection, "oauth provider setup", 2, config)
+	if err != nil {
+		t.Fatalf("unexpected query error: %v", err)
+	}
+	if len(results) != 1 || results[0].Metadata["file_path"] != "/docs/match.md" {
+		t.Fatalf("expected only the close match above the threshold, got %v", results)
+	}
+}
+
+func TestJSONChunkFieldNames(t *testing.T) {
+	similarity := float32(0.5)
+	chunk := toJSONChunk(map[string]string{
+		"file_path":    "/docs/guide.md",
+		"chunk_index":  "2",
+		"start_offset": "100",
+		"end_offset":   "200",
+		"token_count":  "25",
+		"heading_path": "Setup",
+		"is_chunk":     "true",
+	}, ParseFields(DefaultFields))
+	chunk.Similarity = &similarity
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	want := `{"file_path":"/docs/guide.md","similarity":0.5,"chunk_index":2,"start_offset":100,"end_offset":200,"token_count":25,"heading_path":"Setup","is_chunk":true}`
+	if string(data) != want {
+		t.Fatalf("unexpected JSON:\ngot  %s\nwant %s", data, want)
+	}
+}
+
+func TestQueryCollectionNoResultsReason(t *testing.T) {
+	config := newTestConfig(t, 16)
+	doc := testDocument("/docs/guide.md", "aaaa1111", "oauth provider setup", 16)
+	doc.Metadata["heading_path"] = "Guide > Setup"
+	doc.Metadata["tags"] = "auth"
+	collection := newTestCollection(t, config, []chromem.Document{
+		doc,
+		testDocument("/docs/other.md", "bbbb2222", "unrelated gardening notes", 16),
+	})
+	empty := newTestCollection(t, config, nil)
+
+	for _, tc := range []struct {
+		name       string
+		collection *chromem.Collection
+		configure  func(*Config)
+		want       string
+	}{
+		{name: "empty database", collection: empty, want: "database empty"},
+		{
+			name:      "heading filter",
+			configure: func(c *Config) { c.HeadingFilter = "installation" },
+			want:      `2 candidate(s) but all excluded by heading filter "installation"`,
+		},
+		{
+			name: "tag filter",
+			configure: func(c *Config) {
+				c.HeadingFilter = "setup"
+				c.TagFilter = "billing"
+			},
+			want: `1 candidate(s) but all excluded by tag filter "billing"`,
+		},
+		{
+			name:      "open tasks filter",
+			configure: func(c *Config) { c.OpenTasksFilter = true },
+			want:      "2 candidate(s) but none contain open tasks (was the index built with -extract-tasks?)",
+		},
+		{
+			name:      "min similarity",
+			configure: func(c *Config) { c.MinSimilarity = 1.5 },
+			want:      "2 candidate(s) but all below min_similarity 1.5000 (best 1.0000)",
+		},
+	} {
+		callConfig := config
+		if tc.configure != nil {
+			tc.configure(&callConfig)
+		}
+		target := collection
+		if tc.collection != nil {
+			target = tc.collection
+		}
+
+		results, funnel, err := queryCollection(context.Background(), target, "oauth provider setup", 2, callConfig)
+		if err != nil {
+			t.Fatalf("%s: unexpected query error: %v", tc.name, err)
+		}
+		if len(results) != 0 {
+			t.Fatalf("%s: expected no results, got %v", tc.name, results)
+		}
+		if got := funnel.noResultsReason(callConfig); got != tc.want {
+			t.Fatalf("%s: got reason %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSearchDocumentsOffsetJSON(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.JSON = true
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/a.md", "aaaa1111", "deploy the api", 8),
+		testDocument("/docs/b.md", "bbbb2222", "deploy the worker", 8),
+		testDocument("/docs/c.md", "cccc3333", "rotate the keys", 8),
+	})
+
+	page := func(offset int) JSONSearchResults {
+		config.SearchOffset = offset
+		output := captureStdout(t, func() {
+			if err := SearchDocuments("deploy", config); err != nil {
+				t.Fatalf("unexpected search error: %v", err)
+			}
+		})
+		var results JSONSearchResults
+		if err := json.Unmarshal([]byte(output), &results); err != nil {
+			t.Fatalf("failed to parse JSON output %q: %v", output, err)
+		}
+		return results
+	}
+
+	first, second := page(0), page(2)
+	if len(first.Results) != 3 || first.HasMore {
+		t.Fatalf("expected all 3 results on the first page, got %+v", first)
+	}
+	if len(second.Results) != 1 || second.Offset != 2 || second.Results[0].FilePath != first.Results[2].FilePath {
+		t.Fatalf("expected the third result at offset 2, got %+v", second)
+	}
+	if beyond := page(3); len(beyond.Results) != 0 || !strings.Contains(beyond.NoResultsReason, "no results at offset 3") {
+		t.Fatalf("expected an empty page past the results, got %+v", beyond)
+	}
+}
+
+func TestSearchReturnsStructuredResults(t *testing.T) {
+	config := newTestConfig(t, 8)
+	tagged := testDocument("/docs/auth.md", "cccc3333", "deploy the auth service", 8)
+	tagged.Metadata["tags"] = "auth,ops"
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		testDocument("/docs/api.md", "aaaa1111", "deploy the api", 8),
+		testDocument("/docs/keys.md", "bbbb2222", "rotate the keys", 8),
+		tagged,
+	})
+
+	results, err := Search("deploy", config, SearchOptions{MaxResults: 2})
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected max_results to limit the results to 2, got %d", len(results))
+	}
+	if results[0].FilePath == "" || results[0].Metadata["file_hash"] == "" || results[0].Similarity < results[1].Similarity {
+		t.Fatalf("expected ranked results carrying their metadata, got %+v", results)
+	}
+
+	results, err = Search("deploy", config, SearchOptions{TagFilter: "auth"})
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	if len(results) != 1 || results[0].FilePath != "/docs/auth.md" || results[0].Metadata["tags"] != "auth,ops" {
+		t.Fatalf("expected only the tagged document, got %+v", results)
+	}
+
+	if _, err := Search("deploy", config, SearchOptions{MinSimilarity: 1.5}); err == nil || !strings.Contains(err.Error(), "all below min_similarity 1.5000") {
+		t.Fatalf("expected the similarity threshold to explain the empty result, got %v", err)
+	}
+	if _, err := Search("deploy", config, SearchOptions{Offset: 3}); err == nil || !strings.Contains(err.Error(), "no results at offset 3: the query matched 3") {
+		t.Fatalf("expected an offset past the results to be reported, got %v", err)
+	}
+}
+
+func TestReadQueryFromStdin(t *testing.T) {
+	query, err := readQuery(strings.NewReader("  What does \"$HOME\" expand to?\nAnd `~`?\n\n"))
+	if err != nil {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+	if want := "What does \"$HOME\" expand to?\nAnd `~`?"; query != want {
+		t.Fatalf("readQuery = %q, want %q", query, want)
+	}
+
+	if _, err := readQuery(strings.NewReader(" \n")); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Fatalf("expected an empty query to be rejected, got %v", err)
+	}
+}
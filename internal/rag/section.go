@@ -0,0 +1,111 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxListedHeadings bounds the headings a section lookup error lists as alternatives
+const maxListedHeadings = 20
+
+// Section is the part of a markdown file from a heading to the next heading of the same or a
+// higher level
+type Section struct {
+	HeadingPath  string // The heading and the headings enclosing it, joined with " > "
+	Level        int
+	StartOffset  int // Offset of the heading line
+	EndOffset    int // Offset of the next heading of the same or a higher level, or the file length
+	Content      string
+	OtherMatches int // Later sections that also match the requested heading
+}
+
+// MCPRetrieveSection returns the first section of filePath whose heading matches heading, compared
+// case-insensitively. heading may be a heading path such as "API > Authentication", which matches
+// sections whose path ends with those headings.
+func MCPRetrieveSection(filePath, heading string) (*Section, error) {
+	content, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("file not found: %s", filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	contentStr := string(content)
+
+	want := splitHeadingPath(heading)
+	if len(want) == 0 {
+		return nil, fmt.Errorf("heading must not be empty")
+	}
+
+	headings := ExtractHeadings(contentStr, 0)
+	index := NewHeadingIndex(headings, 0)
+
+	var section *Section
+	for i, h := range headings {
+		path := index.Context(h.Position + 1)
+		if !headingPathMatches(path, want) {
+			continue
+		}
+		if section != nil {
+			section.OtherMatches++
+			continue
+		}
+
+		end := len(contentStr)
+		for _, next := range headings[i+1:] {
+			if next.Level <= h.Level {
+				end = next.Position
+				break
+			}
+		}
+		section = &Section{
+			HeadingPath: strings.Join(path, " > "),
+			Level:       h.Level,
+			StartOffset: h.Position,
+			EndOffset:   end,
+			Content:     contentStr[h.Position:end],
+		}
+	}
+	if section != nil {
+		return section, nil
+	}
+
+	if len(headings) == 0 {
+		return nil, fmt.Errorf("heading %q not found: %s has no headings", heading, filePath)
+	}
+	var available []string
+	for _, h := range headings[:min(len(headings), maxListedHeadings)] {
+		available = append(available, h.Text)
+	}
+	more := ""
+	if len(headings) > maxListedHeadings {
+		more = fmt.Sprintf(", and %d more", len(headings)-maxListedHeadings)
+	}
+	return nil, fmt.Errorf("heading %q not found in %s; its headings are: %s%s", heading, filePath, strings.Join(available, ", "), more)
+}
+
+// splitHeadingPath splits a heading or " > "-separated heading path into its lowercase headings
+func splitHeadingPath(heading string) []string {
+	var parts []string
+	for _, part := range strings.Split(heading, ">") {
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// headingPathMatches reports whether path ends with the lowercase headings in want
+func headingPathMatches(path, want []string) bool {
+	if len(want) > len(path) {
+		return false
+	}
+	tail := path[len(path)-len(want):]
+	for i := range want {
+		if strings.ToLower(tail[i]) != want[i] {
+			return false
+		}
+	}
+	return true
+}
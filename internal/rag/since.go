@@ -0,0 +1,44 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// sinceLayouts are the timestamp formats accepted by -since, besides durations
+var sinceLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// ParseSince parses a -since value, either a duration before now such as 24h, or a timestamp
+// such as 2024-05-01 or 2024-05-01T08:00:00Z. Timestamps without a zone are local time.
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("duration %s is negative", value)
+		}
+		return now.Add(-d), nil
+	}
+	for _, layout := range sinceLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a duration such as 24h or a timestamp such as 2006-01-02 or %s", time.RFC3339)
+}
+
+// filesModifiedSince returns the files modified at or after since, judged by their modification
+// time alone so that older files are skipped without being read. A zero since keeps every file,
+// as does a file that cannot be stat'ed, leaving its error to the indexer.
+func filesModifiedSince(files []string, since time.Time) []string {
+	if since.IsZero() {
+		return files
+	}
+	recent := make([]string, 0, len(files))
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Before(since) {
+			recent = append(recent, path)
+		}
+	}
+	return recent
+}
@@ -0,0 +1,62 @@
+package rag
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"24h", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{"2024-04-30T08:00:00Z", time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC)},
+		{"2024-04-30", time.Date(2024, 4, 30, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := ParseSince(tt.value, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseSince(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []string{"-24h", "yesterday"} {
+		if _, err := ParseSince(value, now); err == nil {
+			t.Errorf("expected ParseSince(%q) to fail", value)
+		}
+	}
+}
+
+func TestIndexDocumentsSinceSkipsOlderFiles(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.Progress = TextProgress(io.Discard, LogNormal)
+	root := writeTestFiles(t, map[string]string{
+		"old.md":    "# Old\n\nLast edited last week.\n",
+		"recent.md": "# Recent\n\nEdited this morning.\n",
+		"today.md":  "# Today\n\nEdited just now.\n",
+	})
+	now := time.Now()
+	for name, age := range map[string]time.Duration{"old.md": 7 * 24 * time.Hour, "recent.md": 6 * time.Hour} {
+		modTime := now.Add(-age)
+		if err := os.Chtimes(filepath.Join(root, name), modTime, modTime); err != nil {
+			t.Fatalf("failed to set modification time: %v", err)
+		}
+	}
+
+	config.Since = now.Add(-24 * time.Hour)
+	if err := IndexDocuments(root, config, 200, 15, 0.25); err != nil {
+		t.Fatalf("unexpected index error: %v", err)
+	}
+
+	indexed := make(map[string]bool)
+	for _, doc := range readTestDatabase(t, config) {
+		indexed[filepath.Base(doc.Metadata["file_path"])] = true
+	}
+	if len(indexed) != 2 || !indexed["recent.md"] || !indexed["today.md"] {
+		t.Fatalf("expected only the files modified in the last day to be indexed, got %v", indexed)
+	}
+}
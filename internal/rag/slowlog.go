@@ -0,0 +1,32 @@
+package rag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// slowLogOutput receives slow operation reports. It is standard error because standard output
+// carries the MCP stdio transport.
+var slowLogOutput io.Writer = os.Stderr
+
+// logSlow reports an operation of the given kind that started at start when it has taken longer
+// than config.SlowLog. Call it deferred, as defer logSlow(config, kind, detail, time.Now()).
+func logSlow(config Config, kind, detail string, start time.Time) {
+	if config.SlowLog <= 0 {
+		return
+	}
+	if elapsed := time.Since(start); elapsed > config.SlowLog {
+		fmt.Fprintf(slowLogOutput, "Slow %s: took %s, over the %s threshold (%s)\n", kind, elapsed.Round(time.Millisecond), config.SlowLog, detail)
+	}
+}
+
+// importDatabase loads the database exported to path, read through reader, into db, reporting a
+// slow load
+func importDatabase(db *chromem.DB, reader io.ReadSeeker, path string, config Config) error {
+	defer logSlow(config, "database load", path, time.Now())
+	return db.ImportFromReader(reader, "")
+}
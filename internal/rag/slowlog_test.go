@@ -0,0 +1,52 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowLogReportsSlowEmbeddingsToStderrOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.HasPrefix(req.Prompt, "slow") {
+			time.Sleep(50 * time.Millisecond)
+		}
+		json.NewEncoder(w).Encode(OllamaEmbeddingResponse{Embedding: testEmbedding(req.Prompt, 8)})
+	}))
+	defer server.Close()
+
+	var log bytes.Buffer
+	slowLogOutput = &log
+	t.Cleanup(func() { slowLogOutput = os.Stderr })
+
+	config := newTestConfig(t, 8)
+	config.OllamaURL = server.URL
+	config.SlowLog = 20 * time.Millisecond
+
+	output := captureStdout(t, func() {
+		for _, text := range []string{"fast text", "slow text"} {
+			if _, err := GetEmbedding(context.Background(), text, config); err != nil {
+				t.Fatalf("unexpected embedding error: %v", err)
+			}
+		}
+	})
+	if output != "" {
+		t.Fatalf("expected nothing on standard output, got %q", output)
+	}
+
+	logged := log.String()
+	if strings.Count(logged, "Slow ") != 1 || !strings.Contains(logged, "Slow embedding: took ") || !strings.Contains(logged, "over the 20ms threshold (model test-model, 9 characters)") {
+		t.Fatalf("expected one slow embedding to be logged, got %q", logged)
+	}
+}
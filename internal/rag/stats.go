@@ -3,7 +3,6 @@ package rag
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -11,64 +10,143 @@ import (
 	"github.com/philippgille/chromem-go"
 )
 
+// FileChunkInfo describes how a single file was chunked
+type FileChunkInfo struct {
+	Path   string `json:"file_path"`
+	Chunks int    `json:"chunks"`
+	Size   int    `json:"size"`
+}
+
+// Stats holds aggregate statistics about the database contents
+type Stats struct {
+	UniqueFiles       int             `json:"unique_files"`
+	TotalChunks       int             `json:"total_chunks"`
+	ChunkedFiles      int             `json:"chunked_files"`
+	SingleDocFiles    int             `json:"single_doc_files"`
+	AvgChunksPerFile  float64         `json:"avg_chunks_per_file"`
+	MinTokens         int             `json:"min_tokens"`
+	MaxTokens         int             `json:"max_tokens"`
+	AvgTokensPerChunk float64         `json:"avg_tokens_per_chunk"`
+	TotalTokens       int             `json:"total_tokens"`
+	MinFileSize       int64           `json:"min_file_size"`
+	MaxFileSize       int64           `json:"max_file_size"`
+	AvgFileSize       int64           `json:"avg_file_size"`
+	TotalFileSize     int64           `json:"total_file_size"`
+	MostChunkedFiles  []FileChunkInfo `json:"most_chunked_files"` // Sorted by chunk count (descending)
+	CacheEntries      int             `json:"cache_entries"`      // Embeddings in the persistent embedding cache
+	CacheSize         int64           `json:"cache_size"`         // Size of the embedding cache file in bytes
+	Warnings          []string        `json:"warnings,omitempty"` // Files whose chunks disagree on file attributes, by path
+}
+
 // ShowStats displays statistics about the database contents
 func ShowStats(config Config) error {
+	if config.JSON {
+		stats, err := ComputeStats(config)
+		if err != nil {
+			return err
+		}
+		return writeJSON(stats)
+	}
+
 	fmt.Println("Database Statistics")
 	fmt.Println("===================")
 	fmt.Printf("Database: %s\n\n", config.DBPath)
 
-	// Load database
-	if _, err := os.Stat(config.DBPath); os.IsNotExist(err) {
-		fmt.Println("Database not found. Please run indexing first with -index")
+	stats, err := ComputeStats(config)
+	if err != nil {
+		return err
+	}
+
+	if stats.TotalChunks == 0 {
+		fmt.Println("No documents found in the database.")
 		return nil
 	}
 
-	db := chromem.NewDB()
-	file, err := os.Open(config.DBPath)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+	// Display statistics
+	fmt.Printf("📊 Document Overview:\n")
+	fmt.Printf("   Unique files:        %d\n", stats.UniqueFiles)
+	fmt.Printf("   Total chunks:        %d\n", stats.TotalChunks)
+	fmt.Printf("   Chunked files:       %d\n", stats.ChunkedFiles)
+	fmt.Printf("   Single doc files:    %d\n\n", stats.SingleDocFiles)
+
+	fmt.Printf("📈 Chunk Statistics:\n")
+	fmt.Printf("   Avg chunks per file: %.1f\n", stats.AvgChunksPerFile)
+	fmt.Printf("   Min tokens/chunk:    %d\n", stats.MinTokens)
+	fmt.Printf("   Max tokens/chunk:    %d\n", stats.MaxTokens)
+	fmt.Printf("   Avg tokens/chunk:    %.0f\n\n", stats.AvgTokensPerChunk)
+
+	fmt.Printf("📁 File Size Statistics:\n")
+	fmt.Printf("   Min file size:       %s\n", FormatBytes(stats.MinFileSize))
+	fmt.Printf("   Max file size:       %s\n", FormatBytes(stats.MaxFileSize))
+	fmt.Printf("   Avg file size:       %s\n", FormatBytes(stats.AvgFileSize))
+	fmt.Printf("   Total indexed:       %s\n\n", FormatBytes(stats.TotalFileSize))
+
+	fmt.Printf("🔤 Token Statistics:\n")
+	fmt.Printf("   Total tokens:        %s\n\n", FormatNumber(stats.TotalTokens))
+
+	fmt.Printf("💾 Embedding Cache:\n")
+	fmt.Printf("   Cached embeddings:   %s\n", FormatNumber(stats.CacheEntries))
+	fmt.Printf("   Cache size:          %s\n", FormatBytes(stats.CacheSize))
+
+	for _, warning := range stats.Warnings {
+		fmt.Println(warning)
+	}
+	if len(stats.Warnings) > 0 {
+		fmt.Println()
 	}
-	defer file.Close()
 
-	err = db.ImportFromReader(file, "")
+	fmt.Printf("📋 Top 5 Most Chunked Files:\n")
+	for i, info := range stats.MostChunkedFiles {
+		if i >= 5 {
+			break
+		}
+		// Show just filename, not full path
+		filename := filepath.Base(info.Path)
+		fmt.Printf("   %d. %s (%d chunks, %s)\n", i+1, filename, info.Chunks, FormatBytes(int64(info.Size)))
+	}
+
+	return nil
+}
+
+// ComputeStats computes statistics about the database contents
+func ComputeStats(config Config) (*Stats, error) {
+	collection, release, err := loadCollection(config)
 	if err != nil {
-		return fmt.Errorf("failed to load database: %w", err)
+		return nil, err
 	}
+	defer release()
+
+	stats := &Stats{}
 
-	// Create embedding function for Ollama (needed for GetCollection)
-	embeddingFunc := CreateEmbeddingFunc(config)
+	stats.CacheEntries, stats.CacheSize, err = embeddingCacheSize(config)
+	if err != nil {
+		return nil, err
+	}
 
-	collection := db.GetCollection("documents", embeddingFunc)
 	if collection == nil {
-		fmt.Println("No documents collection found in database.")
-		return nil
+		return stats, nil
 	}
 
 	// Get collection count
 	count := collection.Count()
 	if count == 0 {
-		fmt.Println("No documents found in the database.")
-		return nil
+		return stats, nil
 	}
 
 	// Get all documents for analysis
-	results, err := collection.Query(context.Background(), "text document file", count, nil, nil)
+	results, err := allDocuments(context.Background(), collection)
 	if err != nil {
-		return fmt.Errorf("failed to get documents: %w", err)
+		return nil, fmt.Errorf("failed to get documents: %w", err)
 	}
 
 	// Analyze the documents
 	uniqueFiles := make(map[string]bool)
 	fileSizes := make(map[string]int)
-	fileChunkCounts := make(map[string]int)
-	tokenCounts := []int{}
 	chunksByFile := make(map[string][]chromem.Result)
 
 	var totalTokens int
 	var minTokens, maxTokens int = -1, 0
 	var minFileSize, maxFileSize int64 = -1, 0
-	chunkedFiles := 0
-	singleDocFiles := 0
 
 	for _, result := range results {
 		filePath := result.Metadata["file_path"]
@@ -76,27 +154,10 @@ func ShowStats(config Config) error {
 
 		// Track chunks by file
 		chunksByFile[filePath] = append(chunksByFile[filePath], result)
-		fileChunkCounts[filePath]++
-
-		// Parse file size (only need to do this once per file)
-		if _, exists := fileSizes[filePath]; !exists {
-			if sizeStr, ok := result.Metadata["file_size"]; ok {
-				if size, err := strconv.ParseInt(sizeStr, 10, 64); err == nil {
-					fileSizes[filePath] = int(size)
-					if minFileSize == -1 || size < minFileSize {
-						minFileSize = size
-					}
-					if size > maxFileSize {
-						maxFileSize = size
-					}
-				}
-			}
-		}
 
 		// Parse token count
 		if tokenStr, ok := result.Metadata["token_count"]; ok {
 			if tokens, err := strconv.Atoi(tokenStr); err == nil {
-				tokenCounts = append(tokenCounts, tokens)
 				totalTokens += tokens
 				if minTokens == -1 || tokens < minTokens {
 					minTokens = tokens
@@ -108,77 +169,102 @@ func ShowStats(config Config) error {
 		}
 	}
 
+	// File attributes come from each file's first chunk; the others should agree with it
+	for filePath, chunks := range chunksByFile {
+		first := firstChunk(chunks)
+		if size, err := strconv.ParseInt(first.Metadata["file_size"], 10, 64); err == nil {
+			fileSizes[filePath] = int(size)
+			if minFileSize == -1 || size < minFileSize {
+				minFileSize = size
+			}
+			if size > maxFileSize {
+				maxFileSize = size
+			}
+		}
+		stats.Warnings = append(stats.Warnings, fileAttributeWarnings(filePath, first, chunks)...)
+	}
+	sort.Strings(stats.Warnings)
+
 	// Classify files as chunked vs single documents
 	for _, chunks := range chunksByFile {
 		if len(chunks) > 1 || (len(chunks) == 1 && chunks[0].Metadata["is_chunk"] == "true") {
-			chunkedFiles++
+			stats.ChunkedFiles++
 		} else {
-			singleDocFiles++
+			stats.SingleDocFiles++
 		}
 	}
 
-	// Calculate averages
-	avgChunksPerFile := float64(count) / float64(len(uniqueFiles))
-	avgTokensPerChunk := float64(totalTokens) / float64(count)
-
 	// Calculate file size statistics
 	var totalFileSize int64
 	for _, size := range fileSizes {
 		totalFileSize += int64(size)
 	}
-	avgFileSize := float64(totalFileSize) / float64(len(uniqueFiles))
-
-	// Display statistics
-	fmt.Printf("📊 Document Overview:\n")
-	fmt.Printf("   Unique files:        %d\n", len(uniqueFiles))
-	fmt.Printf("   Total chunks:        %d\n", count)
-	fmt.Printf("   Chunked files:       %d\n", chunkedFiles)
-	fmt.Printf("   Single doc files:    %d\n\n", singleDocFiles)
-
-	fmt.Printf("📈 Chunk Statistics:\n")
-	fmt.Printf("   Avg chunks per file: %.1f\n", avgChunksPerFile)
-	fmt.Printf("   Min tokens/chunk:    %d\n", minTokens)
-	fmt.Printf("   Max tokens/chunk:    %d\n", maxTokens)
-	fmt.Printf("   Avg tokens/chunk:    %.0f\n\n", avgTokensPerChunk)
-
-	fmt.Printf("📁 File Size Statistics:\n")
-	fmt.Printf("   Min file size:       %s\n", FormatBytes(minFileSize))
-	fmt.Printf("   Max file size:       %s\n", FormatBytes(maxFileSize))
-	fmt.Printf("   Avg file size:       %s\n", FormatBytes(int64(avgFileSize)))
-	fmt.Printf("   Total indexed:       %s\n\n", FormatBytes(totalFileSize))
 
-	fmt.Printf("🔤 Token Statistics:\n")
-	fmt.Printf("   Total tokens:        %s\n", FormatNumber(totalTokens))
+	stats.UniqueFiles = len(uniqueFiles)
+	stats.TotalChunks = count
+	stats.AvgChunksPerFile = float64(count) / float64(len(uniqueFiles))
+	stats.MinTokens = minTokens
+	stats.MaxTokens = maxTokens
+	stats.AvgTokensPerChunk = float64(totalTokens) / float64(count)
+	stats.TotalTokens = totalTokens
+	stats.MinFileSize = minFileSize
+	stats.MaxFileSize = maxFileSize
+	stats.AvgFileSize = int64(float64(totalFileSize) / float64(len(uniqueFiles)))
+	stats.TotalFileSize = totalFileSize
 
 	// Find files with most chunks
-	type FileChunkInfo struct {
-		Path   string
-		Chunks int
-		Size   int
-	}
-	var fileInfos []FileChunkInfo
 	for filePath, chunks := range chunksByFile {
-		fileInfos = append(fileInfos, FileChunkInfo{
+		stats.MostChunkedFiles = append(stats.MostChunkedFiles, FileChunkInfo{
 			Path:   filePath,
 			Chunks: len(chunks),
 			Size:   fileSizes[filePath],
 		})
 	}
 
-	// Sort by chunk count (descending)
-	sort.Slice(fileInfos, func(i, j int) bool {
-		return fileInfos[i].Chunks > fileInfos[j].Chunks
+	// Sort by chunk count (descending), then by path so ties are ordered consistently
+	sort.Slice(stats.MostChunkedFiles, func(i, j int) bool {
+		if stats.MostChunkedFiles[i].Chunks != stats.MostChunkedFiles[j].Chunks {
+			return stats.MostChunkedFiles[i].Chunks > stats.MostChunkedFiles[j].Chunks
+		}
+		return stats.MostChunkedFiles[i].Path < stats.MostChunkedFiles[j].Path
 	})
 
-	fmt.Printf("📋 Top 5 Most Chunked Files:\n")
-	for i, info := range fileInfos {
-		if i >= 5 {
-			break
+	return stats, nil
+}
+
+// consistentFileAttributes are the metadata keys every chunk of a file copies from the file when it is
+// indexed, so chunks that disagree on them were stored by different index runs
+var consistentFileAttributes = []string{"file_hash", "file_size"}
+
+// firstChunk returns the chunk with the lowest chunk_index, breaking ties by ID, so that per-file
+// attributes are read from the same chunk however the chunks are ordered
+func firstChunk(chunks []chromem.Result) chromem.Result {
+	first := chunks[0]
+	for _, chunk := range chunks[1:] {
+		index, _ := strconv.Atoi(chunk.Metadata["chunk_index"])
+		firstIndex, _ := strconv.Atoi(first.Metadata["chunk_index"])
+		if index < firstIndex || (index == firstIndex && chunk.ID < first.ID) {
+			first = chunk
 		}
-		// Show just filename, not full path
-		filename := filepath.Base(info.Path)
-		fmt.Printf("   %d. %s (%d chunks, %s)\n", i+1, filename, info.Chunks, FormatBytes(int64(info.Size)))
 	}
+	return first
+}
 
-	return nil
+// fileAttributeWarnings describes the file-level attributes on which chunks of filePath disagree
+// with its first chunk, a sign of stale entries left by an earlier index of the file
+func fileAttributeWarnings(filePath string, first chromem.Result, chunks []chromem.Result) []string {
+	var warnings []string
+	for _, key := range consistentFileAttributes {
+		want := first.Metadata[key]
+		disagreeing := 0
+		for _, chunk := range chunks {
+			if chunk.Metadata[key] != want {
+				disagreeing++
+			}
+		}
+		if disagreeing > 0 {
+			warnings = append(warnings, fmt.Sprintf("Warning: %d of %d chunks of %s disagree with chunk %s on %s (%q); the database may hold stale entries, so re-index the file", disagreeing, len(chunks), filePath, first.Metadata["chunk_index"], key, want))
+		}
+	}
+	return warnings
 }
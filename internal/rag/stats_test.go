@@ -0,0 +1,71 @@
+package rag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// statsTestChunk is a chunk of filePath stored by an index run that saw the file with the given
+// hash and size
+func statsTestChunk(filePath, fileHash string, size, index int) chromem.Document {
+	doc := testDocument(filePath, fileHash, "chunk "+strconv.Itoa(index)+" of "+filePath, 8)
+	doc.ID = fileHash + "_" + strconv.Itoa(index)
+	doc.Metadata["chunk_index"] = strconv.Itoa(index)
+	doc.Metadata["is_chunk"] = "true"
+	doc.Metadata["file_size"] = strconv.Itoa(size)
+	doc.Metadata["token_count"] = "10"
+	return doc
+}
+
+func TestComputeStatsWarnsAboutChunksDisagreeingOnFileAttributes(t *testing.T) {
+	config := newTestConfig(t, 8)
+	writeTestDatabase(t, config.DBPath, []chromem.Document{
+		statsTestChunk("/docs/guide.md", "newhash", 300, 0),
+		statsTestChunk("/docs/guide.md", "newhash", 300, 1),
+		// Left behind by an index of an older, shorter version of the file
+		statsTestChunk("/docs/guide.md", "aaoldhash", 120, 2),
+		statsTestChunk("/docs/faq.md", "faqhash", 50, 0),
+		statsTestChunk("/docs/faq.md", "faqhash", 50, 1),
+	})
+
+	stats, err := ComputeStats(config)
+	if err != nil {
+		t.Fatalf("unexpected stats error: %v", err)
+	}
+	if stats.TotalFileSize != 350 || stats.MaxFileSize != 300 {
+		t.Fatalf("expected file sizes from each file's first chunk, got total %d and max %d", stats.TotalFileSize, stats.MaxFileSize)
+	}
+	if len(stats.Warnings) != 2 {
+		t.Fatalf("expected warnings for the file hash and size of guide.md, got %q", stats.Warnings)
+	}
+	for _, want := range []string{`on file_hash ("newhash")`, `on file_size ("300")`} {
+		if !strings.Contains(strings.Join(stats.Warnings, "\n"), "1 of 3 chunks of /docs/guide.md disagree with chunk 0 "+want) {
+			t.Fatalf("expected a warning ending %q, got %q", want, stats.Warnings)
+		}
+	}
+
+	output := captureStdout(t, func() {
+		if err := ShowStats(config); err != nil {
+			t.Fatalf("unexpected stats error: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Warning: 1 of 3 chunks of /docs/guide.md") {
+		t.Fatalf("expected the warning to be printed, got:\n%s", output)
+	}
+}
+
+func TestListAndStatsReportMissingDatabase(t *testing.T) {
+	config := newTestConfig(t, 8)
+
+	for name, command := range map[string]func(Config) error{"list": ListDocuments, "stats": ShowStats} {
+		var err error
+		captureStdout(t, func() { err = command(config) })
+		if !errors.Is(err, ErrDatabaseNotFound) {
+			t.Fatalf("expected %s of a missing database to fail with ErrDatabaseNotFound, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package rag
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// taskItemPattern matches a task-list item such as "- [ ] Write docs" or "1. [x] Ship", capturing
+// the checkbox state and the task text
+var taskItemPattern = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+(.*\S)\s*$`)
+
+// TaskList counts the task-list items of a chunk of markdown
+type TaskList struct {
+	Open  int
+	Done  int
+	Texts []string // Text of every task, in order, with its checkbox: "[ ] Write docs" or "[x] Ship"
+}
+
+// ExtractTasks finds the markdown task-list items in content, skipping fenced code blocks
+func ExtractTasks(content string) TaskList {
+	var tasks TaskList
+	fences := FindFenceRegions(content)
+	pos := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		start := pos
+		pos += len(line)
+		if inFence(start, fences) {
+			continue
+		}
+		match := taskItemPattern.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if match == nil {
+			continue
+		}
+		if match[1] == " " {
+			tasks.Open++
+			tasks.Texts = append(tasks.Texts, "[ ] "+match[2])
+		} else {
+			tasks.Done++
+			tasks.Texts = append(tasks.Texts, "[x] "+match[2])
+		}
+	}
+	return tasks
+}
+
+// inFence reports whether offset falls inside one of the fenced code blocks
+func inFence(offset int, fences []FenceRegion) bool {
+	for _, fence := range fences {
+		if offset >= fence.Start && offset < fence.End {
+			return true
+		}
+	}
+	return false
+}
+
+// addTaskMetadata records the task-list items of content in a document's metadata when
+// config.ExtractTasks is set. Every document then carries open_tasks and done_tasks, so that
+// the open tasks filter can tell a chunk without tasks from one indexed without extraction.
+func addTaskMetadata(metadata map[string]string, content string, config Config) {
+	if !config.ExtractTasks {
+		return
+	}
+	tasks := ExtractTasks(content)
+	metadata["open_tasks"] = strconv.Itoa(tasks.Open)
+	metadata["done_tasks"] = strconv.Itoa(tasks.Done)
+	metadata["task_text"] = strings.Join(tasks.Texts, "; ")
+}
+
+// withTaskText appends the task-list items of content to the text embedded for it when
+// config.ExtractTasks is set, so that a chunk can be found by searching for its tasks as a list
+func withTaskText(text, content string, config Config) string {
+	if !config.ExtractTasks {
+		return text
+	}
+	tasks := ExtractTasks(content)
+	if len(tasks.Texts) == 0 {
+		return text
+	}
+	return text + "\n\nTasks: " + strings.Join(tasks.Texts, "; ")
+}
+
+// filterByOpenTasks keeps results containing at least one open task-list item when openOnly is set
+func filterByOpenTasks(results []chromem.Result, openOnly bool) []chromem.Result {
+	if !openOnly {
+		return results
+	}
+
+	filtered := results[:0]
+	for _, result := range results {
+		if open, err := strconv.Atoi(result.Metadata["open_tasks"]); err == nil && open > 0 {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
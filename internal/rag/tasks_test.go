@@ -0,0 +1,73 @@
+package rag
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExtractTasksCountsCheckboxStates(t *testing.T) {
+	content := "# Plan\n\n- [ ] Write docs\n- [x] Ship v1\n* [X] Tag release\n1. [ ] Announce\n- [] not a task\n\n```\n- [ ] example in code\n```\n"
+	tasks := ExtractTasks(content)
+	if tasks.Open != 2 || tasks.Done != 2 {
+		t.Fatalf("expected 2 open and 2 done tasks, got %d and %d", tasks.Open, tasks.Done)
+	}
+	want := []string{"[ ] Write docs", "[x] Ship v1", "[x] Tag release", "[ ] Announce"}
+	if !reflect.DeepEqual(tasks.Texts, want) {
+		t.Fatalf("got task texts %q, want %q", tasks.Texts, want)
+	}
+}
+
+func TestIndexDocumentsOpenTasksFilter(t *testing.T) {
+	config := newTestConfig(t, 8)
+	config.ExtractTasks = true
+	config.LogLevel = LogQuiet
+	docsDir := writeTestFiles(t, map[string]string{
+		"todo.md":    "# Release\n\n- [x] Write changelog\n- [ ] Publish release notes\n",
+		"done.md":    "# Release\n\n- [x] Write changelog\n- [x] Publish release notes\n",
+		"notes.md":   "# Release\n\nRelease notes are published with the changelog.\n",
+		"nested.md":  "# Release\n\n```\n- [ ] Publish release notes\n```\n",
+		"another.md": "# Other\n\n* [ ] Review release checklist\n",
+	})
+	captureStdout(t, func() {
+		if err := IndexDocuments(docsDir, config, 200, 15, 0.25); err != nil {
+			t.Fatalf("unexpected indexing error: %v", err)
+		}
+	})
+
+	for _, doc := range readTestDatabase(t, config) {
+		if filepath.Base(doc.Metadata["file_path"]) == "todo.md" {
+			if doc.Metadata["open_tasks"] != "1" || doc.Metadata["done_tasks"] != "1" || doc.Metadata["task_text"] != "[x] Write changelog; [ ] Publish release notes" {
+				t.Fatalf("unexpected task metadata for todo.md: %v", doc.Metadata)
+			}
+		}
+	}
+
+	config.OpenTasksFilter = true
+	results, err := MCPSearchDocumentsWithResults("publish release notes", config, 10)
+	if err != nil {
+		t.Fatalf("unexpected search error: %v", err)
+	}
+	var files []string
+	for _, result := range results {
+		files = append(files, filepath.Base(result.FilePath))
+	}
+	sort.Strings(files)
+	if !reflect.DeepEqual(files, []string{"another.md", "todo.md"}) {
+		t.Fatalf("expected only the files with open tasks, got %v", files)
+	}
+}
+
+func TestChunkEmbeddingTextIncludesTasks(t *testing.T) {
+	chunk := DocumentChunk{Content: "## Release\n\n- [ ] Write docs\n- [x] Ship it\n", FilePath: "/docs/release.md"}
+
+	if text := chunkEmbeddingText(chunk, Config{}); strings.Contains(text, "Tasks:") {
+		t.Fatalf("expected no task list without -extract-tasks, got %q", text)
+	}
+	text := chunkEmbeddingText(chunk, Config{ExtractTasks: true})
+	if !strings.HasSuffix(text, "\n\nTasks: [ ] Write docs; [x] Ship it") {
+		t.Fatalf("expected the tasks appended to the embedded text, got %q", text)
+	}
+}
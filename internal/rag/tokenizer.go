@@ -0,0 +1,140 @@
+package rag
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenCounter counts how many tokens a text takes up in the embedding model
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// HeuristicTokenCounter estimates tokens as a fixed fraction of the byte length
+type HeuristicTokenCounter struct {
+	TokensPerChar float64
+}
+
+// CountTokens implements TokenCounter
+func (c HeuristicTokenCounter) CountTokens(text string) int {
+	return EstimateTokenCount(text, c.TokensPerChar)
+}
+
+// PretokenizerTokenCounter estimates token counts by splitting text the way tiktoken's cl100k
+// pre-tokenizer does (words with their leading space, digit groups of up to three, punctuation
+// runs, and whitespace runs) and charging each piece a typical token cost. It has no vocabulary
+// or merges, so it is still an estimate, but unlike the heuristic it counts each CJK character
+// as a token and punctuation-heavy code as more than its bytes suggest.
+type PretokenizerTokenCounter struct{}
+
+// CountTokens implements TokenCounter
+func (PretokenizerTokenCounter) CountTokens(text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case isCJK(r):
+			// Common CJK characters are a single token each
+			tokens++
+			i += size
+
+		case unicode.IsLetter(r):
+			j, nonASCII := i, 0
+			for j < len(text) {
+				r, size := utf8.DecodeRuneInString(text[j:])
+				if !unicode.IsLetter(r) || isCJK(r) {
+					break
+				}
+				if r >= utf8.RuneSelf {
+					nonASCII++
+				}
+				j += size
+			}
+			tokens += wordTokens(j-i-nonASCII, nonASCII)
+			i = j
+
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			if j == i {
+				j += size
+			}
+			tokens += (j - i + 2) / 3
+			i = j
+
+		case r == ' ':
+			// A single space is merged into the following word or punctuation; longer runs are their own token
+			j := i
+			for j < len(text) && text[j] == ' ' {
+				j++
+			}
+			if j-i > 1 || j == len(text) || !mergesLeadingSpace(text[j:]) {
+				tokens++
+			}
+			i = j
+
+		case unicode.IsSpace(r):
+			j := i
+			for j < len(text) {
+				r, size := utf8.DecodeRuneInString(text[j:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				j += size
+			}
+			tokens++
+			i = j
+
+		default:
+			// Punctuation and symbols merge in pairs such as "()" or "==" on average, taking any
+			// newlines that follow them along, as in "{\n"
+			j := i
+			for j < len(text) {
+				r, size := utf8.DecodeRuneInString(text[j:])
+				if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+					break
+				}
+				j += size
+			}
+			tokens += (utf8.RuneCountInString(text[i:j]) + 1) / 2
+			for j < len(text) && (text[j] == '\n' || text[j] == '\r') {
+				j++
+			}
+			i = j
+		}
+	}
+	return tokens
+}
+
+// wordTokens estimates the tokens in a run of letters: common words up to ten ASCII letters
+// are one token, longer words add one per five letters, and non-ASCII letters cost half a token each
+func wordTokens(ascii, nonASCII int) int {
+	tokens := 1
+	if ascii > 10 {
+		tokens += (ascii - 10 + 4) / 5
+	}
+	return tokens + nonASCII/2
+}
+
+// mergesLeadingSpace reports whether text starts with a word or punctuation that absorbs a
+// preceding space; digit groups never do, so the space in "2 + 2" is a token of its own
+func mergesLeadingSpace(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return !unicode.IsSpace(r) && !unicode.IsDigit(r) && !isCJK(r)
+}
+
+// isCJK reports whether r is a Chinese, Japanese, or Korean character
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// NewTokenCounter returns the pre-tokenizer estimator when config.AccurateTokens is set,
+// otherwise the approxTokensPerChar heuristic
+func NewTokenCounter(config Config, approxTokensPerChar float64) TokenCounter {
+	if config.AccurateTokens {
+		return PretokenizerTokenCounter{}
+	}
+	return HeuristicTokenCounter{TokensPerChar: approxTokensPerChar}
+}
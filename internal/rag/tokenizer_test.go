@@ -0,0 +1,70 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPretokenizerTokenCounterMatchesReferenceCounts(t *testing.T) {
+	// Reference counts from tiktoken's cl100k_base encoding
+	for _, tc := range []struct {
+		text string
+		want int
+	}{
+		{"Hello, world!", 4},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{"I love machine learning.", 5},
+	} {
+		got := PretokenizerTokenCounter{}.CountTokens(tc.text)
+		if diff := got - tc.want; diff*5 > tc.want || -diff*5 > tc.want {
+			t.Errorf("CountTokens(%q) = %d, want %d within 20%%", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestPretokenizerTokenCounterMatchesCodeAndCJKReferenceCounts(t *testing.T) {
+	// Reference counts from tiktoken's cl100k_base encoding. Code and CJK text are what the
+	// byte heuristic gets most wrong, so the estimate must be within 10% here.
+	for _, tc := range []struct {
+		text string
+		want int
+	}{
+		{"2 + 2 = 4", 7},
+		{"お誕生日おめでとう", 9},
+	} {
+		got := PretokenizerTokenCounter{}.CountTokens(tc.text)
+		if diff := got - tc.want; diff*10 > tc.want || -diff*10 > tc.want {
+			t.Errorf("CountTokens(%q) = %d, want %d within 10%%", tc.text, got, tc.want)
+		}
+	}
+}
+
+// maxChunkTokens returns the largest token count among the chunks according to counter
+func maxChunkTokens(chunks []DocumentChunk, counter TokenCounter) int {
+	largest := 0
+	for _, chunk := range chunks {
+		largest = max(largest, counter.CountTokens(chunk.Content))
+	}
+	return largest
+}
+
+func TestChunkDocumentAccurateTokensKeepsCJKChunksWithinLimit(t *testing.T) {
+	content := strings.Repeat("# 概要\n\n日本語のテキストです。", 400)
+	counter := PretokenizerTokenCounter{}
+
+	// The heuristic assumes four bytes per token, but each three-byte CJK character is a token
+	heuristic := ChunkDocument("cjk.md", content, "hash", 500, 15, 0, 0, HeuristicTokenCounter{TokensPerChar: 0.25})
+	if got := maxChunkTokens(heuristic, counter); got <= 500 {
+		t.Fatalf("expected heuristic chunks to exceed the limit for CJK text, largest has %d tokens", got)
+	}
+
+	accurate := ChunkDocument("cjk.md", content, "hash", 500, 15, 0, 0, counter)
+	if got := maxChunkTokens(accurate, counter); got > 500 {
+		t.Fatalf("expected accurate chunks within the limit, largest has %d tokens", got)
+	}
+	for _, chunk := range accurate {
+		if chunk.TokenCount != counter.CountTokens(chunk.Content) {
+			t.Fatalf("chunk %d records %d tokens, want the counter's count", chunk.ChunkIndex, chunk.TokenCount)
+		}
+	}
+}
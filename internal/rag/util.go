@@ -2,8 +2,10 @@ package rag
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // FormatBytes converts bytes to human readable format
@@ -43,3 +45,75 @@ func MinInt(a, b int) int {
 	}
 	return b
 }
+
+// SnapToRuneStart moves a byte offset backward until it lands on the start of a UTF-8 character
+func SnapToRuneStart(s string, pos int) int {
+	for pos > 0 && pos < len(s) && !utf8.RuneStart(s[pos]) {
+		pos--
+	}
+	return pos
+}
+
+// SnapToRuneEnd moves a byte offset forward until it lands just after a complete UTF-8 character
+func SnapToRuneEnd(s string, pos int) int {
+	for pos > 0 && pos < len(s) && !utf8.RuneStart(s[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// SnapToLines widens the byte range [start, end) of s to whole lines: start moves back to the
+// start of its line, and end moves forward past the newline ending its line unless it already
+// follows one. An empty range widens to the line it is on.
+func SnapToLines(s string, start, end int) (int, int) {
+	start = strings.LastIndexByte(s[:start], '\n') + 1
+	if end > start && s[end-1] == '\n' {
+		return start, end
+	}
+	if newline := strings.IndexByte(s[end:], '\n'); newline >= 0 {
+		return start, end + newline + 1
+	}
+	return start, len(s)
+}
+
+// LineRange converts the byte range [start, end) of content into 1-based inclusive line numbers
+func LineRange(content string, start, end int) (int, int) {
+	start = max(0, min(start, len(content)))
+	end = max(start, min(end, len(content)))
+
+	startLine := strings.Count(content[:start], "\n") + 1
+	endLine := startLine
+	if end > start {
+		// A range ending with a newline finishes on the line that newline terminates
+		endLine = strings.Count(content[:end-1], "\n") + 1
+	}
+	return startLine, endLine
+}
+
+// EditorURI formats a reference to a line range of filePath as an editor URI such as
+// file:///docs/guide.md#L240-L260
+func EditorURI(scheme, filePath string, startLine, endLine int) string {
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		filePath = absPath
+	}
+	filePath = filepath.ToSlash(filePath)
+	if !strings.HasPrefix(filePath, "/") {
+		filePath = "/" + filePath
+	}
+
+	anchor := fmt.Sprintf("#L%d", startLine)
+	if endLine > startLine {
+		anchor += fmt.Sprintf("-L%d", endLine)
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, filePath, anchor)
+}
+
+// previewText truncates content to at most maxLen bytes on a character boundary, adding "..." if
+// anything was cut, and collapses line breaks so the preview fits on one line
+func previewText(content string, maxLen int) string {
+	if len(content) > maxLen {
+		content = content[:SnapToRuneStart(content, maxLen)] + "..."
+	}
+	content = strings.ReplaceAll(content, "\n", " ")
+	return strings.ReplaceAll(content, "\r", "")
+}
@@ -0,0 +1,29 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+)
+
+// CosineSimilarity returns the cosine of the angle between a and b, in [-1, 1]. The vectors must
+// have the same length; if either is all zeros the similarity is 0.
+func CosineSimilarity(a, b []float32) (float32, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("vectors have different lengths: %d and %d", len(a), len(b))
+	}
+
+	// Accumulate in float64 so long embeddings don't lose precision
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0, nil
+	}
+
+	similarity := dot / (math.Sqrt(normA) * math.Sqrt(normB))
+	// Rounding can push parallel vectors just past the valid range
+	return float32(max(-1, min(1, similarity))), nil
+}
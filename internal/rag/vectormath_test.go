@@ -0,0 +1,55 @@
+package rag
+
+import (
+	"math"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b []float32
+		want float32
+	}{
+		{name: "identical", a: []float32{1, 2, 3}, b: []float32{1, 2, 3}, want: 1},
+		{name: "scaled", a: []float32{1, 2, 3}, b: []float32{2, 4, 6}, want: 1},
+		{name: "orthogonal", a: []float32{1, 0}, b: []float32{0, 1}, want: 0},
+		{name: "opposite", a: []float32{1, -1}, b: []float32{-1, 1}, want: -1},
+		{name: "zero vector", a: []float32{0, 0}, b: []float32{1, 1}, want: 0},
+		{name: "empty", a: []float32{}, b: []float32{}, want: 0},
+	} {
+		got, err := CosineSimilarity(tc.a, tc.b)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if math.Abs(float64(got-tc.want)) > 1e-6 {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCosineSimilarityRejectsMismatchedLengths(t *testing.T) {
+	if _, err := CosineSimilarity([]float32{1, 2}, []float32{1, 2, 3}); err == nil {
+		t.Fatalf("expected an error for vectors of different lengths")
+	}
+}
+
+func TestCosineSimilarityMatchesCollectionScores(t *testing.T) {
+	config := newTestConfig(t, 16)
+	doc := testDocument("/docs/guide.md", "aaaa1111", "oauth provider setup", 16)
+	collection := newTestCollection(t, config, []chromem.Document{doc})
+
+	queried, err := collection.Query(t.Context(), "provider setup", 1, nil, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	got, err := CosineSimilarity(testEmbedding("provider setup", 16), doc.Embedding)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(float64(got-queried[0].Similarity)) > 1e-5 {
+		t.Fatalf("got %v, chromem scored %v", got, queried[0].Similarity)
+	}
+}
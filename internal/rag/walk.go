@@ -0,0 +1,190 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// walkFilter decides which paths a walk descends into and collects, applying hidden-path handling,
+// .gitignore files, the include/exclude globs, and the indexed extensions. It is safe for concurrent use.
+type walkFilter struct {
+	absRootPath string
+	config      Config
+	ignores     *gitignoreMatcher
+}
+
+func newWalkFilter(absRootPath string, config Config) *walkFilter {
+	filter := &walkFilter{absRootPath: absRootPath, config: config}
+	if config.RespectGitignore {
+		filter.ignores = newGitignoreMatcher()
+	}
+	return filter
+}
+
+// relPath returns path relative to the walk root with slash separators ("" for the root)
+func (f *walkFilter) relPath(path string) string {
+	relPath, err := filepath.Rel(f.absRootPath, path)
+	if err != nil || relPath == "." {
+		return ""
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// skip reports whether path is hidden or excluded by a glob or .gitignore; skipped directories are
+// not descended into. The walk root itself is never skipped.
+func (f *walkFilter) skip(path string, isDir bool) bool {
+	relPath := f.relPath(path)
+	if relPath == "" {
+		return false
+	}
+	if !f.config.IncludeHidden && strings.HasPrefix(filepath.Base(path), ".") {
+		return true
+	}
+	return !shouldIndexPath(relPath, isDir, f.config) ||
+		(f.ignores != nil && f.ignores.ignored(relPath, isDir))
+}
+
+// enterDir loads the directory's .gitignore, whose rules apply to everything below it. It must be
+// called before any of the directory's entries are checked with skip.
+func (f *walkFilter) enterDir(path string) {
+	if f.ignores == nil {
+		return
+	}
+	if err := f.ignores.loadDir(path, f.relPath(path)); err != nil {
+		progressReporter(f.config).warnf(path, err, "Warning: Could not read .gitignore in %s: %v", path, err)
+	}
+}
+
+// collect reports whether a file that was not skipped should be indexed
+func (f *walkFilter) collect(path string) bool {
+	return hasIndexedExtension(path, f.config.Extensions) || isSourceFile(path, f.config)
+}
+
+// findMarkdownFiles walks absRootPath and returns the absolute paths of the markdown files to
+// index, applying .gitignore files and the configured include and exclude globs. Directories are
+// read by config.WalkConcurrency workers when it is above 1; the result is in walk order either way.
+func findMarkdownFiles(absRootPath string, config Config) ([]string, error) {
+	filter := newWalkFilter(absRootPath, config)
+	if config.WalkConcurrency > 1 {
+		return walkConcurrent(absRootPath, filter, config.WalkConcurrency)
+	}
+
+	var mdFiles []string
+	err := filepath.Walk(absRootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Apply .gitignore files and include/exclude globs before anything is read
+		if filter.skip(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			filter.enterDir(path)
+		} else if filter.collect(path) {
+			mdFiles = append(mdFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+	return mdFiles, nil
+}
+
+// walkConcurrent reads directories with a fixed pool of workers sharing a queue of pending
+// directories, so goroutines stay bounded however deep or wide the tree is. The collected
+// files are sorted into the order filepath.Walk would produce.
+func walkConcurrent(absRootPath string, filter *walkFilter, workers int) ([]string, error) {
+	var (
+		mu       sync.Mutex
+		cond     = sync.NewCond(&mu)
+		queue    = []string{absRootPath}
+		inFlight = 0 // Directories taken off the queue but not yet fully read
+		mdFiles  []string
+		firstErr error
+	)
+
+	filter.enterDir(absRootPath)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				for len(queue) == 0 && inFlight > 0 && firstErr == nil {
+					cond.Wait()
+				}
+				if len(queue) == 0 || firstErr != nil {
+					// Nothing queued and nothing in flight that could queue more: the walk is done
+					mu.Unlock()
+					cond.Broadcast()
+					return
+				}
+				dir := queue[len(queue)-1]
+				queue = queue[:len(queue)-1]
+				inFlight++
+				mu.Unlock()
+
+				subdirs, files, err := readWalkDir(dir, filter)
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				queue = append(queue, subdirs...)
+				mdFiles = append(mdFiles, files...)
+				inFlight--
+				mu.Unlock()
+				cond.Broadcast()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", firstErr)
+	}
+
+	slices.SortFunc(mdFiles, compareWalkOrder)
+	return mdFiles, nil
+}
+
+// readWalkDir reads one directory, returning the subdirectories to descend into (with their
+// .gitignore files already loaded) and the files to index
+func readWalkDir(dir string, filter *walkFilter) (subdirs, files []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if filter.skip(path, entry.IsDir()) {
+			continue
+		}
+		if entry.IsDir() {
+			filter.enterDir(path)
+			subdirs = append(subdirs, path)
+		} else if filter.collect(path) {
+			files = append(files, path)
+		}
+	}
+	return subdirs, files, nil
+}
+
+// compareWalkOrder orders paths the way filepath.Walk visits them: component by component, so
+// "a/z.md" sorts before "a.md" just as the directory "a" sorts before the file "a.md"
+func compareWalkOrder(a, b string) int {
+	return slices.Compare(strings.Split(a, string(filepath.Separator)), strings.Split(b, string(filepath.Separator)))
+}
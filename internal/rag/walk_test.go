@@ -0,0 +1,93 @@
+package rag
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeDeepTestTree writes a tree that is depth directories deep and width directories wide at
+// each level, with a markdown file, an ignored file, and a non-markdown file in every directory
+func writeDeepTestTree(t testing.TB, depth, width int) string {
+	t.Helper()
+
+	files := map[string]string{".gitignore": "*.draft.md\n"}
+	var addLevel func(dir string, level int)
+	addLevel = func(dir string, level int) {
+		files[path.Join(dir, "doc.md")] = "content"
+		files[path.Join(dir, "wip.draft.md")] = "draft"
+		files[path.Join(dir, "notes.txt")] = "text"
+		if level == depth {
+			return
+		}
+		for i := 0; i < width; i++ {
+			addLevel(path.Join(dir, fmt.Sprintf("d%d", i)), level+1)
+		}
+	}
+	addLevel("", 0)
+	return writeTestFiles(t, files)
+}
+
+func TestFindMarkdownFilesConcurrentMatchesSequential(t *testing.T) {
+	root := writeDeepTestTree(t, 5, 3)
+	config := Config{RespectGitignore: true}
+
+	sequential, err := findMarkdownFiles(root, config)
+	if err != nil {
+		t.Fatalf("unexpected walk error: %v", err)
+	}
+	if len(sequential) != 1+3+9+27+81+243 {
+		t.Fatalf("unexpected file count: %d", len(sequential))
+	}
+
+	config.WalkConcurrency = 8
+	concurrent, err := findMarkdownFiles(root, config)
+	if err != nil {
+		t.Fatalf("unexpected concurrent walk error: %v", err)
+	}
+	if !reflect.DeepEqual(concurrent, sequential) {
+		t.Fatalf("concurrent walk returned different files or order than the sequential walk")
+	}
+}
+
+func BenchmarkFindMarkdownFiles(b *testing.B) {
+	root := writeDeepTestTree(b, 6, 3)
+
+	for _, workers := range []int{0, 8} {
+		config := Config{RespectGitignore: true, WalkConcurrency: workers}
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := findMarkdownFiles(root, config); err != nil {
+					b.Fatalf("unexpected walk error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestFindMarkdownFilesSkipsHiddenByDefault(t *testing.T) {
+	root := writeTestFiles(t, map[string]string{
+		"README.md":                        "readme",
+		".hidden/notes.md":                 "hidden directory",
+		".github/PULL_REQUEST_TEMPLATE.md": "template",
+		"docs/.draft.md":                   "hidden file",
+	})
+
+	files, err := findMarkdownFiles(root, Config{})
+	if err != nil {
+		t.Fatalf("unexpected walk error: %v", err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(root, "README.md") {
+		t.Fatalf("expected only README.md by default, got %v", files)
+	}
+
+	files, err = findMarkdownFiles(root, Config{IncludeHidden: true})
+	if err != nil {
+		t.Fatalf("unexpected walk error: %v", err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("expected hidden files to be included when enabled, got %v", files)
+	}
+}
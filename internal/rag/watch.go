@@ -0,0 +1,306 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/philippgille/chromem-go"
+)
+
+// watcher keeps a collection in sync with the files under a directory tree. Events only mark
+// paths as pending; each pending path is resolved against the file system when the batch is
+// synced, so an editor's atomic save (write a temporary file, then rename it over the original)
+// is seen as a single modification of the original path rather than a delete and a create.
+type watcher struct {
+	root       string
+	fsWatcher  *fsnotify.Watcher
+	filter     *walkFilter
+	collection *chromem.Collection
+	cache      *EmbeddingCache
+	dimension  *embeddingDimension
+	config     Config
+	counter    TokenCounter
+
+	maxTokensPerChunk   int
+	chunkOverlapPercent int
+
+	dirs    map[string]struct{} // Directories being watched
+	pending map[string]struct{} // Files changed since the last sync
+}
+
+// WatchDocuments keeps the database for rootPath current until SIGINT or SIGTERM. It expects
+// rootPath to have just been indexed, re-embeds files as they are created or modified, removes
+// deleted ones, and saves the database once changes have been quiet for config.WatchDebounce.
+func WatchDocuments(rootPath string, config Config, maxTokensPerChunk, chunkOverlapPercent int, approxTokensPerChar float64) error {
+	absRootPath, err := filepath.Abs(rootPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for %s: %w", rootPath, err)
+	}
+
+	// Load the database written by the initial index run
+	db, file, err := openDatabase(config)
+	if err != nil {
+		return err
+	}
+
+	// Re-indexed files must keep to the vector length already stored
+	metadata, err := readCollectionMetadata(file)
+	dimension := &embeddingDimension{}
+	if err == nil {
+		dimension.size, err = storedEmbeddingDimension(file, metadata)
+	}
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	collection, err := db.GetOrCreateCollection("documents", nil, CreateEmbeddingFunc(config))
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	report := progressReporter(config)
+	config.Progress = report
+
+	var cache *EmbeddingCache
+	if !config.NoCache {
+		cache, err = LoadEmbeddingCache(config, config.CacheMaxEntries)
+		if err != nil {
+			report.warnf("", err, "Warning: Could not load embedding cache, starting fresh: %v", err)
+			cache = NewEmbeddingCache(config.DBPath+EmbeddingCacheSuffix, config.CacheMaxEntries)
+		}
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer fsWatcher.Close()
+
+	w := &watcher{
+		root:                absRootPath,
+		fsWatcher:           fsWatcher,
+		filter:              newWalkFilter(absRootPath, config),
+		collection:          collection,
+		cache:               cache,
+		dimension:           dimension,
+		config:              config,
+		counter:             NewTokenCounter(config, approxTokensPerChar),
+		maxTokensPerChunk:   maxTokensPerChunk,
+		chunkOverlapPercent: chunkOverlapPercent,
+		dirs:                make(map[string]struct{}),
+		pending:             make(map[string]struct{}),
+	}
+	if err := w.addDir(absRootPath, false); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report.infof("Watching %s for changes (Ctrl+C to stop)", absRootPath)
+
+	// The timer fires once events have been quiet for the debounce period
+	debounce := time.NewTimer(config.WatchDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			report.infof("Stopping watch...")
+			if len(w.pending) > 0 && w.sync() {
+				return w.save(db)
+			}
+			return nil
+		case event, ok := <-fsWatcher.Events:
+			if !ok {
+				return nil
+			}
+			if w.handleEvent(event) {
+				debounce.Reset(config.WatchDebounce)
+			}
+		case err, ok := <-fsWatcher.Errors:
+			if !ok {
+				return nil
+			}
+			report.warnf("", err, "Warning: File watcher error: %v", err)
+		case <-debounce.C:
+			if w.sync() {
+				if err := w.save(db); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// addDir watches dir and every directory below it that the walk filter does not skip. When
+// pendFiles is set, the files found are marked pending; a directory created or moved into the
+// tree may already contain files that produced no events of their own.
+func (w *watcher) addDir(dir string, pendFiles bool) error {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if w.filter.skip(path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			w.filter.enterDir(path)
+			if err := w.fsWatcher.Add(path); err != nil {
+				return err
+			}
+			w.dirs[path] = struct{}{}
+		} else if pendFiles && w.filter.collect(path) {
+			w.pending[path] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch directory %s: %w", dir, err)
+	}
+	return nil
+}
+
+// handleEvent records the path an event affects, returning whether anything became pending
+func (w *watcher) handleEvent(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+
+	// New directories need watches of their own before their files can be seen
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if w.filter.skip(event.Name, true) {
+				return false
+			}
+			if err := w.addDir(event.Name, true); err != nil {
+				progressReporter(w.config).warnf(event.Name, err, "Warning: %v", err)
+			}
+			return len(w.pending) > 0
+		}
+	}
+
+	// A directory removed or moved out of the tree takes its indexed files with it
+	if _, isDir := w.dirs[event.Name]; isDir && (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) {
+		prefix := event.Name + string(filepath.Separator)
+		for dir := range w.dirs {
+			if dir == event.Name || strings.HasPrefix(dir, prefix) {
+				delete(w.dirs, dir)
+			}
+		}
+		return w.pendIndexedUnder(event.Name)
+	}
+
+	if w.filter.skip(event.Name, false) || !w.filter.collect(event.Name) {
+		return false
+	}
+	w.pending[event.Name] = struct{}{}
+	return true
+}
+
+// pendIndexedUnder marks every indexed file below dir pending, returning whether there were any
+func (w *watcher) pendIndexedUnder(dir string) bool {
+	count := w.collection.Count()
+	if count == 0 {
+		return false
+	}
+
+	results, err := allDocuments(context.Background(), w.collection)
+	if err != nil {
+		progressReporter(w.config).warnf(dir, err, "Warning: Could not list documents under %s: %v", dir, err)
+		return false
+	}
+
+	prefix := dir + string(filepath.Separator)
+	found := false
+	for _, result := range results {
+		if filePath := resolveFilePath(result.Metadata["file_path"], w.config); strings.HasPrefix(filePath, prefix) {
+			w.pending[filePath] = struct{}{}
+			found = true
+		}
+	}
+	return found
+}
+
+// sync re-indexes pending files that exist and removes the documents of those that no longer
+// do, returning whether the collection changed
+func (w *watcher) sync() bool {
+	paths := make([]string, 0, len(w.pending))
+	for path := range w.pending {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	w.pending = make(map[string]struct{})
+
+	// Under -dedup-files, a change can make a file a copy of another or stop it being one, which
+	// rewrites the documents of every file sharing its content
+	var original map[string]string
+	var duplicates map[string][]string
+	if w.config.DedupFiles {
+		all, err := findMarkdownFiles(w.root, w.config)
+		if err != nil {
+			progressReporter(w.config).warnf("", err, "Warning: Could not list files under %s: %v", w.root, err)
+		}
+		original, duplicates = findDuplicateFiles(all)
+		links, err := duplicateLinks(w.collection, w.config)
+		if err != nil {
+			progressReporter(w.config).warnf("", err, "Warning: Could not read the stored duplicate_paths: %v", err)
+		}
+		for _, path := range duplicateGroupFiles(paths, all, original, duplicates, links) {
+			if !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+		slices.Sort(paths)
+	}
+
+	changed := false
+	for _, path := range paths {
+		// Drop the old chunks first; chunk IDs derive from the content hash, so a modified
+		// file's new chunks would not replace them
+		if err := deleteFileDocuments(w.collection, path, w.config); err != nil {
+			progressReporter(w.config).warnf(path, err, "Warning: Could not remove old chunks for %s: %v", path, err)
+			continue
+		}
+		changed = true
+
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			progressReporter(w.config).infof("✗ Removed: %s", path)
+			continue
+		}
+
+		if firstPath, ok := original[path]; ok {
+			progressReporter(w.config).skipf(path, nil, "  Same content as %s, listed in its duplicate_paths", firstPath)
+			continue
+		}
+
+		progressReporter(w.config)(ProgressEvent{Kind: ProgressFileStarted, FilePath: path, Message: fmt.Sprintf("Re-indexing: %s", path)})
+		indexFile(context.Background(), w.collection, path, duplicates[path], w.config, w.cache, w.dimension, w.maxTokensPerChunk, w.chunkOverlapPercent, w.counter)
+	}
+	return changed
+}
+
+// save writes the database and embedding cache after a batch of changes
+func (w *watcher) save(db *chromem.DB) error {
+	if err := w.cache.Save(); err != nil {
+		progressReporter(w.config).warnf("", err, "Warning: Could not save embedding cache: %v", err)
+	}
+	if err := saveDatabaseAtomic(db, w.config.DBPath); err != nil {
+		return err
+	}
+	progressReporter(w.config)(ProgressEvent{Kind: ProgressSaved, Message: fmt.Sprintf("✓ Saved %s (%d documents)", w.config.DBPath, w.collection.Count())})
+	return nil
+}
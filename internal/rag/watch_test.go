@@ -0,0 +1,101 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/philippgille/chromem-go"
+)
+
+// newTestWatcher returns a watcher over root without an fsnotify watcher attached
+func newTestWatcher(t *testing.T, root string, config Config, docs []chromem.Document) *watcher {
+	t.Helper()
+
+	return &watcher{
+		root:                root,
+		filter:              newWalkFilter(root, config),
+		collection:          newTestCollection(t, config, docs),
+		config:              config,
+		counter:             HeuristicTokenCounter{TokensPerChar: 0.25},
+		maxTokensPerChunk:   4000,
+		chunkOverlapPercent: 15,
+		dirs:                map[string]struct{}{root: {}},
+		pending:             make(map[string]struct{}),
+	}
+}
+
+func TestWatcherSyncReplacesModifiedAndRemovesDeletedFiles(t *testing.T) {
+	config := newTestConfig(t, 8)
+	root := writeTestFiles(t, map[string]string{"guide.md": "# Guide\n\nUpdated content."})
+	guidePath := filepath.Join(root, "guide.md")
+	deletedPath := filepath.Join(root, "deleted.md")
+
+	w := newTestWatcher(t, root, config, []chromem.Document{
+		testDocument(guidePath, "oldhash1", "# Guide\n\nOld content.", 8),
+		testDocument(deletedPath, "oldhash2", "gone", 8),
+	})
+
+	// An editor's atomic save renames the original away and creates it again
+	w.handleEvent(fsnotify.Event{Name: guidePath, Op: fsnotify.Rename})
+	w.handleEvent(fsnotify.Event{Name: guidePath + ".swp", Op: fsnotify.Create})
+	w.handleEvent(fsnotify.Event{Name: guidePath, Op: fsnotify.Create})
+	w.handleEvent(fsnotify.Event{Name: deletedPath, Op: fsnotify.Remove})
+	if len(w.pending) != 2 {
+		t.Fatalf("expected 2 pending files, got %v", w.pending)
+	}
+
+	if !w.sync() {
+		t.Fatalf("expected the collection to change")
+	}
+	if len(w.pending) != 0 {
+		t.Fatalf("expected pending files to be cleared, got %v", w.pending)
+	}
+
+	if w.collection.Count() != 1 {
+		t.Fatalf("expected only the re-indexed guide to remain, got %d documents", w.collection.Count())
+	}
+	if _, err := w.collection.GetByID(context.Background(), "oldhash1"); err == nil {
+		t.Fatalf("expected the guide's stale chunk to be replaced")
+	}
+	results, err := w.collection.Query(context.Background(), "guide", 1, nil, nil)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if results[0].Metadata["file_path"] != guidePath || results[0].Content != "# Guide\n\nUpdated content." {
+		t.Fatalf("unexpected document: %+v", results[0])
+	}
+}
+
+func TestWatcherRemovedDirectoryPendsItsFiles(t *testing.T) {
+	config := newTestConfig(t, 8)
+	root := t.TempDir()
+	notesDir := filepath.Join(root, "notes")
+	notePath := filepath.Join(notesDir, "a.md")
+
+	w := newTestWatcher(t, root, config, []chromem.Document{
+		testDocument(notePath, "hash1", "note", 8),
+		testDocument(filepath.Join(root, "notes-other.md"), "hash2", "other", 8),
+	})
+	w.dirs[notesDir] = struct{}{}
+
+	if !w.handleEvent(fsnotify.Event{Name: notesDir, Op: fsnotify.Remove}) {
+		t.Fatalf("expected the removed directory's files to become pending")
+	}
+	if _, ok := w.pending[notePath]; !ok || len(w.pending) != 1 {
+		t.Fatalf("expected only %s pending, got %v", notePath, w.pending)
+	}
+	if _, ok := w.dirs[notesDir]; ok {
+		t.Fatalf("expected the removed directory to be forgotten")
+	}
+
+	w.sync()
+	if w.collection.Count() != 1 {
+		t.Fatalf("expected the removed directory's documents to be deleted, got %d documents", w.collection.Count())
+	}
+	if _, err := os.Stat(notesDir); !os.IsNotExist(err) {
+		t.Fatalf("fixture directory should not exist")
+	}
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/UnitVectorY-Labs/mcp-markdown-rag/internal/rag"
 )
@@ -14,6 +21,7 @@ import (
 const (
 	DefaultOllamaURL      = "http://localhost:11434/api/embeddings"
 	DefaultEmbeddingModel = "nomic-embed-text"
+	DefaultEmbeddingMode  = "ollama"
 	DefaultDBPath         = "./rag.db"
 	ProjectName           = "mcp-markdown-rag"
 
@@ -22,6 +30,36 @@ const (
 	ChunkOverlapPercent = 15   // 15% overlap between chunks
 	MaxContextTokens    = 8000 // Context window limit for nomic-embed-text
 	ApproxTokensPerChar = 0.25 // Rough approximation: 4 chars per token
+
+	// Indexing configuration
+	DefaultExtensions    = ".md" // Comma-separated file extensions to index
+	DefaultMaxHeadings   = 10000 // Headings per file used before heading-dense files stop being scanned
+	DefaultFlushInterval = 100   // Files indexed between intermediate database saves
+
+	// Search configuration
+	DefaultHybridAlpha      = 0.5 // Equal weight for vector similarity and BM25 in hybrid search
+	DefaultMinDocsForSearch = 1   // Documents needed before rag_search runs queries
+
+	// Embedding configuration
+	DefaultConcurrency = 4 // Concurrent embedding requests
+
+	// Embedding cache configuration
+	DefaultCacheMaxEntries = 100000 // Least recently used embeddings beyond this are evicted
+
+	// Watch configuration
+	DefaultWatchDebounce = time.Second // Quiet period before changed files are re-indexed
+
+	// MCP configuration
+	DefaultMCPAddr = ":8080" // Listen address for the HTTP MCP transport
+
+	// Estimate configuration
+	DefaultEstimateEmbedTime = 200 * time.Millisecond // Assumed time per embedding call
+
+	// Exit codes, so scripts can tell failures apart
+	ExitFailure              = 1 // Any failure not listed below
+	ExitDatabaseNotFound     = 2 // The database does not exist yet
+	ExitEmbeddingUnavailable = 3 // The embedding API could not be reached or refused the request
+	ExitUsage                = 4 // Invalid flags, flag combinations, or config file
 )
 
 // Version is the application version, injected at build time via ldflags.
@@ -56,66 +94,374 @@ func buildVersionOutput(projectName, version string) string {
 	return fmt.Sprintf("%s version %s (%s, %s/%s)", projectName, normalized, runtime.Version(), runtime.GOOS, runtime.GOARCH)
 }
 
+// stringList is a flag value that collects every occurrence of a repeatable flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// exitCode returns the exit code for a command that failed with err
+func exitCode(err error) int {
+	var requestErr *rag.EmbeddingRequestError
+	var apiErr *rag.EmbeddingAPIError
+	switch {
+	case errors.Is(err, rag.ErrDatabaseNotFound):
+		return ExitDatabaseNotFound
+	case errors.As(err, &requestErr), errors.As(err, &apiErr):
+		return ExitEmbeddingUnavailable
+	default:
+		return ExitFailure
+	}
+}
+
+// fail logs the error of a failed command and exits with its exit code
+func fail(message string, err error) {
+	log.Printf("%s: %v", message, err)
+	os.Exit(exitCode(err))
+}
+
+// usagef logs invalid usage and exits with ExitUsage
+func usagef(format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(ExitUsage)
+}
+
 func main() {
+	// Report bad flags with ExitUsage rather than the flag package's exit code 2
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+
 	var indexPath = flag.String("index", "", "Path to folder to recursively index .md files")
+	var include, exclude stringList
+	flag.Var(&include, "include", "Glob of relative paths to index (repeatable)")
+	flag.Var(&exclude, "exclude", "Glob of relative paths to skip when indexing (repeatable, wins over -include)")
+	var estimate = flag.String("estimate", "", "Path to folder to estimate indexing cost for, without embedding")
+	var estimateEmbedTime = flag.Duration("estimate-embed-time", DefaultEstimateEmbedTime, "Assumed time per embedding call for -estimate")
+	var extensions = flag.String("extensions", DefaultExtensions, "Comma-separated file extensions to index (e.g. .md,.mdx,.markdown,.txt)")
+	var sourceExtensions = flag.String("source-extensions", "", "Comma-separated source file extensions whose comments are indexed as markdown (e.g. .go,.py)")
+	var walkConcurrency = flag.Int("walk-concurrency", 0, "Number of directories read concurrently when finding files (0 for a sequential walk)")
+	var skipHidden = flag.Bool("skip-hidden", true, "Skip files and directories whose names begin with \".\" when indexing")
+	var respectGitignore = flag.Bool("respect-gitignore", true, "Skip paths ignored by .gitignore files when indexing")
+	var indexTimeout = flag.Duration("index-timeout", 0, "Abort indexing after this long, saving partial progress (e.g. 10m; 0 for no limit)")
+	var since = flag.String("since", "", "Only index files modified within this duration (e.g. 24h) or since this timestamp (e.g. 2006-01-02)")
+	var flushInterval = flag.Int("flush-interval", DefaultFlushInterval, "Save the database every this many files while indexing (0 to save only at the end)")
+	var dryRun = flag.Bool("dry-run", false, "With -index, report the files and chunks that would be indexed without embedding or writing the database")
+	var quiet = flag.Bool("quiet", false, "Only print warnings, failed files, and the final summary while indexing")
+	var verbose = flag.Bool("verbose", false, "Also print debugging detail while indexing, such as where each chunk was split")
+	var watch = flag.Bool("watch", false, "After indexing, keep watching the -index folder and re-index files as they change")
+	var watchDebounce = flag.Duration("watch-debounce", DefaultWatchDebounce, "Quiet period after changes before -watch re-indexes and saves")
+	var slowLog = flag.Duration("slow-log", 0, "Log embeddings, queries, and database loads slower than this to stderr (e.g. 2s; 0 to disable)")
+	var offset = flag.Int("offset", 0, "Skip this many of the best -query results, to page through them")
 	var query = flag.String("query", "", "Query string to search for similar documents")
+	var hybrid = flag.Bool("hybrid", false, "Use hybrid BM25 + vector search")
+	var hybridAlpha = flag.Float64("hybrid-alpha", DefaultHybridAlpha, "Weight of vector similarity in hybrid search (0-1)")
+	var headingFilter = flag.String("heading-filter", "", "Only return chunks whose heading path contains this text")
+	var openTasks = flag.Bool("open-tasks", false, "Only return chunks containing open task-list items (needs -extract-tasks when indexing)")
+	var linkScheme = flag.String("link-scheme", "", "Print search results as editor URIs with this scheme (e.g. file)")
+	var minSimilarity = flag.Float64("min-similarity", 0, "Drop CLI search results scoring below this similarity")
+	var preferRegion = flag.String("prefer-region", "", "Boost chunks from this region of their document: beginning, middle, or end")
+	var recencyBoost = flag.Float64("recency-boost", 0, "Weight from 0 to 1 of the score decay applied to older documents when searching (0 for none)")
+	var recencyHalfLife = flag.Float64("recency-half-life", rag.DefaultRecencyHalfLifeDays, "Age in days at which -recency-boost halves a document's decay weight")
+	var minDocsForSearch = flag.Int("min-docs-for-search", DefaultMinDocsForSearch, "Documents the database must hold before rag_search runs queries")
+	var queryCacheSize = flag.Int("query-cache-size", rag.DefaultQueryCacheSize, "Query embeddings kept in memory for repeated searches (0 to disable)")
+	var mcpMinSimilarity = flag.Float64("mcp-min-similarity", 0, "Default minimum similarity for rag_search calls that omit min_similarity")
+	var chunkOrder = flag.String("chunk-order", rag.ChunkOrderPosition, "Order of chunks within a file in MCP search results: position or similarity")
+	var mixedEntries = flag.String("mixed-entries", rag.MixedEntriesChunks, "Entries kept when a file matches as both a whole file and chunks in MCP search results: chunks or file")
+	var rerank = flag.Bool("rerank", false, "Re-sort retrieved search results by BM25 keyword overlap with the query")
+	var noDedup = flag.Bool("no-dedup", false, "Keep chunks in MCP search results that mostly overlap a higher-ranked chunk of the same file")
+	var jsonOutput = flag.Bool("json", false, "Print search, list, and stats output as JSON")
+	var fields = flag.String("fields", "", "Comma-separated metadata keys shown in search and list output")
 	var list = flag.Bool("list", false, "List all documents in the database")
 	var stats = flag.Bool("stats", false, "Show statistics about the database contents")
+	var cluster = flag.Int("cluster", 0, "Group the indexed chunks into this many topic clusters by embedding and show them")
+	var pruneAfter = flag.Duration("prune-after", 0, "Only prune files that have been missing for longer than this duration (e.g. 72h)")
+	var merge = flag.String("merge", "", "Path to another database to merge into the database")
+	var exportText = flag.String("export-text", "", "Directory to write the indexed text of every file to as plain text files")
+	var deletePath = flag.String("delete", "", "Path of an indexed file to remove from the database")
+	var prune = flag.Bool("prune", false, "Remove documents whose source files no longer exist")
+	var headingMaxLevel = flag.Int("heading-max-level", 0, "Deepest heading level (1-6) included in chunk heading context (default: all levels)")
+	var maxHeadings = flag.Int("max-headings", DefaultMaxHeadings, "Maximum headings per file used for splitting and heading context (0 for no limit)")
+	var chunkIDs = flag.String("chunk-ids", rag.ChunkIDsFile, "Chunk ID scheme: file, content, or stable")
+	var extractTasks = flag.Bool("extract-tasks", false, "Record the markdown task-list items of each chunk in metadata when indexing")
+	var dedupFiles = flag.Bool("dedup-files", false, "Index files with identical content once, listing the copies in duplicate_paths")
+	var accurateTokens = flag.Bool("accurate-tokens", false, "Size chunks with a tokenizer-based token estimator instead of the character heuristic")
+	var warnChunksPerFile = flag.Int("warn-chunks-per-file", 0, "Warn when a file produces more than this many chunks (0 to disable)")
+	var noCache = flag.Bool("no-cache", false, "Disable the persistent embedding cache")
+	var cacheMaxEntries = flag.Int("cache-max-entries", DefaultCacheMaxEntries, "Maximum number of cached embeddings (0 for no limit)")
+	var cacheMaxAge = flag.Duration("cache-max-age", 0, "Evict cached embeddings unused for longer than this duration (0 for no limit)")
+	var clearCache = flag.Bool("clear-cache", false, "Delete the embedding cache")
 	var help = flag.Bool("help", false, "Show help")
+	var configPath = flag.String("config", "", "Path to a YAML or JSON config file (default: ./.mcp-rag.yaml if present)")
 	var dbPath = flag.String("db", "", "Path to database file (default: ./rag.db)")
+	var root = flag.String("root", "", "Store indexed file paths relative to this directory and resolve them against it")
 	var ollamaURL = flag.String("ollama-url", "", "Ollama API URL (default: http://localhost:11434/api/embeddings)")
 	var embeddingModel = flag.String("embedding-model", "", "Embedding model name (default: nomic-embed-text)")
+	var embeddingMode = flag.String("embedding-mode", "", "Embedding API mode: ollama or openai (default: ollama)")
+	var concurrency = flag.Int("concurrency", 0, "Number of concurrent embedding requests (default: 4)")
+	var check = flag.Bool("check", false, "Check that the embedding API is reachable and the model is available, then exit")
+	var embedPathWeight = flag.Int("embed-path-weight", 0, "Fold the file name into embedded text this many times (0 to disable)")
+	var embedHeadings = flag.Bool("embed-headings", false, "Prepend each chunk's heading path to the text embedded for it")
+	var normalizeText = flag.String("normalize-text", rag.NormalizeOff, "Normalize document and query text before embedding: off, whitespace, or markdown")
 	var mcpMode = flag.Bool("mcp", false, "Run as MCP server")
+	var mcpTransport = flag.String("mcp-transport", rag.MCPTransportStdio, "MCP server transport: stdio or http")
+	var mcpAddr = flag.String("mcp-addr", DefaultMCPAddr, "Listen address for the http MCP transport")
 	var version = flag.Bool("version", false, "Show version")
 
-	flag.Parse()
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(ExitUsage)
+	}
 
 	if *version {
 		fmt.Println(buildVersionOutput(ProjectName, Version))
 		return
 	}
 
-	config := rag.GetConfig(ollamaURL, embeddingModel, dbPath, DefaultOllamaURL, DefaultEmbeddingModel, DefaultDBPath)
+	config, err := rag.GetConfig(configPath, ollamaURL, embeddingModel, embeddingMode, dbPath, concurrency, DefaultOllamaURL, DefaultEmbeddingModel, DefaultEmbeddingMode, DefaultDBPath, DefaultConcurrency)
+	if err != nil {
+		usagef("Error loading configuration: %v", err)
+	}
+	config.Prune = *prune
+	config.PruneAfter = *pruneAfter
+	config.HeadingMaxLevel = *headingMaxLevel
+	config.MaxHeadings = *maxHeadings
+	config.ChunkIDs = *chunkIDs
+	config.ExtractTasks = *extractTasks
+	config.DedupFiles = *dedupFiles
+	config.AccurateTokens = *accurateTokens
+	config.WarnChunksPerFile = *warnChunksPerFile
+	config.NoCache = *noCache
+	config.CacheMaxEntries = *cacheMaxEntries
+	config.CacheMaxAge = *cacheMaxAge
+	config.EmbedPathWeight = *embedPathWeight
+	config.EmbedHeadings = *embedHeadings
+	config.NormalizeText = *normalizeText
+	config.Hybrid = *hybrid
+	config.HybridAlpha = *hybridAlpha
+	config.HeadingFilter = *headingFilter
+	config.OpenTasksFilter = *openTasks
+	config.LinkScheme = *linkScheme
+	config.JSON = *jsonOutput
+	config.Fields = rag.ParseFields(*fields)
+	config.ChunkOrder = *chunkOrder
+	config.MixedEntries = *mixedEntries
+	config.NoDedup = *noDedup
+	if *rerank {
+		config.ReRanker = rag.LexicalReRanker{}
+	}
+	config.MinSimilarity = *minSimilarity
+	config.MCPMinSimilarity = *mcpMinSimilarity
+	config.QueryCacheSize = *queryCacheSize
+	config.SearchOffset = *offset
+	config.MinDocsForSearch = *minDocsForSearch
+	config.PreferRegion = *preferRegion
+	config.RecencyBoost = *recencyBoost
+	config.RecencyHalfLife = *recencyHalfLife
+	config.MCPTransport = *mcpTransport
+	config.MCPAddr = *mcpAddr
+	config.Include = include
+	config.Exclude = exclude
+	config.Extensions = rag.ParseExtensions(*extensions)
+	config.SourceExtensions = rag.ParseExtensions(*sourceExtensions)
+	config.RespectGitignore = *respectGitignore
+	config.IncludeHidden = !*skipHidden
+	config.WalkConcurrency = *walkConcurrency
+	config.WatchDebounce = *watchDebounce
+	config.SlowLog = *slowLog
+	config.IndexTimeout = *indexTimeout
+	config.FlushInterval = *flushInterval
+	if *since != "" {
+		cutoff, err := rag.ParseSince(*since, time.Now())
+		if err != nil {
+			usagef("Invalid -since %q: %v", *since, err)
+		}
+		config.Since = cutoff
+	}
+	config.DryRun = *dryRun
+	if *root != "" {
+		absRoot, err := filepath.Abs(*root)
+		if err != nil {
+			usagef("Invalid -root %q: %v", *root, err)
+		}
+		config.Root = absRoot
+	}
+	switch {
+	case *quiet && *verbose:
+		usagef("-quiet cannot be combined with -verbose")
+	case *quiet:
+		config.LogLevel = rag.LogQuiet
+	case *verbose:
+		config.LogLevel = rag.LogVerbose
+	default:
+		config.LogLevel = rag.LogNormal
+	}
+
+	if config.ChunkOrder != rag.ChunkOrderPosition && config.ChunkOrder != rag.ChunkOrderSimilarity {
+		usagef("Invalid -chunk-order %q: must be %s or %s", config.ChunkOrder, rag.ChunkOrderPosition, rag.ChunkOrderSimilarity)
+	}
+	if config.MixedEntries != rag.MixedEntriesChunks && config.MixedEntries != rag.MixedEntriesFile {
+		usagef("Invalid -mixed-entries %q: must be %s or %s", config.MixedEntries, rag.MixedEntriesChunks, rag.MixedEntriesFile)
+	}
+	if config.PreferRegion != "" && !rag.IsValidRegion(config.PreferRegion) {
+		usagef("Invalid -prefer-region %q: must be %s, %s, or %s", config.PreferRegion, rag.RegionBeginning, rag.RegionMiddle, rag.RegionEnd)
+	}
+	if config.SearchOffset < 0 {
+		usagef("Invalid -offset %d: must not be negative", config.SearchOffset)
+	}
+	if config.RecencyBoost < 0 || config.RecencyBoost > 1 {
+		usagef("Invalid -recency-boost %v: must be between 0 and 1", config.RecencyBoost)
+	}
+	if config.HybridAlpha < 0 || config.HybridAlpha > 1 {
+		usagef("Invalid -hybrid-alpha %v: must be between 0 and 1", config.HybridAlpha)
+	}
+	if config.RecencyHalfLife <= 0 {
+		usagef("Invalid -recency-half-life %v: must be positive", config.RecencyHalfLife)
+	}
+	if config.EmbeddingMode != rag.EmbeddingModeOllama && config.EmbeddingMode != rag.EmbeddingModeOpenAI {
+		usagef("Invalid -embedding-mode %q: must be %s or %s", config.EmbeddingMode, rag.EmbeddingModeOllama, rag.EmbeddingModeOpenAI)
+	}
+	if !rag.IsValidChunkIDScheme(config.ChunkIDs) {
+		usagef("Invalid -chunk-ids %q: must be %s, %s, or %s", config.ChunkIDs, rag.ChunkIDsFile, rag.ChunkIDsContent, rag.ChunkIDsStable)
+	}
+	if !rag.IsValidNormalization(config.NormalizeText) {
+		usagef("Invalid -normalize-text %q: must be %s, %s, or %s", config.NormalizeText, rag.NormalizeOff, rag.NormalizeWhitespace, rag.NormalizeMarkdown)
+	}
+	if *watch && *indexPath == "" {
+		usagef("-watch requires -index")
+	}
+	if *dryRun && *indexPath == "" {
+		usagef("-dry-run requires -index")
+	}
+	if *since != "" && *indexPath == "" {
+		usagef("-since requires -index")
+	}
+	if *cacheMaxAge < 0 {
+		usagef("Invalid -cache-max-age %s: must not be negative", *cacheMaxAge)
+	}
+	if *cluster < 0 {
+		usagef("Invalid -cluster %d: must not be negative", *cluster)
+	}
+	if *dryRun && *watch {
+		usagef("-dry-run cannot be combined with -watch")
+	}
+	if config.MCPTransport != rag.MCPTransportStdio && config.MCPTransport != rag.MCPTransportHTTP {
+		usagef("Invalid -mcp-transport %q: must be %s or %s", config.MCPTransport, rag.MCPTransportStdio, rag.MCPTransportHTTP)
+	}
 
 	// MCP mode takes precedence
 	if *mcpMode {
-		err := rag.RunMCPServer(config)
+		err := rag.RunMCPServer(config, MaxTokensPerChunk, ChunkOverlapPercent, ApproxTokensPerChar)
 		if err != nil {
-			log.Fatalf("MCP Server error: %v", err)
+			fail("MCP Server error", err)
 		}
 		return
 	}
 
-	if *help || (*indexPath == "" && *query == "" && !*list && !*stats) {
+	if *help || (*indexPath == "" && *query == "" && !*list && !*stats && !*prune && *merge == "" && *estimate == "" && *deletePath == "" && *exportText == "" && !*check && *cluster == 0 && !*clearCache) {
 		rag.ShowHelp(MaxTokensPerChunk, ChunkOverlapPercent, MaxContextTokens)
 		return
 	}
 
+	if *check {
+		if err := rag.CheckEmbedding(config); err != nil {
+			fail("Embedding check failed", err)
+		}
+		return
+	}
+
+	if *estimate != "" {
+		err := rag.ShowEstimate(*estimate, config, *estimateEmbedTime, MaxTokensPerChunk, ChunkOverlapPercent, ApproxTokensPerChar)
+		if err != nil {
+			fail("Error estimating index cost", err)
+		}
+		return
+	}
+
+	// Clearing first lets -clear-cache -index rebuild the cache from scratch
+	if *clearCache {
+		if err := rag.ClearEmbeddingCache(config); err != nil {
+			fail("Error clearing embedding cache", err)
+		}
+	}
+
 	if *indexPath != "" {
-		err := rag.IndexDocuments(*indexPath, config, MaxTokensPerChunk, ChunkOverlapPercent, ApproxTokensPerChar)
+		// Ctrl+C or SIGTERM stops indexing like -index-timeout does, saving what was indexed so far
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := rag.IndexDocumentsContext(ctx, *indexPath, config, MaxTokensPerChunk, ChunkOverlapPercent, ApproxTokensPerChar)
+		stop()
 		if err != nil {
-			log.Fatalf("Error indexing documents: %v", err)
+			fail("Error indexing documents", err)
+		}
+	}
+
+	if *merge != "" {
+		err := rag.MergeDatabase(*merge, config)
+		if err != nil {
+			fail("Error merging databases", err)
+		}
+	}
+
+	if *exportText != "" {
+		err := rag.ExportText(*exportText, config)
+		if err != nil {
+			fail("Error exporting text", err)
+		}
+	}
+
+	if *deletePath != "" {
+		err := rag.DeleteDocuments(*deletePath, config)
+		if err != nil {
+			fail("Error deleting document", err)
+		}
+	}
+
+	// When indexing, pruning already happened as part of the index run
+	if *prune && *indexPath == "" {
+		err := rag.PruneDocuments(config)
+		if err != nil {
+			fail("Error pruning documents", err)
 		}
 	}
 
 	if *query != "" {
 		err := rag.SearchDocuments(*query, config)
 		if err != nil {
-			log.Fatalf("Error searching documents: %v", err)
+			fail("Error searching documents", err)
 		}
 	}
 
 	if *list {
 		err := rag.ListDocuments(config)
 		if err != nil {
-			log.Fatalf("Error listing documents: %v", err)
+			fail("Error listing documents", err)
 		}
 	}
 
 	if *stats {
 		err := rag.ShowStats(config)
 		if err != nil {
-			log.Fatalf("Error showing statistics: %v", err)
+			fail("Error showing statistics", err)
+		}
+	}
+
+	if *cluster > 0 {
+		err := rag.ShowClusters(*cluster, config)
+		if err != nil {
+			fail("Error clustering documents", err)
+		}
+	}
+
+	if *watch {
+		err := rag.WatchDocuments(*indexPath, config, MaxTokensPerChunk, ChunkOverlapPercent, ApproxTokensPerChar)
+		if err != nil {
+			fail("Error watching documents", err)
 		}
 	}
 }
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"runtime"
 	"testing"
+
+	"github.com/UnitVectorY-Labs/mcp-markdown-rag/internal/rag"
 )
 
 func TestBuildVersionOutputAddsVPrefixAndMetadata(t *testing.T) {
@@ -50,3 +54,20 @@ func TestBuildVersionOutputNoVPrefixForNonSemver(t *testing.T) {
 		t.Fatalf("unexpected version output: got %q, want %q", got, want)
 	}
 }
+
+func TestExitCodeDistinguishesFailures(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("failed to load: %w", rag.ErrDatabaseNotFound), ExitDatabaseNotFound},
+		{fmt.Errorf("couldn't create embedding of query: %w", &rag.EmbeddingRequestError{API: "Ollama", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}), ExitEmbeddingUnavailable},
+		{fmt.Errorf("embedding check failed: %w", &rag.EmbeddingAPIError{API: "Ollama API", StatusCode: 404}), ExitEmbeddingUnavailable},
+		{errors.New("failed to walk directory"), ExitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}